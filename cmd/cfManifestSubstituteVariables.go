@@ -0,0 +1,328 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/SAP/jenkins-library/pkg/log"
+	"github.com/SAP/jenkins-library/pkg/piperutils"
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+)
+
+// variables file used in case no variables files are provided
+const cfDefaultManifestVariablesFile = "manifest-variables.yml"
+
+var cfVariableReference = regexp.MustCompile(`\(\(([\w-]+)\)\)`)
+
+type cfManifestUtils struct {
+	fileExists func(string) (bool, error)
+	readFile   func(string) ([]byte, error)
+	writeFile  func(string, []byte, os.FileMode) error
+}
+
+// cfVariablesSubstitution keeps track of the references replaced and the references which could not be resolved
+type cfVariablesSubstitution struct {
+	variables  map[string]interface{}
+	replaced   int
+	unresolved map[string]bool
+}
+
+func cfManifestSubstituteVariables(config cfManifestSubstituteVariablesOptions) error {
+	utils := cfManifestUtils{
+		fileExists: piperutils.FileExists,
+		readFile:   ioutil.ReadFile,
+		writeFile:  ioutil.WriteFile,
+	}
+	return runCfManifestSubstituteVariables(config, utils, os.Stdout)
+}
+
+func runCfManifestSubstituteVariables(config cfManifestSubstituteVariablesOptions, utils cfManifestUtils, stdout io.Writer) error {
+
+	exists, err := utils.fileExists(config.ManifestFile)
+	if err != nil {
+		return errors.Wrapf(err, "Cannot check manifest file '%s'", config.ManifestFile)
+	}
+	if !exists {
+		log.Entry().Infof("Could not find YAML file at '%s'. Skipping variable substitution.", config.ManifestFile)
+		return nil
+	}
+
+	// the default variables file is optional, in contrast to variables files provided explicitly
+	if len(config.ManifestVariablesFiles) == 0 {
+		exists, err := utils.fileExists(cfDefaultManifestVariablesFile)
+		if err != nil {
+			return errors.Wrapf(err, "Cannot check manifest variables file '%s'", cfDefaultManifestVariablesFile)
+		}
+		if exists {
+			config.ManifestVariablesFiles = []string{cfDefaultManifestVariablesFile}
+		}
+	}
+
+	return substituteCfManifestVariables(config, utils, stdout)
+}
+
+// substituteCfManifestVariables replaces the variable references in the manifest with the values from all
+// variables files and the variables provided. All variables files need to exist.
+func substituteCfManifestVariables(config cfManifestSubstituteVariablesOptions, utils cfManifestUtils, stdout io.Writer) error {
+
+	variables, err := loadCfManifestVariables(config.ManifestVariablesFiles, config.ManifestVariables, utils)
+	if err != nil {
+		return err
+	}
+
+	content, err := utils.readFile(config.ManifestFile)
+	if err != nil {
+		return errors.Wrapf(err, "Cannot read manifest file '%s'", config.ManifestFile)
+	}
+	var manifest interface{}
+	if err := yaml.Unmarshal(content, &manifest); err != nil {
+		return errors.Wrapf(err, "Cannot parse manifest file '%s'", config.ManifestFile)
+	}
+
+	substitution := cfVariablesSubstitution{variables: variables, unresolved: map[string]bool{}}
+	substituted, err := substitution.substitute(manifest)
+	if err != nil {
+		return errors.Wrapf(err, "Cannot substitute variables in manifest file '%s'", config.ManifestFile)
+	}
+
+	if len(substitution.unresolved) > 0 {
+		unresolved := []string{}
+		for name := range substitution.unresolved {
+			unresolved = append(unresolved, name)
+		}
+		sort.Strings(unresolved)
+		if config.Strict {
+			return fmt.Errorf("Unresolved variable(s) in manifest file '%s': %s", config.ManifestFile, strings.Join(unresolved, ", "))
+		}
+		log.Entry().Warningf("No value found for variable(s) %s. The references in '%s' are left unresolved.", strings.Join(unresolved, ", "), config.ManifestFile)
+	}
+
+	outputFile := config.OutputManifestFile
+	if len(outputFile) == 0 {
+		outputFile = config.ManifestFile
+	}
+
+	if substitution.replaced == 0 {
+		log.Entry().Infof("No variables were found or could be replaced in '%s'. Skipping variable substitution.", config.ManifestFile)
+		if outputFile == config.ManifestFile || config.DryRun {
+			return nil
+		}
+		// subsequent steps rely on the output manifest, hence it is provided with the unchanged content
+		if err := utils.writeFile(outputFile, content, 0644); err != nil {
+			return errors.Wrapf(err, "Cannot write manifest file '%s'", outputFile)
+		}
+		log.Entry().Infof("Copied '%s' unchanged to '%s'.", config.ManifestFile, outputFile)
+		return nil
+	}
+
+	result, err := yaml.Marshal(substituted)
+	if err != nil {
+		return errors.Wrap(err, "Cannot serialize manifest")
+	}
+
+	if config.DryRun {
+		// the original manifest is serialized the same way in order to show only the substitutions
+		original, err := yaml.Marshal(manifest)
+		if err != nil {
+			return errors.Wrap(err, "Cannot serialize manifest")
+		}
+		fmt.Fprintf(stdout, "--- %s\n+++ %s\n", config.ManifestFile, outputFile)
+		for _, line := range cfManifestDiff(string(original), string(result)) {
+			fmt.Fprintln(stdout, line)
+		}
+		log.Entry().Infof("Dry run: replaced %d variable reference(s) in '%s', '%s' has not been written.", substitution.replaced, config.ManifestFile, outputFile)
+		return nil
+	}
+
+	if err := utils.writeFile(outputFile, result, 0644); err != nil {
+		return errors.Wrapf(err, "Cannot write manifest file '%s'", outputFile)
+	}
+	log.Entry().Infof("Replaced %d variable reference(s) in '%s' and wrote the result to '%s'.", substitution.replaced, config.ManifestFile, outputFile)
+	return nil
+}
+
+// loadCfManifestVariables merges the variables of all files and the variables provided.
+// Later definitions win over earlier ones, variables provided directly win over variables from files.
+func loadCfManifestVariables(files []string, variables []map[string]interface{}, utils cfManifestUtils) (map[string]interface{}, error) {
+	merged := map[string]interface{}{}
+
+	for _, file := range files {
+		exists, err := utils.fileExists(file)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Cannot check manifest variables file '%s'", file)
+		}
+		if !exists {
+			return nil, fmt.Errorf("Could not find manifest variables file '%s'. Make sure all files given as manifestVariablesFiles exist.", file)
+		}
+		content, err := utils.readFile(file)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Cannot read manifest variables file '%s'", file)
+		}
+		var data interface{}
+		if err := yaml.Unmarshal(content, &data); err != nil {
+			return nil, errors.Wrapf(err, "Cannot parse manifest variables file '%s'", file)
+		}
+
+		// besides a plain map of variables a list of maps is accepted
+		switch data := data.(type) {
+		case nil:
+		case map[string]interface{}:
+			for name, value := range data {
+				merged[name] = value
+			}
+		case []interface{}:
+			for _, entry := range data {
+				entry, ok := entry.(map[string]interface{})
+				if !ok {
+					return nil, fmt.Errorf("Invalid manifest variables file '%s': expected a map of variables or a list of such maps", file)
+				}
+				for name, value := range entry {
+					merged[name] = value
+				}
+			}
+		default:
+			return nil, fmt.Errorf("Invalid manifest variables file '%s': expected a map of variables or a list of such maps", file)
+		}
+		log.Entry().Infof("Loaded manifest variables file '%s'", file)
+	}
+
+	for _, entry := range variables {
+		for name, value := range entry {
+			merged[name] = value
+		}
+	}
+	return merged, nil
+}
+
+// substitute returns a copy of the node with all variable references replaced. A string consisting of
+// a single reference is replaced by the value itself, keeping its type.
+func (s *cfVariablesSubstitution) substitute(node interface{}) (interface{}, error) {
+	switch node := node.(type) {
+	case map[string]interface{}:
+		result := map[string]interface{}{}
+		for key, value := range node {
+			substituted, err := s.substitute(value)
+			if err != nil {
+				return nil, err
+			}
+			result[key] = substituted
+		}
+		return result, nil
+	case []interface{}:
+		result := []interface{}{}
+		for _, value := range node {
+			substituted, err := s.substitute(value)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, substituted)
+		}
+		return result, nil
+	case string:
+		return s.substituteString(node)
+	default:
+		return node, nil
+	}
+}
+
+func (s *cfVariablesSubstitution) substituteString(node string) (interface{}, error) {
+	if match := cfVariableReference.FindStringSubmatch(node); match != nil && match[0] == node {
+		value, ok := s.variables[match[1]]
+		if !ok {
+			s.unresolved[match[1]] = true
+			return node, nil
+		}
+		log.Entry().Debugf("Replacing '%s' with value of type %T", node, value)
+		s.replaced++
+		return value, nil
+	}
+
+	var err error
+	result := cfVariableReference.ReplaceAllStringFunc(node, func(reference string) string {
+		name := cfVariableReference.FindStringSubmatch(reference)[1]
+		value, ok := s.variables[name]
+		if !ok {
+			s.unresolved[name] = true
+			return reference
+		}
+		text, ok := cfVariableText(value)
+		if !ok {
+			if err == nil {
+				err = fmt.Errorf("Variable '%s' of type %T cannot be embedded into the string '%s'", name, value, node)
+			}
+			return reference
+		}
+		s.replaced++
+		return text
+	})
+	return result, err
+}
+
+// cfVariableText provides the string representation of scalar values
+func cfVariableText(value interface{}) (string, bool) {
+	switch value := value.(type) {
+	case nil:
+		return "", true
+	case string:
+		return value, true
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64), true
+	case float32:
+		return strconv.FormatFloat(float64(value), 'f', -1, 32), true
+	}
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.Map, reflect.Slice, reflect.Array, reflect.Struct:
+		return "", false
+	}
+	return fmt.Sprint(value), true
+}
+
+// cfManifestDiff compares the manifests line by line. Unchanged lines are prefixed by blanks,
+// removed lines by '-' and added lines by '+'.
+func cfManifestDiff(original, substituted string) []string {
+	a := strings.Split(strings.TrimSuffix(original, "\n"), "\n")
+	b := strings.Split(strings.TrimSuffix(substituted, "\n"), "\n")
+
+	// common[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	common := make([][]int, len(a)+1)
+	for i := range common {
+		common[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else if common[i+1][j] >= common[i][j+1] {
+				common[i][j] = common[i+1][j]
+			} else {
+				common[i][j] = common[i][j+1]
+			}
+		}
+	}
+
+	diff := []string{}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			diff = append(diff, "  "+a[i])
+			i++
+			j++
+		case j == len(b) || (i < len(a) && common[i+1][j] >= common[i][j+1]):
+			diff = append(diff, "- "+a[i])
+			i++
+		default:
+			diff = append(diff, "+ "+b[j])
+			j++
+		}
+	}
+	return diff
+}
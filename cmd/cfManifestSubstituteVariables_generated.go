@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/SAP/jenkins-library/pkg/config"
+	"github.com/SAP/jenkins-library/pkg/log"
+
+	"github.com/spf13/cobra"
+)
+
+type cfManifestSubstituteVariablesOptions struct {
+	ManifestFile           string                   `json:"manifestFile,omitempty"`
+	OutputManifestFile     string                   `json:"outputManifestFile,omitempty"`
+	ManifestVariablesFiles []string                 `json:"manifestVariablesFiles,omitempty"`
+	ManifestVariables      []map[string]interface{} `json:"manifestVariables,omitempty"`
+	Strict                 bool                     `json:"strict,omitempty"`
+	DryRun                 bool                     `json:"dryRun,omitempty"`
+}
+
+var myCfManifestSubstituteVariablesOptions cfManifestSubstituteVariablesOptions
+
+// CfManifestSubstituteVariablesCommand Substitutes variables in a Cloud Foundry manifest
+func CfManifestSubstituteVariablesCommand() *cobra.Command {
+	metadata := cfManifestSubstituteVariablesMetadata()
+
+	var createCfManifestSubstituteVariablesCmd = &cobra.Command{
+		Use:   "cfManifestSubstituteVariables",
+		Short: "Substitutes variables in a Cloud Foundry manifest",
+		Long: `Substitutes variable references in a YAML file (e.g. a Cloud Foundry manifest) with the values specified in one or more
+variables files and via ` + "`" + `manifestVariables` + "`" + `. This follows the behavior of ` + "`" + `cf push --vars-file` + "`" + ` and ` + "`" + `cf push --var` + "`" + `, and can be
+used as a pre-deployment step if commands other than ` + "`" + `cf push` + "`" + ` are used for deployment (e.g. ` + "`" + `cf blue-green-deploy` + "`" + `).
+
+The format to reference a variable in the manifest is to use double parentheses ` + "`" + `((` + "`" + ` and ` + "`" + `))` + "`" + `, e.g. ` + "`" + `((variableName))` + "`" + `.
+In case a value consists of a single variable reference only, the reference is replaced by the value of the variable
+keeping its type, i.e. numbers, booleans, lists and maps can be inserted. References embedded into a string are replaced
+by the string representation of the variable value.
+
+Variables defined in files given later in ` + "`" + `manifestVariablesFiles` + "`" + ` win over variables defined in files given before.
+Variables given via ` + "`" + `manifestVariables` + "`" + ` always win over variables defined in files.`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			log.SetStepName("cfManifestSubstituteVariables")
+			log.SetVerbose(GeneralConfig.Verbose)
+			return PrepareConfig(cmd, &metadata, "cfManifestSubstituteVariables", &myCfManifestSubstituteVariablesOptions, config.OpenPiperFile)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			return cfManifestSubstituteVariables(myCfManifestSubstituteVariablesOptions)
+		},
+	}
+
+	addCfManifestSubstituteVariablesFlags(createCfManifestSubstituteVariablesCmd)
+	return createCfManifestSubstituteVariablesCmd
+}
+
+func addCfManifestSubstituteVariablesFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&myCfManifestSubstituteVariablesOptions.ManifestFile, "manifestFile", "manifest.yml", "Path of the YAML file to replace variables in.")
+	cmd.Flags().StringVar(&myCfManifestSubstituteVariablesOptions.OutputManifestFile, "outputManifestFile", os.Getenv("PIPER_outputManifestFile"), "Path of the YAML file to produce as output. In case it is not provided `manifestFile` is overwritten.")
+	cmd.Flags().StringSliceVar(&myCfManifestSubstituteVariablesOptions.ManifestVariablesFiles, "manifestVariablesFiles", []string{}, "Paths of the YAML files containing the variable values. In case of conflicting variables the values of the last file win. All files given explicitly must exist. In case no files are given, the file `manifest-variables.yml` is used if it exists.")
+	cmd.Flags().BoolVar(&myCfManifestSubstituteVariablesOptions.Strict, "strict", false, "When set to `true` the step fails in case variable references remain unresolved. Otherwise unresolved references are kept and reported as warning.")
+	cmd.Flags().BoolVar(&myCfManifestSubstituteVariablesOptions.DryRun, "dryRun", false, "When set to `true` the differences between the original and the substituted manifest are printed, but no file is written.")
+
+}
+
+// retrieve step metadata
+func cfManifestSubstituteVariablesMetadata() config.StepData {
+	var theMetaData = config.StepData{
+		Spec: config.StepSpec{
+			Inputs: config.StepInputs{
+				Parameters: []config.StepParameters{
+					{
+						Name:        "manifestFile",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "outputManifestFile",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "manifestVariablesFiles",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "[]string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "manifestVariables",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "[]map[string]interface{}",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "strict",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "bool",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "dryRun",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "bool",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
+				},
+			},
+		},
+	}
+	return theMetaData
+}
@@ -0,0 +1,16 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCfManifestSubstituteVariablesCommand(t *testing.T) {
+
+	testCmd := CfManifestSubstituteVariablesCommand()
+
+	// only high level testing performed - details are tested in step generation procudure
+	assert.Equal(t, "cfManifestSubstituteVariables", testCmd.Use, "command name incorrect")
+
+}
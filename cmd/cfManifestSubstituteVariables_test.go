@@ -0,0 +1,206 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCfManifestSubstituteVariables(t *testing.T) {
+
+	var files map[string]string
+	var writtenFiles map[string]string
+
+	utils := cfManifestUtils{
+		fileExists: func(path string) (bool, error) {
+			_, ok := files[path]
+			return ok, nil
+		},
+		readFile: func(path string) ([]byte, error) {
+			content, ok := files[path]
+			if !ok {
+				return nil, errors.New("file not found")
+			}
+			return []byte(content), nil
+		},
+		writeFile: func(path string, content []byte, perm os.FileMode) error {
+			writtenFiles[path] = string(content)
+			return nil
+		},
+	}
+
+	manifest := `applications:
+- name: ((appName))
+  instances: ((instances))
+  env:
+    URL: https://((host)).example.org/((path))
+  services: ((services))
+`
+
+	reset := func() {
+		files = map[string]string{"manifest.yml": manifest}
+		writtenFiles = map[string]string{}
+	}
+
+	defaultConfig := cfManifestSubstituteVariablesOptions{ManifestFile: "manifest.yml"}
+
+	t.Run("variables from default file", func(t *testing.T) {
+		reset()
+		files["manifest-variables.yml"] = "appName: myApp\ninstances: 2\nhost: myhost\npath: api\nservices:\n- db\n- xsuaa\n"
+
+		err := runCfManifestSubstituteVariables(defaultConfig, utils, &bytes.Buffer{})
+
+		if assert.NoError(t, err) {
+			assert.Equal(t, `applications:
+- env:
+    URL: https://myhost.example.org/api
+  instances: 2
+  name: myApp
+  services:
+  - db
+  - xsuaa
+`, writtenFiles["manifest.yml"])
+		}
+	})
+
+	t.Run("precedence of variables", func(t *testing.T) {
+		reset()
+		files["manifest-variables.yml"] = "appName: myApp\ninstances: 2\nhost: myhost\n"
+		files["dev-variables.yml"] = "- instances: 3\n- host: devhost\n"
+
+		config := defaultConfig
+		config.OutputManifestFile = "manifest-dev.yml"
+		config.ManifestVariablesFiles = []string{"manifest-variables.yml", "dev-variables.yml"}
+		config.ManifestVariables = []map[string]interface{}{
+			{"host": "otherhost", "path": "api"},
+			{"host": "myhost", "services": []interface{}{"db"}},
+		}
+
+		err := runCfManifestSubstituteVariables(config, utils, &bytes.Buffer{})
+
+		if assert.NoError(t, err) {
+			assert.Equal(t, `applications:
+- env:
+    URL: https://myhost.example.org/api
+  instances: 3
+  name: myApp
+  services:
+  - db
+`, writtenFiles["manifest-dev.yml"])
+			assert.NotContains(t, writtenFiles, "manifest.yml")
+		}
+	})
+
+	t.Run("unresolved variables", func(t *testing.T) {
+		reset()
+		config := defaultConfig
+		config.ManifestVariables = []map[string]interface{}{{"appName": "myApp", "instances": true}}
+
+		err := runCfManifestSubstituteVariables(config, utils, &bytes.Buffer{})
+
+		if assert.NoError(t, err) {
+			assert.Contains(t, writtenFiles["manifest.yml"], "instances: true\n")
+			assert.Contains(t, writtenFiles["manifest.yml"], "URL: https://((host)).example.org/((path))\n")
+			assert.Contains(t, writtenFiles["manifest.yml"], "services: ((services))\n")
+		}
+
+		config.Strict = true
+		writtenFiles = map[string]string{}
+		err = runCfManifestSubstituteVariables(config, utils, &bytes.Buffer{})
+
+		assert.EqualError(t, err, "Unresolved variable(s) in manifest file 'manifest.yml': host, path, services")
+		assert.Empty(t, writtenFiles)
+	})
+
+	t.Run("complex value embedded into string", func(t *testing.T) {
+		reset()
+		config := defaultConfig
+		config.ManifestVariables = []map[string]interface{}{{"host": []interface{}{"a", "b"}}}
+
+		err := runCfManifestSubstituteVariables(config, utils, &bytes.Buffer{})
+
+		assert.EqualError(t, err, "Cannot substitute variables in manifest file 'manifest.yml': Variable 'host' of type []interface {} cannot be embedded into the string 'https://((host)).example.org/((path))'")
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		reset()
+		config := defaultConfig
+		config.DryRun = true
+		config.ManifestVariables = []map[string]interface{}{{"appName": "myApp", "instances": 2.5}}
+		stdout := bytes.Buffer{}
+
+		err := runCfManifestSubstituteVariables(config, utils, &stdout)
+
+		if assert.NoError(t, err) {
+			assert.Empty(t, writtenFiles)
+			assert.Equal(t, `--- manifest.yml
++++ manifest.yml
+  applications:
+  - env:
+      URL: https://((host)).example.org/((path))
+-   instances: ((instances))
+-   name: ((appName))
++   instances: 2.5
++   name: myApp
+    services: ((services))
+`, stdout.String())
+		}
+	})
+
+	t.Run("nothing to substitute", func(t *testing.T) {
+		reset()
+
+		err := runCfManifestSubstituteVariables(defaultConfig, utils, &bytes.Buffer{})
+		assert.NoError(t, err)
+
+		delete(files, "manifest.yml")
+		err = runCfManifestSubstituteVariables(defaultConfig, utils, &bytes.Buffer{})
+		assert.NoError(t, err)
+
+		assert.Empty(t, writtenFiles)
+	})
+
+	t.Run("nothing to substitute, output manifest file", func(t *testing.T) {
+		reset()
+		files["manifest.yml"] = "applications:\n- name: myApp\n"
+		config := defaultConfig
+		config.OutputManifestFile = "manifest-out.yml"
+
+		err := runCfManifestSubstituteVariables(config, utils, &bytes.Buffer{})
+
+		if assert.NoError(t, err) {
+			assert.Equal(t, map[string]string{"manifest-out.yml": "applications:\n- name: myApp\n"}, writtenFiles)
+		}
+
+		reset()
+		files["manifest.yml"] = "applications:\n- name: myApp\n"
+		config.DryRun = true
+		err = runCfManifestSubstituteVariables(config, utils, &bytes.Buffer{})
+
+		if assert.NoError(t, err) {
+			assert.Empty(t, writtenFiles)
+		}
+	})
+
+	t.Run("invalid variables files", func(t *testing.T) {
+		reset()
+		config := defaultConfig
+		config.ManifestVariablesFiles = []string{"notExisting.yml"}
+
+		err := runCfManifestSubstituteVariables(config, utils, &bytes.Buffer{})
+		assert.EqualError(t, err, "Could not find manifest variables file 'notExisting.yml'. Make sure all files given as manifestVariablesFiles exist.")
+
+		files["invalid.yml"] = "- a\n- b\n"
+		config.ManifestVariablesFiles = []string{"invalid.yml"}
+		err = runCfManifestSubstituteVariables(config, utils, &bytes.Buffer{})
+		assert.EqualError(t, err, "Invalid manifest variables file 'invalid.yml': expected a map of variables or a list of such maps")
+	})
+}
+
+func TestCfManifestDiff(t *testing.T) {
+	assert.Equal(t, []string{"  a", "- b", "+ x", "  c", "+ d"}, cfManifestDiff("a\nb\nc\n", "a\nx\nc\nd\n"))
+	assert.Equal(t, []string{"  a"}, cfManifestDiff("a\n", "a\n"))
+}
@@ -0,0 +1,465 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/SAP/jenkins-library/pkg/command"
+	"github.com/SAP/jenkins-library/pkg/log"
+	"github.com/SAP/jenkins-library/pkg/piperutils"
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+)
+
+// file the cf client writes its trace into
+const cfTraceFile = "cf.log"
+
+// default smoke test script, created in case it is not available in the workspace
+const cfDefaultSmokeTestScript = "blueGreenCheckScript.sh"
+
+const cfDefaultSmokeTestScriptContent = `#!/usr/bin/env bash
+# this is simply testing if the application root returns HTTP STATUS_CODE
+curl -so /dev/null -w '%{response_code}' https://$1 | grep $STATUS_CODE
+`
+
+type cfDeployUtils struct {
+	fileExists func(string) (bool, error)
+	readFile   func(string) ([]byte, error)
+	writeFile  func(string, []byte, os.FileMode) error
+	chmod      func(string, os.FileMode) error
+	findMtars  func() ([]string, error)
+	now        func() time.Time
+}
+
+func cloudFoundryDeploy(config cloudFoundryDeployOptions, influx *cloudFoundryDeployInflux) error {
+	c := command.Command{}
+	utils := cfDeployUtils{
+		fileExists: piperutils.FileExists,
+		readFile:   ioutil.ReadFile,
+		writeFile:  ioutil.WriteFile,
+		chmod:      os.Chmod,
+		findMtars:  findMtars,
+		now:        time.Now,
+	}
+	return runCloudFoundryDeploy(&config, influx, &c, utils)
+}
+
+func runCloudFoundryDeploy(config *cloudFoundryDeployOptions, influx *cloudFoundryDeployInflux, s execRunner, utils cfDeployUtils) error {
+
+	log.Entry().Infof("General parameters: deployTool=%s, deployType=%s, cfApiEndpoint=%s, cfOrg=%s, cfSpace=%s",
+		config.DeployTool, config.DeployType, config.APIEndpoint, config.Org, config.Space)
+
+	if config.DeployType != "standard" && config.DeployType != "blue-green" {
+		return fmt.Errorf("Invalid deployType '%s'. Supported values: 'standard', 'blue-green'", config.DeployType)
+	}
+
+	var deployArgs []string
+	var err error
+	switch config.DeployTool {
+	case "mtaDeployPlugin":
+		deployArgs, err = prepareMtaDeployment(config, utils)
+	case "cf_native":
+		deployArgs, err = prepareCfNativeDeployment(config, utils)
+	default:
+		log.Entry().Warningf("Found unsupported deployTool '%s'. Skipping deployment.", config.DeployTool)
+		return nil
+	}
+
+	if err == nil {
+		err = cfDeploy(config, deployArgs, s, utils)
+	}
+
+	influx.deployment_data.fields.artifactURL = "n/a"
+	influx.deployment_data.fields.deployTime = utils.now().Format("Jan 02, 2006 - 15:04:05")
+	influx.deployment_data.tags.artifactVersion = config.ArtifactVersion
+	influx.deployment_data.tags.deployUser = config.Username
+	influx.deployment_data.tags.deployResult = "SUCCESS"
+	if err != nil {
+		influx.deployment_data.tags.deployResult = "FAILURE"
+	}
+	influx.deployment_data.tags.cfAPIEndpoint = config.APIEndpoint
+	influx.deployment_data.tags.cfOrg = config.Org
+	influx.deployment_data.tags.cfSpace = config.Space
+
+	return err
+}
+
+// cfDeploy performs the login, the deployment and the logout. The trace of the cf client is logged in case of failures.
+func cfDeploy(config *cloudFoundryDeployOptions, deployArgs []string, s execRunner, utils cfDeployUtils) error {
+
+	env := []string{"CF_TRACE=" + cfTraceFile}
+	if config.DeployTool == "cf_native" {
+		env = append(env, "STATUS_CODE="+config.SmokeTestStatusCode)
+	}
+	s.Env(env)
+
+	err := cfLogin(config, env, s)
+	if err == nil {
+		log.Entry().Infof("Performing deployment: cf %s", strings.Join(deployArgs, " "))
+		if err = s.RunExecutable("cf", deployArgs...); err != nil {
+			err = errors.Wrap(err, "The execution of the deploy command failed")
+		}
+
+		if err == nil && config.DeployTool == "cf_native" && config.DeployType == "blue-green" && config.KeepOldInstance {
+			err = stopOldAppIfRunning(config.AppName, s)
+		}
+
+		if logoutErr := s.RunExecutable("cf", "logout"); logoutErr != nil {
+			log.Entry().WithError(logoutErr).Warn("cf logout failed")
+		}
+	}
+
+	if err != nil || GeneralConfig.Verbose {
+		logCfTrace(utils)
+	}
+	return err
+}
+
+func cfLogin(config *cloudFoundryDeployOptions, env []string, s execRunner) error {
+
+	apiParams, err := command.ParseArgs(config.APIParameters)
+	if err != nil {
+		return errors.Wrap(err, "Cannot parse api parameters")
+	}
+	loginParams, err := command.ParseArgs(config.LoginParameters)
+	if err != nil {
+		return errors.Wrap(err, "Cannot parse login parameters")
+	}
+
+	if err := s.RunExecutable("cf", append([]string{"api", config.APIEndpoint}, apiParams...)...); err != nil {
+		return errors.Wrapf(err, "Cannot set cf api endpoint '%s'", config.APIEndpoint)
+	}
+
+	// The credentials are not provided on the command line in order to avoid exposing them in the process list.
+	// Without arguments cf auth reads the credentials from the environment.
+	s.Env(append(env, "CF_USERNAME="+config.Username, "CF_PASSWORD="+config.Password))
+	err = s.RunExecutable("cf", append([]string{"auth"}, loginParams...)...)
+	s.Env(env)
+	if err != nil {
+		return errors.Wrap(err, "cf login failed")
+	}
+
+	if err := s.RunExecutable("cf", "target", "-o", config.Org, "-s", config.Space); err != nil {
+		// the session exists already, hence we have to logout
+		s.RunExecutable("cf", "logout")
+		return errors.Wrapf(err, "Cannot target org '%s' and space '%s'", config.Org, config.Space)
+	}
+
+	log.Entry().Infof("cf login has been performed. api-endpoint: '%s', org: '%s', space: '%s'", config.APIEndpoint, config.Org, config.Space)
+	return nil
+}
+
+func prepareMtaDeployment(config *cloudFoundryDeployOptions, utils cfDeployUtils) ([]string, error) {
+
+	if len(config.MtaPath) == 0 {
+		mtars, err := utils.findMtars()
+		if err != nil {
+			return nil, errors.Wrap(err, "Cannot search for *.mtar files")
+		}
+		if len(mtars) > 1 {
+			return nil, fmt.Errorf("Found multiple *.mtar files, please specify file via mtaPath parameter! %v", mtars)
+		}
+		if len(mtars) == 0 {
+			return nil, errors.New("No *.mtar file found!")
+		}
+		config.MtaPath = mtars[0]
+	}
+
+	deployParams, err := command.ParseArgs(config.MtaDeployParameters)
+	if err != nil {
+		return nil, errors.Wrap(err, "Cannot parse mta deploy parameters")
+	}
+
+	deployCommand := "deploy"
+	if config.DeployType == "blue-green" {
+		deployCommand = "bg-deploy"
+		if !sliceContains(deployParams, "--no-confirm") {
+			deployParams = append(deployParams, "--no-confirm")
+		}
+	}
+
+	args := append([]string{deployCommand, config.MtaPath}, deployParams...)
+	if extension := strings.TrimSpace(strings.TrimPrefix(config.MtaExtensionDescriptor, "-e ")); len(extension) > 0 {
+		args = append(args, "-e", extension)
+	}
+
+	log.Entry().Infof("Deploying MTA (%s) with following parameters: %s", config.MtaPath, strings.Join(args[2:], " "))
+	return args, nil
+}
+
+func prepareCfNativeDeployment(config *cloudFoundryDeployOptions, utils cfDeployUtils) ([]string, error) {
+
+	deployParams, err := command.ParseArgs(config.CfNativeDeployParameters)
+	if err != nil {
+		return nil, errors.Wrap(err, "Cannot parse cf native deploy parameters")
+	}
+
+	varOptions, err := cfVarOptions(config.ManifestVariables)
+	if err != nil {
+		return nil, err
+	}
+	varFiles, err := existingCfVarFiles(config.ManifestVariablesFiles, utils)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Entry().Infof("CF native deployment (%s) with cfAppName=%s, cfManifest=%s, cfManifestVariables=%v, cfManifestVariablesFiles=%v, smokeTestScript=%s",
+		config.DeployType, config.AppName, config.Manifest, config.ManifestVariables, config.ManifestVariablesFiles, config.SmokeTestScript)
+
+	if config.DeployType == "blue-green" {
+		if len(config.AppName) == 0 {
+			return nil, errors.New("Blue-green plugin requires app name to be passed (see https://github.com/bluemixgaragelondon/cf-blue-green-deploy/issues/27)")
+		}
+		// the blue-green plugin does not support variables, hence they are substituted in the manifest upfront
+		if len(config.ManifestVariables) > 0 || len(varFiles) > 0 {
+			if err := substituteCfManifestVariables(cfManifestSubstituteVariablesOptions{
+				ManifestFile:           config.Manifest,
+				ManifestVariablesFiles: varFiles,
+				ManifestVariables:      cfManifestVariables(config.ManifestVariables),
+				Strict:                 true,
+			}, cfManifestUtils{fileExists: utils.fileExists, readFile: utils.readFile, writeFile: utils.writeFile}, ioutil.Discard); err != nil {
+				return nil, err
+			}
+		}
+		if err := handleLegacyCfManifest(config.Manifest, utils); err != nil {
+			return nil, err
+		}
+		smokeTest, err := prepareSmokeTest(config.SmokeTestScript, utils)
+		if err != nil {
+			return nil, err
+		}
+
+		args := []string{"blue-green-deploy", config.AppName}
+		if !config.KeepOldInstance {
+			args = append(args, "--delete-old-apps")
+		}
+		args = append(args, "-f", config.Manifest, "--smoke-test", smokeTest)
+		return append(args, deployParams...), nil
+	}
+
+	if len(config.AppName) == 0 {
+		if err := checkAppNameInManifest(config.Manifest, utils); err != nil {
+			return nil, err
+		}
+	}
+
+	args := []string{"push"}
+	if len(config.AppName) > 0 {
+		args = append(args, config.AppName)
+	}
+	args = append(args, varOptions...)
+	for _, file := range varFiles {
+		args = append(args, "--vars-file", file)
+	}
+	args = append(args, "-f", config.Manifest)
+	return append(args, deployParams...), nil
+}
+
+func cfVarOptions(manifestVariables []string) ([]string, error) {
+	options := []string{}
+	for _, variable := range manifestVariables {
+		if !strings.Contains(variable, "=") {
+			return nil, fmt.Errorf("Invalid manifest variable '%s'. Expected format: key=value", variable)
+		}
+		options = append(options, "--var", variable)
+	}
+	return options, nil
+}
+
+// cfManifestVariables converts variables in the form key=value for the substitution in the manifest
+func cfManifestVariables(manifestVariables []string) []map[string]interface{} {
+	variables := []map[string]interface{}{}
+	for _, variable := range manifestVariables {
+		parts := strings.SplitN(variable, "=", 2)
+		variables = append(variables, map[string]interface{}{parts[0]: parts[1]})
+	}
+	return variables
+}
+
+func existingCfVarFiles(manifestVariablesFiles []string, utils cfDeployUtils) ([]string, error) {
+	files := []string{}
+	for _, file := range manifestVariablesFiles {
+		exists, err := utils.fileExists(file)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Cannot check manifest variables file '%s'", file)
+		}
+		if !exists {
+			log.Entry().Warningf("We skip adding not-existing file '%s' as a vars-file to the cf push call", file)
+			continue
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+func readCfManifest(manifestFile string, utils cfDeployUtils) (map[string]interface{}, error) {
+	exists, err := utils.fileExists(manifestFile)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Cannot check manifest file '%s'", manifestFile)
+	}
+	if !exists {
+		return nil, fmt.Errorf("No manifest file '%s' found.", manifestFile)
+	}
+	content, err := utils.readFile(manifestFile)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Cannot read manifest file '%s'", manifestFile)
+	}
+	manifest := map[string]interface{}{}
+	if err := yaml.Unmarshal(content, &manifest); err != nil {
+		return nil, errors.Wrapf(err, "Cannot parse manifest file '%s'", manifestFile)
+	}
+	return manifest, nil
+}
+
+func checkAppNameInManifest(manifestFile string, utils cfDeployUtils) error {
+	manifest, err := readCfManifest(manifestFile, utils)
+	if err != nil {
+		return err
+	}
+	if applications, ok := manifest["applications"].([]interface{}); ok && len(applications) > 0 {
+		if application, ok := applications[0].(map[string]interface{}); ok && application["name"] != nil && application["name"] != "" {
+			return nil
+		}
+	}
+	return fmt.Errorf("No appName available in manifest '%s'.", manifestFile)
+}
+
+// handleLegacyCfManifest rewrites a list of buildpacks into a single buildpack since the blue-green plugin
+// does not support multiple buildpacks (see https://github.com/cloudfoundry/cli/issues/1445).
+func handleLegacyCfManifest(manifestFile string, utils cfDeployUtils) error {
+	manifest, err := readCfManifest(manifestFile, utils)
+	if err != nil {
+		return err
+	}
+	transformed, err := transformCfManifest(manifest)
+	if err != nil {
+		return errors.Wrapf(err, "Cannot transform manifest file '%s'", manifestFile)
+	}
+	if reflect.DeepEqual(manifest, transformed) {
+		return nil
+	}
+
+	content, err := yaml.Marshal(transformed)
+	if err != nil {
+		return errors.Wrapf(err, "Cannot serialize manifest file '%s'", manifestFile)
+	}
+	log.Entry().Infof("The file '%s' is not compatible with the Cloud Foundry blue-green deployment plugin. Re-writing inline. "+
+		"See this issue if you are interested in the background: https://github.com/cloudfoundry/cli/issues/1445.", manifestFile)
+	log.Entry().Infof("Transformed manifest file content:\n%s", string(content))
+	return utils.writeFile(manifestFile, content, 0644)
+}
+
+func transformCfManifest(manifest map[string]interface{}) (map[string]interface{}, error) {
+	applications, ok := manifest["applications"].([]interface{})
+	if !ok {
+		return manifest, nil
+	}
+
+	transformed := map[string]interface{}{}
+	for key, value := range manifest {
+		transformed[key] = value
+	}
+	transformedApplications := []interface{}{}
+	for _, app := range applications {
+		application, ok := app.(map[string]interface{})
+		if !ok || application["buildpacks"] == nil {
+			transformedApplications = append(transformedApplications, app)
+			continue
+		}
+		buildpacks, ok := application["buildpacks"].([]interface{})
+		if !ok {
+			return nil, errors.New("\"buildpacks\" in manifest is not a list. Please check your manifest file.")
+		}
+		if len(buildpacks) > 1 {
+			return nil, errors.New("More than one Cloud Foundry Buildpack is not supported. Please check your manifest file.")
+		}
+		transformedApplication := map[string]interface{}{}
+		for key, value := range application {
+			if key != "buildpacks" {
+				transformedApplication[key] = value
+			}
+		}
+		if len(buildpacks) == 1 {
+			transformedApplication["buildpack"] = buildpacks[0]
+		}
+		transformedApplications = append(transformedApplications, transformedApplication)
+	}
+	transformed["applications"] = transformedApplications
+	return transformed, nil
+}
+
+// prepareSmokeTest provides the absolute path of the smoke test script. The default script is created in case it does not exist.
+func prepareSmokeTest(script string, utils cfDeployUtils) (string, error) {
+	exists, err := utils.fileExists(script)
+	if err != nil {
+		return "", errors.Wrapf(err, "Cannot check smoke test script '%s'", script)
+	}
+	if !exists {
+		if script != cfDefaultSmokeTestScript {
+			return "", fmt.Errorf("Smoke test script '%s' does not exist", script)
+		}
+		if err := utils.writeFile(script, []byte(cfDefaultSmokeTestScriptContent), 0755); err != nil {
+			return "", errors.Wrapf(err, "Cannot create smoke test script '%s'", script)
+		}
+	}
+	if err := utils.chmod(script, 0755); err != nil {
+		return "", errors.Wrapf(err, "Cannot make smoke test script '%s' executable", script)
+	}
+	return filepath.Abs(script)
+}
+
+func stopOldAppIfRunning(appName string, s execRunner) error {
+	oldAppName := appName + "-old"
+
+	output := bytes.Buffer{}
+	s.Stdout(io.MultiWriter(os.Stdout, &output))
+	s.Stderr(io.MultiWriter(os.Stderr, &output))
+	err := s.RunExecutable("cf", "stop", oldAppName)
+	s.Stdout(os.Stdout)
+	s.Stderr(os.Stderr)
+
+	if err != nil && !strings.Contains(output.String(), oldAppName+" not found") {
+		return errors.Wrapf(err, "Could not stop application %s. Error: %s", oldAppName, output.String())
+	}
+	return nil
+}
+
+func logCfTrace(utils cfDeployUtils) {
+	exists, _ := utils.fileExists(cfTraceFile)
+	if !exists {
+		log.Entry().Infof("No trace file found at '%s'", cfTraceFile)
+		return
+	}
+	trace, err := utils.readFile(cfTraceFile)
+	if err != nil {
+		log.Entry().WithError(err).Warnf("Cannot read trace file '%s'", cfTraceFile)
+		return
+	}
+	log.Entry().Info("### START OF CF CLI TRACE OUTPUT ###")
+	log.Entry().Info(string(trace))
+	log.Entry().Info("### END OF CF CLI TRACE OUTPUT ###")
+}
+
+// findMtars searches the workspace for *.mtar files
+func findMtars() ([]string, error) {
+	mtars := []string{}
+	err := filepath.Walk(".", func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && filepath.Ext(path) == ".mtar" {
+			mtars = append(mtars, path)
+		}
+		return nil
+	})
+	sort.Strings(mtars)
+	return mtars, err
+}
@@ -0,0 +1,335 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/SAP/jenkins-library/pkg/config"
+	"github.com/SAP/jenkins-library/pkg/log"
+	"github.com/SAP/jenkins-library/pkg/piperenv"
+	"github.com/spf13/cobra"
+)
+
+type cloudFoundryDeployOptions struct {
+	APIEndpoint              string   `json:"apiEndpoint,omitempty"`
+	APIParameters            string   `json:"apiParameters,omitempty"`
+	LoginParameters          string   `json:"loginParameters,omitempty"`
+	Username                 string   `json:"username,omitempty"`
+	Password                 string   `json:"password,omitempty"`
+	Org                      string   `json:"org,omitempty"`
+	Space                    string   `json:"space,omitempty"`
+	DeployTool               string   `json:"deployTool,omitempty"`
+	DeployType               string   `json:"deployType,omitempty"`
+	KeepOldInstance          bool     `json:"keepOldInstance,omitempty"`
+	AppName                  string   `json:"appName,omitempty"`
+	Manifest                 string   `json:"manifest,omitempty"`
+	ManifestVariablesFiles   []string `json:"manifestVariablesFiles,omitempty"`
+	ManifestVariables        []string `json:"manifestVariables,omitempty"`
+	CfNativeDeployParameters string   `json:"cfNativeDeployParameters,omitempty"`
+	SmokeTestScript          string   `json:"smokeTestScript,omitempty"`
+	SmokeTestStatusCode      string   `json:"smokeTestStatusCode,omitempty"`
+	MtaPath                  string   `json:"mtaPath,omitempty"`
+	MtaDeployParameters      string   `json:"mtaDeployParameters,omitempty"`
+	MtaExtensionDescriptor   string   `json:"mtaExtensionDescriptor,omitempty"`
+	ArtifactVersion          string   `json:"artifactVersion,omitempty"`
+}
+
+type cloudFoundryDeployInflux struct {
+	deployment_data struct {
+		fields struct {
+			artifactURL string
+			deployTime  string
+		}
+		tags struct {
+			artifactVersion string
+			deployUser      string
+			deployResult    string
+			cfAPIEndpoint   string
+			cfOrg           string
+			cfSpace         string
+		}
+	}
+}
+
+func (i *cloudFoundryDeployInflux) persist(path, resourceName string) {
+	measurementContent := []struct {
+		measurement string
+		valType     string
+		name        string
+		value       string
+	}{
+		{valType: config.InfluxField, measurement: "deployment_data", name: "artifactUrl", value: i.deployment_data.fields.artifactURL},
+		{valType: config.InfluxField, measurement: "deployment_data", name: "deployTime", value: i.deployment_data.fields.deployTime},
+		{valType: config.InfluxTag, measurement: "deployment_data", name: "artifactVersion", value: i.deployment_data.tags.artifactVersion},
+		{valType: config.InfluxTag, measurement: "deployment_data", name: "deployUser", value: i.deployment_data.tags.deployUser},
+		{valType: config.InfluxTag, measurement: "deployment_data", name: "deployResult", value: i.deployment_data.tags.deployResult},
+		{valType: config.InfluxTag, measurement: "deployment_data", name: "cfApiEndpoint", value: i.deployment_data.tags.cfAPIEndpoint},
+		{valType: config.InfluxTag, measurement: "deployment_data", name: "cfOrg", value: i.deployment_data.tags.cfOrg},
+		{valType: config.InfluxTag, measurement: "deployment_data", name: "cfSpace", value: i.deployment_data.tags.cfSpace},
+	}
+
+	errCount := 0
+	for _, metric := range measurementContent {
+		err := piperenv.SetResourceParameter(path, resourceName, filepath.Join(metric.measurement, fmt.Sprintf("%vs", metric.valType), metric.name), metric.value)
+		if err != nil {
+			log.Entry().WithError(err).Error("Error persisting influx environment.")
+			errCount++
+		}
+	}
+	if errCount > 0 {
+		os.Exit(1)
+	}
+}
+
+var myCloudFoundryDeployOptions cloudFoundryDeployOptions
+
+// CloudFoundryDeployCommand Deploys an application to Cloud Foundry
+func CloudFoundryDeployCommand() *cobra.Command {
+	metadata := cloudFoundryDeployMetadata()
+	var influx cloudFoundryDeployInflux
+
+	var createCloudFoundryDeployCmd = &cobra.Command{
+		Use:   "cloudFoundryDeploy",
+		Short: "Deploys an application to Cloud Foundry",
+		Long: `Deploys an application to a test or production space within Cloud Foundry.
+Deployment can be done
+
+* in a standard way
+* in a zero downtime manner (using a [blue-green deployment approach](https://martinfowler.com/bliki/BlueGreenDeployment.html))
+
+The following deployment tools are supported:
+
+* Standard ` + "`" + `cf push` + "`" + ` and [Bluemix blue-green plugin](https://github.com/bluemixgaragelondon/cf-blue-green-deploy#how-to-use) (` + "`" + `cf_native` + "`" + `)
+* [MTA CF CLI Plugin](https://github.com/cloudfoundry-incubator/multiapps-cli-plugin) (` + "`" + `mtaDeployPlugin` + "`" + `)
+
+The password is not provided on the command line. The step authenticates via ` + "`" + `cf auth` + "`" + ` which reads the credentials from the environment.`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			log.SetStepName("cloudFoundryDeploy")
+			log.SetVerbose(GeneralConfig.Verbose)
+			return PrepareConfig(cmd, &metadata, "cloudFoundryDeploy", &myCloudFoundryDeployOptions, config.OpenPiperFile)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			handler := func() {
+				influx.persist(GeneralConfig.EnvRootPath, "influx")
+			}
+			log.DeferExitHandler(handler)
+			defer handler()
+			return cloudFoundryDeploy(myCloudFoundryDeployOptions, &influx)
+		},
+	}
+
+	addCloudFoundryDeployFlags(createCloudFoundryDeployCmd)
+	return createCloudFoundryDeployCmd
+}
+
+func addCloudFoundryDeployFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&myCloudFoundryDeployOptions.APIEndpoint, "apiEndpoint", "https://api.cf.eu10.hana.ondemand.com", "Cloud Foundry API endpoint.")
+	cmd.Flags().StringVar(&myCloudFoundryDeployOptions.APIParameters, "apiParameters", os.Getenv("PIPER_apiParameters"), "Additional command line options for the `cf api` command, e.g. `--skip-ssl-validation`. The options are split into separate arguments like a shell would do.")
+	cmd.Flags().StringVar(&myCloudFoundryDeployOptions.LoginParameters, "loginParameters", os.Getenv("PIPER_loginParameters"), "Additional command line options for the `cf auth` command, e.g. `--origin my-idp`. The options are split into separate arguments like a shell would do. The password must not be provided via these options.")
+	cmd.Flags().StringVar(&myCloudFoundryDeployOptions.Username, "username", os.Getenv("PIPER_username"), "User for authenticating against Cloud Foundry.")
+	cmd.Flags().StringVar(&myCloudFoundryDeployOptions.Password, "password", os.Getenv("PIPER_password"), "Password for authenticating against Cloud Foundry.")
+	cmd.Flags().StringVar(&myCloudFoundryDeployOptions.Org, "org", os.Getenv("PIPER_org"), "Cloud Foundry target organization.")
+	cmd.Flags().StringVar(&myCloudFoundryDeployOptions.Space, "space", os.Getenv("PIPER_space"), "Cloud Foundry target space.")
+	cmd.Flags().StringVar(&myCloudFoundryDeployOptions.DeployTool, "deployTool", "cf_native", "Defines the tool which should be used for deployment. Values: 'cf_native', 'mtaDeployPlugin'")
+	cmd.Flags().StringVar(&myCloudFoundryDeployOptions.DeployType, "deployType", "standard", "Defines the type of deployment, either `standard` deployment which results in a system downtime or a zero-downtime `blue-green` deployment. Values: 'standard', 'blue-green'")
+	cmd.Flags().BoolVar(&myCloudFoundryDeployOptions.KeepOldInstance, "keepOldInstance", false, "In case of a `blue-green` deployment the old instance will be deleted by default. If this option is set to true the old instance will remain stopped in the Cloud Foundry space.")
+	cmd.Flags().StringVar(&myCloudFoundryDeployOptions.AppName, "appName", os.Getenv("PIPER_appName"), "Defines the name of the application to be deployed to the Cloud Foundry space. Only relevant for `cf_native`. Mandatory for `blue-green` deployments, otherwise the name is taken from the manifest in case it is not provided.")
+	cmd.Flags().StringVar(&myCloudFoundryDeployOptions.Manifest, "manifest", "manifest.yml", "Defines the manifest to be used for deployment to Cloud Foundry. Only relevant for `cf_native`.")
+	cmd.Flags().StringSliceVar(&myCloudFoundryDeployOptions.ManifestVariablesFiles, "manifestVariablesFiles", []string{"manifest-variables.yml"}, "Defines the manifest variables files used to replace variable references in the manifest like it is provided by `cf push --vars-file <file>`. Files which do not exist are skipped. Only relevant for `cf_native` For `blue-green` deployments the variables are substituted in the manifest before the deployment.")
+	cmd.Flags().StringSliceVar(&myCloudFoundryDeployOptions.ManifestVariables, "manifestVariables", []string{}, "Defines variables in the form `key=value` used to replace variable references in the manifest like it is provided by `cf push --var key=value`. Variables defined here win over variables defined in `manifestVariablesFiles`. Only relevant for `cf_native` For `blue-green` deployments the variables are substituted in the manifest before the deployment.")
+	cmd.Flags().StringVar(&myCloudFoundryDeployOptions.CfNativeDeployParameters, "cfNativeDeployParameters", os.Getenv("PIPER_cfNativeDeployParameters"), "Additional parameters passed to the cf native deployment command. The options are split into separate arguments like a shell would do.")
+	cmd.Flags().StringVar(&myCloudFoundryDeployOptions.SmokeTestScript, "smokeTestScript", "blueGreenCheckScript.sh", "Only relevant for `cf_native` and `blue-green`. Script performing a check of the new application. The script gets the FQDN as parameter and returns exit code 0 in case the check returned `smokeTestStatusCode`. In case the default script `blueGreenCheckScript.sh` does not exist in the workspace it is created.")
+	cmd.Flags().StringVar(&myCloudFoundryDeployOptions.SmokeTestStatusCode, "smokeTestStatusCode", "200", "Expected status code returned by the smoke test. Provided to the smoke test script via the environment variable `STATUS_CODE`.")
+	cmd.Flags().StringVar(&myCloudFoundryDeployOptions.MtaPath, "mtaPath", os.Getenv("PIPER_mtaPath"), "Defines the path to the *.mtar for deployment with the `mtaDeployPlugin`. In case it is not provided the workspace is searched for a single *.mtar file.")
+	cmd.Flags().StringVar(&myCloudFoundryDeployOptions.MtaDeployParameters, "mtaDeployParameters", "-f", "Additional parameters passed to the mta deployment command. The options are split into separate arguments like a shell would do.")
+	cmd.Flags().StringVar(&myCloudFoundryDeployOptions.MtaExtensionDescriptor, "mtaExtensionDescriptor", os.Getenv("PIPER_mtaExtensionDescriptor"), "Defines an additional extension descriptor file for deployment with the `mtaDeployPlugin`.")
+	cmd.Flags().StringVar(&myCloudFoundryDeployOptions.ArtifactVersion, "artifactVersion", os.Getenv("PIPER_artifactVersion"), "Version of the deployed artifact, reported to influx.")
+
+	cmd.MarkFlagRequired("apiEndpoint")
+	cmd.MarkFlagRequired("username")
+	cmd.MarkFlagRequired("password")
+	cmd.MarkFlagRequired("org")
+	cmd.MarkFlagRequired("space")
+}
+
+// retrieve step metadata
+func cloudFoundryDeployMetadata() config.StepData {
+	var theMetaData = config.StepData{
+		Spec: config.StepSpec{
+			Inputs: config.StepInputs{
+				Parameters: []config.StepParameters{
+					{
+						Name:        "apiEndpoint",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   true,
+						Aliases:     []config.Alias{{Name: "cloudFoundry/apiEndpoint"}, {Name: "cfApiEndpoint"}},
+					},
+					{
+						Name:        "apiParameters",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "loginParameters",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "username",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   true,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "password",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   true,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "org",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   true,
+						Aliases:     []config.Alias{{Name: "cloudFoundry/org"}, {Name: "cfOrg"}},
+					},
+					{
+						Name:        "space",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   true,
+						Aliases:     []config.Alias{{Name: "cloudFoundry/space"}, {Name: "cfSpace"}},
+					},
+					{
+						Name:        "deployTool",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "deployType",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "keepOldInstance",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "bool",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "appName",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{{Name: "cloudFoundry/appName"}, {Name: "cfAppName"}},
+					},
+					{
+						Name:        "manifest",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{{Name: "cloudFoundry/manifest"}, {Name: "cfManifest"}},
+					},
+					{
+						Name:        "manifestVariablesFiles",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "[]string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{{Name: "cloudFoundry/manifestVariablesFiles"}, {Name: "cfManifestVariablesFiles"}},
+					},
+					{
+						Name:        "manifestVariables",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "[]string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "cfNativeDeployParameters",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "smokeTestScript",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "smokeTestStatusCode",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "mtaPath",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "mtaDeployParameters",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "mtaExtensionDescriptor",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "artifactVersion",
+						ResourceRef: []config.ResourceReference{{Name: "commonPipelineEnvironment", Param: "artifactVersion"}},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
+				},
+			},
+		},
+	}
+	return theMetaData
+}
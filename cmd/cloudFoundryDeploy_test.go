@@ -0,0 +1,301 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCloudFoundryDeploy(t *testing.T) {
+
+	defaultConfig := cloudFoundryDeployOptions{
+		APIEndpoint:            "https://api.example.org",
+		Username:               "me",
+		Password:               "secretPassword",
+		Org:                    "myOrg",
+		Space:                  "mySpace",
+		DeployTool:             "cf_native",
+		DeployType:             "standard",
+		Manifest:               "manifest.yml",
+		ManifestVariablesFiles: []string{"manifest-variables.yml"},
+		SmokeTestScript:        "blueGreenCheckScript.sh",
+		SmokeTestStatusCode:    "200",
+		MtaDeployParameters:    "-f",
+		ArtifactVersion:        "1.2.3",
+	}
+
+	var files map[string]string
+	var writtenFiles map[string]string
+	var readFiles []string
+
+	utils := cfDeployUtils{
+		fileExists: func(path string) (bool, error) {
+			_, ok := files[path]
+			return ok, nil
+		},
+		readFile: func(path string) ([]byte, error) {
+			readFiles = append(readFiles, path)
+			content, ok := files[path]
+			if !ok {
+				return nil, errors.New("file not found")
+			}
+			return []byte(content), nil
+		},
+		writeFile: func(path string, content []byte, perm os.FileMode) error {
+			writtenFiles[path] = string(content)
+			return nil
+		},
+		chmod: func(string, os.FileMode) error { return nil },
+		findMtars: func() ([]string, error) {
+			mtars := []string{}
+			for path := range files {
+				if filepath.Ext(path) == ".mtar" {
+					mtars = append(mtars, path)
+				}
+			}
+			return mtars, nil
+		},
+		now: func() time.Time { return time.Date(2019, 11, 20, 10, 30, 0, 0, time.UTC) },
+	}
+
+	reset := func() {
+		files = map[string]string{"manifest.yml": "applications:\n- name: myApp\n"}
+		writtenFiles = map[string]string{}
+		readFiles = nil
+	}
+
+	loginCalls := []execCall{
+		{exec: "cf", params: []string{"api", "https://api.example.org"}},
+		{exec: "cf", params: []string{"auth"}},
+		{exec: "cf", params: []string{"target", "-o", "myOrg", "-s", "mySpace"}},
+	}
+
+	t.Run("cf native standard deployment", func(t *testing.T) {
+		reset()
+		files["manifest-variables.yml"] = "route: myroute\n"
+		s := execMockRunner{}
+		influx := cloudFoundryDeployInflux{}
+
+		config := defaultConfig
+		config.AppName = "myApp"
+		config.ManifestVariables = []string{"instances=2"}
+		config.ManifestVariablesFiles = []string{"manifest-variables.yml", "notExisting.yml"}
+		config.APIParameters = "--skip-ssl-validation"
+		config.CfNativeDeployParameters = "--random-route"
+
+		err := runCloudFoundryDeploy(&config, &influx, &s, utils)
+
+		if assert.NoError(t, err) {
+			assert.Equal(t, []execCall{
+				{exec: "cf", params: []string{"api", "https://api.example.org", "--skip-ssl-validation"}},
+				{exec: "cf", params: []string{"auth"}},
+				{exec: "cf", params: []string{"target", "-o", "myOrg", "-s", "mySpace"}},
+				{exec: "cf", params: []string{"push", "myApp", "--var", "instances=2", "--vars-file", "manifest-variables.yml", "-f", "manifest.yml", "--random-route"}},
+				{exec: "cf", params: []string{"logout"}},
+			}, s.calls)
+		}
+		// the credentials are only available for the authentication
+		assert.Equal(t, []string{"CF_TRACE=cf.log", "STATUS_CODE=200"}, s.env)
+
+		assert.Equal(t, "SUCCESS", influx.deployment_data.tags.deployResult)
+		assert.Equal(t, "1.2.3", influx.deployment_data.tags.artifactVersion)
+		assert.Equal(t, "me", influx.deployment_data.tags.deployUser)
+		assert.Equal(t, "Nov 20, 2019 - 10:30:00", influx.deployment_data.fields.deployTime)
+	})
+
+	t.Run("cf native standard deployment, app name from manifest", func(t *testing.T) {
+		reset()
+		s := execMockRunner{}
+
+		err := runCloudFoundryDeploy(&defaultConfig, &cloudFoundryDeployInflux{}, &s, utils)
+
+		if assert.NoError(t, err) && assert.Len(t, s.calls, 5) {
+			assert.Equal(t, execCall{exec: "cf", params: []string{"push", "-f", "manifest.yml"}}, s.calls[3])
+		}
+	})
+
+	t.Run("cf native standard deployment, no app name in manifest", func(t *testing.T) {
+		reset()
+		files["manifest.yml"] = "applications:\n- memory: 1G\n"
+		s := execMockRunner{}
+
+		err := runCloudFoundryDeploy(&defaultConfig, &cloudFoundryDeployInflux{}, &s, utils)
+
+		assert.EqualError(t, err, "No appName available in manifest 'manifest.yml'.")
+		assert.Empty(t, s.calls)
+	})
+
+	t.Run("cf native blue-green deployment", func(t *testing.T) {
+		reset()
+		files["manifest.yml"] = "applications:\n- name: myApp\n  buildpacks:\n  - nodejs_buildpack\n"
+		s := execMockRunner{}
+
+		config := defaultConfig
+		config.AppName = "myApp"
+		config.DeployType = "blue-green"
+		config.ManifestVariablesFiles = []string{}
+
+		err := runCloudFoundryDeploy(&config, &cloudFoundryDeployInflux{}, &s, utils)
+
+		smokeTest, _ := filepath.Abs("blueGreenCheckScript.sh")
+		if assert.NoError(t, err) {
+			assert.Equal(t, append(loginCalls,
+				execCall{exec: "cf", params: []string{"blue-green-deploy", "myApp", "--delete-old-apps", "-f", "manifest.yml", "--smoke-test", smokeTest}},
+				execCall{exec: "cf", params: []string{"logout"}},
+			), s.calls)
+		}
+		assert.Equal(t, "applications:\n- buildpack: nodejs_buildpack\n  name: myApp\n", writtenFiles["manifest.yml"])
+		assert.Equal(t, cfDefaultSmokeTestScriptContent, writtenFiles["blueGreenCheckScript.sh"])
+	})
+
+	t.Run("cf native blue-green deployment, keep old instance", func(t *testing.T) {
+		reset()
+		s := execMockRunner{
+			stdoutReturn:        map[string]string{"cf stop myApp-old": "App myApp-old not found"},
+			shouldFailOnCommand: map[string]error{"cf stop myApp-old": errors.New("exit status 1")},
+		}
+
+		config := defaultConfig
+		config.AppName = "myApp"
+		config.DeployType = "blue-green"
+		config.KeepOldInstance = true
+
+		err := runCloudFoundryDeploy(&config, &cloudFoundryDeployInflux{}, &s, utils)
+
+		if assert.NoError(t, err) && assert.Len(t, s.calls, 6) {
+			assert.Equal(t, []string{"blue-green-deploy", "myApp", "-f", "manifest.yml", "--smoke-test"}, s.calls[3].params[:5])
+			assert.Equal(t, execCall{exec: "cf", params: []string{"stop", "myApp-old"}}, s.calls[4])
+		}
+
+		s = execMockRunner{
+			stdoutReturn:        map[string]string{"cf stop myApp-old": "Server error"},
+			shouldFailOnCommand: map[string]error{"cf stop myApp-old": errors.New("exit status 1")},
+		}
+		err = runCloudFoundryDeploy(&config, &cloudFoundryDeployInflux{}, &s, utils)
+		assert.EqualError(t, err, "Could not stop application myApp-old. Error: Server error: exit status 1")
+		assert.Equal(t, execCall{exec: "cf", params: []string{"logout"}}, s.calls[len(s.calls)-1])
+	})
+
+	t.Run("cf native blue-green deployment, manifest variables", func(t *testing.T) {
+		reset()
+		files["manifest.yml"] = "applications:\n- name: myApp\n  instances: ((instances))\n  routes:\n  - route: ((route))\n"
+		files["manifest-variables.yml"] = "route: myroute\ninstances: 1\n"
+		s := execMockRunner{}
+
+		config := defaultConfig
+		config.AppName = "myApp"
+		config.DeployType = "blue-green"
+		config.ManifestVariables = []string{"route=otherroute"}
+
+		err := runCloudFoundryDeploy(&config, &cloudFoundryDeployInflux{}, &s, utils)
+
+		if assert.NoError(t, err) && assert.Len(t, s.calls, 5) {
+			assert.Equal(t, []string{"blue-green-deploy", "myApp", "--delete-old-apps", "-f", "manifest.yml", "--smoke-test"}, s.calls[3].params[:6])
+		}
+		assert.Equal(t, "applications:\n- instances: 1\n  name: myApp\n  routes:\n  - route: otherroute\n", writtenFiles["manifest.yml"])
+	})
+
+	t.Run("cf native blue-green deployment, invalid configuration", func(t *testing.T) {
+		reset()
+		s := execMockRunner{}
+
+		config := defaultConfig
+		config.DeployType = "blue-green"
+
+		err := runCloudFoundryDeploy(&config, &cloudFoundryDeployInflux{}, &s, utils)
+		assert.EqualError(t, err, "Blue-green plugin requires app name to be passed (see https://github.com/bluemixgaragelondon/cf-blue-green-deploy/issues/27)")
+
+		config.AppName = "myApp"
+		config.ManifestVariables = []string{"instances=2"}
+		files["manifest.yml"] = "applications:\n- name: myApp\n  instances: ((instances))\n  memory: ((memory))\n"
+		err = runCloudFoundryDeploy(&config, &cloudFoundryDeployInflux{}, &s, utils)
+		assert.EqualError(t, err, "Unresolved variable(s) in manifest file 'manifest.yml': memory")
+
+		config.ManifestVariables = nil
+		files["manifest.yml"] = "applications:\n- name: myApp\n  buildpacks:\n  - nodejs_buildpack\n  - java_buildpack\n"
+		err = runCloudFoundryDeploy(&config, &cloudFoundryDeployInflux{}, &s, utils)
+		assert.EqualError(t, err, "Cannot transform manifest file 'manifest.yml': More than one Cloud Foundry Buildpack is not supported. Please check your manifest file.")
+
+		assert.Empty(t, s.calls)
+	})
+
+	t.Run("mta blue-green deployment", func(t *testing.T) {
+		reset()
+		files["target/myApp.mtar"] = ""
+		s := execMockRunner{}
+
+		config := defaultConfig
+		config.DeployTool = "mtaDeployPlugin"
+		config.DeployType = "blue-green"
+		config.MtaExtensionDescriptor = "-e dev.mtaext"
+
+		err := runCloudFoundryDeploy(&config, &cloudFoundryDeployInflux{}, &s, utils)
+
+		if assert.NoError(t, err) {
+			assert.Equal(t, append(loginCalls,
+				execCall{exec: "cf", params: []string{"bg-deploy", "target/myApp.mtar", "-f", "--no-confirm", "-e", "dev.mtaext"}},
+				execCall{exec: "cf", params: []string{"logout"}},
+			), s.calls)
+		}
+		assert.Equal(t, []string{"CF_TRACE=cf.log"}, s.env)
+	})
+
+	t.Run("mta deployment, multiple mtars", func(t *testing.T) {
+		reset()
+		files["a.mtar"] = ""
+		files["b.mtar"] = ""
+		s := execMockRunner{}
+
+		config := defaultConfig
+		config.DeployTool = "mtaDeployPlugin"
+
+		err := runCloudFoundryDeploy(&config, &cloudFoundryDeployInflux{}, &s, utils)
+
+		assert.Contains(t, err.Error(), "Found multiple *.mtar files, please specify file via mtaPath parameter!")
+		assert.Empty(t, s.calls)
+	})
+
+	t.Run("deployment fails", func(t *testing.T) {
+		reset()
+		files[cfTraceFile] = "REQUEST: GET /v2/info"
+		s := execMockRunner{shouldFailOnCommand: map[string]error{"cf push": errors.New("exit status 1")}}
+		influx := cloudFoundryDeployInflux{}
+
+		err := runCloudFoundryDeploy(&defaultConfig, &influx, &s, utils)
+
+		assert.EqualError(t, err, "The execution of the deploy command failed: exit status 1")
+		assert.Equal(t, execCall{exec: "cf", params: []string{"logout"}}, s.calls[len(s.calls)-1])
+		assert.Contains(t, readFiles, cfTraceFile)
+		assert.Equal(t, "FAILURE", influx.deployment_data.tags.deployResult)
+	})
+
+	t.Run("login fails", func(t *testing.T) {
+		reset()
+		s := execMockRunner{shouldFailOnCommand: map[string]error{"cf auth": errors.New("exit status 1")}}
+
+		err := runCloudFoundryDeploy(&defaultConfig, &cloudFoundryDeployInflux{}, &s, utils)
+
+		assert.EqualError(t, err, "cf login failed: exit status 1")
+		assert.Len(t, s.calls, 2)
+		assert.NotContains(t, s.env, "CF_PASSWORD=secretPassword")
+	})
+
+	t.Run("unsupported deploy tool", func(t *testing.T) {
+		reset()
+		s := execMockRunner{}
+
+		config := defaultConfig
+		config.DeployTool = "other"
+		influx := cloudFoundryDeployInflux{}
+
+		err := runCloudFoundryDeploy(&config, &influx, &s, utils)
+
+		assert.NoError(t, err)
+		assert.Empty(t, s.calls)
+		assert.Empty(t, influx.deployment_data.tags.deployResult)
+	})
+}
@@ -2,64 +2,328 @@ package cmd
 
 import (
 	"fmt"
+	"path/filepath"
 	"strings"
 
+	"github.com/SAP/jenkins-library/pkg/blackduck"
 	"github.com/SAP/jenkins-library/pkg/command"
+	piperhttp "github.com/SAP/jenkins-library/pkg/http"
 	"github.com/SAP/jenkins-library/pkg/log"
+	"github.com/SAP/jenkins-library/pkg/piperutils"
+	"github.com/pkg/errors"
 )
 
-func detectExecuteScan(myDetectExecuteScanOptions detectExecuteScanOptions) error {
+// detectExitCode describes an exit code of Synopsys Detect,
+// see https://synopsys.atlassian.net/wiki/spaces/INTDOCS/pages/62423113/Synopsys+Detect
+type detectExitCode struct {
+	name        string
+	description string
+}
+
+var detectExitCodes = map[int]detectExitCode{
+	0:   {name: "SUCCESS", description: "Detect scan completed successfully"},
+	1:   {name: "FAILURE_BLACKDUCK_CONNECTIVITY", description: "Detect was unable to connect to the Black Duck server"},
+	2:   {name: "FAILURE_TIMEOUT", description: "Detect timed out while waiting for the results of the scan"},
+	3:   {name: "FAILURE_POLICY_VIOLATION", description: "Detect found policy violations"},
+	4:   {name: "FAILURE_PROXY_CONNECTIVITY", description: "Detect was unable to connect via the proxy"},
+	5:   {name: "FAILURE_DETECTOR", description: "Detect had an issue while running a detector"},
+	6:   {name: "FAILURE_SCAN", description: "Detect failed to run a scan"},
+	7:   {name: "FAILURE_CONFIGURATION", description: "Detect is not configured correctly"},
+	9:   {name: "FAILURE_DETECTOR_REQUIRED", description: "Detect did not run all of the required detectors"},
+	10:  {name: "FAILURE_BLACKDUCK_VERSION_NOT_SUPPORTED", description: "Detect does not support the version of the Black Duck server"},
+	11:  {name: "FAILURE_BLACKDUCK_FEATURE_ERROR", description: "Detect encountered an error while using a Black Duck feature"},
+	12:  {name: "FAILURE_POLARIS_CONNECTIVITY", description: "Detect was unable to connect to Polaris"},
+	99:  {name: "FAILURE_GENERAL_ERROR", description: "Detect encountered a general error"},
+	100: {name: "FAILURE_UNKNOWN_ERROR", description: "Detect encountered an unknown error"},
+}
+
+// scanners supported by the step
+var detectScanners = []string{"signature", "source", "binary", "docker", "impactAnalysis"}
+
+// detectTools maps the scanners to the detect tools, impact analysis is no separate tool but enabled via property
+var detectTools = map[string]string{
+	"signature": "SIGNATURE_SCAN",
+	"source":    "DETECTOR",
+	"binary":    "BINARY_SCAN",
+	"docker":    "DOCKER",
+}
+
+// detectBuildDescriptors maps build descriptors to the detector types of detect
+var detectBuildDescriptors = []struct {
+	file         string
+	detectorType string
+}{
+	{file: "pom.xml", detectorType: "MAVEN"},
+	{file: "package.json", detectorType: "NPM"},
+	{file: "go.mod", detectorType: "GO_MOD"},
+}
+
+// exit code of Detect in case of policy violations
+const detectPolicyViolationExitCode = 3
+
+// severities of policy violations supported by Detect
+var detectPolicySeverities = []string{"ALL", "BLOCKER", "CRITICAL", "MAJOR", "MINOR", "TRIVIAL", "NONE"}
+
+// exitCoder is implemented by errors providing the exit code of the executed command, e.g. exec.ExitError
+type exitCoder interface {
+	ExitCode() int
+}
+
+func detectExecuteScan(myDetectExecuteScanOptions detectExecuteScanOptions, piperEnvironment *detectExecuteScanCommonPipelineEnvironment, influx *detectExecuteScanInflux) error {
 	c := command.Command{}
 	// reroute command output to logging framework
 	c.Stdout(log.Entry().Writer())
 	c.Stderr(log.Entry().Writer())
-	runDetect(myDetectExecuteScanOptions, &c)
-	return nil
+	resultsClient := blackduck.NewClient(myDetectExecuteScanOptions.ServerURL, myDetectExecuteScanOptions.APIToken, &piperhttp.Client{})
+	return runDetect(myDetectExecuteScanOptions, &c, &piperhttp.Client{}, resultsClient, piperEnvironment, influx, piperutils.FileExists)
 }
 
-func runDetect(myDetectExecuteScanOptions detectExecuteScanOptions, command shellRunner) {
+func runDetect(myDetectExecuteScanOptions detectExecuteScanOptions, command execRunner, client piperhttp.Sender, resultsClient blackDuckResultsClient,
+	piperEnvironment *detectExecuteScanCommonPipelineEnvironment, influx *detectExecuteScanInflux, fileExists func(string) (bool, error)) error {
 	// detect execution details, see https://synopsys.atlassian.net/wiki/spaces/INTDOCS/pages/88440888/Sample+Synopsys+Detect+Scan+Configuration+Scenarios+for+Black+Duck
 
-	args := []string{"bash <(curl -s https://detect.synopsys.com/detect.sh)"}
-	args = addDetectArgs(args, myDetectExecuteScanOptions)
-	script := strings.Join(args, " ")
+	if err := validateDetectOptions(myDetectExecuteScanOptions); err != nil {
+		return err
+	}
+
+	detect, err := getDetectExecutable(myDetectExecuteScanOptions, client)
+	if err != nil {
+		return err
+	}
 
 	command.Dir(".")
+	// the token is provided via the environment, hence it is neither part of the command line nor of the logs
+	command.Env(append(append([]string{}, detect.env...), fmt.Sprintf("BLACKDUCK_API_TOKEN=%v", myDetectExecuteScanOptions.APIToken)))
+
+	for _, run := range getDetectRuns(myDetectExecuteScanOptions) {
+		args := addDetectArgs(append([]string{}, detect.params...), myDetectExecuteScanOptions, run, fileExists)
+		runErr := command.RunExecutable(detect.executable, args...)
+		if runErr != nil && !isDetectPolicyViolation(runErr) {
+			return detectError(runErr, myDetectExecuteScanOptions)
+		}
+		// remaining paths are scanned despite policy violations in order to provide complete results
+		if runErr != nil && err == nil {
+			err = runErr
+		}
+	}
+
+	// the results are also provided in case of policy violations
+	if resultErr := handleDetectResults(myDetectExecuteScanOptions, resultsClient, piperEnvironment, influx); resultErr != nil {
+		if err != nil {
+			log.Entry().WithError(resultErr).Error("Failed to provide the Black Duck results")
+			return detectError(err, myDetectExecuteScanOptions)
+		}
+		return resultErr
+	}
+
+	if err != nil {
+		return detectError(err, myDetectExecuteScanOptions)
+	}
+	return nil
+}
 
-	err := command.RunShell("/bin/bash", script)
+func handleDetectResults(myDetectExecuteScanOptions detectExecuteScanOptions, resultsClient blackDuckResultsClient,
+	piperEnvironment *detectExecuteScanCommonPipelineEnvironment, influx *detectExecuteScanInflux) error {
+	if len(myDetectExecuteScanOptions.ServerURL) == 0 {
+		log.Entry().Info("No serverUrl configured, Black Duck results are not retrieved")
+		return nil
+	}
+
+	report, err := getDetectReport(myDetectExecuteScanOptions, resultsClient)
 	if err != nil {
-		log.Entry().
-			WithError(err).
-			WithField("command", myKarmaExecuteTestsOptions.InstallCommand).
-			Fatal("failed to execute detect scan")
+		return errors.Wrap(err, "Failed to retrieve the Black Duck results")
+	}
+	log.Entry().Infof("Black Duck results of '%v': %v critical, %v high, %v medium and %v low vulnerabilities, policy status %v",
+		report.ProjectVersionURL, report.Vulnerabilities.Critical, report.Vulnerabilities.High, report.Vulnerabilities.Medium, report.Vulnerabilities.Low, report.PolicyStatus)
+
+	persistDetectReport(report, piperEnvironment, influx)
+	return writeDetectReport(report, myDetectExecuteScanOptions.ReportDirectory)
+}
+
+func isDetectPolicyViolation(err error) bool {
+	exitErr, ok := errors.Cause(err).(exitCoder)
+	return ok && exitErr.ExitCode() == detectPolicyViolationExitCode
+}
+
+// detectError maps the exit code of Detect to a meaningful error
+func detectError(err error, myDetectExecuteScanOptions detectExecuteScanOptions) error {
+	exitErr, ok := errors.Cause(err).(exitCoder)
+	if !ok {
+		return errors.Wrap(err, "failed to execute detect scan")
+	}
+
+	exitCode := exitErr.ExitCode()
+	detectCode, known := detectExitCodes[exitCode]
+	if !known {
+		detectCode = detectExitCodes[100]
+	}
+	log.Entry().WithField("exitCode", exitCode).Errorf("Detect scan failed: %v (%v)", detectCode.description, detectCode.name)
+
+	if exitCode == detectPolicyViolationExitCode {
+		return fmt.Errorf("detect scan found policy violations with severity %v in project '%v' version '%v'", strings.Join(myDetectExecuteScanOptions.FailOn, ", "), myDetectExecuteScanOptions.ProjectName, myDetectExecuteScanOptions.ProjectVersion)
+	}
+	return fmt.Errorf("detect scan failed with exit code %v (%v): %v", exitCode, detectCode.name, detectCode.description)
+}
+
+func validateDetectFailOn(failOn []string) error {
+	for _, severity := range failOn {
+		if !sliceContains(detectPolicySeverities, severity) {
+			return fmt.Errorf("Invalid failOn severity '%v'. Supported values: '%v'", severity, strings.Join(detectPolicySeverities, "', '"))
+		}
+	}
+	return nil
+}
+
+// detectRun describes a single execution of detect. Since detect supports only one source path per
+// execution, each additional source path is scanned by a separate execution.
+type detectRun struct {
+	scanners     []string
+	sourcePath   string
+	codeLocation string
+}
+
+func getDetectRuns(myDetectExecuteScanOptions detectExecuteScanOptions) []detectRun {
+	codeLocation := myDetectExecuteScanOptions.CodeLocation
+	if len(codeLocation) == 0 && len(myDetectExecuteScanOptions.ProjectName) > 0 {
+		codeLocation = fmt.Sprintf("%v/%v", myDetectExecuteScanOptions.ProjectName, myDetectExecuteScanOptions.ProjectVersion)
+	}
+
+	run := detectRun{scanners: myDetectExecuteScanOptions.Scanners, codeLocation: codeLocation}
+	if !sliceContains(myDetectExecuteScanOptions.Scanners, "source") {
+		return []detectRun{run}
+	}
+
+	run.sourcePath = myDetectExecuteScanOptions.ScanPaths[0]
+	runs := []detectRun{run}
+	for _, path := range myDetectExecuteScanOptions.ScanPaths[1:] {
+		runs = append(runs, detectRun{scanners: []string{"source"}, sourcePath: path, codeLocation: fmt.Sprintf("%v/%v", codeLocation, filepath.ToSlash(filepath.Clean(path)))})
 	}
+	return runs
 }
 
-func addDetectArgs(args []string, myDetectExecuteScanOptions detectExecuteScanOptions) []string {
+func addDetectArgs(args []string, myDetectExecuteScanOptions detectExecuteScanOptions, run detectRun, fileExists func(string) (bool, error)) []string {
 
 	args = append(args, myDetectExecuteScanOptions.ScanProperties...)
 
 	args = append(args, fmt.Sprintf("--blackduck.url=%v", myDetectExecuteScanOptions.ServerURL))
-	args = append(args, fmt.Sprintf("--blackduck.api.token=%v", myDetectExecuteScanOptions.APIToken))
 
 	args = append(args, fmt.Sprintf("--detect.project.name=%v", myDetectExecuteScanOptions.ProjectName))
 	args = append(args, fmt.Sprintf("--detect.project.version.name=%v", myDetectExecuteScanOptions.ProjectVersion))
-	codeLocation := myDetectExecuteScanOptions.CodeLocation
-	if len(codeLocation) == 0 && len(myDetectExecuteScanOptions.ProjectName) > 0 {
-		codeLocation = fmt.Sprintf("%v/%v", myDetectExecuteScanOptions.ProjectName, myDetectExecuteScanOptions.ProjectVersion)
+	args = append(args, fmt.Sprintf("--detect.code.location.name=%v", run.codeLocation))
+
+	if len(myDetectExecuteScanOptions.FailOn) > 0 {
+		if hasDetectProperty(myDetectExecuteScanOptions.ScanProperties, "detect.policy.check.fail.on.severities") {
+			log.Entry().Info("failOn is ignored since the severities are defined via scanProperties")
+		} else {
+			args = append(args, fmt.Sprintf("--detect.policy.check.fail.on.severities=%v", strings.Join(myDetectExecuteScanOptions.FailOn, ",")))
+		}
+	}
+
+	tools := []string{}
+	for _, scanner := range run.scanners {
+		if tool, ok := detectTools[scanner]; ok {
+			tools = append(tools, tool)
+		}
+	}
+	if len(tools) > 0 && !hasDetectProperty(myDetectExecuteScanOptions.ScanProperties, "detect.tools") {
+		args = append(args, fmt.Sprintf("--detect.tools=%v", strings.Join(tools, ",")))
 	}
-	args = append(args, fmt.Sprintf("--detect.code.location.name=%v", codeLocation))
 
-	if sliceContains(myDetectExecuteScanOptions.Scanners, "signature") {
+	excluded := strings.Join(myDetectExecuteScanOptions.ExcludedDirectories, ",")
+	if len(excluded) > 0 {
+		args = append(args, fmt.Sprintf("--detect.excluded.directories=%v", excluded))
+	}
+
+	if sliceContains(run.scanners, "signature") {
 		args = append(args, fmt.Sprintf("--detect.blackduck.signature.scanner.paths=%v", strings.Join(myDetectExecuteScanOptions.ScanPaths, ",")))
+		if len(excluded) > 0 {
+			args = append(args, fmt.Sprintf("--detect.blackduck.signature.scanner.exclusion.name.patterns=%v", excluded))
+		}
+	}
+
+	if sliceContains(run.scanners, "source") {
+		args = append(args, fmt.Sprintf("--detect.source.path=%v", run.sourcePath))
+		detectorTypes := myDetectExecuteScanOptions.DetectorTypes
+		if len(detectorTypes) == 0 {
+			detectorTypes = getDetectorTypes(run.sourcePath, fileExists)
+		}
+		if len(detectorTypes) > 0 && !hasDetectProperty(myDetectExecuteScanOptions.ScanProperties, "detect.included.detector.types") {
+			args = append(args, fmt.Sprintf("--detect.included.detector.types=%v", strings.Join(detectorTypes, ",")))
+		}
+	}
+
+	if sliceContains(run.scanners, "binary") {
+		args = append(args, fmt.Sprintf("--detect.binary.scan.file.path=%v", myDetectExecuteScanOptions.BinaryScanPath))
+	}
+
+	if sliceContains(run.scanners, "docker") {
+		args = append(args, fmt.Sprintf("--detect.docker.image=%v", myDetectExecuteScanOptions.ScanDockerImage))
 	}
 
-	if sliceContains(myDetectExecuteScanOptions.Scanners, "source") {
-		args = append(args, fmt.Sprintf("--detect.source.path=%v", myDetectExecuteScanOptions.ScanPaths[0]))
+	if sliceContains(run.scanners, "impactAnalysis") {
+		args = append(args, "--detect.impact.analysis.enabled=true")
 	}
 	return args
 }
 
+// getDetectorTypes derives the detector types from the build descriptors contained in the source path
+func getDetectorTypes(sourcePath string, fileExists func(string) (bool, error)) []string {
+	detectorTypes := []string{}
+	for _, descriptor := range detectBuildDescriptors {
+		exists, err := fileExists(filepath.Join(sourcePath, descriptor.file))
+		if err != nil {
+			log.Entry().WithError(err).Warningf("Failed to check build descriptor '%v'", descriptor.file)
+			continue
+		}
+		if exists {
+			log.Entry().Infof("Build descriptor '%v' found, using detector type '%v'", descriptor.file, descriptor.detectorType)
+			detectorTypes = append(detectorTypes, descriptor.detectorType)
+		}
+	}
+	return detectorTypes
+}
+
+func validateDetectOptions(myDetectExecuteScanOptions detectExecuteScanOptions) error {
+	if err := validateDetectFailOn(myDetectExecuteScanOptions.FailOn); err != nil {
+		return err
+	}
+
+	if len(myDetectExecuteScanOptions.Scanners) == 0 {
+		return fmt.Errorf("No scanners configured. Supported values: '%v'", strings.Join(detectScanners, "', '"))
+	}
+	for _, scanner := range myDetectExecuteScanOptions.Scanners {
+		if !sliceContains(detectScanners, scanner) {
+			return fmt.Errorf("Invalid scanner '%v'. Supported values: '%v'", scanner, strings.Join(detectScanners, "', '"))
+		}
+	}
+
+	scanners := myDetectExecuteScanOptions.Scanners
+	if (sliceContains(scanners, "signature") || sliceContains(scanners, "source")) && len(myDetectExecuteScanOptions.ScanPaths) == 0 {
+		return fmt.Errorf("No scanPaths configured, which are required for the scanners 'signature' and 'source'")
+	}
+	for _, path := range myDetectExecuteScanOptions.ScanPaths {
+		if len(strings.TrimSpace(path)) == 0 {
+			return fmt.Errorf("Invalid empty entry in scanPaths")
+		}
+	}
+	if sliceContains(scanners, "binary") && len(myDetectExecuteScanOptions.BinaryScanPath) == 0 {
+		return fmt.Errorf("No binaryScanPath configured, which is required for the scanner 'binary'")
+	}
+	if sliceContains(scanners, "docker") && len(myDetectExecuteScanOptions.ScanDockerImage) == 0 {
+		return fmt.Errorf("No scanDockerImage configured, which is required for the scanner 'docker'")
+	}
+	return nil
+}
+
+func hasDetectProperty(properties []string, name string) bool {
+	for _, property := range properties {
+		if strings.HasPrefix(strings.TrimLeft(property, "-"), name+"=") {
+			return true
+		}
+	}
+	return false
+}
+
 func sliceContains(slice []string, find string) bool {
 	for _, elem := range slice {
 		if elem == find {
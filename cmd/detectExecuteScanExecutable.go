@@ -0,0 +1,167 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	piperhttp "github.com/SAP/jenkins-library/pkg/http"
+	"github.com/SAP/jenkins-library/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// timeout for downloading the detect jar or script
+const detectDownloadTimeout = 10 * time.Minute
+
+// detectExecutable describes how detect is invoked, the detect properties are appended to params
+type detectExecutable struct {
+	executable string
+	params     []string
+	env        []string
+}
+
+// getDetectExecutable resolves detect in the following order: a local jar, a local script, a jar of the
+// pinned version and finally the latest detect script. Downloaded files are cached in the detect cache directory.
+// The latest script is only used without checksum in case the unverified download is explicitly allowed.
+func getDetectExecutable(myDetectExecuteScanOptions detectExecuteScanOptions, client piperhttp.Sender) (detectExecutable, error) {
+	checksum := myDetectExecuteScanOptions.DetectChecksum
+
+	if len(myDetectExecuteScanOptions.DetectJarPath) > 0 {
+		if err := verifyDetectChecksum(myDetectExecuteScanOptions.DetectJarPath, checksum); err != nil {
+			return detectExecutable{}, errors.Wrapf(err, "Verification of detect '%v' failed", myDetectExecuteScanOptions.DetectJarPath)
+		}
+		return detectJarExecutable(myDetectExecuteScanOptions.DetectJarPath), nil
+	}
+
+	if len(myDetectExecuteScanOptions.DetectScriptPath) > 0 {
+		if err := verifyDetectChecksum(myDetectExecuteScanOptions.DetectScriptPath, checksum); err != nil {
+			return detectExecutable{}, errors.Wrapf(err, "Verification of detect '%v' failed", myDetectExecuteScanOptions.DetectScriptPath)
+		}
+		return detectScriptExecutable(myDetectExecuteScanOptions.DetectScriptPath, myDetectExecuteScanOptions), nil
+	}
+
+	if len(myDetectExecuteScanOptions.DetectVersion) > 0 {
+		version := myDetectExecuteScanOptions.DetectVersion
+		url := strings.Replace(myDetectExecuteScanOptions.DetectJarURL, "{version}", version, -1)
+		path := filepath.Join(myDetectExecuteScanOptions.DetectCacheDir, fmt.Sprintf("synopsys-detect-%v.jar", version))
+		// without checksum a cached jar of a pinned version is trusted, since released versions do not change
+		if err := downloadDetectFile(url, path, checksum, len(checksum) == 0, client); err != nil {
+			return detectExecutable{}, err
+		}
+		return detectJarExecutable(path), nil
+	}
+
+	if len(checksum) == 0 && !myDetectExecuteScanOptions.DetectAllowUnverifiedDownload {
+		return detectExecutable{}, fmt.Errorf("The latest detect script from '%v' cannot be verified. Please pin 'detectVersion', provide 'detectChecksum' or explicitly allow the unverified download via 'detectAllowUnverifiedDownload'", myDetectExecuteScanOptions.DetectScriptURL)
+	}
+	path := filepath.Join(myDetectExecuteScanOptions.DetectCacheDir, "detect.sh")
+	// the script always refers to the latest version, hence it is only reused in case the checksum matches
+	if err := downloadDetectFile(myDetectExecuteScanOptions.DetectScriptURL, path, checksum, false, client); err != nil {
+		return detectExecutable{}, err
+	}
+	return detectScriptExecutable(path, myDetectExecuteScanOptions), nil
+}
+
+func detectJarExecutable(path string) detectExecutable {
+	log.Entry().Infof("Using detect jar '%v'", path)
+	return detectExecutable{executable: "java", params: []string{"-jar", path}}
+}
+
+func detectScriptExecutable(path string, myDetectExecuteScanOptions detectExecuteScanOptions) detectExecutable {
+	log.Entry().Infof("Using detect script '%v'", path)
+	env := []string{}
+	if len(myDetectExecuteScanOptions.DetectVersion) > 0 {
+		env = append(env, fmt.Sprintf("DETECT_LATEST_RELEASE_VERSION=%v", myDetectExecuteScanOptions.DetectVersion))
+	}
+	if len(myDetectExecuteScanOptions.DetectCacheDir) > 0 {
+		// the script downloads the jar, which is cached alongside the script
+		env = append(env, fmt.Sprintf("DETECT_JAR_DOWNLOAD_DIR=%v", myDetectExecuteScanOptions.DetectCacheDir))
+	}
+	return detectExecutable{executable: "bash", params: []string{path}, env: env}
+}
+
+// downloadDetectFile downloads the file unless a cached file with matching checksum exists.
+// In case reuseCached is set a cached file is reused without checksum.
+func downloadDetectFile(url, path, checksum string, reuseCached bool, client piperhttp.Sender) error {
+	if _, err := os.Stat(path); err == nil {
+		cached := reuseCached
+		if len(checksum) > 0 {
+			cached = verifyDetectChecksum(path, checksum) == nil
+		}
+		if cached {
+			log.Entry().Infof("Using cached detect file '%v'", path)
+			return nil
+		}
+	}
+
+	if len(url) == 0 {
+		return fmt.Errorf("No download url for '%v' configured", filepath.Base(path))
+	}
+
+	log.Entry().Infof("Downloading detect from '%v'", url)
+	client.SetOptions(piperhttp.ClientOptions{Timeout: detectDownloadTimeout})
+	response, err := client.SendRequest(http.MethodGet, url, nil, nil, nil)
+	if response != nil && response.Body != nil {
+		defer response.Body.Close()
+	}
+	if err != nil {
+		return errors.Wrapf(err, "Failed to download detect from '%v'", url)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return errors.Wrapf(err, "Failed to create detect cache directory '%v'", filepath.Dir(path))
+	}
+	// download into a temporary file first in order to not leave a broken file in the cache
+	tmpFile, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path))
+	if err != nil {
+		return errors.Wrapf(err, "Failed to write '%v'", path)
+	}
+	defer os.Remove(tmpFile.Name())
+	_, err = io.Copy(tmpFile, response.Body)
+	tmpFile.Close()
+	if err != nil {
+		return errors.Wrapf(err, "Failed to download detect from '%v'", url)
+	}
+
+	if len(checksum) > 0 {
+		if err := verifyDetectChecksum(tmpFile.Name(), checksum); err != nil {
+			return errors.Wrapf(err, "Verification of detect downloaded from '%v' failed", url)
+		}
+	} else {
+		log.Entry().Warningf("No checksum configured for detect downloaded from '%v', the file is not verified", url)
+	}
+	if err := os.Rename(tmpFile.Name(), path); err != nil {
+		return errors.Wrapf(err, "Failed to write '%v'", path)
+	}
+	return nil
+}
+
+// verifyDetectChecksum compares the SHA-256 checksum of the file, an empty checksum is not verified
+func verifyDetectChecksum(path, checksum string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to load detect '%v'", path)
+	}
+	defer file.Close()
+
+	if len(checksum) == 0 {
+		log.Entry().Warningf("No checksum configured for detect '%v', the file is not verified", path)
+		return nil
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return errors.Wrapf(err, "Failed to calculate checksum of detect '%v'", path)
+	}
+	actual := fmt.Sprintf("%x", hash.Sum(nil))
+	if !strings.EqualFold(actual, strings.TrimSpace(checksum)) {
+		return fmt.Errorf("checksum '%v' does not match the expected checksum '%v'", actual, checksum)
+	}
+	return nil
+}
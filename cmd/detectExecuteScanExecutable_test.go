@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	piperhttp "github.com/SAP/jenkins-library/pkg/http"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetDetectExecutable(t *testing.T) {
+	requests := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.Path)
+		switch r.URL.Path {
+		case "/detect.sh":
+			w.Write([]byte("script"))
+		case "/6.0.0/synopsys-detect-6.0.0.jar":
+			w.Write([]byte("jar"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	checksum := func(content string) string {
+		return fmt.Sprintf("%x", sha256.Sum256([]byte(content)))
+	}
+
+	newOptions := func(t *testing.T) detectExecuteScanOptions {
+		dir, err := ioutil.TempDir("", "detectCache")
+		if err != nil {
+			t.Fatal("Failed to create temporary directory")
+		}
+		requests = []string{}
+		return detectExecuteScanOptions{
+			DetectCacheDir:  dir,
+			DetectJarURL:    server.URL + "/{version}/synopsys-detect-{version}.jar",
+			DetectScriptURL: server.URL + "/detect.sh",
+		}
+	}
+
+	t.Run("local jar", func(t *testing.T) {
+		options := newOptions(t)
+		defer os.RemoveAll(options.DetectCacheDir)
+		options.DetectJarPath = filepath.Join(options.DetectCacheDir, "local.jar")
+		ioutil.WriteFile(options.DetectJarPath, []byte("local"), 0644)
+		options.DetectChecksum = checksum("local")
+
+		detect, err := getDetectExecutable(options, &piperhttp.Client{})
+
+		if assert.NoError(t, err) {
+			assert.Equal(t, detectExecutable{executable: "java", params: []string{"-jar", options.DetectJarPath}}, detect)
+		}
+		assert.Empty(t, requests)
+	})
+
+	t.Run("local script with pinned version", func(t *testing.T) {
+		options := newOptions(t)
+		defer os.RemoveAll(options.DetectCacheDir)
+		options.DetectScriptPath = filepath.Join(options.DetectCacheDir, "local.sh")
+		ioutil.WriteFile(options.DetectScriptPath, []byte("local"), 0644)
+		options.DetectVersion = "6.0.0"
+
+		detect, err := getDetectExecutable(options, &piperhttp.Client{})
+
+		if assert.NoError(t, err) {
+			assert.Equal(t, "bash", detect.executable)
+			assert.Equal(t, []string{options.DetectScriptPath}, detect.params)
+			assert.Equal(t, []string{"DETECT_LATEST_RELEASE_VERSION=6.0.0", "DETECT_JAR_DOWNLOAD_DIR=" + options.DetectCacheDir}, detect.env)
+		}
+		assert.Empty(t, requests)
+	})
+
+	t.Run("local jar missing", func(t *testing.T) {
+		options := newOptions(t)
+		defer os.RemoveAll(options.DetectCacheDir)
+		options.DetectJarPath = filepath.Join(options.DetectCacheDir, "missing.jar")
+
+		_, err := getDetectExecutable(options, &piperhttp.Client{})
+		assert.Contains(t, fmt.Sprint(err), "Failed to load detect")
+	})
+
+	t.Run("pinned version downloaded and cached", func(t *testing.T) {
+		options := newOptions(t)
+		defer os.RemoveAll(options.DetectCacheDir)
+		options.DetectVersion = "6.0.0"
+		options.DetectChecksum = checksum("jar")
+		jarPath := filepath.Join(options.DetectCacheDir, "synopsys-detect-6.0.0.jar")
+
+		detect, err := getDetectExecutable(options, &piperhttp.Client{})
+		if assert.NoError(t, err) {
+			assert.Equal(t, []string{"-jar", jarPath}, detect.params)
+			content, _ := ioutil.ReadFile(jarPath)
+			assert.Equal(t, "jar", string(content))
+		}
+
+		_, err = getDetectExecutable(options, &piperhttp.Client{})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"/6.0.0/synopsys-detect-6.0.0.jar"}, requests, "cached jar expected to be reused")
+	})
+
+	t.Run("latest script is downloaded again", func(t *testing.T) {
+		options := newOptions(t)
+		defer os.RemoveAll(options.DetectCacheDir)
+		options.DetectAllowUnverifiedDownload = true
+
+		detect, err := getDetectExecutable(options, &piperhttp.Client{})
+		if assert.NoError(t, err) {
+			assert.Equal(t, []string{filepath.Join(options.DetectCacheDir, "detect.sh")}, detect.params)
+		}
+		_, err = getDetectExecutable(options, &piperhttp.Client{})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"/detect.sh", "/detect.sh"}, requests)
+	})
+
+	t.Run("latest script without verification", func(t *testing.T) {
+		options := newOptions(t)
+		defer os.RemoveAll(options.DetectCacheDir)
+
+		_, err := getDetectExecutable(options, &piperhttp.Client{})
+		assert.EqualError(t, err, fmt.Sprintf("The latest detect script from '%v/detect.sh' cannot be verified. Please pin 'detectVersion', provide 'detectChecksum' or explicitly allow the unverified download via 'detectAllowUnverifiedDownload'", server.URL))
+		assert.Empty(t, requests)
+	})
+
+	t.Run("latest script with checksum", func(t *testing.T) {
+		options := newOptions(t)
+		defer os.RemoveAll(options.DetectCacheDir)
+		options.DetectChecksum = checksum("script")
+
+		detect, err := getDetectExecutable(options, &piperhttp.Client{})
+		if assert.NoError(t, err) {
+			assert.Equal(t, []string{filepath.Join(options.DetectCacheDir, "detect.sh")}, detect.params)
+		}
+		_, err = getDetectExecutable(options, &piperhttp.Client{})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"/detect.sh"}, requests, "cached script with matching checksum expected to be reused")
+	})
+
+	t.Run("checksum mismatch of download", func(t *testing.T) {
+		options := newOptions(t)
+		defer os.RemoveAll(options.DetectCacheDir)
+		options.DetectChecksum = checksum("other")
+
+		_, err := getDetectExecutable(options, &piperhttp.Client{})
+		assert.EqualError(t, err, fmt.Sprintf("Verification of detect downloaded from '%v/detect.sh' failed: checksum '%v' does not match the expected checksum '%v'", server.URL, checksum("script"), checksum("other")))
+		files, _ := ioutil.ReadDir(options.DetectCacheDir)
+		assert.Empty(t, files, "no file expected in cache")
+	})
+
+	t.Run("download fails", func(t *testing.T) {
+		options := newOptions(t)
+		defer os.RemoveAll(options.DetectCacheDir)
+		options.DetectVersion = "0.0.1"
+
+		_, err := getDetectExecutable(options, &piperhttp.Client{})
+		assert.Contains(t, fmt.Sprint(err), fmt.Sprintf("Failed to download detect from '%v/0.0.1/synopsys-detect-0.0.1.jar'", server.URL))
+	})
+}
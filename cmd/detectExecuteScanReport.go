@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/SAP/jenkins-library/pkg/blackduck"
+	"github.com/SAP/jenkins-library/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// name of the report files written to the report directory
+const detectReportName = "blackduck-report"
+
+// detectReport contains the results of the project version on the Black Duck server
+type detectReport struct {
+	ProjectName       string           `json:"projectName"`
+	ProjectVersion    string           `json:"projectVersion"`
+	ProjectVersionURL string           `json:"projectVersionUrl"`
+	PolicyStatus      string           `json:"policyStatus"`
+	PolicyViolations  int              `json:"policyViolations"`
+	Vulnerabilities   detectRiskCounts `json:"vulnerabilities"`
+	LicenseRisks      detectRiskCounts `json:"licenseRisks"`
+}
+
+// detectRiskCounts provides the number of components per risk level
+type detectRiskCounts struct {
+	Critical int `json:"critical"`
+	High     int `json:"high"`
+	Medium   int `json:"medium"`
+	Low      int `json:"low"`
+}
+
+type blackDuckResultsClient interface {
+	GetProjectVersion(projectName, versionName string) (*blackduck.ProjectVersion, error)
+	GetRiskProfile(version *blackduck.ProjectVersion) (*blackduck.RiskProfile, error)
+	GetPolicyStatus(version *blackduck.ProjectVersion) (*blackduck.PolicyStatus, error)
+}
+
+const detectReportTemplate = `<!DOCTYPE html>
+<html>
+<head>
+<title>Black Duck Report</title>
+<style>
+body { font-family: Arial, Helvetica, sans-serif; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 4px 12px; text-align: left; }
+.violation { color: #c00; font-weight: bold; }
+</style>
+</head>
+<body>
+<h1>Black Duck Report</h1>
+<p>Project: <a href="{{.ProjectVersionURL}}">{{.ProjectName}} - {{.ProjectVersion}}</a></p>
+<p>Policy status: <span{{if gt .PolicyViolations 0}} class="violation"{{end}}>{{.PolicyStatus}}</span> ({{.PolicyViolations}} components in violation)</p>
+<table>
+<tr><th>Risk</th><th>Critical</th><th>High</th><th>Medium</th><th>Low</th></tr>
+<tr><td>Security</td><td>{{.Vulnerabilities.Critical}}</td><td>{{.Vulnerabilities.High}}</td><td>{{.Vulnerabilities.Medium}}</td><td>{{.Vulnerabilities.Low}}</td></tr>
+<tr><td>License</td><td>{{.LicenseRisks.Critical}}</td><td>{{.LicenseRisks.High}}</td><td>{{.LicenseRisks.Medium}}</td><td>{{.LicenseRisks.Low}}</td></tr>
+</table>
+</body>
+</html>
+`
+
+// getDetectReport retrieves the vulnerability and license risk counts of the scanned project version
+func getDetectReport(myDetectExecuteScanOptions detectExecuteScanOptions, client blackDuckResultsClient) (detectReport, error) {
+	report := detectReport{ProjectName: myDetectExecuteScanOptions.ProjectName, ProjectVersion: myDetectExecuteScanOptions.ProjectVersion}
+
+	version, err := client.GetProjectVersion(myDetectExecuteScanOptions.ProjectName, myDetectExecuteScanOptions.ProjectVersion)
+	if err != nil {
+		return report, err
+	}
+	report.ProjectVersionURL = version.Meta.Href + "/components"
+
+	riskProfile, err := client.GetRiskProfile(version)
+	if err != nil {
+		return report, err
+	}
+	report.Vulnerabilities = getDetectRiskCounts(riskProfile, "VULNERABILITY")
+	report.LicenseRisks = getDetectRiskCounts(riskProfile, "LICENSE")
+
+	policyStatus, err := client.GetPolicyStatus(version)
+	if err != nil {
+		return report, err
+	}
+	report.PolicyStatus = policyStatus.OverallStatus
+	report.PolicyViolations = policyStatus.Count("IN_VIOLATION")
+
+	return report, nil
+}
+
+func getDetectRiskCounts(riskProfile *blackduck.RiskProfile, category string) detectRiskCounts {
+	return detectRiskCounts{
+		Critical: riskProfile.Count(category, "CRITICAL"),
+		High:     riskProfile.Count(category, "HIGH"),
+		Medium:   riskProfile.Count(category, "MEDIUM"),
+		Low:      riskProfile.Count(category, "LOW"),
+	}
+}
+
+// writeDetectReport writes the report as html and json file into the report directory
+func writeDetectReport(report detectReport, reportDirectory string) error {
+	if err := os.MkdirAll(reportDirectory, 0755); err != nil {
+		return errors.Wrapf(err, "Failed to create report directory '%v'", reportDirectory)
+	}
+
+	jsonReport, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "Failed to create json report")
+	}
+	if err := ioutil.WriteFile(filepath.Join(reportDirectory, detectReportName+".json"), jsonReport, 0644); err != nil {
+		return errors.Wrap(err, "Failed to write json report")
+	}
+
+	tmpl, err := template.New("report").Parse(detectReportTemplate)
+	if err != nil {
+		return errors.Wrap(err, "Failed to parse html report template")
+	}
+	var htmlReport bytes.Buffer
+	if err := tmpl.Execute(&htmlReport, report); err != nil {
+		return errors.Wrap(err, "Failed to create html report")
+	}
+	if err := ioutil.WriteFile(filepath.Join(reportDirectory, detectReportName+".html"), htmlReport.Bytes(), 0644); err != nil {
+		return errors.Wrap(err, "Failed to write html report")
+	}
+
+	log.Entry().Infof("Black Duck report written to '%v'", reportDirectory)
+	return nil
+}
+
+// persistDetectReport makes the results available to subsequent steps and influx
+func persistDetectReport(report detectReport, piperEnvironment *detectExecuteScanCommonPipelineEnvironment, influx *detectExecuteScanInflux) {
+	piperEnvironment.blackduck.projectVersionURL = report.ProjectVersionURL
+	piperEnvironment.blackduck.policyStatus = report.PolicyStatus
+	piperEnvironment.blackduck.criticalVulnerabilities = fmt.Sprint(report.Vulnerabilities.Critical)
+	piperEnvironment.blackduck.highVulnerabilities = fmt.Sprint(report.Vulnerabilities.High)
+	piperEnvironment.blackduck.mediumVulnerabilities = fmt.Sprint(report.Vulnerabilities.Medium)
+	piperEnvironment.blackduck.lowVulnerabilities = fmt.Sprint(report.Vulnerabilities.Low)
+	piperEnvironment.blackduck.highLicenseRisks = fmt.Sprint(report.LicenseRisks.High)
+	piperEnvironment.blackduck.mediumLicenseRisks = fmt.Sprint(report.LicenseRisks.Medium)
+	piperEnvironment.blackduck.lowLicenseRisks = fmt.Sprint(report.LicenseRisks.Low)
+
+	influx.blackduck_data.fields.criticalVulnerabilities = fmt.Sprint(report.Vulnerabilities.Critical)
+	influx.blackduck_data.fields.highVulnerabilities = fmt.Sprint(report.Vulnerabilities.High)
+	influx.blackduck_data.fields.mediumVulnerabilities = fmt.Sprint(report.Vulnerabilities.Medium)
+	influx.blackduck_data.fields.lowVulnerabilities = fmt.Sprint(report.Vulnerabilities.Low)
+	influx.blackduck_data.fields.highLicenseRisks = fmt.Sprint(report.LicenseRisks.High)
+	influx.blackduck_data.fields.mediumLicenseRisks = fmt.Sprint(report.LicenseRisks.Medium)
+	influx.blackduck_data.fields.lowLicenseRisks = fmt.Sprint(report.LicenseRisks.Low)
+	influx.blackduck_data.fields.policyViolations = fmt.Sprint(report.PolicyViolations)
+	influx.blackduck_data.tags.projectName = report.ProjectName
+	influx.blackduck_data.tags.projectVersion = report.ProjectVersion
+	influx.blackduck_data.tags.policyStatus = report.PolicyStatus
+}
@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/SAP/jenkins-library/pkg/blackduck"
+	piperhttp "github.com/SAP/jenkins-library/pkg/http"
+	"github.com/stretchr/testify/assert"
+)
+
+type blackDuckMock struct {
+	riskProfile  blackduck.RiskProfile
+	policyStatus blackduck.PolicyStatus
+	err          error
+	projectName  string
+	versionName  string
+}
+
+func (b *blackDuckMock) GetProjectVersion(projectName, versionName string) (*blackduck.ProjectVersion, error) {
+	b.projectName = projectName
+	b.versionName = versionName
+	if b.err != nil {
+		return nil, b.err
+	}
+	return &blackduck.ProjectVersion{VersionName: versionName, Meta: blackduck.Meta{Href: "https://blackduck.server/api/projects/1/versions/2"}}, nil
+}
+
+func (b *blackDuckMock) GetRiskProfile(version *blackduck.ProjectVersion) (*blackduck.RiskProfile, error) {
+	return &b.riskProfile, nil
+}
+
+func (b *blackDuckMock) GetPolicyStatus(version *blackduck.ProjectVersion) (*blackduck.PolicyStatus, error) {
+	return &b.policyStatus, nil
+}
+
+func newBlackDuckMock() *blackDuckMock {
+	return &blackDuckMock{
+		riskProfile: blackduck.RiskProfile{Categories: map[string]map[string]int{
+			"VULNERABILITY": {"CRITICAL": 1, "HIGH": 2, "MEDIUM": 3, "LOW": 4},
+			"LICENSE":       {"HIGH": 5, "LOW": 6},
+		}},
+		policyStatus: blackduck.PolicyStatus{
+			OverallStatus:                "IN_VIOLATION",
+			ComponentVersionStatusCounts: []blackduck.StatusCount{{Name: "IN_VIOLATION", Value: 7}},
+		},
+	}
+}
+
+func TestDetectResults(t *testing.T) {
+	dir, err := ioutil.TempDir("", "detectReport")
+	if err != nil {
+		t.Fatal("Failed to create temporary directory")
+	}
+	defer os.RemoveAll(dir)
+	jarPath := filepath.Join(dir, "detect.jar")
+	ioutil.WriteFile(jarPath, []byte("detect"), 0644)
+
+	myDetectExecuteScanOptions := detectExecuteScanOptions{
+		DetectJarPath:   jarPath,
+		Scanners:        []string{"signature"},
+		ScanPaths:       []string{"."},
+		ServerURL:       "https://blackduck.server",
+		ProjectName:     "testName",
+		ProjectVersion:  "1.0",
+		FailOn:          []string{"BLOCKER"},
+		ReportDirectory: filepath.Join(dir, "report"),
+	}
+
+	t.Run("results of policy violation", func(t *testing.T) {
+		resultsClient := newBlackDuckMock()
+		piperEnvironment := detectExecuteScanCommonPipelineEnvironment{}
+		influx := detectExecuteScanInflux{}
+
+		err := runDetect(myDetectExecuteScanOptions, &execMockRunner{shouldFailWith: detectExitError(3)}, &piperhttp.Client{}, resultsClient, &piperEnvironment, &influx, detectFileExists())
+
+		assert.EqualError(t, err, "detect scan found policy violations with severity BLOCKER in project 'testName' version '1.0'")
+		assert.Equal(t, "testName", resultsClient.projectName)
+		assert.Equal(t, "1.0", resultsClient.versionName)
+
+		assert.Equal(t, "https://blackduck.server/api/projects/1/versions/2/components", piperEnvironment.blackduck.projectVersionURL)
+		assert.Equal(t, "IN_VIOLATION", piperEnvironment.blackduck.policyStatus)
+		assert.Equal(t, "1", piperEnvironment.blackduck.criticalVulnerabilities)
+		assert.Equal(t, "4", piperEnvironment.blackduck.lowVulnerabilities)
+		assert.Equal(t, "5", piperEnvironment.blackduck.highLicenseRisks)
+		assert.Equal(t, "0", piperEnvironment.blackduck.mediumLicenseRisks)
+		assert.Equal(t, "2", influx.blackduck_data.fields.highVulnerabilities)
+		assert.Equal(t, "7", influx.blackduck_data.fields.policyViolations)
+		assert.Equal(t, "testName", influx.blackduck_data.tags.projectName)
+
+		jsonReport, err := ioutil.ReadFile(filepath.Join(dir, "report", "blackduck-report.json"))
+		if assert.NoError(t, err) {
+			report := detectReport{}
+			json.Unmarshal(jsonReport, &report)
+			assert.Equal(t, detectReport{
+				ProjectName:       "testName",
+				ProjectVersion:    "1.0",
+				ProjectVersionURL: "https://blackduck.server/api/projects/1/versions/2/components",
+				PolicyStatus:      "IN_VIOLATION",
+				PolicyViolations:  7,
+				Vulnerabilities:   detectRiskCounts{Critical: 1, High: 2, Medium: 3, Low: 4},
+				LicenseRisks:      detectRiskCounts{High: 5, Low: 6},
+			}, report)
+		}
+		htmlReport, err := ioutil.ReadFile(filepath.Join(dir, "report", "blackduck-report.html"))
+		if assert.NoError(t, err) {
+			assert.Contains(t, string(htmlReport), `<a href="https://blackduck.server/api/projects/1/versions/2/components">testName - 1.0</a>`)
+			assert.Contains(t, string(htmlReport), `<span class="violation">IN_VIOLATION</span> (7 components in violation)`)
+		}
+	})
+
+	t.Run("retrieval fails", func(t *testing.T) {
+		resultsClient := &blackDuckMock{err: fmt.Errorf("project 'testName' not found")}
+
+		err := runDetect(myDetectExecuteScanOptions, &execMockRunner{}, &piperhttp.Client{}, resultsClient, &detectExecuteScanCommonPipelineEnvironment{}, &detectExecuteScanInflux{}, detectFileExists())
+		assert.EqualError(t, err, "Failed to retrieve the Black Duck results: project 'testName' not found")
+
+		// the scan error takes precedence
+		err = runDetect(myDetectExecuteScanOptions, &execMockRunner{shouldFailWith: detectExitError(3)}, &piperhttp.Client{}, resultsClient, &detectExecuteScanCommonPipelineEnvironment{}, &detectExecuteScanInflux{}, detectFileExists())
+		assert.EqualError(t, err, "detect scan found policy violations with severity BLOCKER in project 'testName' version '1.0'")
+	})
+
+	t.Run("no results for other failures", func(t *testing.T) {
+		resultsClient := newBlackDuckMock()
+
+		err := runDetect(myDetectExecuteScanOptions, &execMockRunner{shouldFailWith: detectExitError(1)}, &piperhttp.Client{}, resultsClient, &detectExecuteScanCommonPipelineEnvironment{}, &detectExecuteScanInflux{}, detectFileExists())
+		assert.Contains(t, fmt.Sprint(err), "FAILURE_BLACKDUCK_CONNECTIVITY")
+		assert.Empty(t, resultsClient.projectName)
+	})
+}
@@ -1,23 +1,136 @@
 package cmd
 
 import (
+	"fmt"
 	"os"
+	"path/filepath"
 
 	"github.com/SAP/jenkins-library/pkg/config"
 	"github.com/SAP/jenkins-library/pkg/log"
-
+	"github.com/SAP/jenkins-library/pkg/piperenv"
 	"github.com/spf13/cobra"
 )
 
 type detectExecuteScanOptions struct {
-	APIToken       string   `json:"apiToken,omitempty"`
-	CodeLocation   string   `json:"codeLocation,omitempty"`
-	ProjectName    string   `json:"projectName,omitempty"`
-	ProjectVersion string   `json:"projectVersion,omitempty"`
-	Scanners       []string `json:"scanners,omitempty"`
-	ScanPaths      []string `json:"scanPaths,omitempty"`
-	ScanProperties []string `json:"scanProperties,omitempty"`
-	ServerURL      string   `json:"serverUrl,omitempty"`
+	APIToken                      string   `json:"apiToken,omitempty"`
+	BinaryScanPath                string   `json:"binaryScanPath,omitempty"`
+	CodeLocation                  string   `json:"codeLocation,omitempty"`
+	DetectAllowUnverifiedDownload bool     `json:"detectAllowUnverifiedDownload,omitempty"`
+	DetectCacheDir                string   `json:"detectCacheDir,omitempty"`
+	DetectChecksum                string   `json:"detectChecksum,omitempty"`
+	DetectJarPath                 string   `json:"detectJarPath,omitempty"`
+	DetectJarURL                  string   `json:"detectJarUrl,omitempty"`
+	DetectScriptPath              string   `json:"detectScriptPath,omitempty"`
+	DetectScriptURL               string   `json:"detectScriptUrl,omitempty"`
+	DetectVersion                 string   `json:"detectVersion,omitempty"`
+	DetectorTypes                 []string `json:"detectorTypes,omitempty"`
+	ExcludedDirectories           []string `json:"excludedDirectories,omitempty"`
+	FailOn                        []string `json:"failOn,omitempty"`
+	ProjectName                   string   `json:"projectName,omitempty"`
+	ProjectVersion                string   `json:"projectVersion,omitempty"`
+	ReportDirectory               string   `json:"reportDirectory,omitempty"`
+	ScanDockerImage               string   `json:"scanDockerImage,omitempty"`
+	Scanners                      []string `json:"scanners,omitempty"`
+	ScanPaths                     []string `json:"scanPaths,omitempty"`
+	ScanProperties                []string `json:"scanProperties,omitempty"`
+	ServerURL                     string   `json:"serverUrl,omitempty"`
+}
+
+type detectExecuteScanCommonPipelineEnvironment struct {
+	blackduck struct {
+		projectVersionURL       string
+		policyStatus            string
+		criticalVulnerabilities string
+		highVulnerabilities     string
+		mediumVulnerabilities   string
+		lowVulnerabilities      string
+		highLicenseRisks        string
+		mediumLicenseRisks      string
+		lowLicenseRisks         string
+	}
+}
+
+func (p *detectExecuteScanCommonPipelineEnvironment) persist(path, resourceName string) {
+	content := []struct {
+		category string
+		name     string
+		value    string
+	}{
+		{category: "blackduck", name: "projectVersionUrl", value: p.blackduck.projectVersionURL},
+		{category: "blackduck", name: "policyStatus", value: p.blackduck.policyStatus},
+		{category: "blackduck", name: "criticalVulnerabilities", value: p.blackduck.criticalVulnerabilities},
+		{category: "blackduck", name: "highVulnerabilities", value: p.blackduck.highVulnerabilities},
+		{category: "blackduck", name: "mediumVulnerabilities", value: p.blackduck.mediumVulnerabilities},
+		{category: "blackduck", name: "lowVulnerabilities", value: p.blackduck.lowVulnerabilities},
+		{category: "blackduck", name: "highLicenseRisks", value: p.blackduck.highLicenseRisks},
+		{category: "blackduck", name: "mediumLicenseRisks", value: p.blackduck.mediumLicenseRisks},
+		{category: "blackduck", name: "lowLicenseRisks", value: p.blackduck.lowLicenseRisks},
+	}
+
+	errCount := 0
+	for _, param := range content {
+		err := piperenv.SetResourceParameter(path, resourceName, filepath.Join(param.category, param.name), param.value)
+		if err != nil {
+			log.Entry().WithError(err).Error("Error persisting piper environment.")
+			errCount++
+		}
+	}
+	if errCount > 0 {
+		os.Exit(1)
+	}
+}
+
+type detectExecuteScanInflux struct {
+	blackduck_data struct {
+		fields struct {
+			criticalVulnerabilities string
+			highVulnerabilities     string
+			mediumVulnerabilities   string
+			lowVulnerabilities      string
+			highLicenseRisks        string
+			mediumLicenseRisks      string
+			lowLicenseRisks         string
+			policyViolations        string
+		}
+		tags struct {
+			projectName    string
+			projectVersion string
+			policyStatus   string
+		}
+	}
+}
+
+func (i *detectExecuteScanInflux) persist(path, resourceName string) {
+	measurementContent := []struct {
+		measurement string
+		valType     string
+		name        string
+		value       string
+	}{
+		{valType: config.InfluxField, measurement: "blackduck_data", name: "criticalVulnerabilities", value: i.blackduck_data.fields.criticalVulnerabilities},
+		{valType: config.InfluxField, measurement: "blackduck_data", name: "highVulnerabilities", value: i.blackduck_data.fields.highVulnerabilities},
+		{valType: config.InfluxField, measurement: "blackduck_data", name: "mediumVulnerabilities", value: i.blackduck_data.fields.mediumVulnerabilities},
+		{valType: config.InfluxField, measurement: "blackduck_data", name: "lowVulnerabilities", value: i.blackduck_data.fields.lowVulnerabilities},
+		{valType: config.InfluxField, measurement: "blackduck_data", name: "highLicenseRisks", value: i.blackduck_data.fields.highLicenseRisks},
+		{valType: config.InfluxField, measurement: "blackduck_data", name: "mediumLicenseRisks", value: i.blackduck_data.fields.mediumLicenseRisks},
+		{valType: config.InfluxField, measurement: "blackduck_data", name: "lowLicenseRisks", value: i.blackduck_data.fields.lowLicenseRisks},
+		{valType: config.InfluxField, measurement: "blackduck_data", name: "policyViolations", value: i.blackduck_data.fields.policyViolations},
+		{valType: config.InfluxTag, measurement: "blackduck_data", name: "projectName", value: i.blackduck_data.tags.projectName},
+		{valType: config.InfluxTag, measurement: "blackduck_data", name: "projectVersion", value: i.blackduck_data.tags.projectVersion},
+		{valType: config.InfluxTag, measurement: "blackduck_data", name: "policyStatus", value: i.blackduck_data.tags.policyStatus},
+	}
+
+	errCount := 0
+	for _, metric := range measurementContent {
+		err := piperenv.SetResourceParameter(path, resourceName, filepath.Join(metric.measurement, fmt.Sprintf("%vs", metric.valType), metric.name), metric.value)
+		if err != nil {
+			log.Entry().WithError(err).Error("Error persisting influx environment.")
+			errCount++
+		}
+	}
+	if errCount > 0 {
+		os.Exit(1)
+	}
 }
 
 var myDetectExecuteScanOptions detectExecuteScanOptions
@@ -25,6 +138,8 @@ var myDetectExecuteScanOptions detectExecuteScanOptions
 // DetectExecuteScanCommand Executes Synopsis Detect scan
 func DetectExecuteScanCommand() *cobra.Command {
 	metadata := detectExecuteScanMetadata()
+	var commonPipelineEnvironment detectExecuteScanCommonPipelineEnvironment
+	var influx detectExecuteScanInflux
 
 	var createDetectExecuteScanCmd = &cobra.Command{
 		Use:   "detectExecuteScan",
@@ -36,8 +151,13 @@ func DetectExecuteScanCommand() *cobra.Command {
 			return PrepareConfig(cmd, &metadata, "detectExecuteScan", &myDetectExecuteScanOptions, config.OpenPiperFile)
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-
-			return detectExecuteScan(myDetectExecuteScanOptions)
+			handler := func() {
+				commonPipelineEnvironment.persist(GeneralConfig.EnvRootPath, "commonPipelineEnvironment")
+				influx.persist(GeneralConfig.EnvRootPath, "influx")
+			}
+			log.DeferExitHandler(handler)
+			defer handler()
+			return detectExecuteScan(myDetectExecuteScanOptions, &commonPipelineEnvironment, &influx)
 		},
 	}
 
@@ -47,12 +167,26 @@ func DetectExecuteScanCommand() *cobra.Command {
 
 func addDetectExecuteScanFlags(cmd *cobra.Command) {
 	cmd.Flags().StringVar(&myDetectExecuteScanOptions.APIToken, "apiToken", os.Getenv("PIPER_apiToken"), "Api token to be used for connectivity with Synopsis Detect server.")
+	cmd.Flags().StringVar(&myDetectExecuteScanOptions.BinaryScanPath, "binaryScanPath", os.Getenv("PIPER_binaryScanPath"), "Only relevant for scanner `binary`. Path to the binary file which is uploaded to the Black Duck binary scanner.")
 	cmd.Flags().StringVar(&myDetectExecuteScanOptions.CodeLocation, "codeLocation", os.Getenv("PIPER_codeLocation"), "An override for the name Detect will use for the scan file it creates.")
+	cmd.Flags().BoolVar(&myDetectExecuteScanOptions.DetectAllowUnverifiedDownload, "detectAllowUnverifiedDownload", false, "Allows to execute the latest Synopsis Detect script downloaded from `detectScriptUrl` without verification. Otherwise either `detectChecksum` or `detectVersion` is required in case no local jar or script is provided.")
+	cmd.Flags().StringVar(&myDetectExecuteScanOptions.DetectCacheDir, "detectCacheDir", ".pipeline/detect", "Directory used to cache the downloaded Synopsis Detect jar or script. Cached files are reused in case their checksum matches `detectChecksum`.")
+	cmd.Flags().StringVar(&myDetectExecuteScanOptions.DetectChecksum, "detectChecksum", os.Getenv("PIPER_detectChecksum"), "SHA-256 checksum of the Synopsis Detect jar or script. The scan is not executed in case the checksum does not match.")
+	cmd.Flags().StringVar(&myDetectExecuteScanOptions.DetectJarPath, "detectJarPath", os.Getenv("PIPER_detectJarPath"), "Path to a locally provided Synopsis Detect jar, e.g. for agents without internet access. The jar is executed using `java -jar`.")
+	cmd.Flags().StringVar(&myDetectExecuteScanOptions.DetectJarURL, "detectJarUrl", "https://sig-repo.synopsys.com/bds-integrations-release/com/synopsys/integration/synopsys-detect/{version}/synopsys-detect-{version}.jar", "Download url of the Synopsis Detect jar in case `detectVersion` is pinned. The placeholder `{version}` is replaced with the version.")
+	cmd.Flags().StringVar(&myDetectExecuteScanOptions.DetectScriptPath, "detectScriptPath", os.Getenv("PIPER_detectScriptPath"), "Path to a locally provided Synopsis Detect script (detect.sh).")
+	cmd.Flags().StringVar(&myDetectExecuteScanOptions.DetectScriptURL, "detectScriptUrl", "https://detect.synopsys.com/detect.sh", "Download url of the Synopsis Detect script which is used in case neither a local jar or script nor a version is configured.")
+	cmd.Flags().StringVar(&myDetectExecuteScanOptions.DetectVersion, "detectVersion", os.Getenv("PIPER_detectVersion"), "Pinned version of Synopsis Detect, e.g. `6.0.0`. Without a pinned version the latest script is used, which requires either `detectChecksum` or `detectAllowUnverifiedDownload`.")
+	cmd.Flags().StringSliceVar(&myDetectExecuteScanOptions.DetectorTypes, "detectorTypes", []string{}, "Only relevant for scanner `source`. Detector types used for the source scan, e.g. `MAVEN`. By default the detector types are derived from the build descriptors found in the scan path (`pom.xml`: `MAVEN`, `package.json`: `NPM`, `go.mod`: `GO_MOD`).")
+	cmd.Flags().StringSliceVar(&myDetectExecuteScanOptions.ExcludedDirectories, "excludedDirectories", []string{}, "List of directories which are excluded from all scans, e.g. `node_modules`.")
+	cmd.Flags().StringSliceVar(&myDetectExecuteScanOptions.FailOn, "failOn", []string{"BLOCKER", "CRITICAL", "MAJOR"}, "Severities of policy violations which let the scan fail. Values: 'ALL', 'BLOCKER', 'CRITICAL', 'MAJOR', 'MINOR', 'TRIVIAL', 'NONE'")
 	cmd.Flags().StringVar(&myDetectExecuteScanOptions.ProjectName, "projectName", os.Getenv("PIPER_projectName"), "Name of the Synopsis Detect (formerly BlackDuck) project.")
 	cmd.Flags().StringVar(&myDetectExecuteScanOptions.ProjectVersion, "projectVersion", os.Getenv("PIPER_projectVersion"), "Version of the Synopsis Detect (formerly BlackDuck) project.")
-	cmd.Flags().StringSliceVar(&myDetectExecuteScanOptions.Scanners, "scanners", []string{"signature"}, "List of scanners to be used for Synopsis Detect (formerly BlackDuck) scan.")
-	cmd.Flags().StringSliceVar(&myDetectExecuteScanOptions.ScanPaths, "scanPaths", []string{"."}, "List of paths which should be scanned by the Synopsis Detect (formerly BlackDuck) scan.")
-	cmd.Flags().StringSliceVar(&myDetectExecuteScanOptions.ScanProperties, "scanProperties", []string{"--blackduck.signature.scanner.memory=4096", "--blackduck.timeout=6000", "--blackduck.trust.cert=true", "--detect.policy.check.fail.on.severities=BLOCKER,CRITICAL,MAJOR", "--detect.report.timeout=4800", "--logging.level.com.synopsys.integration=DEBUG"}, "Properties passed to the Synopsis Detect (formerly BlackDuck) scan. You can find details in the [Synopsis Detect documentation](https://synopsys.atlassian.net/wiki/spaces/INTDOCS/pages/622846/Using+Synopsys+Detect+Properties)")
+	cmd.Flags().StringVar(&myDetectExecuteScanOptions.ReportDirectory, "reportDirectory", "blackduck", "Directory the Black Duck report (`blackduck-report.html` and `blackduck-report.json`) is written to after the scan.")
+	cmd.Flags().StringVar(&myDetectExecuteScanOptions.ScanDockerImage, "scanDockerImage", os.Getenv("PIPER_scanDockerImage"), "Only relevant for scanner `docker`. The docker image which is scanned, e.g. `alpine:3.10`.")
+	cmd.Flags().StringSliceVar(&myDetectExecuteScanOptions.Scanners, "scanners", []string{"signature", "source"}, "List of scanners to be used for Synopsis Detect (formerly BlackDuck) scan. Values: 'signature', 'source', 'binary', 'docker', 'impactAnalysis'")
+	cmd.Flags().StringSliceVar(&myDetectExecuteScanOptions.ScanPaths, "scanPaths", []string{"."}, "List of paths which should be scanned by the Synopsis Detect (formerly BlackDuck) scan. The signature scanner scans all paths at once while the source scanner scans each path separately.")
+	cmd.Flags().StringSliceVar(&myDetectExecuteScanOptions.ScanProperties, "scanProperties", []string{"--blackduck.signature.scanner.memory=4096", "--blackduck.timeout=6000", "--blackduck.trust.cert=true", "--detect.report.timeout=4800", "--logging.level.com.synopsys.integration=DEBUG"}, "Properties passed to the Synopsis Detect (formerly BlackDuck) scan. You can find details in the [Synopsis Detect documentation](https://synopsys.atlassian.net/wiki/spaces/INTDOCS/pages/622846/Using+Synopsys+Detect+Properties)")
 	cmd.Flags().StringVar(&myDetectExecuteScanOptions.ServerURL, "serverUrl", os.Getenv("PIPER_serverUrl"), "Server url to the Synopsis Detect (formerly BlackDuck) Server.")
 
 	cmd.MarkFlagRequired("apiToken")
@@ -74,6 +208,14 @@ func detectExecuteScanMetadata() config.StepData {
 						Mandatory:   true,
 						Aliases:     []config.Alias{{Name: "detect/apiToken"}},
 					},
+					{
+						Name:        "binaryScanPath",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{{Name: "detect/binaryScanPath"}},
+					},
 					{
 						Name:        "codeLocation",
 						ResourceRef: []config.ResourceReference{},
@@ -82,6 +224,94 @@ func detectExecuteScanMetadata() config.StepData {
 						Mandatory:   false,
 						Aliases:     []config.Alias{},
 					},
+					{
+						Name:        "detectAllowUnverifiedDownload",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "bool",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "detectCacheDir",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "detectChecksum",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{{Name: "detect/checksum"}},
+					},
+					{
+						Name:        "detectJarPath",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{{Name: "detect/jarPath"}},
+					},
+					{
+						Name:        "detectJarUrl",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "detectScriptPath",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{{Name: "detect/scriptPath"}},
+					},
+					{
+						Name:        "detectScriptUrl",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "detectVersion",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{{Name: "detect/version"}},
+					},
+					{
+						Name:        "detectorTypes",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "[]string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{{Name: "detect/detectorTypes"}},
+					},
+					{
+						Name:        "excludedDirectories",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "[]string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{{Name: "detect/excludedDirectories"}},
+					},
+					{
+						Name:        "failOn",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "[]string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{{Name: "detect/failOn"}},
+					},
 					{
 						Name:        "projectName",
 						ResourceRef: []config.ResourceReference{},
@@ -98,6 +328,22 @@ func detectExecuteScanMetadata() config.StepData {
 						Mandatory:   true,
 						Aliases:     []config.Alias{{Name: "detect/projectVersion"}},
 					},
+					{
+						Name:        "reportDirectory",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "scanDockerImage",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{{Name: "detect/dockerImage"}},
+					},
 					{
 						Name:        "scanners",
 						ResourceRef: []config.ResourceReference{},
@@ -2,39 +2,113 @@ package cmd
 
 import (
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
-	"github.com/SAP/jenkins-library/pkg/log"
+	piperhttp "github.com/SAP/jenkins-library/pkg/http"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestRunDetect(t *testing.T) {
 
+	dir, err := ioutil.TempDir("", "detectTest")
+	if err != nil {
+		t.Fatal("Failed to create temporary directory")
+	}
+	defer os.RemoveAll(dir)
+	jarPath := filepath.Join(dir, "detect.jar")
+	if err := ioutil.WriteFile(jarPath, []byte("detect"), 0644); err != nil {
+		t.Fatal("Failed to create detect jar")
+	}
+
 	t.Run("success case", func(t *testing.T) {
-		s := shellMockRunner{}
-		runDetect(detectExecuteScanOptions{}, &s)
+		e := execMockRunner{}
+		err := runDetect(detectExecuteScanOptions{DetectJarPath: jarPath, APIToken: "apiToken", Scanners: []string{"signature"}, ScanPaths: []string{"."}, ScanProperties: []string{"--detect.excluded.directories=a b"}}, &e, &piperhttp.Client{}, &blackDuckMock{}, &detectExecuteScanCommonPipelineEnvironment{}, &detectExecuteScanInflux{}, detectFileExists())
 
-		assert.Equal(t, ".", s.dir, "Wrong execution directory used")
-		assert.Equal(t, "/bin/bash", s.shell[0], "Bash shell expected")
-		expectedScript := "bash <(curl -s https://detect.synopsys.com/detect.sh) --blackduck.url= --blackduck.api.token= --detect.project.name= --detect.project.version.name= --detect.code.location.name="
-		assert.Equal(t, expectedScript, s.calls[0])
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"."}, e.dir, "Wrong execution directory used")
+		assert.Equal(t, "java", e.calls[0].exec)
+		assert.Equal(t, []string{"-jar", jarPath, "--detect.excluded.directories=a b", "--blackduck.url=", "--detect.project.name=", "--detect.project.version.name=", "--detect.code.location.name=", "--detect.tools=SIGNATURE_SCAN", "--detect.blackduck.signature.scanner.paths=."}, e.calls[0].params)
+		// the token is provided via the environment only
+		assert.Contains(t, e.env, "BLACKDUCK_API_TOKEN=apiToken")
+		assert.NotContains(t, strings.Join(e.calls[0].params, " "), "apiToken")
 	})
 
 	t.Run("failure case", func(t *testing.T) {
-		var hasFailed bool
-		log.Entry().Logger.ExitFunc = func(int) { hasFailed = true }
+		e := execMockRunner{shouldFailWith: fmt.Errorf("Test Error")}
+		err := runDetect(detectExecuteScanOptions{DetectJarPath: jarPath, Scanners: []string{"signature"}, ScanPaths: []string{"."}}, &e, &piperhttp.Client{}, &blackDuckMock{}, &detectExecuteScanCommonPipelineEnvironment{}, &detectExecuteScanInflux{}, detectFileExists())
+		assert.EqualError(t, err, "failed to execute detect scan: Test Error")
+	})
 
-		s := shellMockRunner{shouldFailWith: fmt.Errorf("Test Error")}
-		runDetect(detectExecuteScanOptions{}, &s)
-		assert.True(t, hasFailed, "expected command to exit with fatal")
+	t.Run("policy violation", func(t *testing.T) {
+		e := execMockRunner{shouldFailWith: detectExitError(3)}
+		err := runDetect(detectExecuteScanOptions{DetectJarPath: jarPath, Scanners: []string{"signature"}, ScanPaths: []string{"."}, FailOn: []string{"BLOCKER", "CRITICAL"}, ProjectName: "testName", ProjectVersion: "1.0"}, &e, &piperhttp.Client{}, &blackDuckMock{}, &detectExecuteScanCommonPipelineEnvironment{}, &detectExecuteScanInflux{}, detectFileExists())
+		assert.EqualError(t, err, "detect scan found policy violations with severity BLOCKER, CRITICAL in project 'testName' version '1.0'")
 	})
+
+	t.Run("invalid failOn", func(t *testing.T) {
+		e := execMockRunner{}
+		err := runDetect(detectExecuteScanOptions{DetectJarPath: jarPath, Scanners: []string{"signature"}, ScanPaths: []string{"."}, FailOn: []string{"BLOCKER", "HIGH"}}, &e, &piperhttp.Client{}, &blackDuckMock{}, &detectExecuteScanCommonPipelineEnvironment{}, &detectExecuteScanInflux{}, detectFileExists())
+		assert.EqualError(t, err, "Invalid failOn severity 'HIGH'. Supported values: 'ALL', 'BLOCKER', 'CRITICAL', 'MAJOR', 'MINOR', 'TRIVIAL', 'NONE'")
+		assert.Empty(t, e.calls)
+	})
+
+	t.Run("checksum mismatch", func(t *testing.T) {
+		e := execMockRunner{}
+		err := runDetect(detectExecuteScanOptions{DetectJarPath: jarPath, Scanners: []string{"signature"}, ScanPaths: []string{"."}, DetectChecksum: "abc"}, &e, &piperhttp.Client{}, &blackDuckMock{}, &detectExecuteScanCommonPipelineEnvironment{}, &detectExecuteScanInflux{}, detectFileExists())
+		assert.Contains(t, fmt.Sprint(err), "does not match the expected checksum 'abc'")
+		assert.Empty(t, e.calls)
+	})
+}
+
+type detectExitError int
+
+func (e detectExitError) Error() string {
+	return fmt.Sprintf("exit status %v", int(e))
+}
+
+func (e detectExitError) ExitCode() int {
+	return int(e)
+}
+
+func TestDetectError(t *testing.T) {
+	testData := []struct {
+		err      error
+		expected string
+	}{
+		{err: detectExitError(1), expected: "detect scan failed with exit code 1 (FAILURE_BLACKDUCK_CONNECTIVITY): Detect was unable to connect to the Black Duck server"},
+		{err: detectExitError(2), expected: "detect scan failed with exit code 2 (FAILURE_TIMEOUT): Detect timed out while waiting for the results of the scan"},
+		{err: detectExitError(3), expected: "detect scan found policy violations with severity MAJOR in project 'testName' version '1.0'"},
+		{err: detectExitError(7), expected: "detect scan failed with exit code 7 (FAILURE_CONFIGURATION): Detect is not configured correctly"},
+		{err: detectExitError(42), expected: "detect scan failed with exit code 42 (FAILURE_UNKNOWN_ERROR): Detect encountered an unknown error"},
+		{err: errors.Wrap(detectExitError(6), "wrapped"), expected: "detect scan failed with exit code 6 (FAILURE_SCAN): Detect failed to run a scan"},
+	}
+
+	for _, v := range testData {
+		t.Run(v.err.Error(), func(t *testing.T) {
+			err := detectError(v.err, detectExecuteScanOptions{FailOn: []string{"MAJOR"}, ProjectName: "testName", ProjectVersion: "1.0"})
+			assert.EqualError(t, err, v.expected)
+		})
+	}
+}
+
+func detectFileExists(files ...string) func(string) (bool, error) {
+	return func(path string) (bool, error) {
+		return sliceContains(files, filepath.ToSlash(path)), nil
+	}
 }
 
 func TestAddDetectArgs(t *testing.T) {
 	testData := []struct {
-		args     []string
-		options  detectExecuteScanOptions
-		expected []string
+		args       []string
+		options    detectExecuteScanOptions
+		run        detectRun
+		fileExists func(string) (bool, error)
+		expected   []string
 	}{
 		{
 			args: []string{"--testProp1=1"},
@@ -44,19 +118,20 @@ func TestAddDetectArgs(t *testing.T) {
 				APIToken:       "apiToken",
 				ProjectName:    "testName",
 				ProjectVersion: "1.0",
-				CodeLocation:   "",
-				Scanners:       []string{"signature"},
 				ScanPaths:      []string{"path1", "path2"},
+				FailOn:         []string{"BLOCKER", "CRITICAL"},
 			},
+			run: detectRun{scanners: []string{"signature"}, codeLocation: "testName/1.0"},
 			expected: []string{
 				"--testProp1=1",
 				"--scan1=1",
 				"--scan2=2",
 				"--blackduck.url=https://server.url",
-				"--blackduck.api.token=apiToken",
 				"--detect.project.name=testName",
 				"--detect.project.version.name=1.0",
 				"--detect.code.location.name=testName/1.0",
+				"--detect.policy.check.fail.on.severities=BLOCKER,CRITICAL",
+				"--detect.tools=SIGNATURE_SCAN",
 				"--detect.blackduck.signature.scanner.paths=path1,path2",
 			},
 		},
@@ -67,26 +142,166 @@ func TestAddDetectArgs(t *testing.T) {
 				APIToken:       "apiToken",
 				ProjectName:    "testName",
 				ProjectVersion: "1.0",
-				CodeLocation:   "testLocation",
-				Scanners:       []string{"source"},
 				ScanPaths:      []string{"path1", "path2"},
 			},
+			run:        detectRun{scanners: []string{"source"}, sourcePath: "path1", codeLocation: "testLocation"},
+			fileExists: detectFileExists("path1/pom.xml", "path1/package.json", "path2/go.mod"),
 			expected: []string{
 				"--testProp1=1",
 				"--blackduck.url=https://server.url",
-				"--blackduck.api.token=apiToken",
 				"--detect.project.name=testName",
 				"--detect.project.version.name=1.0",
 				"--detect.code.location.name=testLocation",
+				"--detect.tools=DETECTOR",
 				"--detect.source.path=path1",
+				"--detect.included.detector.types=MAVEN,NPM",
+			},
+		},
+		{
+			args: []string{},
+			options: detectExecuteScanOptions{
+				ScanProperties:      []string{"--detect.policy.check.fail.on.severities=ALL", "--detect.tools=ALL"},
+				ServerURL:           "https://server.url",
+				APIToken:            "apiToken",
+				ProjectName:         "testName",
+				ProjectVersion:      "1.0",
+				FailOn:              []string{"BLOCKER"},
+				ScanPaths:           []string{"."},
+				DetectorTypes:       []string{"GRADLE"},
+				ExcludedDirectories: []string{"node_modules", "test"},
+				BinaryScanPath:      "app.jar",
+				ScanDockerImage:     "alpine:3.10",
+			},
+			run:        detectRun{scanners: []string{"signature", "source", "binary", "docker", "impactAnalysis"}, sourcePath: ".", codeLocation: "testName/1.0"},
+			fileExists: detectFileExists("pom.xml"),
+			expected: []string{
+				"--detect.policy.check.fail.on.severities=ALL",
+				"--detect.tools=ALL",
+				"--blackduck.url=https://server.url",
+				"--detect.project.name=testName",
+				"--detect.project.version.name=1.0",
+				"--detect.code.location.name=testName/1.0",
+				"--detect.excluded.directories=node_modules,test",
+				"--detect.blackduck.signature.scanner.paths=.",
+				"--detect.blackduck.signature.scanner.exclusion.name.patterns=node_modules,test",
+				"--detect.source.path=.",
+				"--detect.included.detector.types=GRADLE",
+				"--detect.binary.scan.file.path=app.jar",
+				"--detect.docker.image=alpine:3.10",
+				"--detect.impact.analysis.enabled=true",
 			},
 		},
 	}
 
 	for k, v := range testData {
 		t.Run(fmt.Sprintf("run %v", k), func(t *testing.T) {
-			got := addDetectArgs(v.args, v.options)
+			fileExists := v.fileExists
+			if fileExists == nil {
+				fileExists = detectFileExists()
+			}
+			got := addDetectArgs(v.args, v.options, v.run, fileExists)
 			assert.Equal(t, v.expected, got)
 		})
 	}
 }
+
+func TestGetDetectRuns(t *testing.T) {
+	t.Run("multiple source paths", func(t *testing.T) {
+		runs := getDetectRuns(detectExecuteScanOptions{
+			ProjectName:    "testName",
+			ProjectVersion: "1.0",
+			Scanners:       []string{"signature", "source"},
+			ScanPaths:      []string{"frontend", "./backend/"},
+		})
+		assert.Equal(t, []detectRun{
+			{scanners: []string{"signature", "source"}, sourcePath: "frontend", codeLocation: "testName/1.0"},
+			{scanners: []string{"source"}, sourcePath: "./backend/", codeLocation: "testName/1.0/backend"},
+		}, runs)
+	})
+
+	t.Run("without source scanner", func(t *testing.T) {
+		runs := getDetectRuns(detectExecuteScanOptions{
+			CodeLocation: "testLocation",
+			Scanners:     []string{"signature", "docker"},
+			ScanPaths:    []string{"frontend", "backend"},
+		})
+		assert.Equal(t, []detectRun{{scanners: []string{"signature", "docker"}, codeLocation: "testLocation"}}, runs)
+	})
+}
+
+func TestRunDetectMultipleSourcePaths(t *testing.T) {
+	dir, err := ioutil.TempDir("", "detectTest")
+	if err != nil {
+		t.Fatal("Failed to create temporary directory")
+	}
+	defer os.RemoveAll(dir)
+	jarPath := filepath.Join(dir, "detect.jar")
+	ioutil.WriteFile(jarPath, []byte("detect"), 0644)
+
+	myDetectExecuteScanOptions := detectExecuteScanOptions{
+		DetectJarPath:  jarPath,
+		ProjectName:    "testName",
+		ProjectVersion: "1.0",
+		Scanners:       []string{"source"},
+		ScanPaths:      []string{"frontend", "backend"},
+		FailOn:         []string{"BLOCKER"},
+	}
+
+	t.Run("all paths scanned despite policy violations", func(t *testing.T) {
+		e := execMockRunner{shouldFailOnCommand: map[string]error{"java -jar " + jarPath + " --blackduck.url= --detect.project.name=testName --detect.project.version.name=1.0 --detect.code.location.name=testName/1.0 ": detectExitError(3)}}
+
+		err := runDetect(myDetectExecuteScanOptions, &e, &piperhttp.Client{}, &blackDuckMock{}, &detectExecuteScanCommonPipelineEnvironment{}, &detectExecuteScanInflux{}, detectFileExists("backend/go.mod"))
+
+		assert.EqualError(t, err, "detect scan found policy violations with severity BLOCKER in project 'testName' version '1.0'")
+		if assert.Len(t, e.calls, 2) {
+			assert.Contains(t, e.calls[0].params, "--detect.source.path=frontend")
+			assert.Equal(t, []string{"-jar", jarPath,
+				"--blackduck.url=",
+				"--detect.project.name=testName",
+				"--detect.project.version.name=1.0",
+				"--detect.code.location.name=testName/1.0/backend",
+				"--detect.policy.check.fail.on.severities=BLOCKER",
+				"--detect.tools=DETECTOR",
+				"--detect.source.path=backend",
+				"--detect.included.detector.types=GO_MOD",
+			}, e.calls[1].params)
+		}
+	})
+
+	t.Run("stops on other failures", func(t *testing.T) {
+		e := execMockRunner{shouldFailOnCommand: map[string]error{"java": detectExitError(7)}}
+
+		err := runDetect(myDetectExecuteScanOptions, &e, &piperhttp.Client{}, &blackDuckMock{}, &detectExecuteScanCommonPipelineEnvironment{}, &detectExecuteScanInflux{}, detectFileExists())
+
+		assert.Contains(t, fmt.Sprint(err), "FAILURE_CONFIGURATION")
+		assert.Len(t, e.calls, 1)
+	})
+}
+
+func TestValidateDetectOptions(t *testing.T) {
+	testData := []struct {
+		options  detectExecuteScanOptions
+		expected string
+	}{
+		{options: detectExecuteScanOptions{Scanners: []string{"signature", "source"}, ScanPaths: []string{"."}, FailOn: []string{"MAJOR"}}},
+		{options: detectExecuteScanOptions{Scanners: []string{"docker", "impactAnalysis"}, ScanDockerImage: "alpine"}},
+		{options: detectExecuteScanOptions{}, expected: "No scanners configured. Supported values: 'signature', 'source', 'binary', 'docker', 'impactAnalysis'"},
+		{options: detectExecuteScanOptions{Scanners: []string{"dependency"}}, expected: "Invalid scanner 'dependency'. Supported values: 'signature', 'source', 'binary', 'docker', 'impactAnalysis'"},
+		{options: detectExecuteScanOptions{Scanners: []string{"source"}}, expected: "No scanPaths configured, which are required for the scanners 'signature' and 'source'"},
+		{options: detectExecuteScanOptions{Scanners: []string{"signature"}, ScanPaths: []string{"a", " "}}, expected: "Invalid empty entry in scanPaths"},
+		{options: detectExecuteScanOptions{Scanners: []string{"binary"}}, expected: "No binaryScanPath configured, which is required for the scanner 'binary'"},
+		{options: detectExecuteScanOptions{Scanners: []string{"docker"}}, expected: "No scanDockerImage configured, which is required for the scanner 'docker'"},
+		{options: detectExecuteScanOptions{Scanners: []string{"binary"}, BinaryScanPath: "app.jar", FailOn: []string{"HIGH"}}, expected: "Invalid failOn severity 'HIGH'. Supported values: 'ALL', 'BLOCKER', 'CRITICAL', 'MAJOR', 'MINOR', 'TRIVIAL', 'NONE'"},
+	}
+
+	for k, v := range testData {
+		t.Run(fmt.Sprintf("run %v", k), func(t *testing.T) {
+			err := validateDetectOptions(v.options)
+			if len(v.expected) == 0 {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, v.expected)
+			}
+		})
+	}
+}
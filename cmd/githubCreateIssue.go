@@ -0,0 +1,193 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/SAP/jenkins-library/pkg/log"
+	"github.com/SAP/jenkins-library/pkg/piperenv"
+	"github.com/google/go-github/v28/github"
+	"github.com/pkg/errors"
+
+	piperGithub "github.com/SAP/jenkins-library/pkg/github"
+)
+
+// the fingerprint is stored as HTML comment in the issue body, hence it is not visible
+const issueFingerprintFormat = "<!-- piper-issue-fingerprint: %v -->"
+
+var issueFingerprintPattern = regexp.MustCompile(`<!-- piper-issue-fingerprint: (\S+) -->`)
+
+type issueTemplateData struct {
+	Title       string
+	Fingerprint string
+}
+
+func githubCreateIssue(myGithubCreateIssueOptions githubCreateIssueOptions) error {
+	auth := piperGithub.Authentication{
+		Token:          myGithubCreateIssueOptions.Token,
+		AppID:          myGithubCreateIssueOptions.AppID,
+		InstallationID: myGithubCreateIssueOptions.InstallationID,
+		PrivateKey:     myGithubCreateIssueOptions.PrivateKey,
+	}
+	ctx, client, err := piperGithub.NewAuthenticatedClient(auth, myGithubCreateIssueOptions.APIURL, "")
+	if err != nil {
+		log.Entry().WithError(err).Fatal("Failed to get GitHub client")
+	}
+
+	err = runGithubCreateIssue(ctx, &myGithubCreateIssueOptions, client.Issues, ioutil.ReadFile, GeneralConfig.EnvRootPath)
+	if err != nil {
+		log.Entry().WithError(err).Fatal("Failed to create GitHub issue")
+	}
+
+	return nil
+}
+
+func runGithubCreateIssue(ctx context.Context, myGithubCreateIssueOptions *githubCreateIssueOptions, ghIssueClient githubIssueClient, readFile func(string) ([]byte, error), envRootPath string) error {
+	fingerprint := myGithubCreateIssueOptions.Fingerprint
+	if len(fingerprint) == 0 {
+		fingerprint = fmt.Sprintf("%x", sha256.Sum256([]byte(myGithubCreateIssueOptions.Title)))[:16]
+	}
+	if strings.ContainsAny(fingerprint, " \t\r\n") {
+		return fmt.Errorf("Invalid fingerprint '%v', whitespace is not allowed", fingerprint)
+	}
+	data := issueTemplateData{Title: myGithubCreateIssueOptions.Title, Fingerprint: fingerprint}
+
+	issue, err := findIssueByFingerprint(ctx, fingerprint, myGithubCreateIssueOptions, ghIssueClient)
+	if err != nil {
+		return err
+	}
+
+	if myGithubCreateIssueOptions.Resolved {
+		if issue == nil {
+			log.Entry().Infof("No open issue with fingerprint '%v' found, nothing to resolve", fingerprint)
+			return nil
+		}
+		comment, err := renderIssueTemplate("resolvedComment", myGithubCreateIssueOptions.ResolvedComment, data, envRootPath)
+		if err != nil {
+			return err
+		}
+		return closeIssue(ctx, issue, comment, myGithubCreateIssueOptions, ghIssueClient)
+	}
+
+	bodyTemplate := myGithubCreateIssueOptions.Body
+	if len(myGithubCreateIssueOptions.BodyFilePath) > 0 {
+		content, err := readFile(myGithubCreateIssueOptions.BodyFilePath)
+		if err != nil {
+			return errors.Wrapf(err, "Failed to read issue body file '%v'", myGithubCreateIssueOptions.BodyFilePath)
+		}
+		bodyTemplate = string(content)
+	}
+	body, err := renderIssueTemplate("body", bodyTemplate, data, envRootPath)
+	if err != nil {
+		return err
+	}
+	body = strings.TrimRight(body, "\n") + "\n\n" + fmt.Sprintf(issueFingerprintFormat, fingerprint) + "\n"
+
+	issueRequest := github.IssueRequest{
+		Title: &myGithubCreateIssueOptions.Title,
+		Body:  &body,
+	}
+	if len(myGithubCreateIssueOptions.Labels) > 0 {
+		issueRequest.Labels = &myGithubCreateIssueOptions.Labels
+	}
+	if len(myGithubCreateIssueOptions.Assignees) > 0 {
+		issueRequest.Assignees = &myGithubCreateIssueOptions.Assignees
+	}
+
+	if issue != nil {
+		updatedIssue, _, err := ghIssueClient.Edit(ctx, myGithubCreateIssueOptions.Owner, myGithubCreateIssueOptions.Repository, issue.GetNumber(), &issueRequest)
+		if err != nil {
+			return errors.Wrapf(err, "Error occured when updating issue #%v", issue.GetNumber())
+		}
+		log.Entry().Infof("Issue updated: %v", updatedIssue.GetHTMLURL())
+		return nil
+	}
+
+	newIssue, _, err := ghIssueClient.Create(ctx, myGithubCreateIssueOptions.Owner, myGithubCreateIssueOptions.Repository, &issueRequest)
+	if err != nil {
+		return errors.Wrap(err, "Error occured when creating issue")
+	}
+	log.Entry().Infof("Issue created: %v", newIssue.GetHTMLURL())
+	return nil
+}
+
+// findIssueByFingerprint provides the open issue containing the fingerprint, nil in case there is none
+func findIssueByFingerprint(ctx context.Context, fingerprint string, myGithubCreateIssueOptions *githubCreateIssueOptions, ghIssueClient githubIssueClient) (*github.Issue, error) {
+	options := github.IssueListByRepoOptions{
+		State:       "open",
+		ListOptions: github.ListOptions{PerPage: githubPageSize},
+	}
+	for {
+		issues, resp, err := ghIssueClient.ListByRepo(ctx, myGithubCreateIssueOptions.Owner, myGithubCreateIssueOptions.Repository, &options)
+		if err != nil {
+			return nil, errors.Wrap(err, "Error occured when searching for existing issue")
+		}
+		for _, issue := range issues {
+			if issue.IsPullRequest() {
+				continue
+			}
+			for _, match := range issueFingerprintPattern.FindAllStringSubmatch(issue.GetBody(), -1) {
+				if match[1] == fingerprint {
+					log.Entry().Infof("Found existing issue #%v with fingerprint '%v'", issue.GetNumber(), fingerprint)
+					return issue, nil
+				}
+			}
+		}
+		if resp == nil || resp.NextPage == 0 {
+			return nil, nil
+		}
+		options.Page = resp.NextPage
+	}
+}
+
+func closeIssue(ctx context.Context, issue *github.Issue, comment string, myGithubCreateIssueOptions *githubCreateIssueOptions, ghIssueClient githubIssueClient) error {
+	if len(strings.TrimSpace(comment)) > 0 {
+		_, _, err := ghIssueClient.CreateComment(ctx, myGithubCreateIssueOptions.Owner, myGithubCreateIssueOptions.Repository, issue.GetNumber(), &github.IssueComment{Body: &comment})
+		if err != nil {
+			return errors.Wrapf(err, "Error occured when commenting issue #%v", issue.GetNumber())
+		}
+	}
+	_, _, err := ghIssueClient.Edit(ctx, myGithubCreateIssueOptions.Owner, myGithubCreateIssueOptions.Repository, issue.GetNumber(), &github.IssueRequest{State: github.String("closed")})
+	if err != nil {
+		return errors.Wrapf(err, "Error occured when closing issue #%v", issue.GetNumber())
+	}
+	log.Entry().Infof("Issue closed: %v", issue.GetHTMLURL())
+	return nil
+}
+
+// issueTemplateEnvVars are the environment variables available in the issue template. Other variables are not accessible,
+// since the environment of the step contains credentials.
+var issueTemplateEnvVars = []string{"BRANCH_NAME", "BUILD_NUMBER", "BUILD_TAG", "BUILD_URL", "GIT_COMMIT", "JOB_NAME", "JOB_URL", "STAGE_NAME"}
+
+// renderIssueTemplate renders the text as template, values of the environment and the pipeline environment are available via functions
+func renderIssueTemplate(name, text string, data issueTemplateData, envRootPath string) (string, error) {
+	functions := template.FuncMap{
+		"env": func(name string) (string, error) {
+			for _, allowed := range issueTemplateEnvVars {
+				if name == allowed {
+					return os.Getenv(name), nil
+				}
+			}
+			return "", fmt.Errorf("environment variable '%v' is not available, supported variables: %v", name, strings.Join(issueTemplateEnvVars, ", "))
+		},
+		"pipelineEnv": func(param string) string {
+			return piperenv.GetResourceParameter(envRootPath, "commonPipelineEnvironment", param)
+		},
+	}
+	tmpl, err := template.New(name).Funcs(functions).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", errors.Wrapf(err, "Invalid template '%v'", name)
+	}
+	var result bytes.Buffer
+	if err := tmpl.Execute(&result, data); err != nil {
+		return "", errors.Wrapf(err, "Failed to render template '%v'", name)
+	}
+	return result.String(), nil
+}
@@ -0,0 +1,218 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/SAP/jenkins-library/pkg/config"
+	"github.com/SAP/jenkins-library/pkg/log"
+
+	"github.com/spf13/cobra"
+)
+
+type githubCreateIssueOptions struct {
+	APIURL          string   `json:"apiUrl,omitempty"`
+	AppID           string   `json:"appId,omitempty"`
+	Assignees       []string `json:"assignees,omitempty"`
+	Body            string   `json:"body,omitempty"`
+	BodyFilePath    string   `json:"bodyFilePath,omitempty"`
+	Fingerprint     string   `json:"fingerprint,omitempty"`
+	InstallationID  string   `json:"installationId,omitempty"`
+	Labels          []string `json:"labels,omitempty"`
+	Owner           string   `json:"owner,omitempty"`
+	PrivateKey      string   `json:"privateKey,omitempty"`
+	Repository      string   `json:"repository,omitempty"`
+	Resolved        bool     `json:"resolved,omitempty"`
+	ResolvedComment string   `json:"resolvedComment,omitempty"`
+	Title           string   `json:"title,omitempty"`
+	Token           string   `json:"token,omitempty"`
+}
+
+var myGithubCreateIssueOptions githubCreateIssueOptions
+
+// GithubCreateIssueCommand Create or update a GitHub issue for a problem detected by the pipeline
+func GithubCreateIssueCommand() *cobra.Command {
+	metadata := githubCreateIssueMetadata()
+
+	var createGithubCreateIssueCmd = &cobra.Command{
+		Use:   "githubCreateIssue",
+		Short: "Create or update a GitHub issue for a problem detected by the pipeline",
+		Long: `This step turns problems detected by the pipeline, e.g. a failed nightly build or new findings of a scan, into GitHub issues.
+
+The problem is identified by a fingerprint which is stored in the body of the issue.
+In case an open issue with the same fingerprint exists, this issue is updated instead of creating a new one.
+Once the problem is resolved in a later run, the step called with ` + "`" + `resolved: true` + "`" + ` closes the issue.
+
+The body is a [Go template](https://golang.org/pkg/text/template/) provided via ` + "`" + `body` + "`" + ` or ` + "`" + `bodyFilePath` + "`" + `. Besides ` + "`" + `{{.Title}}` + "`" + ` and ` + "`" + `{{.Fingerprint}}` + "`" + ` the following functions are available:
+
+* ` + "`" + `{{env "BUILD_URL"}}` + "`" + ` provides the value of an environment variable. Since the environment of the step contains credentials, only ` + "`" + `BRANCH_NAME` + "`" + `, ` + "`" + `BUILD_NUMBER` + "`" + `, ` + "`" + `BUILD_TAG` + "`" + `, ` + "`" + `BUILD_URL` + "`" + `, ` + "`" + `GIT_COMMIT` + "`" + `, ` + "`" + `JOB_NAME` + "`" + `, ` + "`" + `JOB_URL` + "`" + ` and ` + "`" + `STAGE_NAME` + "`" + ` are available.
+* ` + "`" + `{{pipelineEnv "git/commitId"}}` + "`" + ` provides the value of a parameter of the commonPipelineEnvironment`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			log.SetStepName("githubCreateIssue")
+			log.SetVerbose(GeneralConfig.Verbose)
+			return PrepareConfig(cmd, &metadata, "githubCreateIssue", &myGithubCreateIssueOptions, config.OpenPiperFile)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			return githubCreateIssue(myGithubCreateIssueOptions)
+		},
+	}
+
+	addGithubCreateIssueFlags(createGithubCreateIssueCmd)
+	return createGithubCreateIssueCmd
+}
+
+func addGithubCreateIssueFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&myGithubCreateIssueOptions.APIURL, "apiUrl", "https://api.github.com", "Set the GitHub API url.")
+	cmd.Flags().StringVar(&myGithubCreateIssueOptions.AppID, "appId", os.Getenv("PIPER_appId"), "ID of the GitHub App used for authentication. In case it is provided, the step authenticates as installation `installationId` of the app instead of using `token`.")
+	cmd.Flags().StringSliceVar(&myGithubCreateIssueOptions.Assignees, "assignees", []string{}, "Login names of users to which the issue should be assigned to.")
+	cmd.Flags().StringVar(&myGithubCreateIssueOptions.Body, "body", os.Getenv("PIPER_body"), "Template of the issue body in markdown format.")
+	cmd.Flags().StringVar(&myGithubCreateIssueOptions.BodyFilePath, "bodyFilePath", os.Getenv("PIPER_bodyFilePath"), "Path of a file containing the template of the issue body, e.g. a report written by a previous step. It takes precedence over `body`.")
+	cmd.Flags().StringVar(&myGithubCreateIssueOptions.Fingerprint, "fingerprint", os.Getenv("PIPER_fingerprint"), "Identifies the problem the issue is created for. If not set, a fingerprint is derived from the title.")
+	cmd.Flags().StringVar(&myGithubCreateIssueOptions.InstallationID, "installationId", os.Getenv("PIPER_installationId"), "ID of the installation of the GitHub App `appId` in the organization or repository.")
+	cmd.Flags().StringSliceVar(&myGithubCreateIssueOptions.Labels, "labels", []string{}, "Labels to be added to the issue.")
+	cmd.Flags().StringVar(&myGithubCreateIssueOptions.Owner, "owner", os.Getenv("PIPER_owner"), "Set the GitHub organization.")
+	cmd.Flags().StringVar(&myGithubCreateIssueOptions.PrivateKey, "privateKey", os.Getenv("PIPER_privateKey"), "Private key of the GitHub App `appId` in PEM format as generated by GitHub.")
+	cmd.Flags().StringVar(&myGithubCreateIssueOptions.Repository, "repository", os.Getenv("PIPER_repository"), "Set the GitHub repository.")
+	cmd.Flags().BoolVar(&myGithubCreateIssueOptions.Resolved, "resolved", false, "If set to `true`, the problem is considered resolved and an open issue with the fingerprint is closed.")
+	cmd.Flags().StringVar(&myGithubCreateIssueOptions.ResolvedComment, "resolvedComment", "The problem is resolved.", "Template of the comment added to the issue when it is closed.")
+	cmd.Flags().StringVar(&myGithubCreateIssueOptions.Title, "title", os.Getenv("PIPER_title"), "Title of the issue.")
+	cmd.Flags().StringVar(&myGithubCreateIssueOptions.Token, "token", os.Getenv("PIPER_token"), "GitHub personal access token as per https://help.github.com/en/github/authenticating-to-github/creating-a-personal-access-token-for-the-command-line. It is not required when authenticating as GitHub App.")
+
+	cmd.MarkFlagRequired("apiUrl")
+	cmd.MarkFlagRequired("owner")
+	cmd.MarkFlagRequired("repository")
+	cmd.MarkFlagRequired("title")
+}
+
+// retrieve step metadata
+func githubCreateIssueMetadata() config.StepData {
+	var theMetaData = config.StepData{
+		Spec: config.StepSpec{
+			Inputs: config.StepInputs{
+				Parameters: []config.StepParameters{
+					{
+						Name:        "apiUrl",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"GENERAL", "PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   true,
+						Aliases:     []config.Alias{{Name: "githubApiUrl"}},
+					},
+					{
+						Name:        "appId",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"GENERAL", "PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "assignees",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "[]string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "body",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "bodyFilePath",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "fingerprint",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "installationId",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"GENERAL", "PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "labels",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "[]string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "owner",
+						ResourceRef: []config.ResourceReference{{Name: "commonPipelineEnvironment", Param: "github/owner"}},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   true,
+						Aliases:     []config.Alias{{Name: "githubOrg"}},
+					},
+					{
+						Name:        "privateKey",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "repository",
+						ResourceRef: []config.ResourceReference{{Name: "commonPipelineEnvironment", Param: "github/repository"}},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   true,
+						Aliases:     []config.Alias{{Name: "githubRepo"}},
+					},
+					{
+						Name:        "resolved",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "bool",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "resolvedComment",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "title",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   true,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "token",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"GENERAL", "PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{{Name: "githubToken"}},
+					},
+				},
+			},
+		},
+	}
+	return theMetaData
+}
@@ -0,0 +1,16 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGithubCreateIssueCommand(t *testing.T) {
+
+	testCmd := GithubCreateIssueCommand()
+
+	// only high level testing performed - details are tested in step generation procudure
+	assert.Equal(t, "githubCreateIssue", testCmd.Use, "command name incorrect")
+
+}
@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-github/v28/github"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunGithubCreateIssue(t *testing.T) {
+	ctx := context.Background()
+
+	envRootPath, err := ioutil.TempDir("", "githubCreateIssueTest")
+	if err != nil {
+		t.Fatal("Failed to create temporary directory")
+	}
+	defer os.RemoveAll(envRootPath)
+	os.MkdirAll(filepath.Join(envRootPath, "commonPipelineEnvironment", "git"), 0755)
+	ioutil.WriteFile(filepath.Join(envRootPath, "commonPipelineEnvironment", "git", "commitId"), []byte("abc"), 0644)
+
+	buildURL, buildURLSet := os.LookupEnv("BUILD_URL")
+	os.Setenv("BUILD_URL", "https://jenkins/job/nightly/1")
+	defer func() {
+		if buildURLSet {
+			os.Setenv("BUILD_URL", buildURL)
+		} else {
+			os.Unsetenv("BUILD_URL")
+		}
+	}()
+
+	readFile := func(path string) ([]byte, error) {
+		if path == "report.md" {
+			return []byte("{{.Title}} in commit {{pipelineEnv \"git/commitId\"}}, see {{env \"BUILD_URL\"}}\n"), nil
+		}
+		return nil, os.ErrNotExist
+	}
+
+	options := githubCreateIssueOptions{
+		Owner:        "TEST",
+		Repository:   "test",
+		Title:        "Nightly build failed",
+		BodyFilePath: "report.md",
+		Fingerprint:  "nightly",
+		Labels:       []string{"build"},
+	}
+
+	existingIssues := func() [][]*github.Issue {
+		return [][]*github.Issue{
+			{
+				{Number: github.Int(1), Body: github.String("<!-- piper-issue-fingerprint: nightly -->"), PullRequestLinks: &github.PullRequestLinks{}},
+				{Number: github.Int(2), Body: github.String("<!-- piper-issue-fingerprint: nightly-old -->")},
+			},
+			{
+				{Number: github.Int(3), Body: github.String("Failed\n\n<!-- piper-issue-fingerprint: nightly -->\n")},
+			},
+		}
+	}
+
+	t.Run("create issue", func(t *testing.T) {
+		ghIssueClient := ghICMock{}
+
+		err := runGithubCreateIssue(ctx, &options, &ghIssueClient, readFile, envRootPath)
+
+		if assert.NoError(t, err) {
+			assert.Equal(t, "open", ghIssueClient.options.State)
+			assert.Equal(t, "Nightly build failed", ghIssueClient.createRequest.GetTitle())
+			assert.Equal(t, "Nightly build failed in commit abc, see https://jenkins/job/nightly/1\n\n<!-- piper-issue-fingerprint: nightly -->\n", ghIssueClient.createRequest.GetBody())
+			assert.Equal(t, []string{"build"}, ghIssueClient.createRequest.GetLabels())
+			assert.Nil(t, ghIssueClient.createRequest.Assignees)
+			assert.Nil(t, ghIssueClient.editRequest)
+		}
+	})
+
+	t.Run("update existing issue", func(t *testing.T) {
+		ghIssueClient := ghICMock{issuePages: existingIssues()}
+
+		err := runGithubCreateIssue(ctx, &options, &ghIssueClient, readFile, envRootPath)
+
+		if assert.NoError(t, err) {
+			assert.Nil(t, ghIssueClient.createRequest)
+			assert.Equal(t, 3, ghIssueClient.editNumber)
+			assert.Contains(t, ghIssueClient.editRequest.GetBody(), "<!-- piper-issue-fingerprint: nightly -->")
+		}
+	})
+
+	t.Run("close resolved issue", func(t *testing.T) {
+		ghIssueClient := ghICMock{issuePages: existingIssues()}
+		resolvedOptions := options
+		resolvedOptions.Resolved = true
+		resolvedOptions.ResolvedComment = "Fixed in commit {{pipelineEnv \"git/commitId\"}}"
+
+		err := runGithubCreateIssue(ctx, &resolvedOptions, &ghIssueClient, readFile, envRootPath)
+
+		if assert.NoError(t, err) {
+			assert.Equal(t, "Fixed in commit abc", ghIssueClient.comment.GetBody())
+			assert.Equal(t, 3, ghIssueClient.editNumber)
+			assert.Equal(t, github.IssueRequest{State: github.String("closed")}, *ghIssueClient.editRequest)
+		}
+	})
+
+	t.Run("nothing to resolve", func(t *testing.T) {
+		ghIssueClient := ghICMock{}
+		resolvedOptions := options
+		resolvedOptions.Resolved = true
+
+		err := runGithubCreateIssue(ctx, &resolvedOptions, &ghIssueClient, readFile, envRootPath)
+
+		if assert.NoError(t, err) {
+			assert.Nil(t, ghIssueClient.comment)
+			assert.Nil(t, ghIssueClient.editRequest)
+		}
+	})
+
+	t.Run("fingerprint derived from title", func(t *testing.T) {
+		ghIssueClient := ghICMock{}
+		titleOptions := githubCreateIssueOptions{Title: "Scan findings", Body: "{{.Fingerprint}}"}
+
+		err := runGithubCreateIssue(ctx, &titleOptions, &ghIssueClient, readFile, envRootPath)
+
+		if assert.NoError(t, err) {
+			assert.Regexp(t, "^([0-9a-f]{16})\n\n<!-- piper-issue-fingerprint: ([0-9a-f]{16}) -->\n$", ghIssueClient.createRequest.GetBody())
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		invalidOptions := githubCreateIssueOptions{Title: "Test", Fingerprint: "nightly build"}
+		err := runGithubCreateIssue(ctx, &invalidOptions, &ghICMock{}, readFile, envRootPath)
+		assert.EqualError(t, err, "Invalid fingerprint 'nightly build', whitespace is not allowed")
+
+		invalidOptions = githubCreateIssueOptions{Title: "Test", BodyFilePath: "missing.md"}
+		err = runGithubCreateIssue(ctx, &invalidOptions, &ghICMock{}, readFile, envRootPath)
+		assert.EqualError(t, err, "Failed to read issue body file 'missing.md': file does not exist")
+
+		invalidOptions = githubCreateIssueOptions{Title: "Test", Body: "{{.Unknown}}"}
+		err = runGithubCreateIssue(ctx, &invalidOptions, &ghICMock{}, readFile, envRootPath)
+		assert.Contains(t, fmt.Sprint(err), "Failed to render template 'body'")
+
+		invalidOptions = githubCreateIssueOptions{Title: "Test", Body: "{{env \"PIPER_token\"}}"}
+		err = runGithubCreateIssue(ctx, &invalidOptions, &ghICMock{}, readFile, envRootPath)
+		assert.Contains(t, fmt.Sprint(err), "environment variable 'PIPER_token' is not available")
+
+		err = runGithubCreateIssue(ctx, &options, &ghICMock{err: fmt.Errorf("Authentication failed")}, readFile, envRootPath)
+		assert.EqualError(t, err, "Error occured when creating issue: Authentication failed")
+	})
+}
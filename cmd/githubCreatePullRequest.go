@@ -2,6 +2,9 @@ package cmd
 
 import (
 	"context"
+	"fmt"
+	"strings"
+	"time"
 
 	"github.com/SAP/jenkins-library/pkg/log"
 	"github.com/google/go-github/v28/github"
@@ -12,6 +15,10 @@ import (
 
 type githubPRService interface {
 	Create(ctx context.Context, owner string, repo string, pull *github.NewPullRequest) (*github.PullRequest, *github.Response, error)
+	Edit(ctx context.Context, owner string, repo string, number int, pull *github.PullRequest) (*github.PullRequest, *github.Response, error)
+	List(ctx context.Context, owner string, repo string, opt *github.PullRequestListOptions) ([]*github.PullRequest, *github.Response, error)
+	Merge(ctx context.Context, owner string, repo string, number int, commitMessage string, options *github.PullRequestOptions) (*github.PullRequestMergeResult, *github.Response, error)
+	RequestReviewers(ctx context.Context, owner, repo string, number int, reviewers github.ReviewersRequest) (*github.PullRequest, *github.Response, error)
 }
 
 type githubIssueService interface {
@@ -19,12 +26,24 @@ type githubIssueService interface {
 }
 
 func githubCreatePullRequest(myGithubCreatePullRequestOptions githubCreatePullRequestOptions) error {
-	ctx, client, err := piperGithub.NewClient(myGithubCreatePullRequestOptions.Token, myGithubCreatePullRequestOptions.APIURL, "")
+	auth := piperGithub.Authentication{
+		Token:          myGithubCreatePullRequestOptions.Token,
+		AppID:          myGithubCreatePullRequestOptions.AppID,
+		InstallationID: myGithubCreatePullRequestOptions.InstallationID,
+		PrivateKey:     myGithubCreatePullRequestOptions.PrivateKey,
+	}
+	ctx, client, err := piperGithub.NewAuthenticatedClient(auth, myGithubCreatePullRequestOptions.APIURL, "")
 	if err != nil {
 		log.Entry().WithError(err).Fatal("Failed to get GitHub client")
 	}
 
-	err = runGithubCreatePullRequest(ctx, &myGithubCreatePullRequestOptions, client.PullRequests, client.Issues)
+	mergeClients := pullRequestMergeClients{
+		statuses:  client.Repositories,
+		checks:    client.Checks,
+		autoMerge: piperGithub.NewAutoMergeService(client),
+		sleep:     time.Sleep,
+	}
+	err = runGithubCreatePullRequest(ctx, &myGithubCreatePullRequestOptions, client.PullRequests, client.Issues, piperGithub.NewCommitService(client), mergeClients)
 	if err != nil {
 		log.Entry().WithError(err).Fatal("Failed to create GitHub pull request")
 	}
@@ -32,33 +51,185 @@ func githubCreatePullRequest(myGithubCreatePullRequestOptions githubCreatePullRe
 	return nil
 }
 
-func runGithubCreatePullRequest(ctx context.Context, myGithubCreatePullRequestOptions *githubCreatePullRequestOptions, ghPRService githubPRService, ghIssueService githubIssueService) error {
+func runGithubCreatePullRequest(ctx context.Context, myGithubCreatePullRequestOptions *githubCreatePullRequestOptions, ghPRService githubPRService, ghIssueService githubIssueService, ghCommitService githubCommitService, mergeClients pullRequestMergeClients) error {
+
+	if err := validatePullRequestMerge(myGithubCreatePullRequestOptions); err != nil {
+		return err
+	}
+
+	if len(myGithubCreatePullRequestOptions.CommitFiles) > 0 {
+		changed, err := commitPullRequestFiles(ctx, myGithubCreatePullRequestOptions, ghCommitService)
+		if err != nil {
+			return err
+		}
+		if !changed {
+			log.Entry().Infof("Files do not contain any change compared to branch '%v', no pull request created", myGithubCreatePullRequestOptions.Base)
+			// an open pull request would still propose the outdated content of the branch
+			return closeOutdatedPullRequest(ctx, myGithubCreatePullRequestOptions, ghPRService)
+		}
+	}
+
+	var pr *github.PullRequest
+	var err error
+	if myGithubCreatePullRequestOptions.UpdateExisting {
+		pr, err = findOpenPullRequest(ctx, myGithubCreatePullRequestOptions, ghPRService)
+		if err != nil {
+			return err
+		}
+	}
+
+	if pr != nil {
+		pr, err = updatePullRequest(ctx, pr, myGithubCreatePullRequestOptions, ghPRService)
+	} else {
+		pr, err = createPullRequest(ctx, myGithubCreatePullRequestOptions, ghPRService)
+	}
+	if err != nil {
+		return err
+	}
 
+	issueRequest := github.IssueRequest{
+		Labels:    &myGithubCreatePullRequestOptions.Labels,
+		Assignees: &myGithubCreatePullRequestOptions.Assignees,
+	}
+
+	updatedPr, resp, err := ghIssueService.Edit(ctx, myGithubCreatePullRequestOptions.Owner, myGithubCreatePullRequestOptions.Repository, pr.GetNumber(), &issueRequest)
+	if err != nil {
+		logGithubResponse(resp)
+		return errors.Wrap(err, "Error occured when editing pull request")
+	}
+	log.Entry().Debugf("Updated pull request: %v", updatedPr)
+
+	if err := requestPullRequestReviewers(ctx, pr, myGithubCreatePullRequestOptions, ghPRService); err != nil {
+		return err
+	}
+
+	return mergePullRequest(ctx, pr, myGithubCreatePullRequestOptions, ghPRService, mergeClients)
+}
+
+// findOpenPullRequest provides the open pull request for head and base, nil in case there is none
+func findOpenPullRequest(ctx context.Context, myGithubCreatePullRequestOptions *githubCreatePullRequestOptions, ghPRService githubPRService) (*github.PullRequest, error) {
+	head := myGithubCreatePullRequestOptions.Head
+	if !strings.Contains(head, ":") {
+		// the API expects the head in the format user:ref-name
+		head = myGithubCreatePullRequestOptions.Owner + ":" + head
+	}
+	options := github.PullRequestListOptions{
+		State: "open",
+		Head:  head,
+		Base:  myGithubCreatePullRequestOptions.Base,
+	}
+
+	prs, resp, err := ghPRService.List(ctx, myGithubCreatePullRequestOptions.Owner, myGithubCreatePullRequestOptions.Repository, &options)
+	if err != nil {
+		logGithubResponse(resp)
+		return nil, errors.Wrap(err, "Error occured when searching for existing pull request")
+	}
+	if len(prs) == 0 {
+		return nil, nil
+	}
+	log.Entry().Infof("Found existing pull request #%v for '%v'", prs[0].GetNumber(), head)
+	return prs[0], nil
+}
+
+// closeOutdatedPullRequest closes the open pull request for head and base if there is one
+func closeOutdatedPullRequest(ctx context.Context, myGithubCreatePullRequestOptions *githubCreatePullRequestOptions, ghPRService githubPRService) error {
+	pr, err := findOpenPullRequest(ctx, myGithubCreatePullRequestOptions, ghPRService)
+	if err != nil || pr == nil {
+		return err
+	}
+
+	_, resp, err := ghPRService.Edit(ctx, myGithubCreatePullRequestOptions.Owner, myGithubCreatePullRequestOptions.Repository, pr.GetNumber(), &github.PullRequest{State: github.String("closed")})
+	if err != nil {
+		logGithubResponse(resp)
+		return errors.Wrapf(err, "Error occured when closing pull request #%v", pr.GetNumber())
+	}
+	log.Entry().Infof("Closed pull request #%v since the files do not contain any change compared to branch '%v' anymore", pr.GetNumber(), myGithubCreatePullRequestOptions.Base)
+	return nil
+}
+
+func createPullRequest(ctx context.Context, myGithubCreatePullRequestOptions *githubCreatePullRequestOptions, ghPRService githubPRService) (*github.PullRequest, error) {
 	prRequest := github.NewPullRequest{
 		Title: &myGithubCreatePullRequestOptions.Title,
 		Head:  &myGithubCreatePullRequestOptions.Head,
 		Base:  &myGithubCreatePullRequestOptions.Base,
 		Body:  &myGithubCreatePullRequestOptions.Body,
+		Draft: &myGithubCreatePullRequestOptions.Draft,
 	}
 
 	newPR, resp, err := ghPRService.Create(ctx, myGithubCreatePullRequestOptions.Owner, myGithubCreatePullRequestOptions.Repository, &prRequest)
 	if err != nil {
-		log.Entry().Errorf("GitHub response code %v", resp.Status)
-		return errors.Wrap(err, "Error occured when creating pull request")
+		logGithubResponse(resp)
+		return nil, errors.Wrap(err, "Error occured when creating pull request")
 	}
 	log.Entry().Debugf("New pull request created: %v", newPR)
+	return newPR, nil
+}
 
-	issueRequest := github.IssueRequest{
-		Labels:    &myGithubCreatePullRequestOptions.Labels,
-		Assignees: &myGithubCreatePullRequestOptions.Assignees,
+func updatePullRequest(ctx context.Context, pr *github.PullRequest, myGithubCreatePullRequestOptions *githubCreatePullRequestOptions, ghPRService githubPRService) (*github.PullRequest, error) {
+	if myGithubCreatePullRequestOptions.Draft && !pr.GetDraft() {
+		log.Entry().Warningf("Pull request #%v is not converted into a draft since this is not supported by the GitHub API", pr.GetNumber())
+	}
+
+	prRequest := github.PullRequest{
+		Title: &myGithubCreatePullRequestOptions.Title,
+		Body:  &myGithubCreatePullRequestOptions.Body,
+	}
+
+	updatedPR, resp, err := ghPRService.Edit(ctx, myGithubCreatePullRequestOptions.Owner, myGithubCreatePullRequestOptions.Repository, pr.GetNumber(), &prRequest)
+	if err != nil {
+		logGithubResponse(resp)
+		return nil, errors.Wrapf(err, "Error occured when updating pull request #%v", pr.GetNumber())
+	}
+	log.Entry().Debugf("Existing pull request updated: %v", updatedPR)
+	return updatedPR, nil
+}
+
+func requestPullRequestReviewers(ctx context.Context, pr *github.PullRequest, myGithubCreatePullRequestOptions *githubCreatePullRequestOptions, ghPRService githubPRService) error {
+	reviewers := []string{}
+	for _, reviewer := range myGithubCreatePullRequestOptions.Reviewers {
+		// GitHub refuses review requests for the author of the pull request
+		if strings.EqualFold(reviewer, pr.GetUser().GetLogin()) {
+			log.Entry().Infof("Review of '%v' not requested since '%v' is the author of the pull request", reviewer, reviewer)
+			continue
+		}
+		reviewers = append(reviewers, reviewer)
+	}
+	if len(reviewers) == 0 && len(myGithubCreatePullRequestOptions.TeamReviewers) == 0 {
+		return nil
 	}
 
-	updatedPr, resp, err := ghIssueService.Edit(ctx, myGithubCreatePullRequestOptions.Owner, myGithubCreatePullRequestOptions.Repository, newPR.GetNumber(), &issueRequest)
+	reviewersRequest := github.ReviewersRequest{
+		Reviewers:     reviewers,
+		TeamReviewers: myGithubCreatePullRequestOptions.TeamReviewers,
+	}
+	_, resp, err := ghPRService.RequestReviewers(ctx, myGithubCreatePullRequestOptions.Owner, myGithubCreatePullRequestOptions.Repository, pr.GetNumber(), reviewersRequest)
 	if err != nil {
+		logGithubResponse(resp)
+		return errors.Wrap(err, "Error occured when requesting reviewers")
+	}
+	return nil
+}
+
+func logGithubResponse(resp *github.Response) {
+	if resp != nil && resp.Response != nil {
 		log.Entry().Errorf("GitHub response code %v", resp.Status)
-		return errors.Wrap(err, "Error occured when editing pull request")
 	}
-	log.Entry().Debugf("Updated pull request: %v", updatedPr)
+}
 
+func validatePullRequestMerge(myGithubCreatePullRequestOptions *githubCreatePullRequestOptions) error {
+	if !myGithubCreatePullRequestOptions.AutoMerge && !myGithubCreatePullRequestOptions.MergeWhenChecksPass {
+		return nil
+	}
+	if myGithubCreatePullRequestOptions.AutoMerge && myGithubCreatePullRequestOptions.MergeWhenChecksPass {
+		return fmt.Errorf("Parameters 'autoMerge' and 'mergeWhenChecksPass' cannot be used together")
+	}
+	if myGithubCreatePullRequestOptions.Draft {
+		return fmt.Errorf("A draft pull request cannot be merged, please do not set 'draft' together with 'autoMerge' or 'mergeWhenChecksPass'")
+	}
+	switch myGithubCreatePullRequestOptions.MergeMethod {
+	case "merge", "squash", "rebase":
+	default:
+		return fmt.Errorf("Invalid mergeMethod '%v'. Supported values: 'merge', 'squash', 'rebase'", myGithubCreatePullRequestOptions.MergeMethod)
+	}
 	return nil
 }
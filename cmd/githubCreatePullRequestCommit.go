@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/SAP/jenkins-library/pkg/log"
+	"github.com/google/go-github/v28/github"
+	"github.com/pkg/errors"
+
+	piperGithub "github.com/SAP/jenkins-library/pkg/github"
+)
+
+type githubCommitService interface {
+	CommitFiles(ctx context.Context, owner, repo string, commit piperGithub.BranchCommit) (*github.Commit, error)
+}
+
+// commitPullRequestFiles commits the configured files to the head branch, false is returned in case there is nothing to commit
+func commitPullRequestFiles(ctx context.Context, myGithubCreatePullRequestOptions *githubCreatePullRequestOptions, ghCommitService githubCommitService) (bool, error) {
+	if strings.Contains(myGithubCreatePullRequestOptions.Head, ":") {
+		return false, fmt.Errorf("Files can only be committed to a branch of repository '%v/%v', head '%v' is not supported", myGithubCreatePullRequestOptions.Owner, myGithubCreatePullRequestOptions.Repository, myGithubCreatePullRequestOptions.Head)
+	}
+
+	files, err := readCommitFiles(myGithubCreatePullRequestOptions.CommitFiles)
+	if err != nil {
+		return false, err
+	}
+
+	message := myGithubCreatePullRequestOptions.CommitMessage
+	if len(message) == 0 {
+		message = myGithubCreatePullRequestOptions.Title
+	}
+
+	commit, err := ghCommitService.CommitFiles(ctx, myGithubCreatePullRequestOptions.Owner, myGithubCreatePullRequestOptions.Repository, piperGithub.BranchCommit{
+		Base:    myGithubCreatePullRequestOptions.Base,
+		Branch:  myGithubCreatePullRequestOptions.Head,
+		Message: message,
+		Files:   files,
+	})
+	if err != nil {
+		return false, errors.Wrapf(err, "Error occured when committing files to branch '%v'", myGithubCreatePullRequestOptions.Head)
+	}
+	if commit == nil {
+		return false, nil
+	}
+	log.Entry().Infof("Committed %v files to branch '%v': %v", len(files), myGithubCreatePullRequestOptions.Head, commit.GetSHA())
+	return true, nil
+}
+
+// readCommitFiles resolves the glob patterns relative to the workspace and reads the files
+func readCommitFiles(patterns []string) ([]piperGithub.CommitFile, error) {
+	files := []piperGithub.CommitFile{}
+	known := map[string]bool{}
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Invalid commit file pattern '%v'", pattern)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("No file found for '%v'", pattern)
+		}
+		for _, match := range matches {
+			path := filepath.ToSlash(filepath.Clean(match))
+			if filepath.IsAbs(match) || strings.HasPrefix(path, "../") {
+				return nil, fmt.Errorf("File '%v' is not located inside the workspace", match)
+			}
+			if known[path] {
+				continue
+			}
+			info, err := os.Stat(match)
+			if err != nil {
+				return nil, errors.Wrapf(err, "Failed to read file '%v'", match)
+			}
+			if info.IsDir() {
+				continue
+			}
+			content, err := ioutil.ReadFile(match)
+			if err != nil {
+				return nil, errors.Wrapf(err, "Failed to read file '%v'", match)
+			}
+			known[path] = true
+			files = append(files, piperGithub.CommitFile{Path: path, Content: content, Executable: info.Mode()&0111 != 0})
+		}
+	}
+	return files, nil
+}
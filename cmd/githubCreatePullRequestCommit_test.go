@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-github/v28/github"
+	"github.com/stretchr/testify/assert"
+
+	piperGithub "github.com/SAP/jenkins-library/pkg/github"
+)
+
+type ghCommitMock struct {
+	commit    *piperGithub.BranchCommit
+	unchanged bool
+	err       error
+}
+
+func (g *ghCommitMock) CommitFiles(ctx context.Context, owner, repo string, commit piperGithub.BranchCommit) (*github.Commit, error) {
+	g.commit = &commit
+	if g.unchanged || g.err != nil {
+		return nil, g.err
+	}
+	return &github.Commit{SHA: github.String("abc")}, nil
+}
+
+func TestReadCommitFiles(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		files, err := readCommitFiles([]string{"testdata/TestReadCommitFiles/*.yml", "testdata/TestReadCommitFiles/config.yml", "./testdata/TestReadCommitFiles/bump.sh"})
+
+		if assert.NoError(t, err) {
+			assert.Equal(t, []piperGithub.CommitFile{
+				{Path: "testdata/TestReadCommitFiles/config.yml", Content: []byte("version: 1.1\n")},
+				{Path: "testdata/TestReadCommitFiles/other.yml", Content: []byte("other: true\n")},
+				{Path: "testdata/TestReadCommitFiles/bump.sh", Content: []byte("#!/bin/sh\necho bump\n"), Executable: true},
+			}, files)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		_, err := readCommitFiles([]string{"testdata/TestReadCommitFiles/*.json"})
+		assert.EqualError(t, err, "No file found for 'testdata/TestReadCommitFiles/*.json'")
+
+		_, err = readCommitFiles([]string{"../cmd/testdata/TestReadCommitFiles/config.yml"})
+		assert.EqualError(t, err, "File '../cmd/testdata/TestReadCommitFiles/config.yml' is not located inside the workspace")
+	})
+}
+
+func TestCommitPullRequestFiles(t *testing.T) {
+	ctx := context.Background()
+	options := githubCreatePullRequestOptions{
+		Owner:       "TEST",
+		Repository:  "test",
+		Title:       "Bump version",
+		Head:        "bump/version",
+		Base:        "master",
+		CommitFiles: []string{"testdata/TestReadCommitFiles/config.yml"},
+	}
+
+	t.Run("success", func(t *testing.T) {
+		ghCommitService := ghCommitMock{}
+
+		changed, err := commitPullRequestFiles(ctx, &options, &ghCommitService)
+
+		if assert.NoError(t, err) {
+			assert.True(t, changed)
+			assert.Equal(t, "master", ghCommitService.commit.Base)
+			assert.Equal(t, "bump/version", ghCommitService.commit.Branch)
+			assert.Equal(t, "Bump version", ghCommitService.commit.Message)
+			assert.Equal(t, "testdata/TestReadCommitFiles/config.yml", ghCommitService.commit.Files[0].Path)
+		}
+	})
+
+	t.Run("no pull request without changes", func(t *testing.T) {
+		ghPRService := ghPRMock{}
+		ghIssueService := ghIssueMock{}
+		commitOptions := options
+		commitOptions.CommitMessage = "chore: bump version"
+
+		ghCommitService := ghCommitMock{unchanged: true}
+		err := runGithubCreatePullRequest(ctx, &commitOptions, &ghPRService, &ghIssueService, &ghCommitService, pullRequestMergeClients{})
+
+		if assert.NoError(t, err) {
+			assert.Equal(t, "chore: bump version", ghCommitService.commit.Message)
+			assert.Nil(t, ghPRService.pullrequest)
+			assert.Nil(t, ghIssueService.issueRequest)
+			assert.Nil(t, ghPRService.editRequest)
+		}
+	})
+
+	t.Run("unchanged with open pull request", func(t *testing.T) {
+		ghPRService := ghPRMock{existing: []*github.PullRequest{{Number: github.Int(42)}}}
+
+		err := runGithubCreatePullRequest(ctx, &options, &ghPRService, &ghIssueMock{}, &ghCommitMock{unchanged: true}, pullRequestMergeClients{})
+
+		if assert.NoError(t, err) && assert.NotNil(t, ghPRService.editRequest) {
+			assert.Equal(t, "closed", ghPRService.editRequest.GetState())
+			assert.Equal(t, "TEST:bump/version", ghPRService.listOpts.Head)
+			assert.Nil(t, ghPRService.pullrequest)
+		}
+
+		ghPRService = ghPRMock{existing: []*github.PullRequest{{Number: github.Int(42)}}, editError: fmt.Errorf("forbidden")}
+		err = runGithubCreatePullRequest(ctx, &options, &ghPRService, &ghIssueMock{}, &ghCommitMock{unchanged: true}, pullRequestMergeClients{})
+		assert.EqualError(t, err, "Error occured when closing pull request #42: forbidden")
+	})
+
+	t.Run("error", func(t *testing.T) {
+		_, err := commitPullRequestFiles(ctx, &options, &ghCommitMock{err: fmt.Errorf("Failed to create tree")})
+		assert.EqualError(t, err, "Error occured when committing files to branch 'bump/version': Failed to create tree")
+
+		forkOptions := options
+		forkOptions.Head = "fork:bump/version"
+		_, err = commitPullRequestFiles(ctx, &forkOptions, &ghCommitMock{})
+		assert.EqualError(t, err, "Files can only be committed to a branch of repository 'TEST/test', head 'fork:bump/version' is not supported")
+	})
+}
@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SAP/jenkins-library/pkg/log"
+	"github.com/google/go-github/v28/github"
+	"github.com/pkg/errors"
+)
+
+// time between two checks of the pull request statuses
+const pullRequestChecksInterval = 30 * time.Second
+
+type githubStatusService interface {
+	GetCombinedStatus(ctx context.Context, owner, repo, ref string, opt *github.ListOptions) (*github.CombinedStatus, *github.Response, error)
+}
+
+type githubChecksService interface {
+	ListCheckRunsForRef(ctx context.Context, owner, repo, ref string, opt *github.ListCheckRunsOptions) (*github.ListCheckRunsResults, *github.Response, error)
+}
+
+type githubAutoMergeService interface {
+	EnableAutoMerge(ctx context.Context, pullRequestID, mergeMethod string) (*github.Response, error)
+}
+
+// pullRequestMergeClients bundles what is required for merging a pull request
+type pullRequestMergeClients struct {
+	statuses  githubStatusService
+	checks    githubChecksService
+	autoMerge githubAutoMergeService
+	sleep     func(time.Duration)
+}
+
+func mergePullRequest(ctx context.Context, pr *github.PullRequest, myGithubCreatePullRequestOptions *githubCreatePullRequestOptions, ghPRService githubPRService, mergeClients pullRequestMergeClients) error {
+	if myGithubCreatePullRequestOptions.AutoMerge {
+		resp, err := mergeClients.autoMerge.EnableAutoMerge(ctx, pr.GetNodeID(), myGithubCreatePullRequestOptions.MergeMethod)
+		if err != nil {
+			logGithubResponse(resp)
+			return errors.Wrapf(err, "Error occured when enabling auto-merge for pull request #%v", pr.GetNumber())
+		}
+		log.Entry().Infof("Auto-merge enabled for pull request #%v", pr.GetNumber())
+		return nil
+	}
+
+	if !myGithubCreatePullRequestOptions.MergeWhenChecksPass {
+		return nil
+	}
+
+	timeout, err := strconv.Atoi(myGithubCreatePullRequestOptions.ChecksTimeout)
+	if err != nil || timeout < 0 {
+		return fmt.Errorf("Invalid checksTimeout '%v', expected a number of seconds", myGithubCreatePullRequestOptions.ChecksTimeout)
+	}
+
+	sha := pr.GetHead().GetSHA()
+	if err := waitForPullRequestChecks(ctx, sha, time.Duration(timeout)*time.Second, myGithubCreatePullRequestOptions, mergeClients); err != nil {
+		return errors.Wrapf(err, "Pull request #%v not merged", pr.GetNumber())
+	}
+
+	// the sha makes sure that only the checked state of the pull request is merged
+	options := github.PullRequestOptions{MergeMethod: myGithubCreatePullRequestOptions.MergeMethod, SHA: sha}
+	result, resp, err := ghPRService.Merge(ctx, myGithubCreatePullRequestOptions.Owner, myGithubCreatePullRequestOptions.Repository, pr.GetNumber(), "", &options)
+	if err != nil {
+		logGithubResponse(resp)
+		return errors.Wrapf(err, "Error occured when merging pull request #%v", pr.GetNumber())
+	}
+	log.Entry().Infof("Pull request #%v merged: %v", pr.GetNumber(), result.GetSHA())
+	return nil
+}
+
+// waitForPullRequestChecks waits until all statuses and check runs of the commit succeeded
+func waitForPullRequestChecks(ctx context.Context, sha string, timeout time.Duration, myGithubCreatePullRequestOptions *githubCreatePullRequestOptions, mergeClients pullRequestMergeClients) error {
+	maxRetries := int(timeout / pullRequestChecksInterval)
+	for retries := 0; ; retries++ {
+		pending, err := getPendingPullRequestChecks(ctx, sha, myGithubCreatePullRequestOptions, mergeClients)
+		if err != nil {
+			return err
+		}
+		if len(pending) == 0 {
+			return nil
+		}
+		if retries >= maxRetries {
+			return fmt.Errorf("checks did not finish within %v: %v", timeout, strings.Join(pending, ", "))
+		}
+		log.Entry().Infof("Waiting for checks: %v", strings.Join(pending, ", "))
+		mergeClients.sleep(pullRequestChecksInterval)
+	}
+}
+
+// getPendingPullRequestChecks provides the names of the statuses and check runs which did not finish yet.
+// Required checks which have not been reported yet are pending as well. Without required checks at least one
+// status or check run has to be reported, since right after a push there are none yet.
+// An error is returned in case a status or check run failed.
+func getPendingPullRequestChecks(ctx context.Context, sha string, myGithubCreatePullRequestOptions *githubCreatePullRequestOptions, mergeClients pullRequestMergeClients) ([]string, error) {
+	owner, repo := myGithubCreatePullRequestOptions.Owner, myGithubCreatePullRequestOptions.Repository
+	pending, failed := []string{}, []string{}
+	reported := map[string]bool{}
+
+	statusOptions := github.ListOptions{PerPage: githubPageSize}
+	for {
+		combinedStatus, resp, err := mergeClients.statuses.GetCombinedStatus(ctx, owner, repo, sha, &statusOptions)
+		if err != nil {
+			logGithubResponse(resp)
+			return nil, errors.Wrap(err, "Error occured when retrieving commit statuses")
+		}
+		for _, status := range combinedStatus.Statuses {
+			reported[status.GetContext()] = true
+			switch status.GetState() {
+			case "success":
+			case "pending":
+				pending = append(pending, status.GetContext())
+			default:
+				failed = append(failed, status.GetContext())
+			}
+		}
+		if resp == nil || resp.NextPage == 0 {
+			break
+		}
+		statusOptions.Page = resp.NextPage
+	}
+
+	checkRunOptions := github.ListCheckRunsOptions{ListOptions: github.ListOptions{PerPage: githubPageSize}}
+	for {
+		checkRuns, resp, err := mergeClients.checks.ListCheckRunsForRef(ctx, owner, repo, sha, &checkRunOptions)
+		if err != nil {
+			logGithubResponse(resp)
+			return nil, errors.Wrap(err, "Error occured when retrieving check runs")
+		}
+		for _, checkRun := range checkRuns.CheckRuns {
+			reported[checkRun.GetName()] = true
+			if checkRun.GetStatus() != "completed" {
+				pending = append(pending, checkRun.GetName())
+				continue
+			}
+			switch checkRun.GetConclusion() {
+			case "success", "neutral", "skipped":
+			default:
+				failed = append(failed, checkRun.GetName())
+			}
+		}
+		if resp == nil || resp.NextPage == 0 {
+			break
+		}
+		checkRunOptions.Page = resp.NextPage
+	}
+
+	if len(failed) > 0 {
+		return nil, fmt.Errorf("checks failed: %v", strings.Join(failed, ", "))
+	}
+
+	for _, name := range myGithubCreatePullRequestOptions.RequiredChecks {
+		if !reported[name] {
+			pending = append(pending, fmt.Sprintf("%v (not reported yet)", name))
+		}
+	}
+	if len(reported) == 0 && len(myGithubCreatePullRequestOptions.RequiredChecks) == 0 {
+		pending = append(pending, "no statuses or check runs reported yet")
+	}
+	return pending, nil
+}
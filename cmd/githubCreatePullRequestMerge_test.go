@@ -0,0 +1,219 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v28/github"
+	"github.com/stretchr/testify/assert"
+)
+
+type ghStatusMock struct {
+	statuses [][]github.RepoStatus
+	calls    int
+	ref      string
+}
+
+func (g *ghStatusMock) GetCombinedStatus(ctx context.Context, owner, repo, ref string, opt *github.ListOptions) (*github.CombinedStatus, *github.Response, error) {
+	g.ref = ref
+	statuses := g.statuses[len(g.statuses)-1]
+	if g.calls < len(g.statuses) {
+		statuses = g.statuses[g.calls]
+	}
+	g.calls++
+	return &github.CombinedStatus{Statuses: statuses}, nil, nil
+}
+
+type ghChecksMock struct {
+	checkRuns []*github.CheckRun
+	pages     [][]*github.CheckRun
+	err       error
+}
+
+func (g *ghChecksMock) ListCheckRunsForRef(ctx context.Context, owner, repo, ref string, opt *github.ListCheckRunsOptions) (*github.ListCheckRunsResults, *github.Response, error) {
+	if len(g.pages) == 0 {
+		return &github.ListCheckRunsResults{CheckRuns: g.checkRuns}, nil, g.err
+	}
+	page := opt.Page
+	if page == 0 {
+		page = 1
+	}
+	resp := github.Response{}
+	if page < len(g.pages) {
+		resp.NextPage = page + 1
+	}
+	return &github.ListCheckRunsResults{CheckRuns: g.pages[page-1]}, &resp, g.err
+}
+
+type ghAutoMergeMock struct {
+	pullRequestID string
+	mergeMethod   string
+	err           error
+}
+
+func (g *ghAutoMergeMock) EnableAutoMerge(ctx context.Context, pullRequestID, mergeMethod string) (*github.Response, error) {
+	g.pullRequestID = pullRequestID
+	g.mergeMethod = mergeMethod
+	return nil, g.err
+}
+
+func testStatus(name, state string) github.RepoStatus {
+	return github.RepoStatus{Context: github.String(name), State: github.String(state)}
+}
+
+func TestMergePullRequest(t *testing.T) {
+	ctx := context.Background()
+	pr := github.PullRequest{Number: github.Int(1), NodeID: github.String("PR_1"), Head: &github.PullRequestBranch{SHA: github.String("abc")}}
+
+	t.Run("auto-merge", func(t *testing.T) {
+		autoMerge := ghAutoMergeMock{}
+		options := githubCreatePullRequestOptions{AutoMerge: true, MergeMethod: "squash"}
+
+		err := mergePullRequest(ctx, &pr, &options, &ghPRMock{}, pullRequestMergeClients{autoMerge: &autoMerge})
+
+		if assert.NoError(t, err) {
+			assert.Equal(t, "PR_1", autoMerge.pullRequestID)
+			assert.Equal(t, "squash", autoMerge.mergeMethod)
+		}
+
+		autoMerge.err = fmt.Errorf("Auto-merge not allowed")
+		err = mergePullRequest(ctx, &pr, &options, &ghPRMock{}, pullRequestMergeClients{autoMerge: &autoMerge})
+		assert.EqualError(t, err, "Error occured when enabling auto-merge for pull request #1: Auto-merge not allowed")
+	})
+
+	t.Run("merge when checks pass", func(t *testing.T) {
+		statuses := ghStatusMock{statuses: [][]github.RepoStatus{
+			{testStatus("build", "pending")},
+			{testStatus("build", "success")},
+		}}
+		checks := ghChecksMock{checkRuns: []*github.CheckRun{{Name: github.String("lint"), Status: github.String("completed"), Conclusion: github.String("neutral")}}}
+		sleeps := []time.Duration{}
+		ghPRService := ghPRMock{}
+		options := githubCreatePullRequestOptions{MergeWhenChecksPass: true, MergeMethod: "rebase", ChecksTimeout: "60"}
+
+		err := mergePullRequest(ctx, &pr, &options, &ghPRService, pullRequestMergeClients{
+			statuses: &statuses,
+			checks:   &checks,
+			sleep:    func(d time.Duration) { sleeps = append(sleeps, d) },
+		})
+
+		if assert.NoError(t, err) {
+			assert.Equal(t, "abc", statuses.ref)
+			assert.Equal(t, []time.Duration{pullRequestChecksInterval}, sleeps)
+			assert.Equal(t, github.PullRequestOptions{MergeMethod: "rebase", SHA: "abc"}, *ghPRService.mergeOpts)
+		}
+	})
+
+	t.Run("checks failed", func(t *testing.T) {
+		statuses := ghStatusMock{statuses: [][]github.RepoStatus{{testStatus("build", "failure")}}}
+		checks := ghChecksMock{checkRuns: []*github.CheckRun{{Name: github.String("lint"), Status: github.String("completed"), Conclusion: github.String("failure")}}}
+		ghPRService := ghPRMock{}
+		options := githubCreatePullRequestOptions{MergeWhenChecksPass: true, MergeMethod: "merge", ChecksTimeout: "60"}
+
+		err := mergePullRequest(ctx, &pr, &options, &ghPRService, pullRequestMergeClients{statuses: &statuses, checks: &checks})
+
+		assert.EqualError(t, err, "Pull request #1 not merged: checks failed: build, lint")
+		assert.Nil(t, ghPRService.mergeOpts)
+	})
+
+	t.Run("checks timed out", func(t *testing.T) {
+		statuses := ghStatusMock{statuses: [][]github.RepoStatus{{}}}
+		checks := ghChecksMock{checkRuns: []*github.CheckRun{{Name: github.String("tests"), Status: github.String("in_progress")}}}
+		sleeps := 0
+		options := githubCreatePullRequestOptions{MergeWhenChecksPass: true, MergeMethod: "merge", ChecksTimeout: "90"}
+
+		err := mergePullRequest(ctx, &pr, &options, &ghPRMock{}, pullRequestMergeClients{
+			statuses: &statuses,
+			checks:   &checks,
+			sleep:    func(time.Duration) { sleeps++ },
+		})
+
+		assert.EqualError(t, err, "Pull request #1 not merged: checks did not finish within 1m30s: tests")
+		assert.Equal(t, 3, sleeps)
+	})
+
+	t.Run("wait for checks to be reported", func(t *testing.T) {
+		statuses := ghStatusMock{statuses: [][]github.RepoStatus{
+			{},
+			{testStatus("build", "success")},
+		}}
+		sleeps := 0
+		ghPRService := ghPRMock{}
+		options := githubCreatePullRequestOptions{MergeWhenChecksPass: true, MergeMethod: "merge", ChecksTimeout: "60"}
+
+		err := mergePullRequest(ctx, &pr, &options, &ghPRService, pullRequestMergeClients{
+			statuses: &statuses,
+			checks:   &ghChecksMock{},
+			sleep:    func(time.Duration) { sleeps++ },
+		})
+
+		if assert.NoError(t, err) {
+			assert.Equal(t, 1, sleeps)
+			assert.NotNil(t, ghPRService.mergeOpts)
+		}
+
+		statuses = ghStatusMock{statuses: [][]github.RepoStatus{{}}}
+		ghPRService = ghPRMock{}
+		options.ChecksTimeout = "0"
+		err = mergePullRequest(ctx, &pr, &options, &ghPRService, pullRequestMergeClients{statuses: &statuses, checks: &ghChecksMock{}})
+
+		assert.EqualError(t, err, "Pull request #1 not merged: checks did not finish within 0s: no statuses or check runs reported yet")
+		assert.Nil(t, ghPRService.mergeOpts)
+	})
+
+	t.Run("required checks", func(t *testing.T) {
+		statuses := ghStatusMock{statuses: [][]github.RepoStatus{{testStatus("build", "success")}}}
+		checks := ghChecksMock{pages: [][]*github.CheckRun{
+			{{Name: github.String("lint"), Status: github.String("completed"), Conclusion: github.String("success")}},
+			{{Name: github.String("tests"), Status: github.String("completed"), Conclusion: github.String("success")}},
+		}}
+		ghPRService := ghPRMock{}
+		options := githubCreatePullRequestOptions{MergeWhenChecksPass: true, MergeMethod: "merge", ChecksTimeout: "0", RequiredChecks: []string{"build", "tests"}}
+
+		err := mergePullRequest(ctx, &pr, &options, &ghPRService, pullRequestMergeClients{statuses: &statuses, checks: &checks})
+
+		if assert.NoError(t, err, "check runs of all pages are considered") {
+			assert.NotNil(t, ghPRService.mergeOpts)
+		}
+
+		ghPRService = ghPRMock{}
+		options.RequiredChecks = []string{"build", "security"}
+		err = mergePullRequest(ctx, &pr, &options, &ghPRService, pullRequestMergeClients{statuses: &statuses, checks: &checks})
+
+		assert.EqualError(t, err, "Pull request #1 not merged: checks did not finish within 0s: security (not reported yet)")
+		assert.Nil(t, ghPRService.mergeOpts)
+	})
+
+	t.Run("merge error", func(t *testing.T) {
+		statuses := ghStatusMock{statuses: [][]github.RepoStatus{{testStatus("build", "success")}}}
+		options := githubCreatePullRequestOptions{MergeWhenChecksPass: true, MergeMethod: "merge", ChecksTimeout: "0"}
+
+		err := mergePullRequest(ctx, &pr, &options, &ghPRMock{mergeError: fmt.Errorf("Merge conflict")}, pullRequestMergeClients{statuses: &statuses, checks: &ghChecksMock{}})
+
+		assert.EqualError(t, err, "Error occured when merging pull request #1: Merge conflict")
+	})
+}
+
+func TestValidatePullRequestMerge(t *testing.T) {
+	tt := []struct {
+		options githubCreatePullRequestOptions
+		err     string
+	}{
+		{options: githubCreatePullRequestOptions{Draft: true}},
+		{options: githubCreatePullRequestOptions{AutoMerge: true, MergeMethod: "squash"}},
+		{options: githubCreatePullRequestOptions{AutoMerge: true, MergeWhenChecksPass: true, MergeMethod: "merge"}, err: "Parameters 'autoMerge' and 'mergeWhenChecksPass' cannot be used together"},
+		{options: githubCreatePullRequestOptions{MergeWhenChecksPass: true, Draft: true, MergeMethod: "merge"}, err: "A draft pull request cannot be merged, please do not set 'draft' together with 'autoMerge' or 'mergeWhenChecksPass'"},
+		{options: githubCreatePullRequestOptions{MergeWhenChecksPass: true, MergeMethod: "fast-forward"}, err: "Invalid mergeMethod 'fast-forward'. Supported values: 'merge', 'squash', 'rebase'"},
+	}
+
+	for _, test := range tt {
+		err := validatePullRequestMerge(&test.options)
+		if len(test.err) == 0 {
+			assert.NoError(t, err)
+		} else {
+			assert.EqualError(t, err, test.err)
+		}
+	}
+}
@@ -10,17 +10,31 @@ import (
 )
 
 type githubCreatePullRequestOptions struct {
-	Assignees  []string `json:"assignees,omitempty"`
-	Base       string   `json:"base,omitempty"`
-	Body       string   `json:"body,omitempty"`
-	APIURL     string   `json:"apiUrl,omitempty"`
-	Head       string   `json:"head,omitempty"`
-	Owner      string   `json:"owner,omitempty"`
-	Repository string   `json:"repository,omitempty"`
-	ServerURL  string   `json:"serverUrl,omitempty"`
-	Title      string   `json:"title,omitempty"`
-	Token      string   `json:"token,omitempty"`
-	Labels     []string `json:"labels,omitempty"`
+	AppID               string   `json:"appId,omitempty"`
+	Assignees           []string `json:"assignees,omitempty"`
+	AutoMerge           bool     `json:"autoMerge,omitempty"`
+	Base                string   `json:"base,omitempty"`
+	Body                string   `json:"body,omitempty"`
+	ChecksTimeout       string   `json:"checksTimeout,omitempty"`
+	CommitFiles         []string `json:"commitFiles,omitempty"`
+	CommitMessage       string   `json:"commitMessage,omitempty"`
+	APIURL              string   `json:"apiUrl,omitempty"`
+	Draft               bool     `json:"draft,omitempty"`
+	Head                string   `json:"head,omitempty"`
+	InstallationID      string   `json:"installationId,omitempty"`
+	MergeMethod         string   `json:"mergeMethod,omitempty"`
+	MergeWhenChecksPass bool     `json:"mergeWhenChecksPass,omitempty"`
+	Owner               string   `json:"owner,omitempty"`
+	PrivateKey          string   `json:"privateKey,omitempty"`
+	Repository          string   `json:"repository,omitempty"`
+	RequiredChecks      []string `json:"requiredChecks,omitempty"`
+	Reviewers           []string `json:"reviewers,omitempty"`
+	ServerURL           string   `json:"serverUrl,omitempty"`
+	TeamReviewers       []string `json:"teamReviewers,omitempty"`
+	Title               string   `json:"title,omitempty"`
+	Token               string   `json:"token,omitempty"`
+	Labels              []string `json:"labels,omitempty"`
+	UpdateExisting      bool     `json:"updateExisting,omitempty"`
 }
 
 var myGithubCreatePullRequestOptions githubCreatePullRequestOptions
@@ -51,17 +65,31 @@ It can for example be used for GitOps scenarios or for scenarios where you want
 }
 
 func addGithubCreatePullRequestFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&myGithubCreatePullRequestOptions.AppID, "appId", os.Getenv("PIPER_appId"), "ID of the GitHub App used for authentication. In case it is provided, the step authenticates as installation `installationId` of the app instead of using `token`.")
 	cmd.Flags().StringSliceVar(&myGithubCreatePullRequestOptions.Assignees, "assignees", []string{}, "Login names of users to which the PR should be assigned to.")
+	cmd.Flags().BoolVar(&myGithubCreatePullRequestOptions.AutoMerge, "autoMerge", false, "If set to `true`, auto-merge is enabled for the pull request, i.e. GitHub merges the pull request as soon as all required reviews and checks succeeded. Auto-merge needs to be allowed in the repository settings.")
 	cmd.Flags().StringVar(&myGithubCreatePullRequestOptions.Base, "base", os.Getenv("PIPER_base"), "The name of the branch you want the changes pulled into.")
 	cmd.Flags().StringVar(&myGithubCreatePullRequestOptions.Body, "body", os.Getenv("PIPER_body"), "The description text of the pull request in markdown format.")
+	cmd.Flags().StringVar(&myGithubCreatePullRequestOptions.ChecksTimeout, "checksTimeout", "3600", "Time in seconds to wait for the checks of the pull request in case of `mergeWhenChecksPass`.")
+	cmd.Flags().StringSliceVar(&myGithubCreatePullRequestOptions.CommitFiles, "commitFiles", []string{}, "Workspace files which are committed to the branch `head` before the pull request is created, glob patterns like `config/*.yml` are supported. The commit is created via the GitHub API on top of `base`, an existing branch `head` is reset to it unless it contains commits which have not been created by this step. Thus neither git nor git credentials are required. In case the files do not contain any change, no pull request is created and an open pull request for `head` is closed.")
+	cmd.Flags().StringVar(&myGithubCreatePullRequestOptions.CommitMessage, "commitMessage", os.Getenv("PIPER_commitMessage"), "Message of the commit created for `commitFiles`. The title of the pull request is used if not set.")
 	cmd.Flags().StringVar(&myGithubCreatePullRequestOptions.APIURL, "apiUrl", "https://api.github.com", "Set the GitHub API url.")
+	cmd.Flags().BoolVar(&myGithubCreatePullRequestOptions.Draft, "draft", false, "If set to `true`, the pull request is created as draft. An existing pull request is not converted into a draft.")
 	cmd.Flags().StringVar(&myGithubCreatePullRequestOptions.Head, "head", os.Getenv("PIPER_head"), "The name of the branch where your changes are implemented.")
+	cmd.Flags().StringVar(&myGithubCreatePullRequestOptions.InstallationID, "installationId", os.Getenv("PIPER_installationId"), "ID of the installation of the GitHub App `appId` in the organization or repository.")
+	cmd.Flags().StringVar(&myGithubCreatePullRequestOptions.MergeMethod, "mergeMethod", "merge", "Defines how the pull request is merged in case of `autoMerge` or `mergeWhenChecksPass`. Values: 'merge', 'squash', 'rebase'")
+	cmd.Flags().BoolVar(&myGithubCreatePullRequestOptions.MergeWhenChecksPass, "mergeWhenChecksPass", false, "If set to `true`, the step waits until all statuses and check runs of the pull request succeeded and merges the pull request afterwards, see also `requiredChecks`. The step fails in case a check fails or the checks did not finish within `checksTimeout`.")
 	cmd.Flags().StringVar(&myGithubCreatePullRequestOptions.Owner, "owner", os.Getenv("PIPER_owner"), "Set the GitHub organization.")
+	cmd.Flags().StringVar(&myGithubCreatePullRequestOptions.PrivateKey, "privateKey", os.Getenv("PIPER_privateKey"), "Private key of the GitHub App `appId` in PEM format as generated by GitHub.")
 	cmd.Flags().StringVar(&myGithubCreatePullRequestOptions.Repository, "repository", os.Getenv("PIPER_repository"), "Set the GitHub repository.")
+	cmd.Flags().StringSliceVar(&myGithubCreatePullRequestOptions.RequiredChecks, "requiredChecks", []string{}, "Names of the statuses and check runs which have to succeed in case of `mergeWhenChecksPass`. The step waits until they are reported. In case none are configured, the step waits until at least one status or check run is reported.")
+	cmd.Flags().StringSliceVar(&myGithubCreatePullRequestOptions.Reviewers, "reviewers", []string{}, "Login names of users which are requested to review the pull request.")
 	cmd.Flags().StringVar(&myGithubCreatePullRequestOptions.ServerURL, "serverUrl", "https://github.com", "GitHub server url for end-user access.")
+	cmd.Flags().StringSliceVar(&myGithubCreatePullRequestOptions.TeamReviewers, "teamReviewers", []string{}, "Slugs of teams which are requested to review the pull request.")
 	cmd.Flags().StringVar(&myGithubCreatePullRequestOptions.Title, "title", os.Getenv("PIPER_title"), "Title of the pull request.")
-	cmd.Flags().StringVar(&myGithubCreatePullRequestOptions.Token, "token", os.Getenv("PIPER_token"), "GitHub personal access token as per https://help.github.com/en/github/authenticating-to-github/creating-a-personal-access-token-for-the-command-line")
+	cmd.Flags().StringVar(&myGithubCreatePullRequestOptions.Token, "token", os.Getenv("PIPER_token"), "GitHub personal access token as per https://help.github.com/en/github/authenticating-to-github/creating-a-personal-access-token-for-the-command-line. It is not required when authenticating as GitHub App.")
 	cmd.Flags().StringSliceVar(&myGithubCreatePullRequestOptions.Labels, "labels", []string{}, "Labels to be added to the pull request.")
+	cmd.Flags().BoolVar(&myGithubCreatePullRequestOptions.UpdateExisting, "updateExisting", true, "If set to `true`, an existing open pull request for `head` and `base` is updated with the given title, body, labels and assignees instead of creating a new pull request.")
 
 	cmd.MarkFlagRequired("base")
 	cmd.MarkFlagRequired("body")
@@ -71,7 +99,6 @@ func addGithubCreatePullRequestFlags(cmd *cobra.Command) {
 	cmd.MarkFlagRequired("repository")
 	cmd.MarkFlagRequired("serverUrl")
 	cmd.MarkFlagRequired("title")
-	cmd.MarkFlagRequired("token")
 }
 
 // retrieve step metadata
@@ -80,6 +107,14 @@ func githubCreatePullRequestMetadata() config.StepData {
 		Spec: config.StepSpec{
 			Inputs: config.StepInputs{
 				Parameters: []config.StepParameters{
+					{
+						Name:        "appId",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"GENERAL", "PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
 					{
 						Name:        "assignees",
 						ResourceRef: []config.ResourceReference{},
@@ -88,6 +123,14 @@ func githubCreatePullRequestMetadata() config.StepData {
 						Mandatory:   false,
 						Aliases:     []config.Alias{},
 					},
+					{
+						Name:        "autoMerge",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "bool",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
 					{
 						Name:        "base",
 						ResourceRef: []config.ResourceReference{},
@@ -104,6 +147,30 @@ func githubCreatePullRequestMetadata() config.StepData {
 						Mandatory:   true,
 						Aliases:     []config.Alias{},
 					},
+					{
+						Name:        "checksTimeout",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "commitFiles",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "[]string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "commitMessage",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
 					{
 						Name:        "apiUrl",
 						ResourceRef: []config.ResourceReference{},
@@ -112,6 +179,14 @@ func githubCreatePullRequestMetadata() config.StepData {
 						Mandatory:   true,
 						Aliases:     []config.Alias{{Name: "githubApiUrl"}},
 					},
+					{
+						Name:        "draft",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "bool",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
 					{
 						Name:        "head",
 						ResourceRef: []config.ResourceReference{},
@@ -120,6 +195,30 @@ func githubCreatePullRequestMetadata() config.StepData {
 						Mandatory:   true,
 						Aliases:     []config.Alias{},
 					},
+					{
+						Name:        "installationId",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"GENERAL", "PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "mergeMethod",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "mergeWhenChecksPass",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "bool",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
 					{
 						Name:        "owner",
 						ResourceRef: []config.ResourceReference{},
@@ -128,6 +227,14 @@ func githubCreatePullRequestMetadata() config.StepData {
 						Mandatory:   true,
 						Aliases:     []config.Alias{{Name: "githubOrg"}},
 					},
+					{
+						Name:        "privateKey",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
 					{
 						Name:        "repository",
 						ResourceRef: []config.ResourceReference{},
@@ -136,6 +243,22 @@ func githubCreatePullRequestMetadata() config.StepData {
 						Mandatory:   true,
 						Aliases:     []config.Alias{{Name: "githubRepo"}},
 					},
+					{
+						Name:        "requiredChecks",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "[]string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "reviewers",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "[]string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
 					{
 						Name:        "serverUrl",
 						ResourceRef: []config.ResourceReference{},
@@ -144,6 +267,14 @@ func githubCreatePullRequestMetadata() config.StepData {
 						Mandatory:   true,
 						Aliases:     []config.Alias{{Name: "githubServerUrl"}},
 					},
+					{
+						Name:        "teamReviewers",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "[]string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
 					{
 						Name:        "title",
 						ResourceRef: []config.ResourceReference{},
@@ -157,7 +288,7 @@ func githubCreatePullRequestMetadata() config.StepData {
 						ResourceRef: []config.ResourceReference{},
 						Scope:       []string{"GENERAL", "PARAMETERS", "STAGES", "STEPS"},
 						Type:        "string",
-						Mandatory:   true,
+						Mandatory:   false,
 						Aliases:     []config.Alias{{Name: "githubToken"}},
 					},
 					{
@@ -168,6 +299,14 @@ func githubCreatePullRequestMetadata() config.StepData {
 						Mandatory:   false,
 						Aliases:     []config.Alias{},
 					},
+					{
+						Name:        "updateExisting",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "bool",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
 				},
 			},
 		},
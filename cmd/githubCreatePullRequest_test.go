@@ -11,10 +11,18 @@ import (
 )
 
 type ghPRMock struct {
-	pullrequest *github.NewPullRequest
-	prError     error
-	owner       string
-	repo        string
+	pullrequest      *github.NewPullRequest
+	prError          error
+	owner            string
+	repo             string
+	existing         []*github.PullRequest
+	listOpts         *github.PullRequestListOptions
+	editRequest      *github.PullRequest
+	editError        error
+	reviewersRequest *github.ReviewersRequest
+	reviewersError   error
+	mergeOpts        *github.PullRequestOptions
+	mergeError       error
 }
 
 func (g *ghPRMock) Create(ctx context.Context, owner string, repo string, pull *github.NewPullRequest) (*github.PullRequest, *github.Response, error) {
@@ -33,6 +41,29 @@ func (g *ghPRMock) Create(ctx context.Context, owner string, repo string, pull *
 	return &pr, &ghRes, g.prError
 }
 
+func (g *ghPRMock) Edit(ctx context.Context, owner string, repo string, number int, pull *github.PullRequest) (*github.PullRequest, *github.Response, error) {
+	g.editRequest = pull
+	pr := *g.existing[0]
+	pr.Title = pull.Title
+	pr.Body = pull.Body
+	return &pr, nil, g.editError
+}
+
+func (g *ghPRMock) List(ctx context.Context, owner string, repo string, opt *github.PullRequestListOptions) ([]*github.PullRequest, *github.Response, error) {
+	g.listOpts = opt
+	return g.existing, nil, nil
+}
+
+func (g *ghPRMock) Merge(ctx context.Context, owner string, repo string, number int, commitMessage string, options *github.PullRequestOptions) (*github.PullRequestMergeResult, *github.Response, error) {
+	g.mergeOpts = options
+	return &github.PullRequestMergeResult{}, nil, g.mergeError
+}
+
+func (g *ghPRMock) RequestReviewers(ctx context.Context, owner, repo string, number int, reviewers github.ReviewersRequest) (*github.PullRequest, *github.Response, error) {
+	g.reviewersRequest = &reviewers
+	return nil, nil, g.reviewersError
+}
+
 type ghIssueMock struct {
 	issueRequest *github.IssueRequest
 	issueError   error
@@ -84,7 +115,7 @@ func TestRunGithubCreatePullRequest(t *testing.T) {
 		ghPRService := ghPRMock{}
 		ghIssueService := ghIssueMock{}
 
-		err := runGithubCreatePullRequest(ctx, &myGithubPROptions, &ghPRService, &ghIssueService)
+		err := runGithubCreatePullRequest(ctx, &myGithubPROptions, &ghPRService, &ghIssueService, &ghCommitMock{}, pullRequestMergeClients{})
 		assert.NoError(t, err, "Error occured but none expected.")
 
 		assert.Equal(t, myGithubPROptions.Owner, ghPRService.owner, "Owner not passed correctly")
@@ -101,20 +132,73 @@ func TestRunGithubCreatePullRequest(t *testing.T) {
 		assert.Equal(t, 1, ghIssueService.number, "PR number not passed correctly")
 	})
 
+	t.Run("Success - update existing pull request", func(t *testing.T) {
+		ghPRService := ghPRMock{existing: []*github.PullRequest{{Number: github.Int(5), Title: github.String("Old title"), User: &github.User{Login: github.String("User1")}}}}
+		ghIssueService := ghIssueMock{}
+		options := myGithubPROptions
+		options.UpdateExisting = true
+		options.Reviewers = []string{"user1", "User3"}
+		options.TeamReviewers = []string{"team1"}
+
+		err := runGithubCreatePullRequest(ctx, &options, &ghPRService, &ghIssueService, &ghCommitMock{}, pullRequestMergeClients{})
+
+		if assert.NoError(t, err, "Error occured but none expected.") {
+			assert.Nil(t, ghPRService.pullrequest, "Pull request created but update expected")
+			assert.Equal(t, github.PullRequestListOptions{State: "open", Head: "TEST:head/test", Base: "base/test"}, *ghPRService.listOpts)
+			assert.Equal(t, github.PullRequest{Title: &options.Title, Body: &options.Body}, *ghPRService.editRequest)
+			assert.Equal(t, 5, ghIssueService.number, "PR number not passed correctly")
+			assert.Equal(t, options.Labels, ghIssueService.issueRequest.GetLabels(), "Labels not passed correctly")
+			// the author cannot review the pull request
+			assert.Equal(t, github.ReviewersRequest{Reviewers: []string{"User3"}, TeamReviewers: []string{"team1"}}, *ghPRService.reviewersRequest)
+		}
+	})
+
+	t.Run("Success - draft without existing pull request", func(t *testing.T) {
+		ghPRService := ghPRMock{}
+		options := myGithubPROptions
+		options.UpdateExisting = true
+		options.Draft = true
+
+		err := runGithubCreatePullRequest(ctx, &options, &ghPRService, &ghIssueMock{}, &ghCommitMock{}, pullRequestMergeClients{})
+
+		if assert.NoError(t, err, "Error occured but none expected.") {
+			assert.True(t, ghPRService.pullrequest.GetDraft(), "Draft not passed correctly")
+			assert.Nil(t, ghPRService.reviewersRequest, "Reviewers requested but none configured")
+		}
+	})
+
 	t.Run("Create error", func(t *testing.T) {
 		ghPRService := ghPRMock{prError: fmt.Errorf("Authentication failed")}
 		ghIssueService := ghIssueMock{}
 
-		err := runGithubCreatePullRequest(ctx, &myGithubPROptions, &ghPRService, &ghIssueService)
+		err := runGithubCreatePullRequest(ctx, &myGithubPROptions, &ghPRService, &ghIssueService, &ghCommitMock{}, pullRequestMergeClients{})
 		assert.EqualError(t, err, "Error occured when creating pull request: Authentication failed", "Wrong error returned")
 
 	})
 
+	t.Run("Update error", func(t *testing.T) {
+		ghPRService := ghPRMock{existing: []*github.PullRequest{{Number: github.Int(5)}}, editError: fmt.Errorf("Authentication failed")}
+		options := myGithubPROptions
+		options.UpdateExisting = true
+
+		err := runGithubCreatePullRequest(ctx, &options, &ghPRService, &ghIssueMock{}, &ghCommitMock{}, pullRequestMergeClients{})
+		assert.EqualError(t, err, "Error occured when updating pull request #5: Authentication failed", "Wrong error returned")
+	})
+
+	t.Run("Reviewers error", func(t *testing.T) {
+		ghPRService := ghPRMock{reviewersError: fmt.Errorf("Reviewer is not a collaborator")}
+		options := myGithubPROptions
+		options.Reviewers = []string{"User3"}
+
+		err := runGithubCreatePullRequest(ctx, &options, &ghPRService, &ghIssueMock{}, &ghCommitMock{}, pullRequestMergeClients{})
+		assert.EqualError(t, err, "Error occured when requesting reviewers: Reviewer is not a collaborator", "Wrong error returned")
+	})
+
 	t.Run("Edit error", func(t *testing.T) {
 		ghPRService := ghPRMock{}
 		ghIssueService := ghIssueMock{issueError: fmt.Errorf("Authentication failed")}
 
-		err := runGithubCreatePullRequest(ctx, &myGithubPROptions, &ghPRService, &ghIssueService)
+		err := runGithubCreatePullRequest(ctx, &myGithubPROptions, &ghPRService, &ghIssueService, &ghCommitMock{}, pullRequestMergeClients{})
 		assert.EqualError(t, err, "Error occured when editing pull request: Authentication failed", "Wrong error returned")
 	})
 }
@@ -3,9 +3,7 @@ package cmd
 import (
 	"context"
 	"fmt"
-	"mime"
 	"os"
-	"path/filepath"
 	"strings"
 
 	"github.com/SAP/jenkins-library/pkg/log"
@@ -18,22 +16,33 @@ import (
 type githubRepoClient interface {
 	CreateRelease(ctx context.Context, owner string, repo string, release *github.RepositoryRelease) (*github.RepositoryRelease, *github.Response, error)
 	DeleteReleaseAsset(ctx context.Context, owner string, repo string, id int64) (*github.Response, error)
+	EditRelease(ctx context.Context, owner string, repo string, id int64, release *github.RepositoryRelease) (*github.RepositoryRelease, *github.Response, error)
 	GetLatestRelease(ctx context.Context, owner string, repo string) (*github.RepositoryRelease, *github.Response, error)
+	ListReleases(ctx context.Context, owner string, repo string, opt *github.ListOptions) ([]*github.RepositoryRelease, *github.Response, error)
 	ListReleaseAssets(ctx context.Context, owner string, repo string, id int64, opt *github.ListOptions) ([]*github.ReleaseAsset, *github.Response, error)
 	UploadReleaseAsset(ctx context.Context, owner string, repo string, id int64, opt *github.UploadOptions, file *os.File) (*github.ReleaseAsset, *github.Response, error)
 }
 
 type githubIssueClient interface {
+	Create(ctx context.Context, owner string, repo string, issue *github.IssueRequest) (*github.Issue, *github.Response, error)
+	CreateComment(ctx context.Context, owner string, repo string, number int, comment *github.IssueComment) (*github.IssueComment, *github.Response, error)
+	Edit(ctx context.Context, owner string, repo string, number int, issue *github.IssueRequest) (*github.Issue, *github.Response, error)
 	ListByRepo(ctx context.Context, owner string, repo string, opt *github.IssueListByRepoOptions) ([]*github.Issue, *github.Response, error)
 }
 
 func githubPublishRelease(myGithubPublishReleaseOptions githubPublishReleaseOptions) error {
-	ctx, client, err := piperGithub.NewClient(myGithubPublishReleaseOptions.Token, myGithubPublishReleaseOptions.APIURL, myGithubPublishReleaseOptions.UploadURL)
+	auth := piperGithub.Authentication{
+		Token:          myGithubPublishReleaseOptions.Token,
+		AppID:          myGithubPublishReleaseOptions.AppID,
+		InstallationID: myGithubPublishReleaseOptions.InstallationID,
+		PrivateKey:     myGithubPublishReleaseOptions.PrivateKey,
+	}
+	ctx, client, err := piperGithub.NewAuthenticatedClient(auth, myGithubPublishReleaseOptions.APIURL, myGithubPublishReleaseOptions.UploadURL)
 	if err != nil {
 		log.Entry().WithError(err).Fatal("Failed to get GitHub client.")
 	}
 
-	err = runGithubPublishRelease(ctx, &myGithubPublishReleaseOptions, client.Repositories, client.Issues)
+	err = runGithubPublishRelease(ctx, &myGithubPublishReleaseOptions, client.Repositories, client.Issues, piperGithub.NewCompareService(client), client.PullRequests)
 	if err != nil {
 		log.Entry().WithError(err).Fatal("Failed to publish GitHub release.")
 	}
@@ -41,26 +50,25 @@ func githubPublishRelease(myGithubPublishReleaseOptions githubPublishReleaseOpti
 	return nil
 }
 
-func runGithubPublishRelease(ctx context.Context, myGithubPublishReleaseOptions *githubPublishReleaseOptions, ghRepoClient githubRepoClient, ghIssueClient githubIssueClient) error {
+func runGithubPublishRelease(ctx context.Context, myGithubPublishReleaseOptions *githubPublishReleaseOptions, ghRepoClient githubRepoClient, ghIssueClient githubIssueClient, ghCompareClient githubCompareClient, ghPullClient githubPullClient) error {
 
 	var publishedAt github.Timestamp
 
-	lastRelease, resp, err := ghRepoClient.GetLatestRelease(ctx, myGithubPublishReleaseOptions.Owner, myGithubPublishReleaseOptions.Repository)
+	lastRelease, err := getPreviousRelease(ctx, myGithubPublishReleaseOptions, ghRepoClient)
 	if err != nil {
-		if resp.StatusCode == 404 {
-			//no previous release found -> first release
-			myGithubPublishReleaseOptions.AddDeltaToLastRelease = false
-			log.Entry().Debug("This is the first release.")
-		} else {
-			return errors.Wrap(err, "Error occured when retrieving latest GitHub release.")
-		}
+		return err
+	}
+	if lastRelease == nil {
+		//no previous release found -> first release
+		myGithubPublishReleaseOptions.AddDeltaToLastRelease = false
+		log.Entry().Debug("This is the first release.")
 	}
 	publishedAt = lastRelease.GetPublishedAt()
 	log.Entry().Debugf("Previous GitHub release published: '%v'", publishedAt)
 
 	//updating assets only supported on latest release
-	if len(myGithubPublishReleaseOptions.AssetPath) > 0 && myGithubPublishReleaseOptions.Version == "latest" {
-		return uploadReleaseAsset(ctx, lastRelease.GetID(), myGithubPublishReleaseOptions, ghRepoClient)
+	if hasReleaseAssets(myGithubPublishReleaseOptions) && myGithubPublishReleaseOptions.Version == "latest" {
+		return uploadReleaseAssets(ctx, lastRelease.GetID(), myGithubPublishReleaseOptions, ghRepoClient)
 	}
 
 	releaseBody := ""
@@ -69,6 +77,14 @@ func runGithubPublishRelease(ctx context.Context, myGithubPublishReleaseOptions
 		releaseBody += myGithubPublishReleaseOptions.ReleaseBodyHeader + "\n"
 	}
 
+	if myGithubPublishReleaseOptions.AddChangelog {
+		changelogText, err := getChangelogText(ctx, lastRelease, myGithubPublishReleaseOptions, ghCompareClient, ghPullClient)
+		if err != nil {
+			return errors.Wrap(err, "Failed to create changelog")
+		}
+		releaseBody += changelogText
+	}
+
 	if myGithubPublishReleaseOptions.AddClosedIssues {
 		releaseBody += getClosedIssuesText(ctx, publishedAt, myGithubPublishReleaseOptions, ghIssueClient)
 	}
@@ -77,11 +93,23 @@ func runGithubPublishRelease(ctx context.Context, myGithubPublishReleaseOptions
 		releaseBody += getReleaseDeltaText(myGithubPublishReleaseOptions, lastRelease)
 	}
 
+	if myGithubPublishReleaseOptions.UpdateExisting || myGithubPublishReleaseOptions.PromoteDraft {
+		existingRelease, err := getReleaseByTag(ctx, myGithubPublishReleaseOptions.Version, myGithubPublishReleaseOptions, ghRepoClient)
+		if err != nil {
+			return err
+		}
+		if existingRelease != nil {
+			return updateRelease(ctx, existingRelease, releaseBody, myGithubPublishReleaseOptions, ghRepoClient)
+		}
+	}
+
 	release := github.RepositoryRelease{
 		TagName:         &myGithubPublishReleaseOptions.Version,
 		TargetCommitish: &myGithubPublishReleaseOptions.Commitish,
 		Name:            &myGithubPublishReleaseOptions.Version,
 		Body:            &releaseBody,
+		Draft:           &myGithubPublishReleaseOptions.Draft,
+		Prerelease:      &myGithubPublishReleaseOptions.Prerelease,
 	}
 
 	createdRelease, _, err := ghRepoClient.CreateRelease(ctx, myGithubPublishReleaseOptions.Owner, myGithubPublishReleaseOptions.Repository, &release)
@@ -90,10 +118,48 @@ func runGithubPublishRelease(ctx context.Context, myGithubPublishReleaseOptions
 	}
 	log.Entry().Infof("Release %v created on %v/%v", *createdRelease.TagName, myGithubPublishReleaseOptions.Owner, myGithubPublishReleaseOptions.Repository)
 
-	if len(myGithubPublishReleaseOptions.AssetPath) > 0 {
-		return uploadReleaseAsset(ctx, createdRelease.GetID(), myGithubPublishReleaseOptions, ghRepoClient)
+	if hasReleaseAssets(myGithubPublishReleaseOptions) {
+		return uploadReleaseAssets(ctx, createdRelease.GetID(), myGithubPublishReleaseOptions, ghRepoClient)
+	}
+
+	return nil
+}
+
+// updateRelease updates the information and the assets of an existing release. A draft is published
+// only after the assets have been uploaded.
+func updateRelease(ctx context.Context, existingRelease *github.RepositoryRelease, releaseBody string, myGithubPublishReleaseOptions *githubPublishReleaseOptions, ghRepoClient githubRepoClient) error {
+	if hasReleaseAssets(myGithubPublishReleaseOptions) {
+		if err := uploadReleaseAssets(ctx, existingRelease.GetID(), myGithubPublishReleaseOptions, ghRepoClient); err != nil {
+			return err
+		}
+	}
+
+	release := github.RepositoryRelease{}
+	changed := false
+	if myGithubPublishReleaseOptions.UpdateExisting {
+		release.Name = &myGithubPublishReleaseOptions.Version
+		release.Body = &releaseBody
+		release.Prerelease = &myGithubPublishReleaseOptions.Prerelease
+		changed = true
+	}
+	if myGithubPublishReleaseOptions.PromoteDraft && existingRelease.GetDraft() {
+		release.Draft = github.Bool(false)
+		changed = true
+	}
+	if !changed {
+		log.Entry().Infof("Release %v is already published on %v/%v", existingRelease.GetTagName(), myGithubPublishReleaseOptions.Owner, myGithubPublishReleaseOptions.Repository)
+		return nil
 	}
 
+	_, _, err := ghRepoClient.EditRelease(ctx, myGithubPublishReleaseOptions.Owner, myGithubPublishReleaseOptions.Repository, existingRelease.GetID(), &release)
+	if err != nil {
+		return errors.Wrapf(err, "Update of release '%v' failed", existingRelease.GetTagName())
+	}
+	if release.Draft != nil {
+		log.Entry().Infof("Release %v published on %v/%v", existingRelease.GetTagName(), myGithubPublishReleaseOptions.Owner, myGithubPublishReleaseOptions.Repository)
+	} else {
+		log.Entry().Infof("Release %v updated on %v/%v", existingRelease.GetTagName(), myGithubPublishReleaseOptions.Owner, myGithubPublishReleaseOptions.Repository)
+	}
 	return nil
 }
 
@@ -101,16 +167,27 @@ func getClosedIssuesText(ctx context.Context, publishedAt github.Timestamp, myGi
 	closedIssuesText := ""
 
 	options := github.IssueListByRepoOptions{
-		State:     "closed",
-		Direction: "asc",
-		Since:     publishedAt.Time,
+		State:       "closed",
+		Direction:   "asc",
+		Since:       publishedAt.Time,
+		ListOptions: github.ListOptions{PerPage: githubPageSize},
 	}
 	if len(myGithubPublishReleaseOptions.Labels) > 0 {
 		options.Labels = myGithubPublishReleaseOptions.Labels
 	}
-	ghIssues, _, err := ghIssueClient.ListByRepo(ctx, myGithubPublishReleaseOptions.Owner, myGithubPublishReleaseOptions.Repository, &options)
-	if err != nil {
-		log.Entry().WithError(err).Error("Failed to get GitHub issues.")
+
+	ghIssues := []*github.Issue{}
+	for {
+		pageIssues, resp, err := ghIssueClient.ListByRepo(ctx, myGithubPublishReleaseOptions.Owner, myGithubPublishReleaseOptions.Repository, &options)
+		if err != nil {
+			log.Entry().WithError(err).Error("Failed to get GitHub issues.")
+			break
+		}
+		ghIssues = append(ghIssues, pageIssues...)
+		if resp == nil || resp.NextPage == 0 {
+			break
+		}
+		options.Page = resp.NextPage
 	}
 
 	prTexts := []string{"**List of closed pull-requests since last release**"}
@@ -154,56 +231,6 @@ func getReleaseDeltaText(myGithubPublishReleaseOptions *githubPublishReleaseOpti
 	return releaseDeltaText
 }
 
-func uploadReleaseAsset(ctx context.Context, releaseID int64, myGithubPublishReleaseOptions *githubPublishReleaseOptions, ghRepoClient githubRepoClient) error {
-
-	assets, _, err := ghRepoClient.ListReleaseAssets(ctx, myGithubPublishReleaseOptions.Owner, myGithubPublishReleaseOptions.Repository, releaseID, &github.ListOptions{})
-	if err != nil {
-		return errors.Wrap(err, "Failed to get list of release assets.")
-	}
-	var assetID int64
-	for _, a := range assets {
-		if a.GetName() == filepath.Base(myGithubPublishReleaseOptions.AssetPath) {
-			assetID = a.GetID()
-			break
-		}
-	}
-	if assetID != 0 {
-		//asset needs to be deleted first since API does not allow for replacement
-		_, err := ghRepoClient.DeleteReleaseAsset(ctx, myGithubPublishReleaseOptions.Owner, myGithubPublishReleaseOptions.Repository, assetID)
-		if err != nil {
-			return errors.Wrap(err, "Failed to delete release asset.")
-		}
-	}
-
-	mediaType := mime.TypeByExtension(filepath.Ext(myGithubPublishReleaseOptions.AssetPath))
-	if mediaType == "" {
-		mediaType = "application/octet-stream"
-	}
-	log.Entry().Debugf("Using mediaType '%v'", mediaType)
-
-	name := filepath.Base(myGithubPublishReleaseOptions.AssetPath)
-	log.Entry().Debugf("Using file name '%v'", name)
-
-	opts := github.UploadOptions{
-		Name:      name,
-		MediaType: mediaType,
-	}
-	file, err := os.Open(myGithubPublishReleaseOptions.AssetPath)
-	defer file.Close()
-	if err != nil {
-		return errors.Wrapf(err, "Failed to load release asset '%v'", myGithubPublishReleaseOptions.AssetPath)
-	}
-
-	log.Entry().Info("Starting to upload release asset.")
-	asset, _, err := ghRepoClient.UploadReleaseAsset(ctx, myGithubPublishReleaseOptions.Owner, myGithubPublishReleaseOptions.Repository, releaseID, &opts, file)
-	if err != nil {
-		return errors.Wrap(err, "Failed to upload release asset.")
-	}
-	log.Entry().Infof("Done uploading asset '%v'.", asset.GetURL())
-
-	return nil
-}
-
 func isExcluded(issue *github.Issue, excludeLabels []string) bool {
 	//issue.Labels[0].GetName()
 	for _, ex := range excludeLabels {
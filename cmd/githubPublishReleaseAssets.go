@@ -0,0 +1,286 @@
+package cmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/SAP/jenkins-library/pkg/log"
+	"github.com/google/go-github/v28/github"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/openpgp"
+)
+
+// name of the asset containing the checksums of all release assets
+const releaseChecksumsAsset = "SHA256SUMS"
+
+type releaseAsset struct {
+	name string
+	path string
+}
+
+func hasReleaseAssets(myGithubPublishReleaseOptions *githubPublishReleaseOptions) bool {
+	return len(myGithubPublishReleaseOptions.AssetPath) > 0 || len(myGithubPublishReleaseOptions.AssetPathList) > 0
+}
+
+// uploadReleaseAssets uploads all assets including checksums and signatures. Existing assets with the
+// same name are replaced since the API does not allow to update assets.
+func uploadReleaseAssets(ctx context.Context, releaseID int64, myGithubPublishReleaseOptions *githubPublishReleaseOptions, ghRepoClient githubRepoClient) error {
+
+	concurrency := 1
+	if len(myGithubPublishReleaseOptions.AssetUploadConcurrency) > 0 {
+		var err error
+		concurrency, err = strconv.Atoi(myGithubPublishReleaseOptions.AssetUploadConcurrency)
+		if err != nil || concurrency < 1 {
+			return fmt.Errorf("Invalid assetUploadConcurrency '%v', expected a positive number", myGithubPublishReleaseOptions.AssetUploadConcurrency)
+		}
+	}
+
+	assets, err := getReleaseAssets(myGithubPublishReleaseOptions)
+	if err != nil {
+		return err
+	}
+
+	tmpDir, err := ioutil.TempDir("", "releaseAssets")
+	if err != nil {
+		return errors.Wrap(err, "Failed to create directory for generated release assets")
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if myGithubPublishReleaseOptions.AssetChecksums {
+		checksums, err := writeReleaseChecksums(assets, tmpDir)
+		if err != nil {
+			return err
+		}
+		assets = append(assets, checksums)
+	}
+
+	if len(myGithubPublishReleaseOptions.SigningKeyPath) > 0 {
+		signatures, err := writeReleaseSignatures(assets, myGithubPublishReleaseOptions.SigningKeyPath, myGithubPublishReleaseOptions.SigningKeyPassphrase, tmpDir)
+		if err != nil {
+			return err
+		}
+		assets = append(assets, signatures...)
+	}
+
+	existingAssets, err := listReleaseAssets(ctx, releaseID, myGithubPublishReleaseOptions, ghRepoClient)
+	if err != nil {
+		return err
+	}
+
+	errs := make([]error, len(assets))
+	semaphore := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, asset := range assets {
+		wg.Add(1)
+		// the logger is created upfront since log.Entry() is not safe for concurrent use
+		logger := log.Entry().WithField("asset", asset.name)
+		go func(i int, asset releaseAsset, logger *logrus.Entry) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+			errs[i] = uploadReleaseAsset(ctx, releaseID, asset, existingAssets[asset.name], myGithubPublishReleaseOptions, ghRepoClient, logger)
+		}(i, asset, logger)
+	}
+	wg.Wait()
+
+	failed := []string{}
+	for i, err := range errs {
+		if err != nil {
+			log.Entry().WithError(err).Errorf("Upload of release asset '%v' failed", assets[i].name)
+			failed = append(failed, assets[i].name)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("Failed to upload %v of %v release assets: %v", len(failed), len(assets), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// getReleaseAssets resolves the glob patterns of the configured asset paths
+func getReleaseAssets(myGithubPublishReleaseOptions *githubPublishReleaseOptions) ([]releaseAsset, error) {
+	patterns := myGithubPublishReleaseOptions.AssetPathList
+	if len(myGithubPublishReleaseOptions.AssetPath) > 0 {
+		patterns = append([]string{myGithubPublishReleaseOptions.AssetPath}, patterns...)
+	}
+
+	assets := []releaseAsset{}
+	paths := map[string]string{}
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Invalid asset path '%v'", pattern)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("No release asset found for '%v'", pattern)
+		}
+		for _, path := range matches {
+			name := filepath.Base(path)
+			if existingPath, ok := paths[name]; ok {
+				if existingPath == path {
+					continue
+				}
+				return nil, fmt.Errorf("Release assets '%v' and '%v' have the same name", existingPath, path)
+			}
+			if name == releaseChecksumsAsset && myGithubPublishReleaseOptions.AssetChecksums {
+				return nil, fmt.Errorf("Release asset '%v' conflicts with the generated checksums", path)
+			}
+			paths[name] = path
+			assets = append(assets, releaseAsset{name: name, path: path})
+		}
+	}
+	return assets, nil
+}
+
+func listReleaseAssets(ctx context.Context, releaseID int64, myGithubPublishReleaseOptions *githubPublishReleaseOptions, ghRepoClient githubRepoClient) (map[string]int64, error) {
+	assetIDs := map[string]int64{}
+	options := github.ListOptions{PerPage: githubPageSize}
+	for {
+		assets, resp, err := ghRepoClient.ListReleaseAssets(ctx, myGithubPublishReleaseOptions.Owner, myGithubPublishReleaseOptions.Repository, releaseID, &options)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to get list of release assets.")
+		}
+		for _, a := range assets {
+			assetIDs[a.GetName()] = a.GetID()
+		}
+		if resp == nil || resp.NextPage == 0 {
+			return assetIDs, nil
+		}
+		options.Page = resp.NextPage
+	}
+}
+
+func uploadReleaseAsset(ctx context.Context, releaseID int64, asset releaseAsset, existingAssetID int64, myGithubPublishReleaseOptions *githubPublishReleaseOptions, ghRepoClient githubRepoClient, logger *logrus.Entry) error {
+	if existingAssetID != 0 {
+		//asset needs to be deleted first since API does not allow for replacement
+		_, err := ghRepoClient.DeleteReleaseAsset(ctx, myGithubPublishReleaseOptions.Owner, myGithubPublishReleaseOptions.Repository, existingAssetID)
+		if err != nil {
+			return errors.Wrap(err, "Failed to delete release asset.")
+		}
+	}
+
+	mediaType := mime.TypeByExtension(filepath.Ext(asset.path))
+	if mediaType == "" {
+		mediaType = "application/octet-stream"
+	}
+	logger.Debugf("Using mediaType '%v' for '%v'", mediaType, asset.name)
+
+	opts := github.UploadOptions{
+		Name:      asset.name,
+		MediaType: mediaType,
+	}
+	file, err := os.Open(asset.path)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to load release asset '%v'", asset.path)
+	}
+	defer file.Close()
+
+	logger.Infof("Starting to upload release asset '%v'.", asset.name)
+	uploadedAsset, _, err := ghRepoClient.UploadReleaseAsset(ctx, myGithubPublishReleaseOptions.Owner, myGithubPublishReleaseOptions.Repository, releaseID, &opts, file)
+	if err != nil {
+		return errors.Wrap(err, "Failed to upload release asset.")
+	}
+	logger.Infof("Done uploading asset '%v'.", uploadedAsset.GetURL())
+	return nil
+}
+
+// writeReleaseChecksums creates the checksums file in the format of sha256sum
+func writeReleaseChecksums(assets []releaseAsset, dir string) (releaseAsset, error) {
+	sorted := append([]releaseAsset{}, assets...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].name < sorted[j].name })
+
+	lines := []string{}
+	for _, asset := range sorted {
+		file, err := os.Open(asset.path)
+		if err != nil {
+			return releaseAsset{}, errors.Wrapf(err, "Failed to load release asset '%v'", asset.path)
+		}
+		hash := sha256.New()
+		_, err = io.Copy(hash, file)
+		file.Close()
+		if err != nil {
+			return releaseAsset{}, errors.Wrapf(err, "Failed to calculate checksum of release asset '%v'", asset.path)
+		}
+		lines = append(lines, fmt.Sprintf("%x  %v\n", hash.Sum(nil), asset.name))
+	}
+
+	checksums := releaseAsset{name: releaseChecksumsAsset, path: filepath.Join(dir, releaseChecksumsAsset)}
+	if err := ioutil.WriteFile(checksums.path, []byte(strings.Join(lines, "")), 0644); err != nil {
+		return releaseAsset{}, errors.Wrap(err, "Failed to write release checksums")
+	}
+	return checksums, nil
+}
+
+// writeReleaseSignatures creates armored detached signatures for all assets
+func writeReleaseSignatures(assets []releaseAsset, keyPath, passphrase, dir string) ([]releaseAsset, error) {
+	signer, err := readSigningKey(keyPath, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	signatures := []releaseAsset{}
+	for _, asset := range assets {
+		signature := releaseAsset{name: asset.name + ".asc", path: filepath.Join(dir, asset.name+".asc")}
+		if err := signReleaseAsset(asset, signature, signer); err != nil {
+			return nil, err
+		}
+		signatures = append(signatures, signature)
+	}
+	return signatures, nil
+}
+
+func signReleaseAsset(asset, signature releaseAsset, signer *openpgp.Entity) error {
+	file, err := os.Open(asset.path)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to load release asset '%v'", asset.path)
+	}
+	defer file.Close()
+
+	signatureFile, err := os.Create(signature.path)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to create signature of release asset '%v'", asset.path)
+	}
+	defer signatureFile.Close()
+
+	if err := openpgp.ArmoredDetachSign(signatureFile, signer, file, nil); err != nil {
+		return errors.Wrapf(err, "Failed to sign release asset '%v'", asset.path)
+	}
+	return nil
+}
+
+// readSigningKey provides the first private key of the armored key ring, an encrypted key is decrypted using the passphrase
+func readSigningKey(keyPath, passphrase string) (*openpgp.Entity, error) {
+	file, err := os.Open(keyPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to load signing key '%v'", keyPath)
+	}
+	defer file.Close()
+
+	keyRing, err := openpgp.ReadArmoredKeyRing(file)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to read signing key '%v'", keyPath)
+	}
+
+	for _, entity := range keyRing {
+		if entity.PrivateKey == nil {
+			continue
+		}
+		if entity.PrivateKey.Encrypted {
+			if err := entity.PrivateKey.Decrypt([]byte(passphrase)); err != nil {
+				return nil, errors.Wrapf(err, "Failed to decrypt signing key '%v'", keyPath)
+			}
+		}
+		return entity, nil
+	}
+	return nil, fmt.Errorf("No private key found in signing key '%v'", keyPath)
+}
@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v28/github"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+)
+
+func TestUploadReleaseAssets(t *testing.T) {
+	ctx := context.Background()
+	var releaseID int64 = 1
+
+	dir, err := ioutil.TempDir("", "releaseAssetsTest")
+	if err != nil {
+		t.Fatal("Failed to create temporary directory")
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{"a.jar": "content a", "b.jar": "content b", "notes.txt": "notes", "other/a.jar": "other a"}
+	for name, content := range files {
+		os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755)
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal("Failed to create test file")
+		}
+	}
+
+	checksum := func(content string) string {
+		return fmt.Sprintf("%x", sha256.Sum256([]byte(content)))
+	}
+
+	t.Run("Success - glob patterns and checksums", func(t *testing.T) {
+		existingName := "a.jar"
+		var existingID int64 = 5
+		ghRepoClient := ghRCMock{listReleaseAssets: []*github.ReleaseAsset{{Name: &existingName, ID: &existingID}}}
+
+		myGithubPublishReleaseOptions := githubPublishReleaseOptions{
+			Owner:                  "TEST",
+			Repository:             "test",
+			AssetPath:              filepath.Join(dir, "notes.txt"),
+			AssetPathList:          []string{filepath.Join(dir, "*.jar"), filepath.Join(dir, "a.jar")},
+			AssetChecksums:         true,
+			AssetUploadConcurrency: "2",
+		}
+
+		err := uploadReleaseAssets(ctx, releaseID, &myGithubPublishReleaseOptions, &ghRepoClient)
+
+		if assert.NoError(t, err) {
+			assert.Equal(t, map[string]string{
+				"a.jar":     "content a",
+				"b.jar":     "content b",
+				"notes.txt": "notes",
+				"SHA256SUMS": checksum("content a") + "  a.jar\n" +
+					checksum("content b") + "  b.jar\n" +
+					checksum("notes") + "  notes.txt\n",
+			}, ghRepoClient.uploadedAssets)
+			assert.Equal(t, existingID, ghRepoClient.delID)
+			assert.Equal(t, 100, ghRepoClient.listOpts.PerPage)
+		}
+	})
+
+	t.Run("Success - signatures", func(t *testing.T) {
+		signer, err := openpgp.NewEntity("Test", "", "test@example.org", nil)
+		if err != nil {
+			t.Fatal("Failed to create signing key")
+		}
+		keyPath := filepath.Join(dir, "key.asc")
+		keyFile, _ := os.Create(keyPath)
+		w, _ := armor.Encode(keyFile, openpgp.PrivateKeyType, nil)
+		signer.SerializePrivate(w, nil)
+		w.Close()
+		keyFile.Close()
+
+		ghRepoClient := ghRCMock{}
+		myGithubPublishReleaseOptions := githubPublishReleaseOptions{
+			AssetPathList:  []string{filepath.Join(dir, "b.jar")},
+			AssetChecksums: true,
+			SigningKeyPath: keyPath,
+		}
+
+		err = uploadReleaseAssets(ctx, releaseID, &myGithubPublishReleaseOptions, &ghRepoClient)
+
+		if assert.NoError(t, err) && assert.Len(t, ghRepoClient.uploadedAssets, 4) {
+			for _, name := range []string{"b.jar", "SHA256SUMS"} {
+				_, err := openpgp.CheckArmoredDetachedSignature(openpgp.EntityList{signer},
+					strings.NewReader(ghRepoClient.uploadedAssets[name]),
+					strings.NewReader(ghRepoClient.uploadedAssets[name+".asc"]))
+				assert.NoError(t, err, fmt.Sprintf("signature of %v not valid", name))
+			}
+		}
+	})
+
+	t.Run("Error - invalid configuration", func(t *testing.T) {
+		ghRepoClient := ghRCMock{}
+		myGithubPublishReleaseOptions := githubPublishReleaseOptions{AssetPathList: []string{filepath.Join(dir, "*.war")}}
+
+		err := uploadReleaseAssets(ctx, releaseID, &myGithubPublishReleaseOptions, &ghRepoClient)
+		assert.EqualError(t, err, fmt.Sprintf("No release asset found for '%v'", filepath.Join(dir, "*.war")))
+
+		myGithubPublishReleaseOptions.AssetPathList = []string{filepath.Join(dir, "a.jar"), filepath.Join(dir, "other", "*.jar")}
+		err = uploadReleaseAssets(ctx, releaseID, &myGithubPublishReleaseOptions, &ghRepoClient)
+		assert.EqualError(t, err, fmt.Sprintf("Release assets '%v' and '%v' have the same name", filepath.Join(dir, "a.jar"), filepath.Join(dir, "other", "a.jar")))
+
+		myGithubPublishReleaseOptions.AssetUploadConcurrency = "0"
+		err = uploadReleaseAssets(ctx, releaseID, &myGithubPublishReleaseOptions, &ghRepoClient)
+		assert.EqualError(t, err, "Invalid assetUploadConcurrency '0', expected a positive number")
+
+		myGithubPublishReleaseOptions = githubPublishReleaseOptions{AssetPath: filepath.Join(dir, "a.jar"), SigningKeyPath: filepath.Join(dir, "notes.txt")}
+		err = uploadReleaseAssets(ctx, releaseID, &myGithubPublishReleaseOptions, &ghRepoClient)
+		assert.Contains(t, fmt.Sprint(err), "Failed to read signing key")
+
+		assert.Empty(t, ghRepoClient.uploadedAssets)
+	})
+
+	t.Run("Error - upload", func(t *testing.T) {
+		ghRepoClient := ghRCMock{uploadErr: fmt.Errorf("Upload error")}
+		myGithubPublishReleaseOptions := githubPublishReleaseOptions{
+			AssetPathList:          []string{filepath.Join(dir, "a.jar"), filepath.Join(dir, "b.jar")},
+			AssetUploadConcurrency: "4",
+		}
+
+		err := uploadReleaseAssets(ctx, releaseID, &myGithubPublishReleaseOptions, &ghRepoClient)
+		assert.EqualError(t, err, "Failed to upload 2 of 2 release assets: a.jar, b.jar")
+	})
+}
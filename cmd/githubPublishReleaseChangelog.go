@@ -0,0 +1,360 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/SAP/jenkins-library/pkg/log"
+	"github.com/google/go-github/v28/github"
+	"github.com/pkg/errors"
+)
+
+// title of the section containing all entries not matching any configured section
+const changelogOtherSection = "Other changes"
+
+// number of items requested per page from the GitHub API
+const githubPageSize = 100
+
+var conventionalCommitTitle = regexp.MustCompile(`^(\w+)(?:\(([^)]*)\))?(!)?:\s*(.+)$`)
+var conventionalCommitBreakingChange = regexp.MustCompile(`(?m)^BREAKING[ -]CHANGE:`)
+
+var defaultChangelogSections = []changelogSection{
+	{Title: "Breaking changes", Labels: []string{"breaking"}, Breaking: true},
+	{Title: "Features", Labels: []string{"feature", "enhancement"}, Types: []string{"feat"}},
+	{Title: "Fixes", Labels: []string{"bug", "fix"}, Types: []string{"fix"}},
+}
+
+type githubCompareClient interface {
+	CompareCommits(ctx context.Context, owner, repo string, base, head string, opt *github.ListOptions) (*github.CommitsComparison, *github.Response, error)
+}
+
+type githubPullClient interface {
+	List(ctx context.Context, owner string, repo string, opt *github.PullRequestListOptions) ([]*github.PullRequest, *github.Response, error)
+	ListCommits(ctx context.Context, owner string, repo string, number int, opt *github.ListOptions) ([]*github.RepositoryCommit, *github.Response, error)
+}
+
+type changelogSection struct {
+	Title    string   `json:"title"`
+	Labels   []string `json:"labels"`
+	Types    []string `json:"types"`
+	Breaking bool     `json:"breaking"`
+}
+
+// changelogEntry represents either a pull-request or a commit pushed directly
+type changelogEntry struct {
+	reference   string
+	description string
+	labels      []string
+	author      string
+	commitType  string
+	breaking    bool
+}
+
+func getChangelogText(ctx context.Context, lastRelease *github.RepositoryRelease, myGithubPublishReleaseOptions *githubPublishReleaseOptions, ghCompareClient githubCompareClient, ghPullClient githubPullClient) (string, error) {
+
+	sections, err := parseChangelogSections(myGithubPublishReleaseOptions.ChangelogSections)
+	if err != nil {
+		return "", err
+	}
+
+	if len(lastRelease.GetTagName()) == 0 {
+		log.Entry().Info("No previous release available, changelog skipped.")
+		return "", nil
+	}
+
+	commits, since, err := getCommitsSinceRelease(ctx, lastRelease.GetTagName(), myGithubPublishReleaseOptions, ghCompareClient)
+	if err != nil {
+		return "", err
+	}
+	log.Entry().Debugf("Found %v commits between '%v' and '%v'", len(commits), lastRelease.GetTagName(), myGithubPublishReleaseOptions.Commitish)
+
+	pulls, err := getMergedPullRequests(ctx, commits, since, myGithubPublishReleaseOptions, ghPullClient)
+	if err != nil {
+		return "", err
+	}
+
+	entries, contributors, err := getChangelogEntries(ctx, commits, pulls, myGithubPublishReleaseOptions, ghPullClient)
+	if err != nil {
+		return "", err
+	}
+
+	return formatChangelog(entries, contributors, sections), nil
+}
+
+func parseChangelogSections(rawSections []map[string]interface{}) ([]changelogSection, error) {
+	if len(rawSections) == 0 {
+		return defaultChangelogSections, nil
+	}
+
+	sections := []changelogSection{}
+	for i, rawSection := range rawSections {
+		section := changelogSection{}
+		content, err := json.Marshal(rawSection)
+		if err == nil {
+			decoder := json.NewDecoder(bytes.NewReader(content))
+			decoder.DisallowUnknownFields()
+			err = decoder.Decode(&section)
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "Invalid changelog section #%v", i+1)
+		}
+		if len(section.Title) == 0 {
+			return nil, fmt.Errorf("Invalid changelog section #%v: no title provided", i+1)
+		}
+		sections = append(sections, section)
+	}
+	return sections, nil
+}
+
+// getCommitsSinceRelease provides the commits between the release tag and the commitish as well as the
+// date of the merge base, i.e. the last commit contained in both.
+func getCommitsSinceRelease(ctx context.Context, tag string, myGithubPublishReleaseOptions *githubPublishReleaseOptions, ghCompareClient githubCompareClient) ([]github.RepositoryCommit, time.Time, error) {
+	commits := []github.RepositoryCommit{}
+	var since time.Time
+
+	options := github.ListOptions{PerPage: githubPageSize}
+	for {
+		comparison, resp, err := ghCompareClient.CompareCommits(ctx, myGithubPublishReleaseOptions.Owner, myGithubPublishReleaseOptions.Repository, tag, myGithubPublishReleaseOptions.Commitish, &options)
+		if err != nil {
+			return nil, since, errors.Wrapf(err, "Failed to compare '%v' with '%v'", tag, myGithubPublishReleaseOptions.Commitish)
+		}
+		if options.Page <= 1 {
+			since = comparison.GetMergeBaseCommit().GetCommit().GetCommitter().GetDate()
+		}
+		commits = append(commits, comparison.Commits...)
+		if resp == nil || resp.NextPage == 0 || len(commits) >= comparison.GetTotalCommits() {
+			break
+		}
+		options.Page = resp.NextPage
+	}
+	return commits, since, nil
+}
+
+// getMergedPullRequests provides the pull-requests which have been merged via one of the commits.
+// Pull-requests merged into other branches are not considered.
+func getMergedPullRequests(ctx context.Context, commits []github.RepositoryCommit, since time.Time, myGithubPublishReleaseOptions *githubPublishReleaseOptions, ghPullClient githubPullClient) (map[string]*github.PullRequest, error) {
+	commitSHAs := map[string]bool{}
+	for _, commit := range commits {
+		commitSHAs[commit.GetSHA()] = true
+	}
+
+	pulls := map[string]*github.PullRequest{}
+	options := github.PullRequestListOptions{
+		State:       "closed",
+		Sort:        "updated",
+		Direction:   "desc",
+		ListOptions: github.ListOptions{PerPage: githubPageSize},
+	}
+	for {
+		ghPulls, resp, err := ghPullClient.List(ctx, myGithubPublishReleaseOptions.Owner, myGithubPublishReleaseOptions.Repository, &options)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to get GitHub pull-requests")
+		}
+		for _, pull := range ghPulls {
+			// pull-requests are sorted by update, older ones cannot be merged after the previous release
+			if pull.GetUpdatedAt().Before(since) {
+				return pulls, nil
+			}
+			if pull.MergedAt != nil && commitSHAs[pull.GetMergeCommitSHA()] {
+				pulls[pull.GetMergeCommitSHA()] = pull
+			}
+		}
+		if resp == nil || resp.NextPage == 0 {
+			return pulls, nil
+		}
+		options.Page = resp.NextPage
+	}
+}
+
+// getChangelogEntries provides the entries in the order of the commits. Commits belonging to a
+// pull-request are represented by the pull-request.
+func getChangelogEntries(ctx context.Context, commits []github.RepositoryCommit, pulls map[string]*github.PullRequest, myGithubPublishReleaseOptions *githubPublishReleaseOptions, ghPullClient githubPullClient) ([]changelogEntry, []string, error) {
+	covered := map[string]bool{}
+	contributors := map[string]bool{}
+
+	// rebase merges create new commits, they are identified by the properties a rebase keeps
+	rebasedCommits := map[string][]string{}
+	for _, commit := range commits {
+		key := rebasedCommitKey(commit.GetCommit())
+		rebasedCommits[key] = append(rebasedCommits[key], commit.GetSHA())
+	}
+
+	for _, commit := range commits {
+		pull, ok := pulls[commit.GetSHA()]
+		if !ok {
+			continue
+		}
+		pullCommits, err := getPullRequestCommits(ctx, pull.GetNumber(), myGithubPublishReleaseOptions, ghPullClient)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, pullCommit := range pullCommits {
+			covered[pullCommit.GetSHA()] = true
+			for _, sha := range rebasedCommits[rebasedCommitKey(pullCommit.GetCommit())] {
+				covered[sha] = true
+			}
+			if !pullRequestExcluded(pull, myGithubPublishReleaseOptions.ExcludeLabels) {
+				contributors[commitAuthor(pullCommit)] = true
+			}
+		}
+	}
+
+	entries := []changelogEntry{}
+	for _, commit := range commits {
+		if pull, ok := pulls[commit.GetSHA()]; ok {
+			if pullRequestExcluded(pull, myGithubPublishReleaseOptions.ExcludeLabels) {
+				log.Entry().Debugf("Excluded PR #%v from changelog", pull.GetNumber())
+				continue
+			}
+			labels := []string{}
+			for _, label := range pull.Labels {
+				labels = append(labels, label.GetName())
+			}
+			author := "@" + pull.GetUser().GetLogin()
+			contributors[author] = true
+			entries = append(entries, newChangelogEntry(fmt.Sprintf("[#%v](%v)", pull.GetNumber(), pull.GetHTMLURL()), pull.GetTitle(), pull.GetBody(), labels, author))
+			continue
+		}
+		if covered[commit.GetSHA()] || len(commit.Parents) > 1 {
+			continue
+		}
+		message := strings.SplitN(commit.GetCommit().GetMessage(), "\n", 2)
+		body := ""
+		if len(message) > 1 {
+			body = message[1]
+		}
+		author := commitAuthor(&commit)
+		contributors[author] = true
+		sha := commit.GetSHA()
+		if len(sha) > 7 {
+			sha = sha[:7]
+		}
+		entries = append(entries, newChangelogEntry(fmt.Sprintf("[%v](%v)", sha, commit.GetHTMLURL()), message[0], body, nil, author))
+	}
+
+	delete(contributors, "")
+	delete(contributors, "@")
+	contributorList := []string{}
+	for contributor := range contributors {
+		contributorList = append(contributorList, contributor)
+	}
+	sort.Strings(contributorList)
+	return entries, contributorList, nil
+}
+
+// rebasedCommitKey identifies a commit by message and author, which are kept when a commit is rebased
+func rebasedCommitKey(commit *github.Commit) string {
+	author := commit.GetAuthor()
+	return fmt.Sprintf("%v\x00%v\x00%v\x00%v", strings.TrimSpace(commit.GetMessage()), author.GetName(), author.GetEmail(), author.GetDate().UTC().Format(time.RFC3339))
+}
+
+func getPullRequestCommits(ctx context.Context, number int, myGithubPublishReleaseOptions *githubPublishReleaseOptions, ghPullClient githubPullClient) ([]*github.RepositoryCommit, error) {
+	commits := []*github.RepositoryCommit{}
+	options := github.ListOptions{PerPage: githubPageSize}
+	for {
+		pageCommits, resp, err := ghPullClient.ListCommits(ctx, myGithubPublishReleaseOptions.Owner, myGithubPublishReleaseOptions.Repository, number, &options)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to get commits of pull-request #%v", number)
+		}
+		commits = append(commits, pageCommits...)
+		if resp == nil || resp.NextPage == 0 {
+			return commits, nil
+		}
+		options.Page = resp.NextPage
+	}
+}
+
+// newChangelogEntry creates an entry, titles following the conventional commits specification are parsed
+func newChangelogEntry(reference, title, body string, labels []string, author string) changelogEntry {
+	entry := changelogEntry{
+		reference:   reference,
+		description: strings.TrimSpace(title),
+		labels:      labels,
+		author:      author,
+		breaking:    conventionalCommitBreakingChange.MatchString(body),
+	}
+	if match := conventionalCommitTitle.FindStringSubmatch(entry.description); match != nil {
+		entry.commitType = strings.ToLower(match[1])
+		entry.breaking = entry.breaking || len(match[3]) > 0
+		entry.description = match[4]
+		if len(match[2]) > 0 {
+			entry.description = fmt.Sprintf("**%v:** %v", match[2], match[4])
+		}
+	}
+	return entry
+}
+
+func (s changelogSection) matches(entry changelogEntry) bool {
+	if s.Breaking && entry.breaking {
+		return true
+	}
+	for _, label := range entry.labels {
+		for _, sectionLabel := range s.Labels {
+			if strings.EqualFold(label, sectionLabel) {
+				return true
+			}
+		}
+	}
+	return len(entry.commitType) > 0 && sliceContains(s.Types, entry.commitType)
+}
+
+func formatChangelog(entries []changelogEntry, contributors []string, sections []changelogSection) string {
+	sectionTexts := make([][]string, len(sections)+1)
+	for _, entry := range entries {
+		// entries not matching any section are added to the other changes
+		i := len(sections)
+		for j, section := range sections {
+			if section.matches(entry) {
+				i = j
+				break
+			}
+		}
+		text := fmt.Sprintf("%v: %v", entry.reference, entry.description)
+		if len(entry.author) > 0 {
+			text += fmt.Sprintf(" (%v)", entry.author)
+		}
+		sectionTexts[i] = append(sectionTexts[i], text)
+	}
+
+	changelogText := ""
+	for i, texts := range sectionTexts {
+		if len(texts) == 0 {
+			continue
+		}
+		title := changelogOtherSection
+		if i < len(sections) {
+			title = sections[i].Title
+		}
+		changelogText += fmt.Sprintf("\n**%v**\n%v\n", title, strings.Join(texts, "\n"))
+	}
+
+	if len(contributors) > 0 {
+		changelogText += fmt.Sprintf("\n**Contributors**\n%v\n", strings.Join(contributors, ", "))
+	}
+	return changelogText
+}
+
+// commitAuthor provides the GitHub login of the author of the commit, or the name in case it is not a GitHub user
+func commitAuthor(commit *github.RepositoryCommit) string {
+	if login := commit.GetAuthor().GetLogin(); len(login) > 0 {
+		return "@" + login
+	}
+	return commit.GetCommit().GetAuthor().GetName()
+}
+
+func pullRequestExcluded(pull *github.PullRequest, excludeLabels []string) bool {
+	for _, ex := range excludeLabels {
+		for _, l := range pull.Labels {
+			if ex == l.GetName() {
+				return true
+			}
+		}
+	}
+	return false
+}
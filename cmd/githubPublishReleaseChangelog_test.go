@@ -0,0 +1,292 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v28/github"
+	"github.com/stretchr/testify/assert"
+)
+
+type ghCCMock struct {
+	comparisonPages []*github.CommitsComparison
+	base            string
+	head            string
+	err             error
+}
+
+func (g *ghCCMock) CompareCommits(ctx context.Context, owner, repo string, base, head string, opt *github.ListOptions) (*github.CommitsComparison, *github.Response, error) {
+	g.base = base
+	g.head = head
+	if g.err != nil {
+		return nil, nil, g.err
+	}
+	if len(g.comparisonPages) == 0 {
+		return &github.CommitsComparison{}, nil, nil
+	}
+	page := opt.Page
+	if page == 0 {
+		page = 1
+	}
+	resp := github.Response{}
+	if page < len(g.comparisonPages) {
+		resp.NextPage = page + 1
+	}
+	return g.comparisonPages[page-1], &resp, nil
+}
+
+type ghPCMock struct {
+	pulls       []*github.PullRequest
+	pullCommits map[int][]*github.RepositoryCommit
+	listOpts    *github.PullRequestListOptions
+}
+
+func (g *ghPCMock) List(ctx context.Context, owner string, repo string, opt *github.PullRequestListOptions) ([]*github.PullRequest, *github.Response, error) {
+	g.listOpts = opt
+	return g.pulls, nil, nil
+}
+
+func (g *ghPCMock) ListCommits(ctx context.Context, owner string, repo string, number int, opt *github.ListOptions) ([]*github.RepositoryCommit, *github.Response, error) {
+	return g.pullCommits[number], nil, nil
+}
+
+func testCommit(sha, message, login, name string, parents int) github.RepositoryCommit {
+	commit := github.RepositoryCommit{
+		SHA:     github.String(sha),
+		HTMLURL: github.String("https://github.com/TEST/test/commit/" + sha),
+		Commit:  &github.Commit{Message: github.String(message), Author: &github.CommitAuthor{Name: github.String(name)}},
+	}
+	if len(login) > 0 {
+		commit.Author = &github.User{Login: github.String(login)}
+	}
+	for i := 0; i < parents; i++ {
+		commit.Parents = append(commit.Parents, github.Commit{SHA: github.String(fmt.Sprintf("parent%v", i))})
+	}
+	return commit
+}
+
+func testPull(number int, title, login, mergeCommitSHA string, updatedAt time.Time, labels ...string) *github.PullRequest {
+	pull := github.PullRequest{
+		Number:         github.Int(number),
+		Title:          github.String(title),
+		HTMLURL:        github.String(fmt.Sprintf("https://github.com/TEST/test/pull/%v", number)),
+		User:           &github.User{Login: github.String(login)},
+		MergeCommitSHA: github.String(mergeCommitSHA),
+		MergedAt:       &updatedAt,
+		UpdatedAt:      &updatedAt,
+	}
+	for _, label := range labels {
+		pull.Labels = append(pull.Labels, &github.Label{Name: github.String(label)})
+	}
+	return &pull
+}
+
+func TestGetChangelogText(t *testing.T) {
+	ctx := context.Background()
+	since := time.Date(2019, 11, 1, 0, 0, 0, 0, time.UTC)
+	merged := since.Add(24 * time.Hour)
+
+	lastRelease := github.RepositoryRelease{TagName: github.String("1.0")}
+
+	newCompareClient := func() *ghCCMock {
+		return &ghCCMock{comparisonPages: []*github.CommitsComparison{
+			{
+				TotalCommits:    github.Int(6),
+				MergeBaseCommit: &github.RepositoryCommit{Commit: &github.Commit{Committer: &github.CommitAuthor{Date: &since}}},
+				Commits: []github.RepositoryCommit{
+					testCommit("1111111aaaaa", "feat(api): add endpoint", "alice", "Alice", 1),
+					testCommit("3333333ccccc", "work in progress", "erin", "Erin", 1),
+					testCommit("2222222bbbbb", "Merge pull request #10 from TEST/feature", "bob", "Bob", 2),
+				},
+			},
+			{
+				TotalCommits: github.Int(6),
+				Commits: []github.RepositoryCommit{
+					testCommit("4444444ddddd", "fix: crash (#11)", "carol", "Carol", 1),
+					testCommit("5555555eeeee", "refactor: drop old api\n\nBREAKING CHANGE: old api removed", "", "Dave", 1),
+					testCommit("6666666fffff", "docs: readme (#12)", "bob", "Bob", 1),
+				},
+			},
+		}}
+	}
+
+	newPullClient := func() *ghPCMock {
+		return &ghPCMock{
+			pulls: []*github.PullRequest{
+				testPull(12, "docs: readme", "bob", "6666666fffff", merged, "no-changelog"),
+				testPull(11, "fix: crash", "carol", "4444444ddddd", merged, "bug"),
+				// merged into another branch
+				testPull(13, "Other branch", "frank", "7777777", merged),
+				testPull(10, "Add feature", "bob", "2222222bbbbb", merged, "enhancement"),
+				// merged before the previous release
+				testPull(9, "Old feature", "bob", "1111111aaaaa", since.Add(-time.Hour), "enhancement"),
+			},
+			pullCommits: map[int][]*github.RepositoryCommit{
+				10: {&github.RepositoryCommit{SHA: github.String("3333333ccccc"), Author: &github.User{Login: github.String("erin")}}},
+			},
+		}
+	}
+
+	t.Run("default sections", func(t *testing.T) {
+		compareClient := newCompareClient()
+		pullClient := newPullClient()
+		myGithubPublishReleaseOptions := githubPublishReleaseOptions{
+			Commitish:     "master",
+			Owner:         "TEST",
+			Repository:    "test",
+			ExcludeLabels: []string{"no-changelog"},
+		}
+
+		res, err := getChangelogText(ctx, &lastRelease, &myGithubPublishReleaseOptions, compareClient, pullClient)
+
+		if assert.NoError(t, err) {
+			assert.Equal(t, `
+**Breaking changes**
+[5555555](https://github.com/TEST/test/commit/5555555eeeee): drop old api (Dave)
+
+**Features**
+[1111111](https://github.com/TEST/test/commit/1111111aaaaa): **api:** add endpoint (@alice)
+[#10](https://github.com/TEST/test/pull/10): Add feature (@bob)
+
+**Fixes**
+[#11](https://github.com/TEST/test/pull/11): crash (@carol)
+
+**Contributors**
+@alice, @bob, @carol, @erin, Dave
+`, res)
+		}
+		assert.Equal(t, "1.0", compareClient.base)
+		assert.Equal(t, "master", compareClient.head)
+		assert.Equal(t, "closed", pullClient.listOpts.State)
+		assert.Equal(t, "updated", pullClient.listOpts.Sort)
+		assert.Equal(t, "desc", pullClient.listOpts.Direction)
+	})
+
+	t.Run("configured sections", func(t *testing.T) {
+		myGithubPublishReleaseOptions := githubPublishReleaseOptions{
+			ChangelogSections: []map[string]interface{}{
+				{"title": "Documentation", "types": []interface{}{"docs"}},
+				{"title": "Bugs", "labels": []interface{}{"BUG"}},
+			},
+		}
+
+		res, err := getChangelogText(ctx, &lastRelease, &myGithubPublishReleaseOptions, newCompareClient(), newPullClient())
+
+		if assert.NoError(t, err) {
+			assert.Contains(t, res, "\n**Documentation**\n[#12](https://github.com/TEST/test/pull/12): readme (@bob)\n")
+			assert.Contains(t, res, "\n**Bugs**\n[#11](https://github.com/TEST/test/pull/11): crash (@carol)\n")
+			assert.Contains(t, res, "\n**Other changes**\n[1111111](https://github.com/TEST/test/commit/1111111aaaaa): **api:** add endpoint (@alice)\n[#10](https://github.com/TEST/test/pull/10): Add feature (@bob)\n[5555555](https://github.com/TEST/test/commit/5555555eeeee): drop old api (Dave)\n")
+		}
+	})
+
+	t.Run("rebase merged pull-requests", func(t *testing.T) {
+		compareClient := ghCCMock{comparisonPages: []*github.CommitsComparison{
+			{
+				TotalCommits:    github.Int(4),
+				MergeBaseCommit: &github.RepositoryCommit{Commit: &github.Commit{Committer: &github.CommitAuthor{Date: &since}}},
+				Commits: []github.RepositoryCommit{
+					testCommit("aaaaaaa11111", "feat: first part", "alice", "Alice", 1),
+					testCommit("aaaaaaa22222", "feat: second part", "erin", "Erin", 1),
+					testCommit("bbbbbbb11111", "fix: temporary fix", "bob", "Bob", 1),
+					testCommit("bbbbbbb22222", "fix: final fix", "bob", "Bob", 1),
+				},
+			},
+		}}
+		originalCommit := func(sha, message, login, name string) *github.RepositoryCommit {
+			commit := testCommit(sha, message, login, name, 1)
+			return &commit
+		}
+		pullClient := ghPCMock{
+			pulls: []*github.PullRequest{
+				testPull(20, "feat: new feature", "alice", "aaaaaaa22222", merged),
+				testPull(21, "fix: internal fix", "bob", "bbbbbbb22222", merged, "no-changelog"),
+			},
+			pullCommits: map[int][]*github.RepositoryCommit{
+				20: {originalCommit("ccccccc11111", "feat: first part", "alice", "Alice"), originalCommit("ccccccc22222", "feat: second part", "erin", "Erin")},
+				21: {originalCommit("ddddddd11111", "fix: temporary fix", "bob", "Bob"), originalCommit("ddddddd22222", "fix: final fix", "bob", "Bob")},
+			},
+		}
+		myGithubPublishReleaseOptions := githubPublishReleaseOptions{ExcludeLabels: []string{"no-changelog"}}
+
+		res, err := getChangelogText(ctx, &lastRelease, &myGithubPublishReleaseOptions, &compareClient, &pullClient)
+
+		if assert.NoError(t, err) {
+			assert.Equal(t, `
+**Features**
+[#20](https://github.com/TEST/test/pull/20): new feature (@alice)
+
+**Contributors**
+@alice, @erin
+`, res)
+		}
+	})
+
+	t.Run("first release", func(t *testing.T) {
+		compareClient := ghCCMock{}
+		myGithubPublishReleaseOptions := githubPublishReleaseOptions{}
+
+		res, err := getChangelogText(ctx, nil, &myGithubPublishReleaseOptions, &compareClient, &ghPCMock{})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "", res)
+		assert.Equal(t, "", compareClient.head)
+	})
+
+	t.Run("invalid sections", func(t *testing.T) {
+		myGithubPublishReleaseOptions := githubPublishReleaseOptions{
+			ChangelogSections: []map[string]interface{}{{"labels": []interface{}{"bug"}}},
+		}
+		_, err := getChangelogText(ctx, &lastRelease, &myGithubPublishReleaseOptions, &ghCCMock{}, &ghPCMock{})
+		assert.EqualError(t, err, "Invalid changelog section #1: no title provided")
+
+		myGithubPublishReleaseOptions.ChangelogSections = []map[string]interface{}{{"title": "Fixes", "label": "bug"}}
+		_, err = getChangelogText(ctx, &lastRelease, &myGithubPublishReleaseOptions, &ghCCMock{}, &ghPCMock{})
+		assert.EqualError(t, err, "Invalid changelog section #1: json: unknown field \"label\"")
+	})
+
+	t.Run("compare fails", func(t *testing.T) {
+		myGithubPublishReleaseOptions := githubPublishReleaseOptions{Commitish: "master"}
+		_, err := getChangelogText(ctx, &lastRelease, &myGithubPublishReleaseOptions, &ghCCMock{err: fmt.Errorf("not found")}, &ghPCMock{})
+		assert.EqualError(t, err, "Failed to compare '1.0' with 'master': not found")
+	})
+
+	t.Run("release body", func(t *testing.T) {
+		ghRepoClient := ghRCMock{latestRelease: &lastRelease}
+		myGithubPublishReleaseOptions := githubPublishReleaseOptions{
+			AddChangelog:      true,
+			ReleaseBodyHeader: "Header",
+			Version:           "1.1",
+		}
+
+		err := runGithubPublishRelease(ctx, &myGithubPublishReleaseOptions, &ghRepoClient, &ghICMock{}, newCompareClient(), newPullClient())
+
+		if assert.NoError(t, err) {
+			assert.Contains(t, ghRepoClient.release.GetBody(), "Header\n\n**Breaking changes**\n")
+		}
+	})
+}
+
+func TestNewChangelogEntry(t *testing.T) {
+	tt := []struct {
+		title       string
+		body        string
+		commitType  string
+		description string
+		breaking    bool
+	}{
+		{title: "Add feature", description: "Add feature"},
+		{title: "feat: add feature", commitType: "feat", description: "add feature"},
+		{title: "Feat(ui)!: add feature", commitType: "feat", description: "**ui:** add feature", breaking: true},
+		{title: "fix: crash", body: "BREAKING-CHANGE: changed", commitType: "fix", description: "crash", breaking: true},
+		{title: "Merge: something", commitType: "merge", description: "something"},
+	}
+
+	for _, test := range tt {
+		entry := newChangelogEntry("ref", test.title, test.body, nil, "")
+		assert.Equal(t, test.commitType, entry.commitType, test.title)
+		assert.Equal(t, test.description, entry.description, test.title)
+		assert.Equal(t, test.breaking, entry.breaking, test.title)
+	}
+}
@@ -0,0 +1,180 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/SAP/jenkins-library/pkg/log"
+	"github.com/google/go-github/v28/github"
+	"github.com/pkg/errors"
+)
+
+// semanticVersion represents a version following https://semver.org, build metadata is ignored
+type semanticVersion struct {
+	numbers    []int
+	prerelease []string
+}
+
+// parseSemanticVersion parses versions like '1.2.3', 'v1.2' or '1.2.3-rc.1+build'
+func parseSemanticVersion(version string) (semanticVersion, bool) {
+	v := semanticVersion{}
+	version = strings.TrimPrefix(strings.TrimPrefix(version, "v"), "V")
+	version = strings.SplitN(version, "+", 2)[0]
+
+	parts := strings.SplitN(version, "-", 2)
+	if len(parts) > 1 {
+		if len(parts[1]) == 0 {
+			return v, false
+		}
+		v.prerelease = strings.Split(parts[1], ".")
+	}
+
+	numbers := strings.Split(parts[0], ".")
+	if len(numbers) > 3 {
+		return v, false
+	}
+	for _, number := range numbers {
+		n, err := strconv.Atoi(number)
+		if err != nil || n < 0 {
+			return v, false
+		}
+		v.numbers = append(v.numbers, n)
+	}
+	return v, true
+}
+
+// compare returns a negative number in case v is lower than other, a positive number in case v is
+// greater than other and 0 in case both have the same precedence
+func (v semanticVersion) compare(other semanticVersion) int {
+	for i := 0; i < 3; i++ {
+		a, b := 0, 0
+		if i < len(v.numbers) {
+			a = v.numbers[i]
+		}
+		if i < len(other.numbers) {
+			b = other.numbers[i]
+		}
+		if a != b {
+			return a - b
+		}
+	}
+
+	// a release has a higher precedence than its prereleases
+	if len(v.prerelease) == 0 || len(other.prerelease) == 0 {
+		return len(other.prerelease) - len(v.prerelease)
+	}
+	for i := 0; i < len(v.prerelease) && i < len(other.prerelease); i++ {
+		if c := comparePrereleaseIdentifier(v.prerelease[i], other.prerelease[i]); c != 0 {
+			return c
+		}
+	}
+	return len(v.prerelease) - len(other.prerelease)
+}
+
+func comparePrereleaseIdentifier(a, b string) int {
+	na, errA := strconv.Atoi(a)
+	nb, errB := strconv.Atoi(b)
+	switch {
+	case errA == nil && errB == nil:
+		return na - nb
+	case errA == nil:
+		// numeric identifiers have lower precedence than alphanumeric ones
+		return -1
+	case errB == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+// getPreviousRelease provides the release the new release is compared to, nil in case there is none
+func getPreviousRelease(ctx context.Context, myGithubPublishReleaseOptions *githubPublishReleaseOptions, ghRepoClient githubRepoClient) (*github.RepositoryRelease, error) {
+	switch myGithubPublishReleaseOptions.PreviousReleaseStrategy {
+	case "", "latest":
+		latestRelease, resp, err := ghRepoClient.GetLatestRelease(ctx, myGithubPublishReleaseOptions.Owner, myGithubPublishReleaseOptions.Repository)
+		if err != nil {
+			if resp != nil && resp.StatusCode == 404 {
+				return nil, nil
+			}
+			return nil, errors.Wrap(err, "Error occured when retrieving latest GitHub release.")
+		}
+		if latestRelease.GetTagName() != myGithubPublishReleaseOptions.Version || myGithubPublishReleaseOptions.Version == "latest" {
+			return latestRelease, nil
+		}
+		// the release is updated, hence the latest release cannot serve as previous release
+		log.Entry().Infof("Latest release is release '%v' itself, using the previous release by semantic version.", latestRelease.GetTagName())
+	case "semver":
+	default:
+		return nil, fmt.Errorf("Invalid previousReleaseStrategy '%v'. Supported values: 'latest', 'semver'", myGithubPublishReleaseOptions.PreviousReleaseStrategy)
+	}
+
+	releases, err := listReleases(ctx, myGithubPublishReleaseOptions, ghRepoClient)
+	if err != nil {
+		return nil, err
+	}
+	return getPreviousReleaseBySemanticVersion(releases, myGithubPublishReleaseOptions.Version), nil
+}
+
+// getPreviousReleaseBySemanticVersion provides the published release with the highest version lower than the
+// given version. Prereleases are only considered in case the version is a prerelease itself.
+func getPreviousReleaseBySemanticVersion(releases []*github.RepositoryRelease, version string) *github.RepositoryRelease {
+	currentVersion, currentValid := parseSemanticVersion(version)
+	if !currentValid {
+		log.Entry().Warningf("Version '%v' is not a semantic version, using the release with the highest version as previous release.", version)
+	}
+
+	var previousRelease *github.RepositoryRelease
+	var previousVersion semanticVersion
+	for _, release := range releases {
+		if release.GetDraft() || release.GetTagName() == version {
+			continue
+		}
+		releaseVersion, ok := parseSemanticVersion(release.GetTagName())
+		if !ok {
+			log.Entry().Debugf("Release '%v' ignored since it is not a semantic version", release.GetTagName())
+			continue
+		}
+		if currentValid && (releaseVersion.compare(currentVersion) >= 0 || (len(releaseVersion.prerelease) > 0 && len(currentVersion.prerelease) == 0)) {
+			continue
+		}
+		if previousRelease == nil || releaseVersion.compare(previousVersion) > 0 {
+			previousRelease, previousVersion = release, releaseVersion
+		}
+	}
+	if previousRelease != nil {
+		log.Entry().Debugf("Previous release by semantic version: '%v'", previousRelease.GetTagName())
+	}
+	return previousRelease
+}
+
+// listReleases provides all releases including drafts
+func listReleases(ctx context.Context, myGithubPublishReleaseOptions *githubPublishReleaseOptions, ghRepoClient githubRepoClient) ([]*github.RepositoryRelease, error) {
+	releases := []*github.RepositoryRelease{}
+	options := github.ListOptions{PerPage: githubPageSize}
+	for {
+		pageReleases, resp, err := ghRepoClient.ListReleases(ctx, myGithubPublishReleaseOptions.Owner, myGithubPublishReleaseOptions.Repository, &options)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to get list of GitHub releases.")
+		}
+		releases = append(releases, pageReleases...)
+		if resp == nil || resp.NextPage == 0 {
+			return releases, nil
+		}
+		options.Page = resp.NextPage
+	}
+}
+
+// getReleaseByTag provides the release for the tag, drafts are included in contrast to the GitHub API for releases by tag
+func getReleaseByTag(ctx context.Context, tag string, myGithubPublishReleaseOptions *githubPublishReleaseOptions, ghRepoClient githubRepoClient) (*github.RepositoryRelease, error) {
+	releases, err := listReleases(ctx, myGithubPublishReleaseOptions, ghRepoClient)
+	if err != nil {
+		return nil, err
+	}
+	for _, release := range releases {
+		if release.GetTagName() == tag {
+			return release, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-github/v28/github"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSemanticVersion(t *testing.T) {
+	tt := []struct {
+		version    string
+		valid      bool
+		numbers    []int
+		prerelease []string
+	}{
+		{version: "1.2.3", valid: true, numbers: []int{1, 2, 3}},
+		{version: "v1.2", valid: true, numbers: []int{1, 2}},
+		{version: "1.2.3-rc.1+build.5", valid: true, numbers: []int{1, 2, 3}, prerelease: []string{"rc", "1"}},
+		{version: "1.2.3.4"},
+		{version: "1.x"},
+		{version: "1.0-"},
+		{version: "latest"},
+	}
+
+	for _, test := range tt {
+		v, ok := parseSemanticVersion(test.version)
+		assert.Equal(t, test.valid, ok, test.version)
+		if test.valid {
+			assert.Equal(t, test.numbers, v.numbers, test.version)
+			assert.Equal(t, test.prerelease, v.prerelease, test.version)
+		}
+	}
+}
+
+func TestCompareSemanticVersion(t *testing.T) {
+	// ordered by precedence as given in https://semver.org
+	versions := []string{"0.9", "1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-alpha.beta", "1.0.0-beta", "1.0.0-beta.2", "1.0.0-beta.11", "1.0.0-rc.1", "1.0.0", "1.1", "1.10.0", "2.0.0"}
+
+	for i := 0; i < len(versions)-1; i++ {
+		lower, _ := parseSemanticVersion(versions[i])
+		higher, _ := parseSemanticVersion(versions[i+1])
+		assert.True(t, lower.compare(higher) < 0, fmt.Sprintf("%v < %v", versions[i], versions[i+1]))
+		assert.True(t, higher.compare(lower) > 0, fmt.Sprintf("%v > %v", versions[i+1], versions[i]))
+	}
+
+	v1, _ := parseSemanticVersion("1.0")
+	v2, _ := parseSemanticVersion("v1.0.0+build")
+	assert.Equal(t, 0, v1.compare(v2))
+}
+
+func TestGetPreviousRelease(t *testing.T) {
+	ctx := context.Background()
+
+	releases := []*github.RepositoryRelease{
+		{TagName: github.String("1.0.0")},
+		{TagName: github.String("1.2.0"), Draft: github.Bool(true)},
+		{TagName: github.String("2.0.0")},
+		{TagName: github.String("1.1.0")},
+		{TagName: github.String("1.1.1-rc.1"), Prerelease: github.Bool(true)},
+		{TagName: github.String("nightly")},
+	}
+
+	t.Run("semantic version", func(t *testing.T) {
+		tt := []struct {
+			version  string
+			expected string
+		}{
+			{version: "1.2.0", expected: "1.1.0"},
+			{version: "1.1.1", expected: "1.1.0"},
+			{version: "1.1.1-rc.2", expected: "1.1.1-rc.1"},
+			{version: "2.0.0", expected: "1.1.0"},
+			{version: "1.0.0", expected: ""},
+			{version: "custom", expected: "2.0.0"},
+		}
+
+		for _, test := range tt {
+			myGithubPublishReleaseOptions := githubPublishReleaseOptions{PreviousReleaseStrategy: "semver", Version: test.version}
+			release, err := getPreviousRelease(ctx, &myGithubPublishReleaseOptions, &ghRCMock{releases: releases})
+			assert.NoError(t, err, test.version)
+			assert.Equal(t, test.expected, release.GetTagName(), test.version)
+		}
+	})
+
+	t.Run("latest release", func(t *testing.T) {
+		ghRepoClient := ghRCMock{latestRelease: releases[2], releases: releases}
+		myGithubPublishReleaseOptions := githubPublishReleaseOptions{PreviousReleaseStrategy: "latest", Version: "1.1.1"}
+
+		release, err := getPreviousRelease(ctx, &myGithubPublishReleaseOptions, &ghRepoClient)
+		assert.NoError(t, err)
+		assert.Equal(t, "2.0.0", release.GetTagName())
+
+		// release is updated
+		myGithubPublishReleaseOptions.Version = "2.0.0"
+		release, err = getPreviousRelease(ctx, &myGithubPublishReleaseOptions, &ghRepoClient)
+		assert.NoError(t, err)
+		assert.Equal(t, "1.1.0", release.GetTagName())
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		myGithubPublishReleaseOptions := githubPublishReleaseOptions{PreviousReleaseStrategy: "oldest"}
+		_, err := getPreviousRelease(ctx, &myGithubPublishReleaseOptions, &ghRCMock{})
+		assert.EqualError(t, err, "Invalid previousReleaseStrategy 'oldest'. Supported values: 'latest', 'semver'")
+
+		myGithubPublishReleaseOptions.PreviousReleaseStrategy = "semver"
+		_, err = getPreviousRelease(ctx, &myGithubPublishReleaseOptions, &ghRCMock{releasesErr: fmt.Errorf("list error")})
+		assert.EqualError(t, err, "Failed to get list of GitHub releases.: list error")
+	})
+}
@@ -10,20 +10,35 @@ import (
 )
 
 type githubPublishReleaseOptions struct {
-	AddClosedIssues       bool     `json:"addClosedIssues,omitempty"`
-	AddDeltaToLastRelease bool     `json:"addDeltaToLastRelease,omitempty"`
-	APIURL                string   `json:"apiUrl,omitempty"`
-	AssetPath             string   `json:"assetPath,omitempty"`
-	Commitish             string   `json:"commitish,omitempty"`
-	ExcludeLabels         []string `json:"excludeLabels,omitempty"`
-	Labels                []string `json:"labels,omitempty"`
-	Owner                 string   `json:"owner,omitempty"`
-	ReleaseBodyHeader     string   `json:"releaseBodyHeader,omitempty"`
-	Repository            string   `json:"repository,omitempty"`
-	ServerURL             string   `json:"serverUrl,omitempty"`
-	Token                 string   `json:"token,omitempty"`
-	UploadURL             string   `json:"uploadUrl,omitempty"`
-	Version               string   `json:"version,omitempty"`
+	AddChangelog            bool                     `json:"addChangelog,omitempty"`
+	AddClosedIssues         bool                     `json:"addClosedIssues,omitempty"`
+	AddDeltaToLastRelease   bool                     `json:"addDeltaToLastRelease,omitempty"`
+	APIURL                  string                   `json:"apiUrl,omitempty"`
+	AppID                   string                   `json:"appId,omitempty"`
+	AssetChecksums          bool                     `json:"assetChecksums,omitempty"`
+	AssetPath               string                   `json:"assetPath,omitempty"`
+	AssetPathList           []string                 `json:"assetPathList,omitempty"`
+	AssetUploadConcurrency  string                   `json:"assetUploadConcurrency,omitempty"`
+	ChangelogSections       []map[string]interface{} `json:"changelogSections,omitempty"`
+	Commitish               string                   `json:"commitish,omitempty"`
+	Draft                   bool                     `json:"draft,omitempty"`
+	ExcludeLabels           []string                 `json:"excludeLabels,omitempty"`
+	InstallationID          string                   `json:"installationId,omitempty"`
+	Labels                  []string                 `json:"labels,omitempty"`
+	Owner                   string                   `json:"owner,omitempty"`
+	Prerelease              bool                     `json:"prerelease,omitempty"`
+	PreviousReleaseStrategy string                   `json:"previousReleaseStrategy,omitempty"`
+	PromoteDraft            bool                     `json:"promoteDraft,omitempty"`
+	PrivateKey              string                   `json:"privateKey,omitempty"`
+	ReleaseBodyHeader       string                   `json:"releaseBodyHeader,omitempty"`
+	Repository              string                   `json:"repository,omitempty"`
+	ServerURL               string                   `json:"serverUrl,omitempty"`
+	SigningKeyPassphrase    string                   `json:"signingKeyPassphrase,omitempty"`
+	SigningKeyPath          string                   `json:"signingKeyPath,omitempty"`
+	Token                   string                   `json:"token,omitempty"`
+	UpdateExisting          bool                     `json:"updateExisting,omitempty"`
+	UploadURL               string                   `json:"uploadUrl,omitempty"`
+	Version                 string                   `json:"version,omitempty"`
 }
 
 var myGithubPublishReleaseOptions githubPublishReleaseOptions
@@ -38,6 +53,7 @@ func GithubPublishReleaseCommand() *cobra.Command {
 		Long: `This step creates a tag in your GitHub repository together with a release.
 The release can be filled with text plus additional information like:
 
+* Changelog generated from the commits and pull-requests since last release
 * Closed pull request since last release
 * Closed issues since last release
 * Link to delta information showing all commits since last release
@@ -61,18 +77,32 @@ The result looks like
 }
 
 func addGithubPublishReleaseFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&myGithubPublishReleaseOptions.AddChangelog, "addChangelog", false, "If set to `true`, a changelog will be added below the `releaseBodyHeader`. It is generated from the commits between the previous release and `commitish` together with the pull-requests merged via these commits. The entries are grouped into `changelogSections` and the contributors are listed.")
 	cmd.Flags().BoolVar(&myGithubPublishReleaseOptions.AddClosedIssues, "addClosedIssues", false, "If set to `true`, closed issues and merged pull-requests since the last release will added below the `releaseBodyHeader`")
 	cmd.Flags().BoolVar(&myGithubPublishReleaseOptions.AddDeltaToLastRelease, "addDeltaToLastRelease", false, "If set to `true`, a link will be added to the relese information that brings up all commits since the last release.")
 	cmd.Flags().StringVar(&myGithubPublishReleaseOptions.APIURL, "apiUrl", "https://api.github.com", "Set the GitHub API url.")
+	cmd.Flags().StringVar(&myGithubPublishReleaseOptions.AppID, "appId", os.Getenv("PIPER_appId"), "ID of the GitHub App used for authentication. In case it is provided, the step authenticates as installation `installationId` of the app instead of using `token`.")
+	cmd.Flags().BoolVar(&myGithubPublishReleaseOptions.AssetChecksums, "assetChecksums", true, "If set to `true`, an asset `SHA256SUMS` containing the SHA-256 checksums of all uploaded assets is added to the release.")
 	cmd.Flags().StringVar(&myGithubPublishReleaseOptions.AssetPath, "assetPath", os.Getenv("PIPER_assetPath"), "Path to a release asset which should be uploaded to the list of release assets.")
+	cmd.Flags().StringSliceVar(&myGithubPublishReleaseOptions.AssetPathList, "assetPathList", []string{}, "List of paths to release assets which should be uploaded to the list of release assets. Glob patterns like `target/*.jar` are supported. Existing assets with the same name are replaced.")
+	cmd.Flags().StringVar(&myGithubPublishReleaseOptions.AssetUploadConcurrency, "assetUploadConcurrency", "4", "Maximum number of release assets uploaded in parallel.")
 	cmd.Flags().StringVar(&myGithubPublishReleaseOptions.Commitish, "commitish", "master", "Target git commitish for the release")
-	cmd.Flags().StringSliceVar(&myGithubPublishReleaseOptions.ExcludeLabels, "excludeLabels", []string{}, "Allows to exclude issues with dedicated list of labels.")
+	cmd.Flags().BoolVar(&myGithubPublishReleaseOptions.Draft, "draft", false, "If set to `true`, the release is created as draft. Drafts are not visible to the public and can be published later on via `promoteDraft`.")
+	cmd.Flags().StringSliceVar(&myGithubPublishReleaseOptions.ExcludeLabels, "excludeLabels", []string{}, "Allows to exclude issues and pull-requests with dedicated list of labels.")
+	cmd.Flags().StringVar(&myGithubPublishReleaseOptions.InstallationID, "installationId", os.Getenv("PIPER_installationId"), "ID of the installation of the GitHub App `appId` in the organization or repository.")
 	cmd.Flags().StringSliceVar(&myGithubPublishReleaseOptions.Labels, "labels", []string{}, "Labels to include in issue search.")
 	cmd.Flags().StringVar(&myGithubPublishReleaseOptions.Owner, "owner", os.Getenv("PIPER_owner"), "Set the GitHub organization.")
+	cmd.Flags().BoolVar(&myGithubPublishReleaseOptions.Prerelease, "prerelease", false, "If set to `true`, the release is marked as prerelease.")
+	cmd.Flags().StringVar(&myGithubPublishReleaseOptions.PreviousReleaseStrategy, "previousReleaseStrategy", "latest", "Defines how the previous release is determined which is the base for the changelog, the closed issues and the delta information. With `latest` the latest release as provided by GitHub is used, with `semver` the release with the highest semantic version lower than `version` is used. Values: 'latest', 'semver'")
+	cmd.Flags().BoolVar(&myGithubPublishReleaseOptions.PromoteDraft, "promoteDraft", false, "If set to `true`, an existing draft release for `version` is published after the assets have been uploaded.")
+	cmd.Flags().StringVar(&myGithubPublishReleaseOptions.PrivateKey, "privateKey", os.Getenv("PIPER_privateKey"), "Private key of the GitHub App `appId` in PEM format as generated by GitHub.")
 	cmd.Flags().StringVar(&myGithubPublishReleaseOptions.ReleaseBodyHeader, "releaseBodyHeader", os.Getenv("PIPER_releaseBodyHeader"), "Content which will appear for the release.")
 	cmd.Flags().StringVar(&myGithubPublishReleaseOptions.Repository, "repository", os.Getenv("PIPER_repository"), "Set the GitHub repository.")
 	cmd.Flags().StringVar(&myGithubPublishReleaseOptions.ServerURL, "serverUrl", "https://github.com", "GitHub server url for end-user access.")
-	cmd.Flags().StringVar(&myGithubPublishReleaseOptions.Token, "token", os.Getenv("PIPER_token"), "GitHub personal access token as per https://help.github.com/en/github/authenticating-to-github/creating-a-personal-access-token-for-the-command-line")
+	cmd.Flags().StringVar(&myGithubPublishReleaseOptions.SigningKeyPassphrase, "signingKeyPassphrase", os.Getenv("PIPER_signingKeyPassphrase"), "Passphrase of the private key provided via `signingKeyPath`. On Jenkins it is provided via `signingKeyPassphraseCredentialsId`.")
+	cmd.Flags().StringVar(&myGithubPublishReleaseOptions.SigningKeyPath, "signingKeyPath", os.Getenv("PIPER_signingKeyPath"), "Path to an armored OpenPGP private key. In case it is provided a detached signature (`<asset>.asc`) is uploaded for every release asset including `SHA256SUMS`.")
+	cmd.Flags().StringVar(&myGithubPublishReleaseOptions.Token, "token", os.Getenv("PIPER_token"), "GitHub personal access token as per https://help.github.com/en/github/authenticating-to-github/creating-a-personal-access-token-for-the-command-line. It is not required when authenticating as GitHub App.")
+	cmd.Flags().BoolVar(&myGithubPublishReleaseOptions.UpdateExisting, "updateExisting", false, "If set to `true`, an existing release for `version` is updated instead of failing, i.e. the release information is replaced and the assets are uploaded again. This allows to re-run the step.")
 	cmd.Flags().StringVar(&myGithubPublishReleaseOptions.UploadURL, "uploadUrl", "https://uploads.github.com", "Set the GitHub API url.")
 	cmd.Flags().StringVar(&myGithubPublishReleaseOptions.Version, "version", os.Getenv("PIPER_version"), "Define the version number which will be written as tag as well as release name.")
 
@@ -80,7 +110,6 @@ func addGithubPublishReleaseFlags(cmd *cobra.Command) {
 	cmd.MarkFlagRequired("owner")
 	cmd.MarkFlagRequired("repository")
 	cmd.MarkFlagRequired("serverUrl")
-	cmd.MarkFlagRequired("token")
 	cmd.MarkFlagRequired("uploadUrl")
 	cmd.MarkFlagRequired("version")
 }
@@ -91,6 +120,14 @@ func githubPublishReleaseMetadata() config.StepData {
 		Spec: config.StepSpec{
 			Inputs: config.StepInputs{
 				Parameters: []config.StepParameters{
+					{
+						Name:        "addChangelog",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "bool",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
 					{
 						Name:        "addClosedIssues",
 						ResourceRef: []config.ResourceReference{},
@@ -115,6 +152,22 @@ func githubPublishReleaseMetadata() config.StepData {
 						Mandatory:   true,
 						Aliases:     []config.Alias{{Name: "githubApiUrl"}},
 					},
+					{
+						Name:        "appId",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"GENERAL", "PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "assetChecksums",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "bool",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
 					{
 						Name:        "assetPath",
 						ResourceRef: []config.ResourceReference{},
@@ -123,6 +176,30 @@ func githubPublishReleaseMetadata() config.StepData {
 						Mandatory:   false,
 						Aliases:     []config.Alias{},
 					},
+					{
+						Name:        "assetPathList",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "[]string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "assetUploadConcurrency",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "changelogSections",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "[]map[string]interface{}",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
 					{
 						Name:        "commitish",
 						ResourceRef: []config.ResourceReference{},
@@ -131,6 +208,14 @@ func githubPublishReleaseMetadata() config.StepData {
 						Mandatory:   false,
 						Aliases:     []config.Alias{},
 					},
+					{
+						Name:        "draft",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "bool",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
 					{
 						Name:        "excludeLabels",
 						ResourceRef: []config.ResourceReference{},
@@ -139,6 +224,14 @@ func githubPublishReleaseMetadata() config.StepData {
 						Mandatory:   false,
 						Aliases:     []config.Alias{},
 					},
+					{
+						Name:        "installationId",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"GENERAL", "PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
 					{
 						Name:        "labels",
 						ResourceRef: []config.ResourceReference{},
@@ -155,6 +248,38 @@ func githubPublishReleaseMetadata() config.StepData {
 						Mandatory:   true,
 						Aliases:     []config.Alias{{Name: "githubOrg"}},
 					},
+					{
+						Name:        "prerelease",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "bool",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "previousReleaseStrategy",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "promoteDraft",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "bool",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "privateKey",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
 					{
 						Name:        "releaseBodyHeader",
 						ResourceRef: []config.ResourceReference{},
@@ -179,14 +304,38 @@ func githubPublishReleaseMetadata() config.StepData {
 						Mandatory:   true,
 						Aliases:     []config.Alias{{Name: "githubServerUrl"}},
 					},
+					{
+						Name:        "signingKeyPassphrase",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "signingKeyPath",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
 					{
 						Name:        "token",
 						ResourceRef: []config.ResourceReference{},
 						Scope:       []string{"GENERAL", "PARAMETERS", "STAGES", "STEPS"},
 						Type:        "string",
-						Mandatory:   true,
+						Mandatory:   false,
 						Aliases:     []config.Alias{{Name: "githubToken"}},
 					},
+					{
+						Name:        "updateExisting",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "bool",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
 					{
 						Name:        "uploadUrl",
 						ResourceRef: []config.ResourceReference{},
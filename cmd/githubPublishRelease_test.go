@@ -3,9 +3,11 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 
@@ -21,18 +23,26 @@ type ghRCMock struct {
 	delID             int64
 	delOwner          string
 	delRepo           string
+	editErr           error
+	editID            int64
+	editRelease       *github.RepositoryRelease
 	listErr           error
 	listID            int64
 	listOwner         string
 	listReleaseAssets []*github.ReleaseAsset
 	listRepo          string
 	listOpts          *github.ListOptions
+	releases          []*github.RepositoryRelease
+	releasesErr       error
 	latestStatusCode  int
 	latestErr         error
 	uploadID          int64
 	uploadOpts        *github.UploadOptions
 	uploadOwner       string
 	uploadRepo        string
+	uploadErr         error
+	uploadedAssets    map[string]string
+	mutex             sync.Mutex
 }
 
 func (g *ghRCMock) CreateRelease(ctx context.Context, owner string, repo string, release *github.RepositoryRelease) (*github.RepositoryRelease, *github.Response, error) {
@@ -41,12 +51,20 @@ func (g *ghRCMock) CreateRelease(ctx context.Context, owner string, repo string,
 }
 
 func (g *ghRCMock) DeleteReleaseAsset(ctx context.Context, owner string, repo string, id int64) (*github.Response, error) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
 	g.delOwner = owner
 	g.delRepo = repo
 	g.delID = id
 	return nil, g.delErr
 }
 
+func (g *ghRCMock) EditRelease(ctx context.Context, owner string, repo string, id int64, release *github.RepositoryRelease) (*github.RepositoryRelease, *github.Response, error) {
+	g.editID = id
+	g.editRelease = release
+	return release, nil, g.editErr
+}
+
 func (g *ghRCMock) GetLatestRelease(ctx context.Context, owner string, repo string) (*github.RepositoryRelease, *github.Response, error) {
 	hc := http.Response{StatusCode: 200}
 	if g.latestStatusCode != 0 {
@@ -64,20 +82,53 @@ func (g *ghRCMock) ListReleaseAssets(ctx context.Context, owner string, repo str
 	return g.listReleaseAssets, nil, g.listErr
 }
 
+func (g *ghRCMock) ListReleases(ctx context.Context, owner string, repo string, opt *github.ListOptions) ([]*github.RepositoryRelease, *github.Response, error) {
+	return g.releases, nil, g.releasesErr
+}
+
 func (g *ghRCMock) UploadReleaseAsset(ctx context.Context, owner string, repo string, id int64, opt *github.UploadOptions, file *os.File) (*github.ReleaseAsset, *github.Response, error) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
 	g.uploadID = id
 	g.uploadOwner = owner
 	g.uploadRepo = repo
 	g.uploadOpts = opt
-	return nil, nil, nil
+	if g.uploadedAssets == nil {
+		g.uploadedAssets = map[string]string{}
+	}
+	content, _ := ioutil.ReadAll(file)
+	g.uploadedAssets[opt.Name] = string(content)
+	return nil, nil, g.uploadErr
 }
 
 type ghICMock struct {
 	issues        []*github.Issue
+	issuePages    [][]*github.Issue
 	lastPublished time.Time
 	owner         string
 	repo          string
 	options       *github.IssueListByRepoOptions
+	createRequest *github.IssueRequest
+	editRequest   *github.IssueRequest
+	editNumber    int
+	comment       *github.IssueComment
+	err           error
+}
+
+func (g *ghICMock) Create(ctx context.Context, owner string, repo string, issue *github.IssueRequest) (*github.Issue, *github.Response, error) {
+	g.createRequest = issue
+	return &github.Issue{Number: github.Int(1)}, nil, g.err
+}
+
+func (g *ghICMock) CreateComment(ctx context.Context, owner string, repo string, number int, comment *github.IssueComment) (*github.IssueComment, *github.Response, error) {
+	g.comment = comment
+	return comment, nil, g.err
+}
+
+func (g *ghICMock) Edit(ctx context.Context, owner string, repo string, number int, issue *github.IssueRequest) (*github.Issue, *github.Response, error) {
+	g.editNumber = number
+	g.editRequest = issue
+	return &github.Issue{Number: &number}, nil, g.err
 }
 
 func (g *ghICMock) ListByRepo(ctx context.Context, owner string, repo string, opt *github.IssueListByRepoOptions) ([]*github.Issue, *github.Response, error) {
@@ -85,9 +136,31 @@ func (g *ghICMock) ListByRepo(ctx context.Context, owner string, repo string, op
 	g.repo = repo
 	g.options = opt
 	g.lastPublished = opt.Since
+	if len(g.issuePages) > 0 {
+		return githubPage(g.issuePages, opt.Page), githubPageResponse(len(g.issuePages), opt.Page), nil
+	}
 	return g.issues, nil, nil
 }
 
+// githubPage provides the page of the items like the GitHub API does, the first page is provided for page 0
+func githubPage(pages [][]*github.Issue, page int) []*github.Issue {
+	if page == 0 {
+		page = 1
+	}
+	return pages[page-1]
+}
+
+func githubPageResponse(pageCount, page int) *github.Response {
+	if page == 0 {
+		page = 1
+	}
+	resp := github.Response{Response: &http.Response{StatusCode: 200}}
+	if page < pageCount {
+		resp.NextPage = page + 1
+	}
+	return &resp
+}
+
 func TestRunGithubPublishRelease(t *testing.T) {
 	ctx := context.Background()
 
@@ -107,7 +180,7 @@ func TestRunGithubPublishRelease(t *testing.T) {
 			ReleaseBodyHeader:     "Header",
 			Version:               "1.0",
 		}
-		err := runGithubPublishRelease(ctx, &myGithubPublishReleaseOptions, &ghRepoClient, &ghIssueClient)
+		err := runGithubPublishRelease(ctx, &myGithubPublishReleaseOptions, &ghRepoClient, &ghIssueClient, &ghCCMock{}, &ghPCMock{})
 		assert.NoError(t, err, "Error occured but none expected.")
 
 		assert.Equal(t, "Header\n", ghRepoClient.release.GetBody())
@@ -147,7 +220,7 @@ func TestRunGithubPublishRelease(t *testing.T) {
 			ReleaseBodyHeader:     "Header",
 			Version:               "1.1",
 		}
-		err := runGithubPublishRelease(ctx, &myGithubPublishReleaseOptions, &ghRepoClient, &ghIssueClient)
+		err := runGithubPublishRelease(ctx, &myGithubPublishReleaseOptions, &ghRepoClient, &ghIssueClient, &ghCCMock{}, &ghPCMock{})
 
 		assert.NoError(t, err, "Error occured but none expected.")
 
@@ -173,7 +246,7 @@ func TestRunGithubPublishRelease(t *testing.T) {
 			Version:   "latest",
 		}
 
-		err := runGithubPublishRelease(ctx, &myGithubPublishReleaseOptions, &ghRepoClient, &ghIssueClient)
+		err := runGithubPublishRelease(ctx, &myGithubPublishReleaseOptions, &ghRepoClient, &ghIssueClient, &ghCCMock{}, &ghPCMock{})
 
 		assert.NoError(t, err, "Error occured but none expected.")
 
@@ -183,13 +256,114 @@ func TestRunGithubPublishRelease(t *testing.T) {
 		assert.Equal(t, releaseID, ghRepoClient.uploadID)
 	})
 
+	t.Run("Success - draft prerelease", func(t *testing.T) {
+		ghRepoClient := ghRCMock{latestStatusCode: 404, latestErr: fmt.Errorf("not found")}
+		myGithubPublishReleaseOptions := githubPublishReleaseOptions{
+			Draft:      true,
+			Prerelease: true,
+			Version:    "1.0-rc.1",
+		}
+
+		err := runGithubPublishRelease(ctx, &myGithubPublishReleaseOptions, &ghRepoClient, &ghICMock{}, &ghCCMock{}, &ghPCMock{})
+
+		assert.NoError(t, err, "Error occured but none expected.")
+		assert.True(t, ghRepoClient.release.GetDraft())
+		assert.True(t, ghRepoClient.release.GetPrerelease())
+	})
+
+	t.Run("Success - update existing release", func(t *testing.T) {
+		var releaseID int64 = 2
+		existingRelease := github.RepositoryRelease{ID: &releaseID, TagName: github.String("1.1")}
+		ghRepoClient := ghRCMock{
+			latestRelease: &existingRelease,
+			releases: []*github.RepositoryRelease{
+				&existingRelease,
+				{TagName: github.String("1.0")},
+				{TagName: github.String("0.9")},
+			},
+		}
+		myGithubPublishReleaseOptions := githubPublishReleaseOptions{
+			AddDeltaToLastRelease: true,
+			AssetPath:             filepath.Join("testdata", "TestRunGithubPublishRelease", "Success_-_update_asset_test.txt"),
+			Owner:                 "TEST",
+			Repository:            "test",
+			ServerURL:             "https://github.com",
+			UpdateExisting:        true,
+			Version:               "1.1",
+		}
+
+		err := runGithubPublishRelease(ctx, &myGithubPublishReleaseOptions, &ghRepoClient, &ghICMock{}, &ghCCMock{}, &ghPCMock{})
+
+		if assert.NoError(t, err, "Error occured but none expected.") {
+			assert.Nil(t, ghRepoClient.release)
+			assert.Equal(t, releaseID, ghRepoClient.uploadID)
+			assert.Equal(t, releaseID, ghRepoClient.editID)
+			assert.Equal(t, "\n**Changes**\n[1.0...1.1](https://github.com/TEST/test/compare/1.0...1.1)\n", ghRepoClient.editRelease.GetBody())
+			assert.Nil(t, ghRepoClient.editRelease.Draft)
+		}
+	})
+
+	t.Run("Success - promote draft", func(t *testing.T) {
+		var releaseID int64 = 3
+		ghRepoClient := ghRCMock{
+			latestStatusCode: 404,
+			latestErr:        fmt.Errorf("not found"),
+			releases:         []*github.RepositoryRelease{{ID: &releaseID, TagName: github.String("1.0"), Draft: github.Bool(true)}},
+		}
+		myGithubPublishReleaseOptions := githubPublishReleaseOptions{
+			PromoteDraft: true,
+			Version:      "1.0",
+		}
+
+		err := runGithubPublishRelease(ctx, &myGithubPublishReleaseOptions, &ghRepoClient, &ghICMock{}, &ghCCMock{}, &ghPCMock{})
+
+		if assert.NoError(t, err, "Error occured but none expected.") {
+			assert.Nil(t, ghRepoClient.release)
+			assert.Equal(t, releaseID, ghRepoClient.editID)
+			assert.Equal(t, github.RepositoryRelease{Draft: github.Bool(false)}, *ghRepoClient.editRelease)
+		}
+	})
+
+	t.Run("Success - promote draft without existing release", func(t *testing.T) {
+		ghRepoClient := ghRCMock{latestStatusCode: 404, latestErr: fmt.Errorf("not found")}
+		myGithubPublishReleaseOptions := githubPublishReleaseOptions{
+			PromoteDraft: true,
+			Version:      "1.0",
+		}
+
+		err := runGithubPublishRelease(ctx, &myGithubPublishReleaseOptions, &ghRepoClient, &ghICMock{}, &ghCCMock{}, &ghPCMock{})
+
+		if assert.NoError(t, err, "Error occured but none expected.") {
+			assert.Equal(t, "1.0", ghRepoClient.release.GetTagName())
+			assert.False(t, ghRepoClient.release.GetDraft())
+			assert.Nil(t, ghRepoClient.editRelease)
+		}
+	})
+
+	t.Run("Error - update release", func(t *testing.T) {
+		ghRepoClient := ghRCMock{
+			latestStatusCode: 404,
+			latestErr:        fmt.Errorf("not found"),
+			releases:         []*github.RepositoryRelease{{TagName: github.String("1.0")}},
+			editErr:          fmt.Errorf("Edit release error"),
+		}
+		myGithubPublishReleaseOptions := githubPublishReleaseOptions{
+			UpdateExisting: true,
+			Version:        "1.0",
+		}
+
+		err := runGithubPublishRelease(ctx, &myGithubPublishReleaseOptions, &ghRepoClient, &ghICMock{}, &ghCCMock{}, &ghPCMock{})
+
+		assert.EqualError(t, err, "Update of release '1.0' failed: Edit release error")
+	})
+
 	t.Run("Error - get release", func(t *testing.T) {
 		ghIssueClient := ghICMock{}
 		ghRepoClient := ghRCMock{
 			latestErr: fmt.Errorf("Latest release error"),
 		}
 		myGithubPublishReleaseOptions := githubPublishReleaseOptions{}
-		err := runGithubPublishRelease(ctx, &myGithubPublishReleaseOptions, &ghRepoClient, &ghIssueClient)
+		err := runGithubPublishRelease(ctx, &myGithubPublishReleaseOptions, &ghRepoClient, &ghIssueClient, &ghCCMock{}, &ghPCMock{})
 
 		assert.Equal(t, "Error occured when retrieving latest GitHub release.: Latest release error", fmt.Sprint(err))
 	})
@@ -202,7 +376,7 @@ func TestRunGithubPublishRelease(t *testing.T) {
 		myGithubPublishReleaseOptions := githubPublishReleaseOptions{
 			Version: "1.0",
 		}
-		err := runGithubPublishRelease(ctx, &myGithubPublishReleaseOptions, &ghRepoClient, &ghIssueClient)
+		err := runGithubPublishRelease(ctx, &myGithubPublishReleaseOptions, &ghRepoClient, &ghIssueClient, &ghCCMock{}, &ghPCMock{})
 
 		assert.Equal(t, "Creation of release '1.0' failed: Create release error", fmt.Sprint(err))
 	})
@@ -259,6 +433,24 @@ func TestGetClosedIssuesText(t *testing.T) {
 		assert.Equal(t, publishedAt.Time, ghIssueClient.options.Since, "PublishedAt not properly passed")
 	})
 
+	t.Run("Paginated issues", func(t *testing.T) {
+		issTitle := []string{"Issue1", "Issue2"}
+		issNo := []int{1, 2}
+
+		ghIssueClient := ghICMock{
+			issuePages: [][]*github.Issue{
+				{{Number: &issNo[0], Title: &issTitle[0]}},
+				{{Number: &issNo[1], Title: &issTitle[1]}},
+			},
+		}
+		myGithubPublishReleaseOptions := githubPublishReleaseOptions{}
+
+		res := getClosedIssuesText(ctx, publishedAt, &myGithubPublishReleaseOptions, &ghIssueClient)
+
+		assert.Equal(t, "\n**List of closed issues since last release**\n[#1](): Issue1\n[#2](): Issue2\n", res)
+		assert.Equal(t, 100, ghIssueClient.options.PerPage)
+	})
+
 }
 
 func TestGetReleaseDeltaText(t *testing.T) {
@@ -300,7 +492,7 @@ func TestUploadReleaseAsset(t *testing.T) {
 			AssetPath:  filepath.Join("testdata", t.Name()+"_test.txt"),
 		}
 
-		err := uploadReleaseAsset(ctx, releaseID, &myGithubPublishReleaseOptions, &ghRepoClient)
+		err := uploadReleaseAssets(ctx, releaseID, &myGithubPublishReleaseOptions, &ghRepoClient)
 
 		assert.NoError(t, err, "Error occured but none expected.")
 
@@ -337,7 +529,7 @@ func TestUploadReleaseAsset(t *testing.T) {
 			AssetPath:  filepath.Join("testdata", t.Name()+"_test.txt"),
 		}
 
-		err := uploadReleaseAsset(ctx, releaseID, &myGithubPublishReleaseOptions, &ghRepoClient)
+		err := uploadReleaseAssets(ctx, releaseID, &myGithubPublishReleaseOptions, &ghRepoClient)
 
 		assert.NoError(t, err, "Error occured but none expected.")
 
@@ -351,7 +543,7 @@ func TestUploadReleaseAsset(t *testing.T) {
 		}
 		myGithubPublishReleaseOptions := githubPublishReleaseOptions{}
 
-		err := uploadReleaseAsset(ctx, releaseID, &myGithubPublishReleaseOptions, &ghRepoClient)
+		err := uploadReleaseAssets(ctx, releaseID, &myGithubPublishReleaseOptions, &ghRepoClient)
 		assert.Equal(t, "Failed to get list of release assets.: List Asset Error", fmt.Sprint(err), "Wrong error received")
 	})
 }
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/SAP/jenkins-library/pkg/log"
+	"github.com/google/go-github/v28/github"
+	"github.com/pkg/errors"
+
+	piperGithub "github.com/SAP/jenkins-library/pkg/github"
+)
+
+type githubStatusReporter interface {
+	SetCommitStatus(ctx context.Context, owner, repo string, report piperGithub.StatusReport) error
+	CreateCheckRun(ctx context.Context, owner, repo string, report piperGithub.StatusReport) (*github.CheckRun, error)
+}
+
+func githubSetCommitStatus(myGithubSetCommitStatusOptions githubSetCommitStatusOptions) error {
+	auth := piperGithub.Authentication{
+		Token:          myGithubSetCommitStatusOptions.Token,
+		AppID:          myGithubSetCommitStatusOptions.AppID,
+		InstallationID: myGithubSetCommitStatusOptions.InstallationID,
+		PrivateKey:     myGithubSetCommitStatusOptions.PrivateKey,
+	}
+	ctx, client, err := piperGithub.NewAuthenticatedClient(auth, myGithubSetCommitStatusOptions.APIURL, "")
+	if err != nil {
+		log.Entry().WithError(err).Fatal("Failed to get GitHub client")
+	}
+
+	err = runGithubSetCommitStatus(ctx, &myGithubSetCommitStatusOptions, piperGithub.NewStatusService(client), auth.IsApp(), ioutil.ReadFile)
+	if err != nil {
+		log.Entry().WithError(err).Fatal("Failed to report status to GitHub")
+	}
+
+	return nil
+}
+
+func runGithubSetCommitStatus(ctx context.Context, myGithubSetCommitStatusOptions *githubSetCommitStatusOptions, ghStatusReporter githubStatusReporter, githubApp bool, readFile func(string) ([]byte, error)) error {
+	report := piperGithub.StatusReport{
+		Name:        myGithubSetCommitStatusOptions.Context,
+		SHA:         myGithubSetCommitStatusOptions.CommitID,
+		State:       myGithubSetCommitStatusOptions.State,
+		Description: myGithubSetCommitStatusOptions.Description,
+		Summary:     myGithubSetCommitStatusOptions.Summary,
+		DetailsURL:  myGithubSetCommitStatusOptions.TargetURL,
+	}
+
+	switch myGithubSetCommitStatusOptions.ReportType {
+	case "status":
+		if len(myGithubSetCommitStatusOptions.AnnotationsFile) > 0 || len(myGithubSetCommitStatusOptions.SummaryFile) > 0 {
+			log.Entry().Warning("Summary and annotations are only reported for reportType 'checkRun'")
+		}
+		if err := ghStatusReporter.SetCommitStatus(ctx, myGithubSetCommitStatusOptions.Owner, myGithubSetCommitStatusOptions.Repository, report); err != nil {
+			return err
+		}
+		log.Entry().Infof("Status '%v' of commit %v set to '%v'", report.Name, report.SHA, report.State)
+		return nil
+	case "checkRun":
+		if !githubApp {
+			return fmt.Errorf("reportType 'checkRun' requires authentication as GitHub App, a personal access token is not sufficient. Please use reportType 'status'")
+		}
+	default:
+		return fmt.Errorf("Invalid reportType '%v'. Supported values: 'status', 'checkRun'", myGithubSetCommitStatusOptions.ReportType)
+	}
+
+	if len(myGithubSetCommitStatusOptions.SummaryFile) > 0 {
+		summary, err := readFile(myGithubSetCommitStatusOptions.SummaryFile)
+		if err != nil {
+			return errors.Wrapf(err, "Failed to read summary file '%v'", myGithubSetCommitStatusOptions.SummaryFile)
+		}
+		report.Summary = string(summary)
+	}
+
+	if len(myGithubSetCommitStatusOptions.AnnotationsFile) > 0 {
+		content, err := readFile(myGithubSetCommitStatusOptions.AnnotationsFile)
+		if err != nil {
+			return errors.Wrapf(err, "Failed to read annotations file '%v'", myGithubSetCommitStatusOptions.AnnotationsFile)
+		}
+		if err := json.Unmarshal(content, &report.Annotations); err != nil {
+			return errors.Wrapf(err, "Failed to parse annotations file '%v'", myGithubSetCommitStatusOptions.AnnotationsFile)
+		}
+	}
+
+	checkRun, err := ghStatusReporter.CreateCheckRun(ctx, myGithubSetCommitStatusOptions.Owner, myGithubSetCommitStatusOptions.Repository, report)
+	if err != nil {
+		return err
+	}
+	log.Entry().Infof("Check run '%v' created for commit %v with %v annotations: %v", report.Name, report.SHA, len(report.Annotations), checkRun.GetHTMLURL())
+	return nil
+}
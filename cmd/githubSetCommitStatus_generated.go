@@ -0,0 +1,228 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/SAP/jenkins-library/pkg/config"
+	"github.com/SAP/jenkins-library/pkg/log"
+
+	"github.com/spf13/cobra"
+)
+
+type githubSetCommitStatusOptions struct {
+	AnnotationsFile string `json:"annotationsFile,omitempty"`
+	APIURL          string `json:"apiUrl,omitempty"`
+	AppID           string `json:"appId,omitempty"`
+	CommitID        string `json:"commitId,omitempty"`
+	Context         string `json:"context,omitempty"`
+	Description     string `json:"description,omitempty"`
+	InstallationID  string `json:"installationId,omitempty"`
+	Owner           string `json:"owner,omitempty"`
+	PrivateKey      string `json:"privateKey,omitempty"`
+	ReportType      string `json:"reportType,omitempty"`
+	Repository      string `json:"repository,omitempty"`
+	State           string `json:"state,omitempty"`
+	Summary         string `json:"summary,omitempty"`
+	SummaryFile     string `json:"summaryFile,omitempty"`
+	TargetURL       string `json:"targetUrl,omitempty"`
+	Token           string `json:"token,omitempty"`
+}
+
+var myGithubSetCommitStatusOptions githubSetCommitStatusOptions
+
+// GithubSetCommitStatusCommand Report the result of a step to a commit on GitHub
+func GithubSetCommitStatusCommand() *cobra.Command {
+	metadata := githubSetCommitStatusMetadata()
+
+	var createGithubSetCommitStatusCmd = &cobra.Command{
+		Use:   "githubSetCommitStatus",
+		Short: "Report the result of a step to a commit on GitHub",
+		Long: `This step reports the result of a step like a scan, a test or a deployment back to a commit on GitHub.
+
+The result is either reported as commit status or as check run.
+A check run in addition contains a summary in markdown format and annotations, i.e. findings related to lines of files which are shown in the pull request.
+Please note that check runs can only be created when authenticated as GitHub App, i.e. ` + "`" + `appId` + "`" + `, ` + "`" + `installationId` + "`" + ` and ` + "`" + `privateKey` + "`" + ` are provided instead of ` + "`" + `token` + "`" + `.
+
+The annotations are read from a JSON file containing a list of objects like
+` + "`" + `{"path": "src/main.go", "startLine": 10, "endLine": 12, "level": "warning", "title": "Unchecked error", "message": "..."}` + "`" + `.
+Supported levels are ` + "`" + `notice` + "`" + `, ` + "`" + `warning` + "`" + ` (default) and ` + "`" + `failure` + "`" + `.`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			log.SetStepName("githubSetCommitStatus")
+			log.SetVerbose(GeneralConfig.Verbose)
+			return PrepareConfig(cmd, &metadata, "githubSetCommitStatus", &myGithubSetCommitStatusOptions, config.OpenPiperFile)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			return githubSetCommitStatus(myGithubSetCommitStatusOptions)
+		},
+	}
+
+	addGithubSetCommitStatusFlags(createGithubSetCommitStatusCmd)
+	return createGithubSetCommitStatusCmd
+}
+
+func addGithubSetCommitStatusFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&myGithubSetCommitStatusOptions.AnnotationsFile, "annotationsFile", os.Getenv("PIPER_annotationsFile"), "Path of a JSON file containing the annotations of a check run.")
+	cmd.Flags().StringVar(&myGithubSetCommitStatusOptions.APIURL, "apiUrl", "https://api.github.com", "Set the GitHub API url.")
+	cmd.Flags().StringVar(&myGithubSetCommitStatusOptions.AppID, "appId", os.Getenv("PIPER_appId"), "ID of the GitHub App used for authentication. In case it is provided, the step authenticates as installation `installationId` of the app instead of using `token`.")
+	cmd.Flags().StringVar(&myGithubSetCommitStatusOptions.CommitID, "commitId", os.Getenv("PIPER_commitId"), "SHA of the commit the result is reported for.")
+	cmd.Flags().StringVar(&myGithubSetCommitStatusOptions.Context, "context", "piper", "Name of the commit status or check run. It identifies the result, i.e. reporting again with the same name replaces the commit status.")
+	cmd.Flags().StringVar(&myGithubSetCommitStatusOptions.Description, "description", os.Getenv("PIPER_description"), "Short description of the result. It is used as title of a check run.")
+	cmd.Flags().StringVar(&myGithubSetCommitStatusOptions.InstallationID, "installationId", os.Getenv("PIPER_installationId"), "ID of the installation of the GitHub App `appId` in the organization or repository.")
+	cmd.Flags().StringVar(&myGithubSetCommitStatusOptions.Owner, "owner", os.Getenv("PIPER_owner"), "Set the GitHub organization.")
+	cmd.Flags().StringVar(&myGithubSetCommitStatusOptions.PrivateKey, "privateKey", os.Getenv("PIPER_privateKey"), "Private key of the GitHub App `appId` in PEM format as generated by GitHub.")
+	cmd.Flags().StringVar(&myGithubSetCommitStatusOptions.ReportType, "reportType", "status", "Defines how the result is reported. Values: 'status', 'checkRun'. A check run requires authentication as GitHub App.")
+	cmd.Flags().StringVar(&myGithubSetCommitStatusOptions.Repository, "repository", os.Getenv("PIPER_repository"), "Set the GitHub repository.")
+	cmd.Flags().StringVar(&myGithubSetCommitStatusOptions.State, "state", os.Getenv("PIPER_state"), "State of the result. Values: 'pending', 'success', 'failure', 'error'")
+	cmd.Flags().StringVar(&myGithubSetCommitStatusOptions.Summary, "summary", os.Getenv("PIPER_summary"), "Summary of a check run in markdown format.")
+	cmd.Flags().StringVar(&myGithubSetCommitStatusOptions.SummaryFile, "summaryFile", os.Getenv("PIPER_summaryFile"), "Path of a markdown file containing the summary of a check run, e.g. a report created by a previous step. It takes precedence over `summary`.")
+	cmd.Flags().StringVar(&myGithubSetCommitStatusOptions.TargetURL, "targetUrl", os.Getenv("PIPER_targetUrl"), "URL of the details of the result, e.g. the URL of the build.")
+	cmd.Flags().StringVar(&myGithubSetCommitStatusOptions.Token, "token", os.Getenv("PIPER_token"), "GitHub personal access token as per https://help.github.com/en/github/authenticating-to-github/creating-a-personal-access-token-for-the-command-line. It is not required when authenticating as GitHub App.")
+
+	cmd.MarkFlagRequired("apiUrl")
+	cmd.MarkFlagRequired("commitId")
+	cmd.MarkFlagRequired("owner")
+	cmd.MarkFlagRequired("repository")
+	cmd.MarkFlagRequired("state")
+}
+
+// retrieve step metadata
+func githubSetCommitStatusMetadata() config.StepData {
+	var theMetaData = config.StepData{
+		Spec: config.StepSpec{
+			Inputs: config.StepInputs{
+				Parameters: []config.StepParameters{
+					{
+						Name:        "annotationsFile",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "apiUrl",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"GENERAL", "PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   true,
+						Aliases:     []config.Alias{{Name: "githubApiUrl"}},
+					},
+					{
+						Name:        "appId",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"GENERAL", "PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "commitId",
+						ResourceRef: []config.ResourceReference{{Name: "commonPipelineEnvironment", Param: "git/commitId"}},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   true,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "context",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "description",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "installationId",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"GENERAL", "PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "owner",
+						ResourceRef: []config.ResourceReference{{Name: "commonPipelineEnvironment", Param: "github/owner"}},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   true,
+						Aliases:     []config.Alias{{Name: "githubOrg"}},
+					},
+					{
+						Name:        "privateKey",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "reportType",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "repository",
+						ResourceRef: []config.ResourceReference{{Name: "commonPipelineEnvironment", Param: "github/repository"}},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   true,
+						Aliases:     []config.Alias{{Name: "githubRepo"}},
+					},
+					{
+						Name:        "state",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   true,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "summary",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "summaryFile",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "targetUrl",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "token",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"GENERAL", "PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{{Name: "githubToken"}},
+					},
+				},
+			},
+		},
+	}
+	return theMetaData
+}
@@ -0,0 +1,16 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGithubSetCommitStatusCommand(t *testing.T) {
+
+	testCmd := GithubSetCommitStatusCommand()
+
+	// only high level testing performed - details are tested in step generation procudure
+	assert.Equal(t, "githubSetCommitStatus", testCmd.Use, "command name incorrect")
+
+}
@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/google/go-github/v28/github"
+	"github.com/stretchr/testify/assert"
+
+	piperGithub "github.com/SAP/jenkins-library/pkg/github"
+)
+
+type ghStatusReporterMock struct {
+	statusReport   *piperGithub.StatusReport
+	checkRunReport *piperGithub.StatusReport
+	owner          string
+	repo           string
+	err            error
+}
+
+func (g *ghStatusReporterMock) SetCommitStatus(ctx context.Context, owner, repo string, report piperGithub.StatusReport) error {
+	g.owner = owner
+	g.repo = repo
+	g.statusReport = &report
+	return g.err
+}
+
+func (g *ghStatusReporterMock) CreateCheckRun(ctx context.Context, owner, repo string, report piperGithub.StatusReport) (*github.CheckRun, error) {
+	g.owner = owner
+	g.repo = repo
+	g.checkRunReport = &report
+	return &github.CheckRun{}, g.err
+}
+
+func TestRunGithubSetCommitStatus(t *testing.T) {
+	ctx := context.Background()
+	files := map[string]string{
+		"summary.md":       "**2** findings",
+		"annotations.json": `[{"path": "src/main.go", "startLine": 10, "level": "failure", "message": "Unchecked error"}]`,
+		"invalid.json":     `{"path": "src/main.go"}`,
+	}
+	readFile := func(path string) ([]byte, error) {
+		if content, ok := files[path]; ok {
+			return []byte(content), nil
+		}
+		return nil, os.ErrNotExist
+	}
+
+	options := githubSetCommitStatusOptions{
+		Owner:       "TEST",
+		Repository:  "test",
+		CommitID:    "abc",
+		Context:     "piper/scan",
+		State:       "failure",
+		Description: "2 findings",
+		Summary:     "Findings",
+		TargetURL:   "https://jenkins/job/1",
+		ReportType:  "status",
+	}
+
+	t.Run("commit status", func(t *testing.T) {
+		ghStatusReporter := ghStatusReporterMock{}
+
+		err := runGithubSetCommitStatus(ctx, &options, &ghStatusReporter, false, readFile)
+
+		if assert.NoError(t, err) {
+			assert.Equal(t, "TEST", ghStatusReporter.owner)
+			assert.Equal(t, "test", ghStatusReporter.repo)
+			assert.Equal(t, piperGithub.StatusReport{
+				Name:        "piper/scan",
+				SHA:         "abc",
+				State:       "failure",
+				Description: "2 findings",
+				Summary:     "Findings",
+				DetailsURL:  "https://jenkins/job/1",
+			}, *ghStatusReporter.statusReport)
+			assert.Nil(t, ghStatusReporter.checkRunReport)
+		}
+	})
+
+	t.Run("check run", func(t *testing.T) {
+		ghStatusReporter := ghStatusReporterMock{}
+		checkRunOptions := options
+		checkRunOptions.ReportType = "checkRun"
+		checkRunOptions.SummaryFile = "summary.md"
+		checkRunOptions.AnnotationsFile = "annotations.json"
+
+		err := runGithubSetCommitStatus(ctx, &checkRunOptions, &ghStatusReporter, true, readFile)
+
+		if assert.NoError(t, err) {
+			assert.Equal(t, "**2** findings", ghStatusReporter.checkRunReport.Summary)
+			assert.Equal(t, []piperGithub.Annotation{{Path: "src/main.go", StartLine: 10, Level: "failure", Message: "Unchecked error"}}, ghStatusReporter.checkRunReport.Annotations)
+			assert.Nil(t, ghStatusReporter.statusReport)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		invalidOptions := options
+		invalidOptions.ReportType = "comment"
+		err := runGithubSetCommitStatus(ctx, &invalidOptions, &ghStatusReporterMock{}, true, readFile)
+		assert.EqualError(t, err, "Invalid reportType 'comment'. Supported values: 'status', 'checkRun'")
+
+		invalidOptions.ReportType = "checkRun"
+		ghStatusReporter := ghStatusReporterMock{}
+		err = runGithubSetCommitStatus(ctx, &invalidOptions, &ghStatusReporter, false, readFile)
+		assert.EqualError(t, err, "reportType 'checkRun' requires authentication as GitHub App, a personal access token is not sufficient. Please use reportType 'status'")
+		assert.Nil(t, ghStatusReporter.checkRunReport)
+
+		invalidOptions.ReportType = "checkRun"
+		invalidOptions.AnnotationsFile = "missing.json"
+		err = runGithubSetCommitStatus(ctx, &invalidOptions, &ghStatusReporterMock{}, true, readFile)
+		assert.EqualError(t, err, "Failed to read annotations file 'missing.json': file does not exist")
+
+		invalidOptions.AnnotationsFile = "invalid.json"
+		err = runGithubSetCommitStatus(ctx, &invalidOptions, &ghStatusReporterMock{}, true, readFile)
+		assert.Contains(t, fmt.Sprint(err), "Failed to parse annotations file 'invalid.json'")
+
+		err = runGithubSetCommitStatus(ctx, &options, &ghStatusReporterMock{err: fmt.Errorf("Failed to set status")}, false, readFile)
+		assert.EqualError(t, err, "Failed to set status")
+	})
+}
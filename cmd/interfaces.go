@@ -2,15 +2,26 @@ package cmd
 
 import (
 	"io"
+
+	"github.com/SAP/jenkins-library/pkg/command"
 )
 
 type execRunner interface {
 	RunExecutable(e string, p ...string) error
 	Dir(d string)
+	Env(e []string)
+	Stdin(in io.Reader)
 	Stdout(out io.Writer)
 	Stderr(err io.Writer)
 }
 
+// capturingExecRunner additionally routes the output of the executions into a capture
+type capturingExecRunner interface {
+	execRunner
+	Capture(capture *command.Capture)
+	TailOnFailure(lines int)
+}
+
 type shellRunner interface {
 	RunShell(s string, c string) error
 	Dir(d string)
@@ -2,43 +2,113 @@ package cmd
 
 import (
 	"strings"
+	"time"
 
 	"github.com/SAP/jenkins-library/pkg/command"
 	"github.com/SAP/jenkins-library/pkg/log"
+	"github.com/pkg/errors"
 )
 
-func karmaExecuteTests(myKarmaExecuteTestsOptions karmaExecuteTestsOptions) error {
-	c := command.Command{}
-	// reroute command output to loging framework
-	// also log stdout as Karma reports into it
-	c.Stdout(log.Entry().Writer())
-	c.Stderr(log.Entry().Writer())
-	runKarma(myKarmaExecuteTestsOptions, &c)
-	return nil
+func karmaExecuteTests(myKarmaExecuteTestsOptions karmaExecuteTestsOptions, piperEnvironment *karmaExecuteTestsCommonPipelineEnvironment, influx *karmaExecuteTestsInflux) error {
+	// each module requires its own command since modules may be tested in parallel
+	newCommand := func() execRunner {
+		return &command.Command{}
+	}
+	return runKarma(myKarmaExecuteTestsOptions, newCommand, piperEnvironment, influx)
 }
 
-func runKarma(myKarmaExecuteTestsOptions karmaExecuteTestsOptions, command execRunner) {
-	installCommandTokens := tokenize(myKarmaExecuteTestsOptions.InstallCommand)
-	command.Dir(myKarmaExecuteTestsOptions.ModulePath)
-	err := command.RunExecutable(installCommandTokens[0], installCommandTokens[1:]...)
+func runKarma(myKarmaExecuteTestsOptions karmaExecuteTestsOptions, newCommand func() execRunner, piperEnvironment *karmaExecuteTestsCommonPipelineEnvironment, influx *karmaExecuteTestsInflux) error {
+	// invalid commands are reported once instead of failing every module
+	if _, err := parseKarmaCommand("installCommand", myKarmaExecuteTestsOptions.InstallCommand); err != nil {
+		return err
+	}
+	if _, err := parseKarmaCommand("runCommand", myKarmaExecuteTestsOptions.RunCommand); err != nil {
+		return err
+	}
+
+	modulePaths, err := getKarmaModules(myKarmaExecuteTestsOptions)
 	if err != nil {
-		log.Entry().
-			WithError(err).
-			WithField("command", myKarmaExecuteTestsOptions.InstallCommand).
-			Fatal("failed to execute install command")
+		return err
 	}
 
-	runCommandTokens := tokenize(myKarmaExecuteTestsOptions.RunCommand)
-	command.Dir(myKarmaExecuteTestsOptions.ModulePath)
-	err = command.RunExecutable(runCommandTokens[0], runCommandTokens[1:]...)
+	moduleResults, err := runKarmaModules(modulePaths, myKarmaExecuteTestsOptions, newCommand)
 	if err != nil {
-		log.Entry().
-			WithError(err).
-			WithField("command", myKarmaExecuteTestsOptions.RunCommand).
-			Fatal("failed to execute run command")
+		return err
+	}
+
+	results := karmaResults{}
+	failed := []string{}
+	for _, module := range moduleResults {
+		if len(moduleResults) > 1 {
+			log.Entry().Infof("Results of module '%v':", module.modulePath)
+			logKarmaResults(module.results)
+		}
+		results.add(module.results)
+		if module.err != nil {
+			log.Entry().WithError(module.err).WithField("module", module.modulePath).Error("Karma tests failed")
+			failed = append(failed, module.modulePath)
+		}
+	}
+	if len(moduleResults) > 1 {
+		log.Entry().Infof("Aggregated results of %v modules:", len(moduleResults))
 	}
+	logKarmaResults(results)
+	persistKarmaResults(results, piperEnvironment, influx)
+
+	if len(failed) > 0 {
+		if len(moduleResults) == 1 {
+			return moduleResults[0].err
+		}
+		return errors.Errorf("Karma tests failed in %v of %v modules: %v", len(failed), len(moduleResults), strings.Join(failed, ", "))
+	}
+	return checkKarmaCoverage(results, myKarmaExecuteTestsOptions)
 }
 
-func tokenize(command string) []string {
-	return strings.Split(command, " ")
+// runKarmaModule installs the test tool and runs the tests of a single module
+func runKarmaModule(modulePath string, myKarmaExecuteTestsOptions karmaExecuteTestsOptions, command execRunner) (karmaResults, error) {
+	installCommandTokens, err := parseKarmaCommand("installCommand", myKarmaExecuteTestsOptions.InstallCommand)
+	if err != nil {
+		return karmaResults{}, err
+	}
+	runCommandTokens, err := parseKarmaCommand("runCommand", myKarmaExecuteTestsOptions.RunCommand)
+	if err != nil {
+		return karmaResults{}, err
+	}
+
+	command.Dir(modulePath)
+	err = command.RunExecutable(installCommandTokens[0], installCommandTokens[1:]...)
+	if err != nil {
+		return karmaResults{}, errors.Wrapf(err, "failed to execute install command '%v'", myKarmaExecuteTestsOptions.InstallCommand)
+	}
+
+	// reports of previous runs are not considered, the time is truncated since file systems may store seconds only
+	started := time.Now().Truncate(time.Second)
+	command.Dir(modulePath)
+	runErr := command.RunExecutable(runCommandTokens[0], runCommandTokens[1:]...)
+
+	// results are also published in case of failing tests
+	results, err := collectKarmaResults(modulePath, started, myKarmaExecuteTestsOptions)
+	if err != nil {
+		if runErr == nil {
+			return results, err
+		}
+		log.Entry().WithError(err).WithField("module", modulePath).Error("Failed to collect the test results")
+	}
+
+	if runErr != nil {
+		return results, errors.Wrapf(runErr, "failed to execute run command '%v'", myKarmaExecuteTestsOptions.RunCommand)
+	}
+	return results, nil
+}
+
+// parseKarmaCommand splits the command into executable and arguments, quotes are considered like in a shell
+func parseKarmaCommand(name, karmaCommand string) ([]string, error) {
+	tokens, err := command.ParseArgs(karmaCommand)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Invalid %v '%v'", name, karmaCommand)
+	}
+	if len(tokens) == 0 {
+		return nil, errors.Errorf("No %v provided", name)
+	}
+	return tokens, nil
 }
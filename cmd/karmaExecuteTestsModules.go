@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/SAP/jenkins-library/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// karmaModuleResult contains the results of a single module, err is set in case the tests of the module failed
+type karmaModuleResult struct {
+	modulePath string
+	results    karmaResults
+	err        error
+}
+
+// getKarmaModules resolves the module paths, the karma config pattern takes precedence over the module paths
+func getKarmaModules(myKarmaExecuteTestsOptions karmaExecuteTestsOptions) ([]string, error) {
+	if len(myKarmaExecuteTestsOptions.KarmaConfigPattern) > 0 {
+		matches, err := filepath.Glob(myKarmaExecuteTestsOptions.KarmaConfigPattern)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Invalid karmaConfigPattern '%v'", myKarmaExecuteTestsOptions.KarmaConfigPattern)
+		}
+		modules := []string{}
+		for _, match := range matches {
+			modules = appendKarmaModule(modules, filepath.Dir(match))
+		}
+		if len(modules) == 0 {
+			return nil, fmt.Errorf("No karma configuration found for '%v'", myKarmaExecuteTestsOptions.KarmaConfigPattern)
+		}
+		return modules, nil
+	}
+
+	if len(myKarmaExecuteTestsOptions.ModulePaths) > 0 {
+		modules := []string{}
+		for _, pattern := range myKarmaExecuteTestsOptions.ModulePaths {
+			matches, err := filepath.Glob(pattern)
+			if err != nil {
+				return nil, errors.Wrapf(err, "Invalid module path '%v'", pattern)
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("No module found for '%v'", pattern)
+			}
+			sort.Strings(matches)
+			for _, match := range matches {
+				if info, err := os.Stat(match); err == nil && info.IsDir() {
+					modules = appendKarmaModule(modules, match)
+				}
+			}
+		}
+		if len(modules) == 0 {
+			return nil, fmt.Errorf("No module directory found for modulePaths '%v'", strings.Join(myKarmaExecuteTestsOptions.ModulePaths, "', '"))
+		}
+		return modules, nil
+	}
+
+	return []string{myKarmaExecuteTestsOptions.ModulePath}, nil
+}
+
+func appendKarmaModule(modules []string, modulePath string) []string {
+	if sliceContains(modules, modulePath) {
+		return modules
+	}
+	return append(modules, modulePath)
+}
+
+// runKarmaModules tests all modules, a failing module does not prevent the remaining modules from being tested
+func runKarmaModules(modulePaths []string, myKarmaExecuteTestsOptions karmaExecuteTestsOptions, newCommand func() execRunner) ([]karmaModuleResult, error) {
+	concurrency := 1
+	if len(myKarmaExecuteTestsOptions.ModuleConcurrency) > 0 {
+		var err error
+		concurrency, err = strconv.Atoi(myKarmaExecuteTestsOptions.ModuleConcurrency)
+		if err != nil || concurrency < 1 {
+			return nil, fmt.Errorf("Invalid moduleConcurrency '%v', expected a positive number", myKarmaExecuteTestsOptions.ModuleConcurrency)
+		}
+	}
+	if concurrency > 1 && len(modulePaths) > 1 {
+		log.Entry().Warningf("Testing %v modules in parallel, the modules share the Selenium sidecar and require distinct Karma ports", len(modulePaths))
+	}
+
+	if len(myKarmaExecuteTestsOptions.LogDirectory) > 0 {
+		if err := os.MkdirAll(myKarmaExecuteTestsOptions.LogDirectory, 0755); err != nil {
+			return nil, errors.Wrapf(err, "Failed to create log directory '%v'", myKarmaExecuteTestsOptions.LogDirectory)
+		}
+	}
+
+	results := make([]karmaModuleResult, len(modulePaths))
+	semaphore := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, modulePath := range modulePaths {
+		// the logger is created outside of the goroutines since its lazy initialization is not thread-safe
+		logger := log.Entry().WithField("module", modulePath)
+		wg.Add(1)
+		go func(i int, modulePath string, logger *logrus.Entry) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+			results[i] = runKarmaModuleWithLog(modulePath, myKarmaExecuteTestsOptions, newCommand(), logger)
+		}(i, modulePath, logger)
+	}
+	wg.Wait()
+	return results, nil
+}
+
+// runKarmaModuleWithLog tests the module, the output is logged with the module as field and written to the log file of the module
+func runKarmaModuleWithLog(modulePath string, myKarmaExecuteTestsOptions karmaExecuteTestsOptions, command execRunner, logger *logrus.Entry) karmaModuleResult {
+	logger.Infof("Executing karma tests of module '%v'", modulePath)
+	// also log stdout as Karma reports into it
+	logWriter := logger.Writer()
+	defer logWriter.Close()
+
+	var out io.Writer = logWriter
+	if len(myKarmaExecuteTestsOptions.LogDirectory) > 0 {
+		logPath := filepath.Join(myKarmaExecuteTestsOptions.LogDirectory, karmaLogName(modulePath))
+		logFile, err := os.Create(logPath)
+		if err != nil {
+			return karmaModuleResult{modulePath: modulePath, err: errors.Wrapf(err, "Failed to create log file '%v'", logPath)}
+		}
+		defer logFile.Close()
+		out = io.MultiWriter(logWriter, logFile)
+	}
+	command.Stdout(out)
+	command.Stderr(out)
+
+	results, err := runKarmaModule(modulePath, myKarmaExecuteTestsOptions, command)
+	return karmaModuleResult{modulePath: modulePath, results: results, err: err}
+}
+
+// karmaLogName provides the name of the log file of the module, e.g. 'apps_app1.log' for module 'apps/app1'
+func karmaLogName(modulePath string) string {
+	name := strings.Trim(filepath.ToSlash(filepath.Clean(modulePath)), "./")
+	if len(name) == 0 {
+		name = "root"
+	}
+	return strings.Replace(name, "/", "_", -1) + ".log"
+}
@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetKarmaModules(t *testing.T) {
+	appsPath := filepath.Join("testdata", "TestKarmaModules", "apps")
+	moduleA, moduleB, moduleC := filepath.Join(appsPath, "a"), filepath.Join(appsPath, "b"), filepath.Join(appsPath, "c")
+
+	t.Run("default module path", func(t *testing.T) {
+		modules, err := getKarmaModules(karmaExecuteTestsOptions{ModulePath: "."})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"."}, modules)
+	})
+
+	t.Run("karma config pattern", func(t *testing.T) {
+		opts := karmaExecuteTestsOptions{ModulePath: ".", KarmaConfigPattern: filepath.Join(appsPath, "*", "karma.conf.js")}
+		modules, err := getKarmaModules(opts)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{moduleA, moduleB}, modules)
+	})
+
+	t.Run("module paths with glob", func(t *testing.T) {
+		opts := karmaExecuteTestsOptions{ModulePaths: []string{moduleB, filepath.Join(appsPath, "*")}}
+		modules, err := getKarmaModules(opts)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{moduleB, moduleA, moduleC}, modules)
+	})
+
+	t.Run("no karma config found", func(t *testing.T) {
+		opts := karmaExecuteTestsOptions{KarmaConfigPattern: filepath.Join(appsPath, "*", "karma.config.ts")}
+		_, err := getKarmaModules(opts)
+		assert.EqualError(t, err, "No karma configuration found for '"+opts.KarmaConfigPattern+"'")
+	})
+
+	t.Run("no module found", func(t *testing.T) {
+		opts := karmaExecuteTestsOptions{ModulePaths: []string{filepath.Join(appsPath, "x*")}}
+		_, err := getKarmaModules(opts)
+		assert.EqualError(t, err, "No module found for '"+opts.ModulePaths[0]+"'")
+	})
+
+	t.Run("only files found", func(t *testing.T) {
+		opts := karmaExecuteTestsOptions{ModulePaths: []string{filepath.Join(appsPath, "*", "karma.conf.js"), filepath.Join(moduleC, "README.md")}}
+		_, err := getKarmaModules(opts)
+		assert.EqualError(t, err, "No module directory found for modulePaths '"+opts.ModulePaths[0]+"', '"+opts.ModulePaths[1]+"'")
+	})
+}
+
+func TestRunKarmaModules(t *testing.T) {
+	modulesPath := copyKarmaReports(t, filepath.Join("testdata", "TestKarmaModules"))
+	defer os.RemoveAll(modulesPath)
+	appsPath := filepath.Join(modulesPath, "apps")
+	moduleA, moduleB := filepath.Join(appsPath, "a"), filepath.Join(appsPath, "b")
+
+	// newMockCommands creates a mock per module, commands of module b fail in case failRun is set
+	newMockCommands := func(failRun bool) (func() execRunner, *[]*execMockRunner) {
+		var mutex sync.Mutex
+		mocks := []*execMockRunner{}
+		return func() execRunner {
+			mutex.Lock()
+			defer mutex.Unlock()
+			e := &execMockRunner{stdoutReturn: map[string]string{"npm run": "Executed tests\n"}}
+			if failRun {
+				e.shouldFailOnCommand = map[string]error{"npm run test": errors.New("error case")}
+			}
+			mocks = append(mocks, e)
+			return e
+		}, &mocks
+	}
+
+	t.Run("aggregated results", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "karma")
+		if err != nil {
+			t.Fatal("Failed to create temporary directory")
+		}
+		defer os.RemoveAll(dir)
+
+		opts := karmaExecuteTestsOptions{
+			KarmaConfigPattern:  filepath.Join(appsPath, "*", "karma.conf.js"),
+			InstallCommand:      "npm install",
+			RunCommand:          "npm run test",
+			JunitReportPatterns: []string{"TEST-*.xml"},
+			ModuleConcurrency:   "2",
+			LogDirectory:        filepath.Join(dir, "logs"),
+		}
+		piperEnvironment := karmaExecuteTestsCommonPipelineEnvironment{}
+		influx := karmaExecuteTestsInflux{}
+		newCommand, mocks := newMockCommands(false)
+
+		err = runKarma(opts, newCommand, &piperEnvironment, &influx)
+
+		assert.NoError(t, err)
+		assert.Len(t, *mocks, 2)
+		dirs := []string{}
+		for _, e := range *mocks {
+			assert.Len(t, e.calls, 2)
+			dirs = append(dirs, e.dir[0])
+		}
+		sort.Strings(dirs)
+		assert.Equal(t, []string{moduleA, moduleB}, dirs)
+		assert.Equal(t, "5", piperEnvironment.karma.tests)
+		assert.Equal(t, "1", piperEnvironment.karma.failures)
+		assert.Equal(t, "5", influx.karma_data.fields.tests)
+
+		logA, err := ioutil.ReadFile(filepath.Join(dir, "logs", karmaLogName(moduleA)))
+		assert.NoError(t, err)
+		assert.Equal(t, "Executed tests\n", string(logA))
+		assert.FileExists(t, filepath.Join(dir, "logs", karmaLogName(moduleB)))
+	})
+
+	t.Run("failing modules", func(t *testing.T) {
+		opts := karmaExecuteTestsOptions{
+			ModulePaths:         []string{moduleA, moduleB},
+			InstallCommand:      "npm install",
+			RunCommand:          "npm run test",
+			JunitReportPatterns: []string{"TEST-*.xml"},
+			ModuleConcurrency:   "1",
+		}
+		piperEnvironment := karmaExecuteTestsCommonPipelineEnvironment{}
+		newCommand, mocks := newMockCommands(true)
+
+		err := runKarma(opts, newCommand, &piperEnvironment, &karmaExecuteTestsInflux{})
+
+		assert.EqualError(t, err, "Karma tests failed in 2 of 2 modules: "+moduleA+", "+moduleB)
+		assert.Len(t, *mocks, 2, "all modules expected to be tested")
+		assert.Equal(t, "5", piperEnvironment.karma.tests, "results of all modules expected")
+	})
+
+	t.Run("invalid module concurrency", func(t *testing.T) {
+		opts := karmaExecuteTestsOptions{ModulePath: moduleA, InstallCommand: "npm install", RunCommand: "npm run test", ModuleConcurrency: "0"}
+		newCommand, mocks := newMockCommands(false)
+
+		err := runKarma(opts, newCommand, &karmaExecuteTestsCommonPipelineEnvironment{}, &karmaExecuteTestsInflux{})
+
+		assert.EqualError(t, err, "Invalid moduleConcurrency '0', expected a positive number")
+		assert.Empty(t, *mocks)
+	})
+}
+
+func TestKarmaLogName(t *testing.T) {
+	assert.Equal(t, "root.log", karmaLogName("."))
+	assert.Equal(t, "apps_app1.log", karmaLogName("./apps/app1/"))
+	assert.Equal(t, "ui.log", karmaLogName("ui"))
+}
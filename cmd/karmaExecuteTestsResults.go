@@ -0,0 +1,341 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SAP/jenkins-library/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// karmaResults contains the aggregated test and coverage results of all reports
+type karmaResults struct {
+	tests           int
+	failures        int
+	errors          int
+	skipped         int
+	linesCovered    int
+	linesValid      int
+	branchesCovered int
+	branchesValid   int
+	coverageReports int
+}
+
+// junitSuite is used for <testsuites> as well as <testsuite> elements, suites may be nested
+type junitSuite struct {
+	Suites []junitSuite `xml:"testsuite"`
+	Cases  []junitCase  `xml:"testcase"`
+}
+
+type junitCase struct {
+	Failures []struct{} `xml:"failure"`
+	Errors   []struct{} `xml:"error"`
+	Skipped  []struct{} `xml:"skipped"`
+}
+
+type coberturaCoverage struct {
+	LinesCovered    *int            `xml:"lines-covered,attr"`
+	LinesValid      *int            `xml:"lines-valid,attr"`
+	BranchesCovered *int            `xml:"branches-covered,attr"`
+	BranchesValid   *int            `xml:"branches-valid,attr"`
+	Lines           []coberturaLine `xml:"packages>package>classes>class>lines>line"`
+}
+
+type coberturaLine struct {
+	Hits              int    `xml:"hits,attr"`
+	Branch            bool   `xml:"branch,attr"`
+	ConditionCoverage string `xml:"condition-coverage,attr"`
+}
+
+func (r *karmaResults) add(other karmaResults) {
+	r.tests += other.tests
+	r.failures += other.failures
+	r.errors += other.errors
+	r.skipped += other.skipped
+	r.linesCovered += other.linesCovered
+	r.linesValid += other.linesValid
+	r.branchesCovered += other.branchesCovered
+	r.branchesValid += other.branchesValid
+	r.coverageReports += other.coverageReports
+}
+
+// lineCoverage provides the line coverage in percent, 100 in case there are no lines
+func (r *karmaResults) lineCoverage() float64 {
+	return coveragePercent(r.linesCovered, r.linesValid)
+}
+
+// branchCoverage provides the branch coverage in percent, 100 in case there are no branches
+func (r *karmaResults) branchCoverage() float64 {
+	return coveragePercent(r.branchesCovered, r.branchesValid)
+}
+
+func coveragePercent(covered, valid int) float64 {
+	if valid == 0 {
+		return 100
+	}
+	return float64(covered) * 100 / float64(valid)
+}
+
+// collectKarmaResults parses the JUnit and coverage reports found in the module, reports written before since are outdated and skipped
+func collectKarmaResults(modulePath string, since time.Time, myKarmaExecuteTestsOptions karmaExecuteTestsOptions) (karmaResults, error) {
+	results := karmaResults{}
+
+	junitReports, err := findKarmaReports(modulePath, since, myKarmaExecuteTestsOptions.JunitReportPatterns)
+	if err != nil {
+		return results, err
+	}
+	for _, report := range junitReports {
+		reportResults, err := parseJUnitReport(report)
+		if err != nil {
+			return results, err
+		}
+		results.add(reportResults)
+	}
+
+	coverageReports, err := findKarmaReports(modulePath, since, myKarmaExecuteTestsOptions.CoverageReportPatterns)
+	if err != nil {
+		return results, err
+	}
+	for _, report := range coverageReports {
+		var reportResults karmaResults
+		if strings.HasSuffix(strings.ToLower(report), ".xml") {
+			reportResults, err = parseCoberturaReport(report)
+		} else {
+			reportResults, err = parseLcovReport(report)
+		}
+		if err != nil {
+			return results, err
+		}
+		results.add(reportResults)
+	}
+
+	log.Entry().Debugf("Found %v JUnit and %v coverage reports in '%v'", len(junitReports), len(coverageReports), modulePath)
+	return results, nil
+}
+
+// findKarmaReports provides the files below the directory with a name matching one of the patterns which have been
+// modified since the given time, node_modules is skipped
+func findKarmaReports(dir string, since time.Time, patterns []string) ([]string, error) {
+	for _, pattern := range patterns {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, errors.Wrapf(err, "Invalid report pattern '%v'", pattern)
+		}
+	}
+
+	reports := []string{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if info.Name() == "node_modules" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		for _, pattern := range patterns {
+			if matched, _ := filepath.Match(pattern, info.Name()); !matched {
+				continue
+			}
+			if info.ModTime().Before(since) {
+				log.Entry().Warningf("Report '%v' is ignored since it has not been written by the current test run", path)
+				break
+			}
+			reports = append(reports, path)
+			break
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to search reports in '%v'", dir)
+	}
+	return reports, nil
+}
+
+func parseJUnitReport(path string) (karmaResults, error) {
+	content, err := readKarmaReport(path)
+	if err != nil {
+		return karmaResults{}, err
+	}
+	suite := junitSuite{}
+	if err := xml.Unmarshal(content, &suite); err != nil {
+		return karmaResults{}, errors.Wrapf(err, "Failed to parse JUnit report '%v'", path)
+	}
+	results := karmaResults{}
+	countJUnitSuite(suite, &results)
+	return results, nil
+}
+
+func countJUnitSuite(suite junitSuite, results *karmaResults) {
+	for _, testCase := range suite.Cases {
+		results.tests++
+		switch {
+		case len(testCase.Errors) > 0:
+			results.errors++
+		case len(testCase.Failures) > 0:
+			results.failures++
+		case len(testCase.Skipped) > 0:
+			results.skipped++
+		}
+	}
+	for _, child := range suite.Suites {
+		countJUnitSuite(child, results)
+	}
+}
+
+func parseCoberturaReport(path string) (karmaResults, error) {
+	content, err := readKarmaReport(path)
+	if err != nil {
+		return karmaResults{}, err
+	}
+	coverage := coberturaCoverage{}
+	if err := xml.Unmarshal(content, &coverage); err != nil {
+		return karmaResults{}, errors.Wrapf(err, "Failed to parse Cobertura report '%v'", path)
+	}
+
+	if coverage.LinesCovered != nil && coverage.LinesValid != nil && coverage.BranchesCovered != nil && coverage.BranchesValid != nil {
+		return karmaResults{
+			linesCovered:    *coverage.LinesCovered,
+			linesValid:      *coverage.LinesValid,
+			branchesCovered: *coverage.BranchesCovered,
+			branchesValid:   *coverage.BranchesValid,
+			coverageReports: 1,
+		}, nil
+	}
+
+	// older reports only provide rates, the absolute counts are calculated from the lines instead
+	if len(coverage.Lines) == 0 {
+		log.Entry().Warningf("Cobertura report '%v' contains neither absolute counts nor lines, it is not considered for the coverage", path)
+		return karmaResults{}, nil
+	}
+	results := karmaResults{coverageReports: 1}
+	for _, line := range coverage.Lines {
+		results.linesValid++
+		if line.Hits > 0 {
+			results.linesCovered++
+		}
+		if !line.Branch {
+			continue
+		}
+		// e.g. condition-coverage="50% (1/2)"
+		var percent, covered, valid int
+		if _, err := fmt.Sscanf(line.ConditionCoverage, "%d%% (%d/%d)", &percent, &covered, &valid); err != nil {
+			return karmaResults{}, fmt.Errorf("Failed to parse Cobertura report '%v': invalid condition-coverage '%v'", path, line.ConditionCoverage)
+		}
+		results.branchesCovered += covered
+		results.branchesValid += valid
+	}
+	return results, nil
+}
+
+func parseLcovReport(path string) (karmaResults, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return karmaResults{}, errors.Wrapf(err, "Failed to read report '%v'", path)
+	}
+	defer file.Close()
+
+	results := karmaResults{coverageReports: 1}
+	counters := map[string]*int{
+		"LH":  &results.linesCovered,
+		"LF":  &results.linesValid,
+		"BRH": &results.branchesCovered,
+		"BRF": &results.branchesValid,
+	}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		parts := strings.SplitN(strings.TrimSpace(scanner.Text()), ":", 2)
+		counter, ok := counters[parts[0]]
+		if !ok || len(parts) < 2 {
+			continue
+		}
+		value, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return karmaResults{}, fmt.Errorf("Failed to parse LCOV report '%v': invalid line '%v'", path, scanner.Text())
+		}
+		*counter += value
+	}
+	if err := scanner.Err(); err != nil {
+		return karmaResults{}, errors.Wrapf(err, "Failed to read report '%v'", path)
+	}
+	return results, nil
+}
+
+func readKarmaReport(path string) ([]byte, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to read report '%v'", path)
+	}
+	return content, nil
+}
+
+// logKarmaResults prints a summary of the results
+func logKarmaResults(results karmaResults) {
+	log.Entry().Infof("Test results: %v tests, %v failures, %v errors, %v skipped", results.tests, results.failures, results.errors, results.skipped)
+	if results.coverageReports == 0 {
+		log.Entry().Info("No coverage report found")
+		return
+	}
+	log.Entry().Infof("Coverage: %.2f%% lines (%v/%v), %.2f%% branches (%v/%v)",
+		results.lineCoverage(), results.linesCovered, results.linesValid,
+		results.branchCoverage(), results.branchesCovered, results.branchesValid)
+}
+
+// checkKarmaCoverage fails in case the coverage is below the configured thresholds
+func checkKarmaCoverage(results karmaResults, myKarmaExecuteTestsOptions karmaExecuteTestsOptions) error {
+	thresholds := []struct {
+		name      string
+		threshold string
+		coverage  float64
+	}{
+		{name: "line", threshold: myKarmaExecuteTestsOptions.LineCoverageThreshold, coverage: results.lineCoverage()},
+		{name: "branch", threshold: myKarmaExecuteTestsOptions.BranchCoverageThreshold, coverage: results.branchCoverage()},
+	}
+
+	for _, t := range thresholds {
+		if len(t.threshold) == 0 {
+			continue
+		}
+		threshold, err := strconv.ParseFloat(t.threshold, 64)
+		if err != nil || threshold < 0 || threshold > 100 {
+			return fmt.Errorf("Invalid %vCoverageThreshold '%v', expected a percentage between 0 and 100", t.name, t.threshold)
+		}
+		if results.coverageReports == 0 {
+			return fmt.Errorf("No coverage report found, the %v coverage threshold of %v%% cannot be verified", t.name, t.threshold)
+		}
+		if t.coverage < threshold {
+			return fmt.Errorf("The %v coverage of %.2f%% is below the threshold of %v%%", t.name, t.coverage, t.threshold)
+		}
+	}
+	return nil
+}
+
+// persistKarmaResults makes the results available to subsequent steps and influx
+func persistKarmaResults(results karmaResults, piperEnvironment *karmaExecuteTestsCommonPipelineEnvironment, influx *karmaExecuteTestsInflux) {
+	lineCoverage, branchCoverage := "", ""
+	if results.coverageReports > 0 {
+		lineCoverage = fmt.Sprintf("%.2f", results.lineCoverage())
+		branchCoverage = fmt.Sprintf("%.2f", results.branchCoverage())
+	}
+
+	piperEnvironment.karma.tests = fmt.Sprint(results.tests)
+	piperEnvironment.karma.failures = fmt.Sprint(results.failures)
+	piperEnvironment.karma.errors = fmt.Sprint(results.errors)
+	piperEnvironment.karma.skipped = fmt.Sprint(results.skipped)
+	piperEnvironment.karma.lineCoverage = lineCoverage
+	piperEnvironment.karma.branchCoverage = branchCoverage
+
+	influx.karma_data.fields.tests = fmt.Sprint(results.tests)
+	influx.karma_data.fields.failures = fmt.Sprint(results.failures)
+	influx.karma_data.fields.errors = fmt.Sprint(results.errors)
+	influx.karma_data.fields.skipped = fmt.Sprint(results.skipped)
+	influx.karma_data.fields.lineCoverage = lineCoverage
+	influx.karma_data.fields.branchCoverage = branchCoverage
+}
@@ -0,0 +1,175 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollectKarmaResults(t *testing.T) {
+	myKarmaExecuteTestsOptions := karmaExecuteTestsOptions{
+		JunitReportPatterns:    []string{"TEST-*.xml"},
+		CoverageReportPatterns: []string{"cobertura-coverage.xml", "lcov.info"},
+	}
+
+	t.Run("success", func(t *testing.T) {
+		results, err := collectKarmaResults(filepath.Join("testdata", "TestKarmaResults"), time.Time{}, myKarmaExecuteTestsOptions)
+
+		if assert.NoError(t, err) {
+			assert.Equal(t, karmaResults{
+				tests:           6,
+				failures:        1,
+				errors:          1,
+				skipped:         1,
+				linesCovered:    240,
+				linesValid:      300,
+				branchesCovered: 35,
+				branchesValid:   60,
+				coverageReports: 2,
+			}, results)
+			assert.Equal(t, 80.0, results.lineCoverage())
+			assert.InDelta(t, 58.33, results.branchCoverage(), 0.01)
+		}
+	})
+
+	t.Run("outdated reports", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "karmaResults")
+		if err != nil {
+			t.Fatal("Failed to create temporary directory")
+		}
+		defer os.RemoveAll(dir)
+
+		ioutil.WriteFile(filepath.Join(dir, "TEST-Chrome.xml"), []byte(`<testsuite><testcase/></testsuite>`), 0644)
+		ioutil.WriteFile(filepath.Join(dir, "TEST-Firefox.xml"), []byte(`<testsuite><testcase/><testcase/></testsuite>`), 0644)
+		started := time.Now().Truncate(time.Second)
+		previousRun := started.Add(-time.Hour)
+		os.Chtimes(filepath.Join(dir, "TEST-Firefox.xml"), previousRun, previousRun)
+
+		results, err := collectKarmaResults(dir, started, myKarmaExecuteTestsOptions)
+
+		if assert.NoError(t, err) {
+			assert.Equal(t, 1, results.tests)
+		}
+	})
+
+	t.Run("no reports", func(t *testing.T) {
+		results, err := collectKarmaResults(filepath.Join("testdata", "TestKarmaResults", "target"), time.Time{}, karmaExecuteTestsOptions{JunitReportPatterns: []string{"*.json"}})
+
+		if assert.NoError(t, err) {
+			assert.Equal(t, karmaResults{}, results)
+			assert.Equal(t, 100.0, results.lineCoverage())
+		}
+	})
+
+	t.Run("invalid pattern", func(t *testing.T) {
+		_, err := collectKarmaResults(filepath.Join("testdata", "TestKarmaResults"), time.Time{}, karmaExecuteTestsOptions{JunitReportPatterns: []string{"TEST-[.xml"}})
+		assert.EqualError(t, err, "Invalid report pattern 'TEST-[.xml': syntax error in pattern")
+	})
+
+	t.Run("invalid reports", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "karmaResults")
+		if err != nil {
+			t.Fatal("Failed to create temporary directory")
+		}
+		defer os.RemoveAll(dir)
+
+		ioutil.WriteFile(filepath.Join(dir, "TEST-Chrome.xml"), []byte("<testsuite>"), 0644)
+		_, err = collectKarmaResults(dir, time.Time{}, myKarmaExecuteTestsOptions)
+		assert.Contains(t, fmt.Sprint(err), "Failed to parse JUnit report")
+
+		os.Remove(filepath.Join(dir, "TEST-Chrome.xml"))
+		ioutil.WriteFile(filepath.Join(dir, "lcov.info"), []byte("LF:abc\n"), 0644)
+		_, err = collectKarmaResults(dir, time.Time{}, myKarmaExecuteTestsOptions)
+		assert.EqualError(t, err, fmt.Sprintf("Failed to parse LCOV report '%v': invalid line 'LF:abc'", filepath.Join(dir, "lcov.info")))
+	})
+
+	t.Run("cobertura report with rates only", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "karmaResults")
+		if err != nil {
+			t.Fatal("Failed to create temporary directory")
+		}
+		defer os.RemoveAll(dir)
+
+		ioutil.WriteFile(filepath.Join(dir, "cobertura-coverage.xml"), []byte(`<coverage line-rate="0.75" branch-rate="0.25"><packages><package><classes>
+<class name="a.js"><methods><method><lines><line number="1" hits="1"/></lines></method></methods><lines>
+<line number="1" hits="1"/><line number="2" hits="0"/><line number="3" hits="2" branch="true" condition-coverage="50% (2/4)"/>
+</lines></class>
+<class name="b.js"><lines><line number="1" hits="3" branch="true" condition-coverage="0% (0/4)"/></lines></class>
+</classes></package></packages></coverage>`), 0644)
+		results, err := collectKarmaResults(dir, time.Time{}, myKarmaExecuteTestsOptions)
+		if assert.NoError(t, err) {
+			assert.Equal(t, karmaResults{linesCovered: 3, linesValid: 4, branchesCovered: 2, branchesValid: 8, coverageReports: 1}, results)
+		}
+
+		ioutil.WriteFile(filepath.Join(dir, "cobertura-coverage.xml"), []byte(`<coverage line-rate="0.755" branch-rate="0.5"></coverage>`), 0644)
+		results, err = collectKarmaResults(dir, time.Time{}, myKarmaExecuteTestsOptions)
+		if assert.NoError(t, err) {
+			assert.Equal(t, karmaResults{}, results, "report without counts and lines expected to be ignored")
+		}
+	})
+}
+
+func TestCheckKarmaCoverage(t *testing.T) {
+	results := karmaResults{linesCovered: 80, linesValid: 100, branchesCovered: 50, branchesValid: 100, coverageReports: 1}
+
+	testData := []struct {
+		line     string
+		branch   string
+		results  karmaResults
+		expected string
+	}{
+		{results: results},
+		{line: "80", branch: "50.0", results: results},
+		{line: "80.5", results: results, expected: "The line coverage of 80.00% is below the threshold of 80.5%"},
+		{line: "70", branch: "60", results: results, expected: "The branch coverage of 50.00% is below the threshold of 60%"},
+		{branch: "high", results: results, expected: "Invalid branchCoverageThreshold 'high', expected a percentage between 0 and 100"},
+		{line: "101", results: results, expected: "Invalid lineCoverageThreshold '101', expected a percentage between 0 and 100"},
+		{line: "80", results: karmaResults{}, expected: "No coverage report found, the line coverage threshold of 80% cannot be verified"},
+	}
+
+	for k, v := range testData {
+		t.Run(fmt.Sprintf("run %v", k), func(t *testing.T) {
+			err := checkKarmaCoverage(v.results, karmaExecuteTestsOptions{LineCoverageThreshold: v.line, BranchCoverageThreshold: v.branch})
+			if len(v.expected) == 0 {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, v.expected)
+			}
+		})
+	}
+}
+
+func TestPersistKarmaResults(t *testing.T) {
+	t.Run("with coverage", func(t *testing.T) {
+		piperEnvironment := karmaExecuteTestsCommonPipelineEnvironment{}
+		influx := karmaExecuteTestsInflux{}
+
+		persistKarmaResults(karmaResults{tests: 6, failures: 1, errors: 2, skipped: 3, linesCovered: 2, linesValid: 3, branchesCovered: 1, branchesValid: 4, coverageReports: 1}, &piperEnvironment, &influx)
+
+		assert.Equal(t, "6", piperEnvironment.karma.tests)
+		assert.Equal(t, "1", piperEnvironment.karma.failures)
+		assert.Equal(t, "2", piperEnvironment.karma.errors)
+		assert.Equal(t, "3", piperEnvironment.karma.skipped)
+		assert.Equal(t, "66.67", piperEnvironment.karma.lineCoverage)
+		assert.Equal(t, "25.00", piperEnvironment.karma.branchCoverage)
+		assert.Equal(t, "6", influx.karma_data.fields.tests)
+		assert.Equal(t, "66.67", influx.karma_data.fields.lineCoverage)
+		assert.Equal(t, "25.00", influx.karma_data.fields.branchCoverage)
+	})
+
+	t.Run("without coverage", func(t *testing.T) {
+		piperEnvironment := karmaExecuteTestsCommonPipelineEnvironment{}
+		influx := karmaExecuteTestsInflux{}
+
+		persistKarmaResults(karmaResults{tests: 2}, &piperEnvironment, &influx)
+
+		assert.Equal(t, "2", piperEnvironment.karma.tests)
+		assert.Equal(t, "", piperEnvironment.karma.lineCoverage)
+		assert.Equal(t, "", influx.karma_data.fields.branchCoverage)
+	})
+}
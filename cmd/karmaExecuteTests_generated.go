@@ -1,16 +1,109 @@
 package cmd
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
+
 	"github.com/SAP/jenkins-library/pkg/config"
 	"github.com/SAP/jenkins-library/pkg/log"
-
+	"github.com/SAP/jenkins-library/pkg/piperenv"
 	"github.com/spf13/cobra"
 )
 
 type karmaExecuteTestsOptions struct {
-	InstallCommand string `json:"installCommand,omitempty"`
-	ModulePath     string `json:"modulePath,omitempty"`
-	RunCommand     string `json:"runCommand,omitempty"`
+	BranchCoverageThreshold string   `json:"branchCoverageThreshold,omitempty"`
+	CoverageReportPatterns  []string `json:"coverageReportPatterns,omitempty"`
+	InstallCommand          string   `json:"installCommand,omitempty"`
+	JunitReportPatterns     []string `json:"junitReportPatterns,omitempty"`
+	KarmaConfigPattern      string   `json:"karmaConfigPattern,omitempty"`
+	LineCoverageThreshold   string   `json:"lineCoverageThreshold,omitempty"`
+	LogDirectory            string   `json:"logDirectory,omitempty"`
+	ModuleConcurrency       string   `json:"moduleConcurrency,omitempty"`
+	ModulePath              string   `json:"modulePath,omitempty"`
+	ModulePaths             []string `json:"modulePaths,omitempty"`
+	RunCommand              string   `json:"runCommand,omitempty"`
+}
+
+type karmaExecuteTestsCommonPipelineEnvironment struct {
+	karma struct {
+		tests          string
+		failures       string
+		errors         string
+		skipped        string
+		lineCoverage   string
+		branchCoverage string
+	}
+}
+
+func (p *karmaExecuteTestsCommonPipelineEnvironment) persist(path, resourceName string) {
+	content := []struct {
+		category string
+		name     string
+		value    string
+	}{
+		{category: "karma", name: "tests", value: p.karma.tests},
+		{category: "karma", name: "failures", value: p.karma.failures},
+		{category: "karma", name: "errors", value: p.karma.errors},
+		{category: "karma", name: "skipped", value: p.karma.skipped},
+		{category: "karma", name: "lineCoverage", value: p.karma.lineCoverage},
+		{category: "karma", name: "branchCoverage", value: p.karma.branchCoverage},
+	}
+
+	errCount := 0
+	for _, param := range content {
+		err := piperenv.SetResourceParameter(path, resourceName, filepath.Join(param.category, param.name), param.value)
+		if err != nil {
+			log.Entry().WithError(err).Error("Error persisting piper environment.")
+			errCount++
+		}
+	}
+	if errCount > 0 {
+		os.Exit(1)
+	}
+}
+
+type karmaExecuteTestsInflux struct {
+	karma_data struct {
+		fields struct {
+			tests          string
+			failures       string
+			errors         string
+			skipped        string
+			lineCoverage   string
+			branchCoverage string
+		}
+		tags struct {
+		}
+	}
+}
+
+func (i *karmaExecuteTestsInflux) persist(path, resourceName string) {
+	measurementContent := []struct {
+		measurement string
+		valType     string
+		name        string
+		value       string
+	}{
+		{valType: config.InfluxField, measurement: "karma_data", name: "tests", value: i.karma_data.fields.tests},
+		{valType: config.InfluxField, measurement: "karma_data", name: "failures", value: i.karma_data.fields.failures},
+		{valType: config.InfluxField, measurement: "karma_data", name: "errors", value: i.karma_data.fields.errors},
+		{valType: config.InfluxField, measurement: "karma_data", name: "skipped", value: i.karma_data.fields.skipped},
+		{valType: config.InfluxField, measurement: "karma_data", name: "lineCoverage", value: i.karma_data.fields.lineCoverage},
+		{valType: config.InfluxField, measurement: "karma_data", name: "branchCoverage", value: i.karma_data.fields.branchCoverage},
+	}
+
+	errCount := 0
+	for _, metric := range measurementContent {
+		err := piperenv.SetResourceParameter(path, resourceName, filepath.Join(metric.measurement, fmt.Sprintf("%vs", metric.valType), metric.name), metric.value)
+		if err != nil {
+			log.Entry().WithError(err).Error("Error persisting influx environment.")
+			errCount++
+		}
+	}
+	if errCount > 0 {
+		os.Exit(1)
+	}
 }
 
 var myKarmaExecuteTestsOptions karmaExecuteTestsOptions
@@ -18,6 +111,8 @@ var myKarmaExecuteTestsOptions karmaExecuteTestsOptions
 // KarmaExecuteTestsCommand Executes the Karma test runner
 func KarmaExecuteTestsCommand() *cobra.Command {
 	metadata := karmaExecuteTestsMetadata()
+	var commonPipelineEnvironment karmaExecuteTestsCommonPipelineEnvironment
+	var influx karmaExecuteTestsInflux
 
 	var createKarmaExecuteTestsCmd = &cobra.Command{
 		Use:   "karmaExecuteTests",
@@ -39,8 +134,18 @@ In the Docker network, the containers can be referenced by the values provided i
 			return PrepareConfig(cmd, &metadata, "karmaExecuteTests", &myKarmaExecuteTestsOptions, config.OpenPiperFile)
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-
-			return karmaExecuteTests(myKarmaExecuteTestsOptions)
+			stopSidecars, err := StartSidecars(&metadata)
+			if err != nil {
+				return err
+			}
+			defer stopSidecars()
+			handler := func() {
+				commonPipelineEnvironment.persist(GeneralConfig.EnvRootPath, "commonPipelineEnvironment")
+				influx.persist(GeneralConfig.EnvRootPath, "influx")
+			}
+			log.DeferExitHandler(handler)
+			defer handler()
+			return karmaExecuteTests(myKarmaExecuteTestsOptions, &commonPipelineEnvironment, &influx)
 		},
 	}
 
@@ -49,8 +154,16 @@ In the Docker network, the containers can be referenced by the values provided i
 }
 
 func addKarmaExecuteTestsFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&myKarmaExecuteTestsOptions.BranchCoverageThreshold, "branchCoverageThreshold", os.Getenv("PIPER_branchCoverageThreshold"), "Minimum branch coverage in percent, e.g. `80`. The step fails in case the branch coverage is below the threshold. By default no threshold is enforced.")
+	cmd.Flags().StringSliceVar(&myKarmaExecuteTestsOptions.CoverageReportPatterns, "coverageReportPatterns", []string{"cobertura-coverage.xml", "lcov.info"}, "File name patterns of the coverage reports searched within `modulePath`, `node_modules` is excluded. Reports ending with `.xml` are read as Cobertura reports, all others as LCOV reports. Reports which have not been written by the current test run are ignored.")
 	cmd.Flags().StringVar(&myKarmaExecuteTestsOptions.InstallCommand, "installCommand", "npm install --quiet", "The command that is executed to install the test tool.")
+	cmd.Flags().StringSliceVar(&myKarmaExecuteTestsOptions.JunitReportPatterns, "junitReportPatterns", []string{"TEST-*.xml"}, "File name patterns of the JUnit XML reports searched within `modulePath`, `node_modules` is excluded. Reports which have not been written by the current test run are ignored.")
+	cmd.Flags().StringVar(&myKarmaExecuteTestsOptions.KarmaConfigPattern, "karmaConfigPattern", os.Getenv("PIPER_karmaConfigPattern"), "Glob pattern for Karma configuration files, e.g. `apps/*/karma.conf.js`. Each directory containing a matching file is treated as a module. Takes precedence over `modulePath`.")
+	cmd.Flags().StringVar(&myKarmaExecuteTestsOptions.LineCoverageThreshold, "lineCoverageThreshold", os.Getenv("PIPER_lineCoverageThreshold"), "Minimum line coverage in percent, e.g. `80`. The step fails in case the line coverage is below the threshold. By default no threshold is enforced.")
+	cmd.Flags().StringVar(&myKarmaExecuteTestsOptions.LogDirectory, "logDirectory", "karma-logs", "Directory the output of the install and run commands is written to, one log file per module. No log files are written in case the directory is empty.")
+	cmd.Flags().StringVar(&myKarmaExecuteTestsOptions.ModuleConcurrency, "moduleConcurrency", "1", "Number of modules which are tested in parallel. All modules share the Selenium sidecar and the host, hence modules tested in parallel require distinct ports in their Karma configuration and a Selenium sidecar supporting parallel sessions. Otherwise keep the default `1`.")
 	cmd.Flags().StringVar(&myKarmaExecuteTestsOptions.ModulePath, "modulePath", ".", "Define the path of the module to execute tests on.")
+	cmd.Flags().StringSliceVar(&myKarmaExecuteTestsOptions.ModulePaths, "modulePaths", []string{}, "List of paths of the modules to execute tests on. Glob patterns like `apps/*` are supported. Takes precedence over `modulePath`.")
 	cmd.Flags().StringVar(&myKarmaExecuteTestsOptions.RunCommand, "runCommand", "npm run karma", "The command that is executed to start the tests.")
 
 	cmd.MarkFlagRequired("installCommand")
@@ -64,6 +177,22 @@ func karmaExecuteTestsMetadata() config.StepData {
 		Spec: config.StepSpec{
 			Inputs: config.StepInputs{
 				Parameters: []config.StepParameters{
+					{
+						Name:        "branchCoverageThreshold",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "coverageReportPatterns",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "[]string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
 					{
 						Name:        "installCommand",
 						ResourceRef: []config.ResourceReference{},
@@ -72,6 +201,46 @@ func karmaExecuteTestsMetadata() config.StepData {
 						Mandatory:   true,
 						Aliases:     []config.Alias{},
 					},
+					{
+						Name:        "junitReportPatterns",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "[]string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "karmaConfigPattern",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "lineCoverageThreshold",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "logDirectory",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "moduleConcurrency",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
 					{
 						Name:        "modulePath",
 						ResourceRef: []config.ResourceReference{},
@@ -80,6 +249,14 @@ func karmaExecuteTestsMetadata() config.StepData {
 						Mandatory:   true,
 						Aliases:     []config.Alias{},
 					},
+					{
+						Name:        "modulePaths",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "[]string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
 					{
 						Name:        "runCommand",
 						ResourceRef: []config.ResourceReference{},
@@ -90,6 +267,21 @@ func karmaExecuteTestsMetadata() config.StepData {
 					},
 				},
 			},
+			Sidecars: []config.Container{
+				{
+					Name:            "selenium",
+					Image:           "selenium/standalone-chrome",
+					ImagePullPolicy: "",
+					ReadyCommand:    "curl --fail --silent http://localhost:4444/wd/hub/status",
+					WorkingDir:      "",
+					Command:         []string{},
+					EnvVars:         []config.EnvVar{},
+					Options:         []config.Option{},
+					Ports:           []config.Port{{Name: "", ContainerPort: 4444, HostPort: 0}},
+					VolumeMounts:    []config.VolumeMount{{Name: "dev-shm", MountPath: "/dev/shm"}},
+					SecurityContext: config.SecurityContext{Privileged: true},
+				},
+			},
 		},
 	}
 	return theMetaData
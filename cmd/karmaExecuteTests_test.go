@@ -2,46 +2,128 @@ package cmd
 
 import (
 	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
-	"github.com/SAP/jenkins-library/pkg/log"
 	"github.com/stretchr/testify/assert"
 )
 
+// copyKarmaReports copies the test data into a temporary directory, the files appear to be written by the test run
+func copyKarmaReports(t *testing.T, src string) string {
+	dir, err := ioutil.TempDir("", "karmaReports")
+	if err != nil {
+		t.Fatal("Failed to create temporary directory")
+	}
+	duringRun := time.Now().Add(time.Minute)
+	err = filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dir, strings.TrimPrefix(path, src))
+		if info.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(target, content, 0644); err != nil {
+			return err
+		}
+		return os.Chtimes(target, duringRun, duringRun)
+	})
+	if err != nil {
+		t.Fatalf("Failed to copy '%v': %v", src, err)
+	}
+	return dir
+}
+
 func TestRunKarma(t *testing.T) {
+	modulePath := copyKarmaReports(t, filepath.Join("testdata", "TestKarmaResults"))
+	defer os.RemoveAll(modulePath)
+
 	t.Run("success case", func(t *testing.T) {
-		opts := karmaExecuteTestsOptions{ModulePath: "./test", InstallCommand: "npm install test", RunCommand: "npm run test"}
+		opts := karmaExecuteTestsOptions{ModulePath: modulePath, InstallCommand: "npm install test", RunCommand: "npm run test"}
 
 		e := execMockRunner{}
-		runKarma(opts, &e)
+		err := runKarma(opts, func() execRunner { return &e }, &karmaExecuteTestsCommonPipelineEnvironment{}, &karmaExecuteTestsInflux{})
 
-		assert.Equal(t, e.dir[0], "./test", "install command dir incorrect")
+		assert.NoError(t, err)
+		assert.Equal(t, e.dir[0], modulePath, "install command dir incorrect")
 		assert.Equal(t, e.calls[0], execCall{exec: "npm", params: []string{"install", "test"}}, "install command/params incorrect")
 
-		assert.Equal(t, e.dir[1], "./test", "run command dir incorrect")
+		assert.Equal(t, e.dir[1], modulePath, "run command dir incorrect")
 		assert.Equal(t, e.calls[1], execCall{exec: "npm", params: []string{"run", "test"}}, "run command/params incorrect")
 
 	})
 
-	t.Run("error case install command", func(t *testing.T) {
-		var hasFailed bool
-		log.Entry().Logger.ExitFunc = func(int) { hasFailed = true }
+	t.Run("quoted arguments", func(t *testing.T) {
+		opts := karmaExecuteTestsOptions{ModulePath: modulePath, InstallCommand: "npm  install", RunCommand: `npm run test -- --browsers "Chrome Headless"`}
 
-		opts := karmaExecuteTestsOptions{ModulePath: "./test", InstallCommand: "fail install test", RunCommand: "npm run test"}
+		e := execMockRunner{}
+		err := runKarma(opts, func() execRunner { return &e }, &karmaExecuteTestsCommonPipelineEnvironment{}, &karmaExecuteTestsInflux{})
 
-		e := execMockRunner{shouldFailWith: errors.New("error case")}
-		runKarma(opts, &e)
-		assert.True(t, hasFailed, "expected command to exit with fatal")
+		assert.NoError(t, err)
+		assert.Equal(t, execCall{exec: "npm", params: []string{"install"}}, e.calls[0])
+		assert.Equal(t, execCall{exec: "npm", params: []string{"run", "test", "--", "--browsers", "Chrome Headless"}}, e.calls[1])
 	})
 
-	t.Run("error case run command", func(t *testing.T) {
-		var hasFailed bool
-		log.Entry().Logger.ExitFunc = func(int) { hasFailed = true }
+	t.Run("error case invalid commands", func(t *testing.T) {
+		e := execMockRunner{}
+
+		err := runKarma(karmaExecuteTestsOptions{ModulePath: modulePath, InstallCommand: " ", RunCommand: "npm run test"}, func() execRunner { return &e }, &karmaExecuteTestsCommonPipelineEnvironment{}, &karmaExecuteTestsInflux{})
+		assert.EqualError(t, err, "No installCommand provided")
+
+		err = runKarma(karmaExecuteTestsOptions{ModulePath: modulePath, InstallCommand: "npm install", RunCommand: ""}, func() execRunner { return &e }, &karmaExecuteTestsCommonPipelineEnvironment{}, &karmaExecuteTestsInflux{})
+		assert.EqualError(t, err, "No runCommand provided")
+
+		err = runKarma(karmaExecuteTestsOptions{ModulePath: modulePath, InstallCommand: "npm install", RunCommand: "npm run 'test"}, func() execRunner { return &e }, &karmaExecuteTestsCommonPipelineEnvironment{}, &karmaExecuteTestsInflux{})
+		assert.EqualError(t, err, "Invalid runCommand 'npm run 'test': unterminated single quote in 'npm run 'test'")
 
-		opts := karmaExecuteTestsOptions{ModulePath: "./test", InstallCommand: "npm install test", RunCommand: "npm run test"}
+		assert.Empty(t, e.calls)
+	})
+
+	t.Run("results and coverage threshold", func(t *testing.T) {
+		opts := karmaExecuteTestsOptions{
+			ModulePath:             modulePath,
+			InstallCommand:         "npm install test",
+			RunCommand:             "npm run test",
+			JunitReportPatterns:    []string{"TEST-*.xml"},
+			CoverageReportPatterns: []string{"cobertura-coverage.xml", "lcov.info"},
+			LineCoverageThreshold:  "85",
+		}
+		piperEnvironment := karmaExecuteTestsCommonPipelineEnvironment{}
+		influx := karmaExecuteTestsInflux{}
+
+		e := execMockRunner{}
+		err := runKarma(opts, func() execRunner { return &e }, &piperEnvironment, &influx)
+
+		assert.EqualError(t, err, "The line coverage of 80.00% is below the threshold of 85%")
+		assert.Equal(t, "6", piperEnvironment.karma.tests)
+		assert.Equal(t, "80.00", piperEnvironment.karma.lineCoverage)
+		assert.Equal(t, "1", influx.karma_data.fields.failures)
+	})
+
+	t.Run("error case install command", func(t *testing.T) {
+		opts := karmaExecuteTestsOptions{ModulePath: modulePath, InstallCommand: "fail install test", RunCommand: "npm run test"}
 
 		e := execMockRunner{shouldFailWith: errors.New("error case")}
-		runKarma(opts, &e)
-		assert.True(t, hasFailed, "expected command to exit with fatal")
+		err := runKarma(opts, func() execRunner { return &e }, &karmaExecuteTestsCommonPipelineEnvironment{}, &karmaExecuteTestsInflux{})
+		assert.EqualError(t, err, "failed to execute install command 'fail install test': error case")
+		assert.Empty(t, e.calls)
+	})
+
+	t.Run("error case run command", func(t *testing.T) {
+		opts := karmaExecuteTestsOptions{ModulePath: modulePath, InstallCommand: "npm install test", RunCommand: "npm run test", JunitReportPatterns: []string{"TEST-*.xml"}}
+		piperEnvironment := karmaExecuteTestsCommonPipelineEnvironment{}
+
+		e := execMockRunner{shouldFailOnCommand: map[string]error{"npm run": errors.New("error case")}}
+		err := runKarma(opts, func() execRunner { return &e }, &piperEnvironment, &karmaExecuteTestsInflux{})
+		assert.EqualError(t, err, "failed to execute run command 'npm run test': error case")
+		assert.Equal(t, "6", piperEnvironment.karma.tests, "results expected despite failing tests")
 	})
 }
@@ -6,9 +6,11 @@ import (
 	"io"
 	"os"
 
+	"github.com/SAP/jenkins-library/pkg/command"
 	"github.com/SAP/jenkins-library/pkg/config"
 	"github.com/SAP/jenkins-library/pkg/log"
 	"github.com/SAP/jenkins-library/pkg/piperutils"
+	"github.com/SAP/jenkins-library/pkg/sidecar"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 )
@@ -23,6 +25,7 @@ type GeneralConfigOptions struct {
 	StepConfigJSON string
 	StepMetadata   string //metadata to be considered, can be filePath or ENV containing JSON in format 'ENV:MY_ENV_VAR'
 	StepName       string
+	StartSidecars  bool
 	Verbose        bool
 }
 
@@ -49,6 +52,10 @@ func Execute() {
 	rootCmd.AddCommand(XsDeployCommand())
 	rootCmd.AddCommand(GithubPublishReleaseCommand())
 	rootCmd.AddCommand(GithubCreatePullRequestCommand())
+	rootCmd.AddCommand(CloudFoundryDeployCommand())
+	rootCmd.AddCommand(CfManifestSubstituteVariablesCommand())
+	rootCmd.AddCommand(GithubSetCommitStatusCommand())
+	rootCmd.AddCommand(GithubCreateIssueCommand())
 
 	addRootFlags(rootCmd)
 	if err := rootCmd.Execute(); err != nil {
@@ -65,6 +72,7 @@ func addRootFlags(rootCmd *cobra.Command) {
 	rootCmd.PersistentFlags().StringVar(&GeneralConfig.EnvRootPath, "envRootPath", ".pipeline", "Root path to Piper pipeline shared environments")
 	rootCmd.PersistentFlags().StringVar(&GeneralConfig.StageName, "stageName", os.Getenv("STAGE_NAME"), "Name of the stage for which configuration should be included")
 	rootCmd.PersistentFlags().StringVar(&GeneralConfig.StepConfigJSON, "stepConfigJSON", os.Getenv("PIPER_stepConfigJSON"), "Step configuration in JSON format")
+	rootCmd.PersistentFlags().BoolVar(&GeneralConfig.StartSidecars, "startSidecars", false, "Start the sidecar containers defined in the step metadata (only required when running outside of Jenkins)")
 	rootCmd.PersistentFlags().BoolVarP(&GeneralConfig.Verbose, "verbose", "v", false, "verbose output")
 
 }
@@ -124,3 +132,28 @@ func PrepareConfig(cmd *cobra.Command, metadata *config.StepData, stepName strin
 
 	return nil
 }
+
+// StartSidecars starts the sidecar containers defined in the step metadata in case this is requested via flag 'startSidecars'.
+// Inside Jenkins the sidecars are provided by the library steps, thus they are not started by default.
+// The returned function removes the sidecars again and needs to be called once the step is finished.
+func StartSidecars(metadata *config.StepData) (func(), error) {
+	if !GeneralConfig.StartSidecars || len(metadata.Spec.Sidecars) == 0 {
+		return func() {}, nil
+	}
+
+	c := command.Command{}
+	// reroute docker output to logging framework
+	c.Stdout(log.Entry().Writer())
+	c.Stderr(log.Entry().Writer())
+
+	manager := sidecar.NewManager(&c)
+	if err := manager.Start(metadata.Spec.Sidecars); err != nil {
+		return func() {}, errors.Wrap(err, "starting sidecars failed")
+	}
+
+	return func() {
+		if err := manager.Stop(); err != nil {
+			log.Entry().WithError(err).Warn("Failed to remove sidecars")
+		}
+	}, nil
+}
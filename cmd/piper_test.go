@@ -6,6 +6,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/SAP/jenkins-library/pkg/command"
 	"github.com/SAP/jenkins-library/pkg/config"
 	"github.com/spf13/cobra"
 	flag "github.com/spf13/pflag"
@@ -13,11 +14,18 @@ import (
 )
 
 type execMockRunner struct {
-	dir            []string
-	calls          []execCall
-	stdout         io.Writer
-	stderr         io.Writer
-	shouldFailWith error
+	dir                 []string
+	env                 []string
+	calls               []execCall
+	stdin               io.Reader
+	stdinContent        []string
+	stdout              io.Writer
+	stderr              io.Writer
+	stdoutReturn        map[string]string
+	shouldFailWith      error
+	shouldFailOnCommand map[string]error
+	capture             *command.Capture
+	tailLines           int
 }
 
 type execCall struct {
@@ -44,9 +52,39 @@ func (m *execMockRunner) RunExecutable(e string, p ...string) error {
 	}
 	exec := execCall{exec: e, params: p}
 	m.calls = append(m.calls, exec)
+	if m.stdin != nil {
+		in, _ := ioutil.ReadAll(m.stdin)
+		m.stdinContent = append(m.stdinContent, string(in))
+	}
+	call := strings.Join(append([]string{e}, p...), " ")
+	for prefix, out := range m.stdoutReturn {
+		if !strings.HasPrefix(call, prefix) {
+			continue
+		}
+		if m.stdout != nil {
+			io.WriteString(m.stdout, out)
+		}
+		if m.capture != nil {
+			io.WriteString(m.capture.Writer(), out)
+			m.capture.Flush()
+		}
+	}
+	for prefix, err := range m.shouldFailOnCommand {
+		if strings.HasPrefix(call, prefix) {
+			return err
+		}
+	}
 	return nil
 }
 
+func (m *execMockRunner) Env(e []string) {
+	m.env = e
+}
+
+func (m *execMockRunner) Stdin(in io.Reader) {
+	m.stdin = in
+}
+
 func (m *execMockRunner) Stdout(out io.Writer) {
 	m.stdout = out
 }
@@ -55,6 +93,14 @@ func (m *execMockRunner) Stderr(err io.Writer) {
 	m.stderr = err
 }
 
+func (m *execMockRunner) Capture(capture *command.Capture) {
+	m.capture = capture
+}
+
+func (m *execMockRunner) TailOnFailure(lines int) {
+	m.tailLines = lines
+}
+
 func (m *shellMockRunner) Dir(d string) {
 	m.dir = d
 }
@@ -103,6 +149,7 @@ func TestAddRootFlags(t *testing.T) {
 	assert.NotNil(t, testRootCmd.Flag("parametersJSON"), "expected flag not available")
 	assert.NotNil(t, testRootCmd.Flag("stageName"), "expected flag not available")
 	assert.NotNil(t, testRootCmd.Flag("stepConfigJSON"), "expected flag not available")
+	assert.NotNil(t, testRootCmd.Flag("startSidecars"), "expected flag not available")
 	assert.NotNil(t, testRootCmd.Flag("verbose"), "expected flag not available")
 
 }
@@ -173,3 +220,29 @@ func TestPrepareConfig(t *testing.T) {
 		})
 	})
 }
+
+func TestStartSidecars(t *testing.T) {
+	metadata := config.StepData{
+		Spec: config.StepSpec{
+			Sidecars: []config.Container{{Name: "selenium", Image: "selenium/standalone-chrome"}},
+		},
+	}
+
+	t.Run("not requested", func(t *testing.T) {
+		stop, err := StartSidecars(&metadata)
+		assert.NoError(t, err)
+		assert.NotNil(t, stop)
+		stop()
+	})
+
+	t.Run("no sidecars", func(t *testing.T) {
+		startSidecarsBak := GeneralConfig.StartSidecars
+		GeneralConfig.StartSidecars = true
+		defer func() { GeneralConfig.StartSidecars = startSidecarsBak }()
+
+		stop, err := StartSidecars(&config.StepData{})
+		assert.NoError(t, err)
+		assert.NotNil(t, stop)
+		stop()
+	})
+}
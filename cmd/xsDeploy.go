@@ -1,20 +1,22 @@
 package cmd
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
 	"github.com/SAP/jenkins-library/pkg/command"
+	piperhttp "github.com/SAP/jenkins-library/pkg/http"
 	"github.com/SAP/jenkins-library/pkg/log"
+	"github.com/SAP/jenkins-library/pkg/piperenv"
 	"github.com/SAP/jenkins-library/pkg/piperutils"
 	"github.com/pkg/errors"
 	"io"
 	"io/ioutil"
+	"net/http"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
-	"sync"
-	"text/template"
+	"time"
 )
 
 // DeployMode ...
@@ -93,31 +95,105 @@ func (a Action) String() string {
 	}[a]
 }
 
-const loginScript = `#!/bin/bash
-xs login -a {{.APIURL}} -u {{.User}} -p '{{.Password}}' -o {{.Org}} -s {{.Space}} {{.LoginOpts}}
-`
+// number of output lines of the xs commands kept for error reporting
+const xsOutputLines = 1000
 
-const logoutScript = `#!/bin/bash
-xs logout`
+// time between two health check requests against the idle routes
+const healthCheckInterval = 10 * time.Second
 
-const deployScript = `#!/bin/bash
-xs {{.Mode}} {{.MtaPath}} {{.DeployOpts}}`
+// healthCheck verifies the provided urls and returns the result for each url
+type healthCheck func(urls []string, timeout time.Duration) ([]healthCheckResult, error)
 
-const completeScript = `#!/bin/bash
-xs {{.Mode.GetDeployCommand}} -i {{.OperationID}} -a {{.Action.GetAction}}
-`
+type healthCheckResult struct {
+	URL        string
+	StatusCode int
+	Err        error
+}
+
+// xsSession denotes the directories used for keeping the xs session
+type xsSession struct {
+	// home directory of the xs client, the client keeps the session and its logs there
+	homeDir string
+	// directory inside the workspace the session file is copied to in order to be available for subsequent calls
+	workDir string
+}
+
+func xsDeploy(XsDeployOptions xsDeployOptions, piperEnvironment *xsDeployCommonPipelineEnvironment) error {
+	verify := func(urls []string, timeout time.Duration) ([]healthCheckResult, error) {
+		return checkIdleRoutes(urls, timeout, &piperhttp.Client{}, time.Sleep)
+	}
+
+	render := func(extension mtaExtension, idSuffix, file string) error {
+		return renderMtaExtension(extension, idSuffix, file, XsDeployOptions.MtaDescriptorPath, ioutil.ReadFile, ioutil.WriteFile)
+	}
+
+	XsDeployOptions = applyXsOperationState(XsDeployOptions, func(param string) string {
+		return piperenv.GetResourceParameter(GeneralConfig.EnvRootPath, "commonPipelineEnvironment", param)
+	})
+
+	if len(XsDeployOptions.Targets) > 0 {
+		deployTarget := func(targetOptions xsDeployOptions, session xsSession, targetEnvironment *xsDeployCommonPipelineEnvironment) error {
+			c := command.Command{}
+			// each target gets its own home directory, hence the xs sessions do not interfere
+			c.Env(xsSessionEnv(session))
+			return runXsDeploy(targetOptions, targetEnvironment, session, &c, piperutils.FileExists, piperutils.Copy, os.Remove, verify, ioutil.Discard)
+		}
+		return runXsDeployTargets(XsDeployOptions, piperEnvironment, deployTarget, render, os.MkdirAll, os.RemoveAll, os.Getenv, os.Stdout)
+	}
+
+	XsDeployOptions, err := applyMtaExtension(XsDeployOptions, render)
+	if err != nil {
+		return err
+	}
 
-func xsDeploy(XsDeployOptions xsDeployOptions) error {
 	c := command.Command{}
-	return runXsDeploy(XsDeployOptions, &c, piperutils.FileExists, piperutils.Copy, os.Remove, os.Stdout)
+	session := xsSession{homeDir: os.Getenv("HOME"), workDir: "."}
+	return runXsDeploy(XsDeployOptions, piperEnvironment, session, &c, piperutils.FileExists, piperutils.Copy, os.Remove, verify, os.Stdout)
 }
 
-func runXsDeploy(XsDeployOptions xsDeployOptions, s shellRunner,
+// applyXsOperationState takes the mode and the target of a previously started deployment from the common pipeline
+// environment in case the deployment is resumed or aborted. Configured values take precedence, except for the default
+// mode 'DEPLOY' which does not allow to resume or abort. For other actions these values are not taken into account,
+// otherwise a subsequent deployment would inherit them.
+func applyXsOperationState(XsDeployOptions xsDeployOptions, getParameter func(string) string) xsDeployOptions {
+	if XsDeployOptions.Action != Resume.String() && XsDeployOptions.Action != Abort.String() {
+		return XsDeployOptions
+	}
+	for _, param := range []struct {
+		name       string
+		value      *string
+		overridden bool
+	}{
+		{"xsDeploy/mode", &XsDeployOptions.Mode, XsDeployOptions.Mode == Deploy.String()},
+		{"xsDeploy/apiUrl", &XsDeployOptions.APIURL, false},
+		{"xsDeploy/org", &XsDeployOptions.Org, false},
+		{"xsDeploy/space", &XsDeployOptions.Space, false},
+	} {
+		if len(*param.value) > 0 && !param.overridden {
+			continue
+		}
+		if value := getParameter(param.name); len(value) > 0 {
+			log.Entry().Debugf("Using '%s' from common pipeline environment: '%s'", param.name, value)
+			*param.value = value
+		}
+	}
+	return XsDeployOptions
+}
+
+func runXsDeploy(XsDeployOptions xsDeployOptions, piperEnvironment *xsDeployCommonPipelineEnvironment, session xsSession, s capturingExecRunner,
 	fExists func(string) (bool, error),
 	fCopy func(string, string) (int64, error),
 	fRemove func(string) error,
+	fVerify healthCheck,
 	stdout io.Writer) error {
 
+	// the pipeline environment is persisted in any case, hence we start with the state we received
+	piperEnvironment.xsDeploy.operationID = XsDeployOptions.OperationID
+	piperEnvironment.xsDeploy.mode = XsDeployOptions.Mode
+	piperEnvironment.xsDeploy.APIURL = XsDeployOptions.APIURL
+	piperEnvironment.xsDeploy.org = XsDeployOptions.Org
+	piperEnvironment.xsDeploy.space = XsDeployOptions.Space
+
 	mode, err := ValueOfMode(XsDeployOptions.Mode)
 	if err != nil {
 		return errors.Wrapf(err, "Extracting mode failed: '%s'", XsDeployOptions.Mode)
@@ -137,12 +213,28 @@ func runXsDeploy(XsDeployOptions xsDeployOptions, s shellRunner,
 		return errors.New(fmt.Sprintf("Cannot perform action '%s' in mode '%s'. Only action '%s' is allowed.", action, mode, None))
 	}
 
-	log.Entry().Debugf("Mode: '%s', Action: '%s'", mode, action)
+	autoComplete := mode == BGDeploy && action == None && XsDeployOptions.AutoComplete
+
+	var healthCheckTimeout time.Duration
+	if autoComplete {
+		healthCheckTimeout, err = time.ParseDuration(XsDeployOptions.HealthCheckTimeout)
+		if err != nil {
+			return errors.Wrapf(err, "Invalid health check timeout: '%s'", XsDeployOptions.HealthCheckTimeout)
+		}
+	}
+
+	log.Entry().Debugf("Mode: '%s', Action: '%s', autoComplete: %t", mode, action, autoComplete)
 
 	performLogin := mode == Deploy || (mode == BGDeploy && !(action == Resume || action == Abort))
-	performLogout := mode == Deploy || (mode == BGDeploy && action != None)
+	performLogout := mode == Deploy || (mode == BGDeploy && (action != None || autoComplete))
 	log.Entry().Debugf("performLogin: %t, performLogout: %t", performLogin, performLogout)
 
+	if performLogin {
+		if missing := missingLoginParameters(XsDeployOptions); len(missing) > 0 {
+			return fmt.Errorf("Missing mandatory parameter(s) for xs login: %s", strings.Join(missing, ", "))
+		}
+	}
+
 	{
 		exists, e := fExists(XsDeployOptions.MtaPath)
 		if e != nil {
@@ -157,32 +249,39 @@ func runXsDeploy(XsDeployOptions xsDeployOptions, s shellRunner,
 		return errors.New(fmt.Sprintf("OperationID was not provided. This is required for action '%s'.", action))
 	}
 
-	prOut, pwOut := io.Pipe()
-	prErr, pwErr := io.Pipe()
-
-	s.Stdout(pwOut)
-	s.Stderr(pwErr)
-
-	var e, o string
+	var operationIDPattern, idleRoutePattern *regexp.Regexp
+	if mode == BGDeploy && action == None {
+		if operationIDPattern, err = regexp.Compile(XsDeployOptions.OperationIDLogPattern); err != nil {
+			return errors.Wrapf(err, "Invalid operationIdLogPattern: '%s'", XsDeployOptions.OperationIDLogPattern)
+		}
+	}
+	if autoComplete && len(XsDeployOptions.IdleRouteLogPattern) > 0 {
+		if idleRoutePattern, err = regexp.Compile(XsDeployOptions.IdleRouteLogPattern); err != nil {
+			return errors.Wrapf(err, "Invalid idleRouteLogPattern: '%s'", XsDeployOptions.IdleRouteLogPattern)
+		}
+	}
 
-	var wg sync.WaitGroup
-	wg.Add(2)
+	capture := command.NewCapture(xsOutputLines)
 
-	go func() {
-		buf := new(bytes.Buffer)
-		r := io.TeeReader(prOut, os.Stderr)
-		io.Copy(buf, r)
-		o = buf.String()
-		wg.Done()
-	}()
+	var operationID string
+	var idleRoutes []string
+	completed := false
+	capture.OnLine(func(line string) {
+		if operationIDPattern != nil && len(operationID) == 0 {
+			operationID = retrieveFromLog(line, operationIDPattern)
+		}
+		if idleRoutePattern != nil {
+			if route := retrieveFromLog(line, idleRoutePattern); len(route) > 0 {
+				idleRoutes = append(idleRoutes, strings.TrimSuffix(route, "/")+XsDeployOptions.HealthCheckPath)
+			}
+		}
+	})
 
-	go func() {
-		buf := new(bytes.Buffer)
-		r := io.TeeReader(prErr, os.Stderr)
-		io.Copy(buf, r)
-		e = buf.String()
-		wg.Done()
-	}()
+	// stdout of this step is reserved for the status, hence the output of the xs commands goes to stderr
+	s.Stdout(os.Stderr)
+	s.Stderr(os.Stderr)
+	s.Capture(capture)
+	s.TailOnFailure(xsOutputLines)
 
 	var loginErr error
 
@@ -190,33 +289,45 @@ func runXsDeploy(XsDeployOptions xsDeployOptions, s shellRunner,
 	if len(XsDeployOptions.XsSessionFile) > 0 {
 		xsSessionFile = XsDeployOptions.XsSessionFile
 	}
+	workspaceSessionFile := filepath.Join(session.workDir, xsSessionFile)
 
 	if performLogin {
 		loginErr = xsLogin(XsDeployOptions, s)
 		if loginErr == nil {
-			err = copyFileFromHomeToPwd(xsSessionFile, fCopy)
+			err = copyFileFromHomeToPwd(session, xsSessionFile, fCopy)
 		}
 	}
 
 	if loginErr == nil && err == nil {
 
 		{
-			exists, e := fExists(xsSessionFile)
+			exists, e := fExists(workspaceSessionFile)
 			if e != nil {
 				return e
 			}
 			if !exists {
-				return fmt.Errorf("xs session file does not exist (%s)", xsSessionFile)
+				return fmt.Errorf("xs session file does not exist (%s)", workspaceSessionFile)
 			}
 		}
 
-		copyFileFromPwdToHome(xsSessionFile, fCopy)
+		copyFileFromPwdToHome(session, xsSessionFile, fCopy)
 
 		switch action {
 		case Resume, Abort, Retry:
 			err = complete(mode, action, XsDeployOptions.OperationID, s)
 		default:
 			err = deploy(mode, XsDeployOptions, s)
+			if err == nil && autoComplete {
+				capture.Flush()
+				urls := XsDeployOptions.HealthCheckURLs
+				if len(urls) == 0 {
+					urls = idleRoutes
+				}
+				var decision Action
+				decision, err = verifyAndComplete(mode, operationID, urls, healthCheckTimeout, fVerify, s)
+				XsDeployOptions.Action = decision.String()
+				completed = decision != None
+			}
 		}
 	}
 
@@ -229,10 +340,10 @@ func runXsDeploy(XsDeployOptions xsDeployOptions, s shellRunner,
 
 			// we delete the xs session file from workspace. From home directory it is deleted by the
 			// xs command itself.
-			if e := fRemove(xsSessionFile); e != nil {
+			if e := fRemove(workspaceSessionFile); e != nil {
 				err = e
 			}
-			log.Entry().Debugf("xs session file '%s' has been deleted from workspace", xsSessionFile)
+			log.Entry().Debugf("xs session file '%s' has been deleted from workspace", workspaceSessionFile)
 		}
 	} else {
 		if loginErr != nil {
@@ -247,22 +358,27 @@ func runXsDeploy(XsDeployOptions xsDeployOptions, s shellRunner,
 	}
 
 	if err != nil {
-		if e := handleLog(fmt.Sprintf("%s/%s", os.Getenv("HOME"), ".xs_logs")); e != nil {
+		if e := handleLog(filepath.Join(session.homeDir, ".xs_logs"), session.workDir); e != nil {
 			log.Entry().Warningf("Cannot provide the logs: %s", e.Error())
 		}
 	}
 
-	pwOut.Close()
-	pwErr.Close()
-
-	wg.Wait()
+	capture.Flush()
 
 	if err == nil && (mode == BGDeploy && action == None) {
-		XsDeployOptions.OperationID = retrieveOperationID(o, XsDeployOptions.OperationIDLogPattern)
+		if len(operationID) > 0 {
+			log.Entry().Infof("Operation identifier: '%s'", operationID)
+		} else {
+			log.Entry().Infof("No operation identifier found in the output of the xs command (pattern: '%s').", XsDeployOptions.OperationIDLogPattern)
+		}
+		XsDeployOptions.OperationID = operationID
 	}
 
-	if err != nil {
-		log.Entry().Errorf("An error occured. Stdout from underlying process: >>%s<<. Stderr from underlying process: >>%s<<", o, e)
+	piperEnvironment.xsDeploy.operationID = pendingOperationID(mode, action, err, completed, operationID, XsDeployOptions.OperationID)
+
+	if completed {
+		// the deployment has already been resumed or aborted, the identifier must not be used for a further action
+		XsDeployOptions.OperationID = ""
 	}
 
 	if e := printStatus(XsDeployOptions, stdout); e != nil {
@@ -274,6 +390,39 @@ func runXsDeploy(XsDeployOptions xsDeployOptions, s shellRunner,
 	return err
 }
 
+// pendingOperationID provides the identifier of a blue-green deployment which still needs to be resumed or aborted.
+// Once a deployment has been completed the identifier must not be reused, hence it is reset.
+func pendingOperationID(mode DeployMode, action Action, err error, completed bool, startedOperationID, providedOperationID string) string {
+	switch {
+	case mode != BGDeploy:
+		return ""
+	case action == None && completed:
+		return ""
+	case action == None:
+		return startedOperationID
+	case err == nil && action != Retry:
+		return ""
+	default:
+		return providedOperationID
+	}
+}
+
+func missingLoginParameters(XsDeployOptions xsDeployOptions) []string {
+	missing := []string{}
+	for _, param := range []struct{ name, value string }{
+		{"apiUrl", XsDeployOptions.APIURL},
+		{"user", XsDeployOptions.User},
+		{"password", XsDeployOptions.Password},
+		{"org", XsDeployOptions.Org},
+		{"space", XsDeployOptions.Space},
+	} {
+		if len(param.value) == 0 {
+			missing = append(missing, param.name)
+		}
+	}
+	return missing
+}
+
 func printStatus(XsDeployOptions xsDeployOptions, stdout io.Writer) error {
 	XsDeployOptionsCopy := XsDeployOptions
 	XsDeployOptionsCopy.Password = ""
@@ -285,72 +434,43 @@ func printStatus(XsDeployOptions xsDeployOptions, stdout io.Writer) error {
 	return e
 }
 
-func handleLog(logDir string) error {
-
-	if _, e := os.Stat(logDir); !os.IsNotExist(e) {
-		log.Entry().Warningf(fmt.Sprintf("Here are the logs (%s):", logDir))
-
-		logFiles, e := ioutil.ReadDir(logDir)
-
-		if e != nil {
-			return e
-		}
-
-		if len(logFiles) == 0 {
-			log.Entry().Warningf("Cannot provide xs logs. No log files found inside '%s'.", logDir)
-		}
-
-		for _, logFile := range logFiles {
-			buf := make([]byte, 32*1024)
-			log.Entry().Infof("File: '%s'", logFile.Name())
-			if f, e := os.Open(fmt.Sprintf("%s/%s", logDir, logFile.Name())); e == nil {
-				for {
-					if n, e := f.Read(buf); e != nil {
-						if e == io.EOF {
-							break
-						}
-						return e
-					} else {
-						os.Stderr.WriteString(string(buf[:n]))
-					}
-				}
-			} else {
-				return e
-			}
-		}
-	} else {
-		log.Entry().Warningf("Cannot provide xs logs. Log directory '%s' does not exist.", logDir)
-	}
-	return nil
-}
-
-func retrieveOperationID(deployLog, pattern string) string {
-	re := regexp.MustCompile(pattern)
+// retrieveFromLog provides the first capturing group of the pattern in the first matching line of the log,
+// e.g. the operation id or an idle route
+func retrieveFromLog(deployLog string, re *regexp.Regexp) string {
 	lines := strings.Split(deployLog, "\n")
-	var operationID string
 	for _, line := range lines {
 		matched := re.FindStringSubmatch(line)
-		if len(matched) >= 1 {
-			operationID = matched[1]
-			break
+		if len(matched) >= 2 {
+			return matched[1]
 		}
 	}
-
-	if len(operationID) > 0 {
-		log.Entry().Infof("Operation identifier: '%s'", operationID)
-	} else {
-		log.Entry().Infof("No operation identifier found in >>>>%s<<<<.", deployLog)
-	}
-
-	return operationID
+	return ""
 }
 
-func xsLogin(XsDeployOptions xsDeployOptions, s shellRunner) error {
+func xsLogin(XsDeployOptions xsDeployOptions, s execRunner) error {
 
 	log.Entry().Debugf("Performing xs login. api-url: '%s', org: '%s', space: '%s'",
 		XsDeployOptions.APIURL, XsDeployOptions.Org, XsDeployOptions.Space)
 
-	if e := executeCmd("login", loginScript, XsDeployOptions, s); e != nil {
+	loginOpts, err := command.ParseArgs(XsDeployOptions.LoginOpts)
+	if err != nil {
+		return errors.Wrap(err, "Cannot parse login options")
+	}
+	for _, opt := range loginOpts {
+		if isXsPasswordOption(opt) {
+			return errors.New("Login options must not contain a password. Provide the password via the 'password' parameter.")
+		}
+	}
+
+	args := append([]string{"login", "-a", XsDeployOptions.APIURL, "-u", XsDeployOptions.User,
+		"-o", XsDeployOptions.Org, "-s", XsDeployOptions.Space}, loginOpts...)
+
+	// Without '-p' the xs client prompts for the password, which is answered via stdin.
+	// This way the password is neither part of the arguments nor of the environment of any process.
+	s.Stdin(strings.NewReader(XsDeployOptions.Password + "\n"))
+	defer s.Stdin(nil)
+
+	if e := s.RunExecutable("xs", args...); e != nil {
 		log.Entry().Errorf("xs login failed: %s", e.Error())
 		return e
 	}
@@ -361,11 +481,24 @@ func xsLogin(XsDeployOptions xsDeployOptions, s shellRunner) error {
 	return nil
 }
 
-func xsLogout(XsDeployOptions xsDeployOptions, s shellRunner) error {
+// isXsPasswordOption detects the password option of xs login, also in the forms '-p=secret', '-psecret' and '--password=secret'
+func isXsPasswordOption(opt string) bool {
+	return opt == "--password" || strings.HasPrefix(opt, "--password=") || (strings.HasPrefix(opt, "-p") && !strings.HasPrefix(opt, "--"))
+}
+
+// xsSessionEnv provides the environment of the xs calls, the home directory contains the xs session
+func xsSessionEnv(session xsSession) []string {
+	if len(session.homeDir) == 0 {
+		return nil
+	}
+	return []string{"HOME=" + session.homeDir}
+}
+
+func xsLogout(XsDeployOptions xsDeployOptions, s execRunner) error {
 
 	log.Entry().Debug("Performing xs logout.")
 
-	if e := executeCmd("logout", logoutScript, XsDeployOptions, s); e != nil {
+	if e := s.RunExecutable("xs", "logout"); e != nil {
 		return e
 	}
 	log.Entry().Info("xs logout has been performed")
@@ -373,20 +506,20 @@ func xsLogout(XsDeployOptions xsDeployOptions, s shellRunner) error {
 	return nil
 }
 
-func deploy(mode DeployMode, XsDeployOptions xsDeployOptions, s shellRunner) error {
+func deploy(mode DeployMode, XsDeployOptions xsDeployOptions, s execRunner) error {
 
 	deployCommand, err := mode.GetDeployCommand()
 	if err != nil {
 		return err
 	}
 
-	type deployProperties struct {
-		xsDeployOptions
-		Mode string
+	deployOpts, err := command.ParseArgs(XsDeployOptions.DeployOpts)
+	if err != nil {
+		return errors.Wrap(err, "Cannot parse deploy options")
 	}
 
 	log.Entry().Infof("Performing xs %s.", deployCommand)
-	if e := executeCmd("deploy", deployScript, deployProperties{xsDeployOptions: XsDeployOptions, Mode: deployCommand}, s); e != nil {
+	if e := s.RunExecutable("xs", append([]string{deployCommand, XsDeployOptions.MtaPath}, deployOpts...)...); e != nil {
 		return e
 	}
 	log.Entry().Infof("xs %s performed.", deployCommand)
@@ -394,46 +527,119 @@ func deploy(mode DeployMode, XsDeployOptions xsDeployOptions, s shellRunner) err
 	return nil
 }
 
-func complete(mode DeployMode, action Action, operationID string, s shellRunner) error {
+func complete(mode DeployMode, action Action, operationID string, s execRunner) error {
 	log.Entry().Debugf("Performing xs %s", action)
 
-	type completeProperties struct {
-		xsDeployOptions
-		Mode        DeployMode
-		Action      Action
-		OperationID string
+	deployCommand, err := mode.GetDeployCommand()
+	if err != nil {
+		return err
 	}
 
-	CompleteProperties := completeProperties{Mode: mode, Action: action, OperationID: operationID}
-
-	if e := executeCmd("complete", completeScript, CompleteProperties, s); e != nil {
-		return e
+	a, err := action.GetAction()
+	if err != nil {
+		return err
 	}
 
-	return nil
+	return s.RunExecutable("xs", deployCommand, "-i", operationID, "-a", a)
 }
 
-func executeCmd(templateID string, commandPattern string, properties interface{}, s shellRunner) error {
+// verifyAndComplete checks the idle routes of a blue-green deployment and resumes the deployment in case all checks succeeded.
+// Otherwise the deployment is aborted. The returned action denotes the action which has been performed,
+// None in case the deployment could not be completed.
+func verifyAndComplete(mode DeployMode, operationID string, urls []string, timeout time.Duration, fVerify healthCheck, s execRunner) (Action, error) {
 
-	tmpl, e := template.New(templateID).Parse(commandPattern)
-	if e != nil {
-		return e
+	if len(operationID) == 0 {
+		return None, errors.New("Cannot complete the blue-green deployment automatically. No operation identifier found in the output of the xs command.")
 	}
 
-	var script bytes.Buffer
-	tmpl.Execute(&script, properties)
-	if e := s.RunShell("/bin/bash", script.String()); e != nil {
-		return e
+	decision, reason := Resume, ""
+	var results []healthCheckResult
+
+	if len(urls) == 0 {
+		decision, reason = Abort, "no idle routes found for the health check"
+	} else {
+		log.Entry().Infof("Verifying idle routes (timeout: %s): %s", timeout, strings.Join(urls, ", "))
+		var verifyErr error
+		results, verifyErr = fVerify(urls, timeout)
+		if verifyErr != nil {
+			decision, reason = Abort, verifyErr.Error()
+		} else {
+			reason = "all idle routes are healthy"
+		}
 	}
 
-	return nil
+	log.Entry().Info("Blue-green deployment verification summary:")
+	for _, result := range results {
+		if result.Err != nil {
+			log.Entry().Infof("  %s: failed (%s)", result.URL, result.Err.Error())
+		} else {
+			log.Entry().Infof("  %s: ok (status code %d)", result.URL, result.StatusCode)
+		}
+	}
+	log.Entry().Infof("Decision: %s, reason: %s", decision, reason)
+
+	if err := complete(mode, decision, operationID, s); err != nil {
+		return None, errors.Wrapf(err, "Cannot %s blue-green deployment with operation id '%s'", strings.ToLower(decision.String()), operationID)
+	}
+
+	if decision == Abort {
+		return decision, fmt.Errorf("Blue-green deployment with operation id '%s' has been aborted: %s", operationID, reason)
+	}
+	log.Entry().Infof("Blue-green deployment with operation id '%s' has been resumed", operationID)
+	return decision, nil
+}
+
+// checkIdleRoutes polls each url until it responds with a 2xx status code. The timeout applies to all urls together.
+func checkIdleRoutes(urls []string, timeout time.Duration, client piperhttp.Sender, sleep func(time.Duration)) ([]healthCheckResult, error) {
+
+	client.SetOptions(piperhttp.ClientOptions{Timeout: healthCheckInterval})
+
+	maxRetries := int(timeout / healthCheckInterval)
+	retries := 0
+	results := []healthCheckResult{}
+	var err error
+
+	for _, url := range urls {
+		result := healthCheckResult{URL: url}
+		for {
+			result.StatusCode, result.Err = checkRoute(url, client)
+			if result.Err == nil || retries >= maxRetries {
+				break
+			}
+			log.Entry().Debugf("Health check of '%s' failed: %s", url, result.Err.Error())
+			retries++
+			sleep(healthCheckInterval)
+		}
+		results = append(results, result)
+		if result.Err != nil && err == nil {
+			err = fmt.Errorf("health check of '%s' did not succeed within %s", url, timeout)
+		}
+	}
+	return results, err
+}
+
+func checkRoute(url string, client piperhttp.Sender) (int, error) {
+	response, err := client.SendRequest(http.MethodGet, url, nil, nil, nil)
+	if response != nil && response.Body != nil {
+		defer response.Body.Close()
+	}
+	if err != nil {
+		if response != nil {
+			return response.StatusCode, err
+		}
+		return 0, err
+	}
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return response.StatusCode, fmt.Errorf("unexpected status code %d", response.StatusCode)
+	}
+	return response.StatusCode, nil
 }
 
-func copyFileFromHomeToPwd(xsSessionFile string, fCopy func(string, string) (int64, error)) error {
+func copyFileFromHomeToPwd(session xsSession, xsSessionFile string, fCopy func(string, string) (int64, error)) error {
 	if fCopy == nil {
 		fCopy = piperutils.Copy
 	}
-	src, dest := fmt.Sprintf("%s/%s", os.Getenv("HOME"), xsSessionFile), fmt.Sprintf("%s", xsSessionFile)
+	src, dest := fmt.Sprintf("%s/%s", session.homeDir, xsSessionFile), filepath.Join(session.workDir, xsSessionFile)
 	log.Entry().Debugf("Copying xs session file from home directory ('%s') to workspace ('%s')", src, dest)
 	if _, err := fCopy(src, dest); err != nil {
 		return errors.Wrapf(err, "Cannot copy xssession file from home directory ('%s') to workspace ('%s')", src, dest)
@@ -442,7 +648,7 @@ func copyFileFromHomeToPwd(xsSessionFile string, fCopy func(string, string) (int
 	return nil
 }
 
-func copyFileFromPwdToHome(xsSessionFile string, fCopy func(string, string) (int64, error)) error {
+func copyFileFromPwdToHome(session xsSession, xsSessionFile string, fCopy func(string, string) (int64, error)) error {
 
 	//
 	// We rely on running inside a docker container which is discarded after a single use.
@@ -454,7 +660,7 @@ func copyFileFromPwdToHome(xsSessionFile string, fCopy func(string, string) (int
 	if fCopy == nil {
 		fCopy = piperutils.Copy
 	}
-	src, dest := fmt.Sprintf("%s", xsSessionFile), fmt.Sprintf("%s/%s", os.Getenv("HOME"), xsSessionFile)
+	src, dest := filepath.Join(session.workDir, xsSessionFile), fmt.Sprintf("%s/%s", session.homeDir, xsSessionFile)
 	log.Entry().Debugf("Copying xs session file from workspace ('%s') to home directory ('%s')", src, dest)
 	if _, err := fCopy(src, dest); err != nil {
 		return errors.Wrapf(err, "Cannot copy xssession file from workspace ('%s') to home directory ('%s')", src, dest)
@@ -463,7 +669,7 @@ func copyFileFromPwdToHome(xsSessionFile string, fCopy func(string, string) (int
 	return nil
 }
 
-//GetAction ...
+// GetAction ...
 func (a Action) GetAction() (string, error) {
 	switch a {
 	case Resume, Abort, Retry:
@@ -473,7 +679,7 @@ func (a Action) GetAction() (string, error) {
 
 }
 
-//GetDeployCommand ...
+// GetDeployCommand ...
 func (m DeployMode) GetDeployCommand() (string, error) {
 
 	switch m {
@@ -0,0 +1,205 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/SAP/jenkins-library/pkg/log"
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+)
+
+// name of the MTA extension descriptor created from the mtaExtension configuration
+const xsExtensionFile = "xsDeploy.mtaext"
+
+type mtaExtension struct {
+	SchemaVersion string                 `json:"_schema-version,omitempty"`
+	ID            string                 `json:"ID,omitempty"`
+	Extends       string                 `json:"extends,omitempty"`
+	Parameters    map[string]interface{} `json:"parameters,omitempty"`
+	Modules       []mtaExtensionEntry    `json:"modules,omitempty"`
+	Resources     []mtaExtensionEntry    `json:"resources,omitempty"`
+}
+
+type mtaExtensionEntry struct {
+	Name       string                 `json:"name"`
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+	Properties map[string]interface{} `json:"properties,omitempty"`
+}
+
+// mtaDescriptor contains the parts of the MTA descriptor (mta.yaml) relevant for validating an extension
+type mtaDescriptor struct {
+	SchemaVersion string `json:"_schema-version"`
+	ID            string `json:"ID"`
+	Modules       []struct {
+		Name string `json:"name"`
+	} `json:"modules"`
+	Resources []struct {
+		Name string `json:"name"`
+	} `json:"resources"`
+}
+
+// mtaExtensionRenderer validates the extension and writes it as MTA extension descriptor into the file
+type mtaExtensionRenderer func(extension mtaExtension, idSuffix, file string) error
+
+// parseMtaExtension converts the extension provided via configuration. Unknown keys are rejected
+// in order to detect typos instead of silently dropping parts of the extension.
+func parseMtaExtension(rawExtension map[string]interface{}) (mtaExtension, error) {
+	extension := mtaExtension{}
+	if len(rawExtension) == 0 {
+		return extension, nil
+	}
+
+	content, err := json.Marshal(rawExtension)
+	if err != nil {
+		return extension, errors.Wrap(err, "Invalid MTA extension")
+	}
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&extension); err != nil {
+		return extension, errors.Wrap(err, "Invalid MTA extension")
+	}
+	if len(extension.Extends) > 0 {
+		return extension, errors.New("Invalid MTA extension: 'extends' must not be provided, it is taken from the MTA descriptor")
+	}
+	return extension, nil
+}
+
+// applyMtaExtension creates the MTA extension descriptor in case an extension is configured and adds it to the deploy options
+func applyMtaExtension(XsDeployOptions xsDeployOptions, render mtaExtensionRenderer) (xsDeployOptions, error) {
+	if len(XsDeployOptions.MtaExtension) == 0 || XsDeployOptions.Mode == NoDeploy.String() || XsDeployOptions.Action != None.String() {
+		return XsDeployOptions, nil
+	}
+
+	extension, err := parseMtaExtension(XsDeployOptions.MtaExtension)
+	if err != nil {
+		return XsDeployOptions, err
+	}
+	if err := render(extension, "xsDeploy", xsExtensionFile); err != nil {
+		return XsDeployOptions, err
+	}
+	XsDeployOptions.DeployOpts = strings.TrimSpace(fmt.Sprintf("%s -e %s", XsDeployOptions.DeployOpts, quoteArg(xsExtensionFile)))
+	return XsDeployOptions, nil
+}
+
+func renderMtaExtension(extension mtaExtension, idSuffix, file, mtaDescriptorPath string,
+	fReadFile func(string) ([]byte, error),
+	fWriteFile func(string, []byte, os.FileMode) error) error {
+
+	content, err := fReadFile(mtaDescriptorPath)
+	if err != nil {
+		return errors.Wrapf(err, "Cannot read MTA descriptor '%s'", mtaDescriptorPath)
+	}
+	descriptor := mtaDescriptor{}
+	if err := yaml.Unmarshal(content, &descriptor); err != nil {
+		return errors.Wrapf(err, "Cannot parse MTA descriptor '%s'", mtaDescriptorPath)
+	}
+	if len(descriptor.ID) == 0 {
+		return fmt.Errorf("MTA descriptor '%s' does not provide an ID", mtaDescriptorPath)
+	}
+
+	if mismatches := validateMtaExtension(extension, descriptor); len(mismatches) > 0 {
+		log.Entry().Errorf("MTA extension does not match the MTA descriptor '%s' (ID: '%s'):", mtaDescriptorPath, descriptor.ID)
+		for _, mismatch := range mismatches {
+			log.Entry().Errorf("  %s", mismatch)
+		}
+		return fmt.Errorf("MTA extension does not match the MTA descriptor '%s': %s", mtaDescriptorPath, strings.Join(mismatches, "; "))
+	}
+
+	extension.Extends = descriptor.ID
+	if len(extension.ID) == 0 {
+		extension.ID = fmt.Sprintf("%s.%s", descriptor.ID, idSuffix)
+	}
+	if len(extension.SchemaVersion) == 0 {
+		extension.SchemaVersion = descriptor.SchemaVersion
+	}
+
+	content, err = yaml.Marshal(extension)
+	if err != nil {
+		return errors.Wrap(err, "Cannot create MTA extension descriptor")
+	}
+	if err := fWriteFile(file, content, 0644); err != nil {
+		return errors.Wrapf(err, "Cannot write MTA extension descriptor '%s'", file)
+	}
+	log.Entry().Infof("MTA extension descriptor '%s' created (ID: '%s', extends: '%s')", file, extension.ID, extension.Extends)
+	return nil
+}
+
+// validateMtaExtension checks that all modules and resources of the extension are defined in the MTA descriptor
+func validateMtaExtension(extension mtaExtension, descriptor mtaDescriptor) []string {
+	modules, resources := []string{}, []string{}
+	for _, module := range descriptor.Modules {
+		modules = append(modules, module.Name)
+	}
+	for _, resource := range descriptor.Resources {
+		resources = append(resources, resource.Name)
+	}
+
+	mismatches := validateMtaExtensionEntries("module", extension.Modules, modules)
+	return append(mismatches, validateMtaExtensionEntries("resource", extension.Resources, resources)...)
+}
+
+func validateMtaExtensionEntries(kind string, entries []mtaExtensionEntry, known []string) []string {
+	mismatches := []string{}
+	for i, entry := range entries {
+		if len(entry.Name) == 0 {
+			mismatches = append(mismatches, fmt.Sprintf("%s #%d has no name", kind, i+1))
+			continue
+		}
+		if !sliceContains(known, entry.Name) {
+			sorted := append([]string{}, known...)
+			sort.Strings(sorted)
+			mismatches = append(mismatches, fmt.Sprintf("%s '%s' is not defined in the MTA (available: %s)", kind, entry.Name, strings.Join(sorted, ", ")))
+		}
+	}
+	return mismatches
+}
+
+// mergeMtaExtensions merges two extensions. Parameters and properties of the override take precedence,
+// modules and resources are merged by name.
+func mergeMtaExtensions(base, override mtaExtension) mtaExtension {
+	merged := base
+	if len(override.SchemaVersion) > 0 {
+		merged.SchemaVersion = override.SchemaVersion
+	}
+	if len(override.ID) > 0 {
+		merged.ID = override.ID
+	}
+	merged.Parameters = mergeMaps(base.Parameters, override.Parameters)
+	merged.Modules = mergeMtaExtensionEntries(base.Modules, override.Modules)
+	merged.Resources = mergeMtaExtensionEntries(base.Resources, override.Resources)
+	return merged
+}
+
+func mergeMtaExtensionEntries(base, override []mtaExtensionEntry) []mtaExtensionEntry {
+	merged := []mtaExtensionEntry{}
+	index := map[string]int{}
+	for _, entry := range append(append([]mtaExtensionEntry{}, base...), override...) {
+		if i, ok := index[entry.Name]; ok {
+			merged[i].Parameters = mergeMaps(merged[i].Parameters, entry.Parameters)
+			merged[i].Properties = mergeMaps(merged[i].Properties, entry.Properties)
+			continue
+		}
+		index[entry.Name] = len(merged)
+		merged = append(merged, entry)
+	}
+	return merged
+}
+
+func mergeMaps(base, override map[string]interface{}) map[string]interface{} {
+	if len(base) == 0 && len(override) == 0 {
+		return nil
+	}
+	merged := map[string]interface{}{}
+	for key, value := range base {
+		merged[key] = value
+	}
+	for key, value := range override {
+		merged[key] = value
+	}
+	return merged
+}
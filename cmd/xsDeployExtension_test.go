@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/SAP/jenkins-library/pkg/command"
+	"github.com/stretchr/testify/assert"
+)
+
+const testMtaDescriptor = `_schema-version: "3.1"
+ID: com.example.myapp
+version: 1.0.0
+
+modules:
+  - name: srv
+    type: nodejs
+  - name: ui
+    type: html5
+
+resources:
+  - name: hdi-container
+    type: com.sap.xs.hdi-container
+`
+
+func TestRenderMtaExtension(t *testing.T) {
+
+	fReadFile := func(path string) ([]byte, error) {
+		if path == "mta.yaml" {
+			return []byte(testMtaDescriptor), nil
+		}
+		return nil, errors.New("file not found")
+	}
+
+	var written map[string]string
+	fWriteFile := func(path string, content []byte, perm os.FileMode) error {
+		written[path] = string(content)
+		return nil
+	}
+
+	t.Run("success", func(t *testing.T) {
+		written = map[string]string{}
+		extension, err := parseMtaExtension(map[string]interface{}{
+			"parameters": map[string]interface{}{"keep-existing-routes": true},
+			"modules":    []interface{}{map[string]interface{}{"name": "srv", "parameters": map[string]interface{}{"memory": "512M"}}},
+			"resources":  []interface{}{map[string]interface{}{"name": "hdi-container", "properties": map[string]interface{}{"schema": "MY_SCHEMA"}}},
+		})
+		assert.NoError(t, err)
+
+		err = renderMtaExtension(extension, "xsDeploy", "xsDeploy.mtaext", "mta.yaml", fReadFile, fWriteFile)
+
+		assert.NoError(t, err)
+		assert.Equal(t, `_schema-version: "3.1"
+ID: com.example.myapp.xsDeploy
+extends: com.example.myapp
+modules:
+- name: srv
+  parameters:
+    memory: 512M
+parameters:
+  keep-existing-routes: true
+resources:
+- name: hdi-container
+  properties:
+    schema: MY_SCHEMA
+`, written["xsDeploy.mtaext"])
+	})
+
+	t.Run("mismatches", func(t *testing.T) {
+		written = map[string]string{}
+		extension := mtaExtension{
+			ID:        "my.extension",
+			Modules:   []mtaExtensionEntry{{Name: "srv"}, {Name: "backend"}, {}},
+			Resources: []mtaExtensionEntry{{Name: "uaa"}},
+		}
+
+		err := renderMtaExtension(extension, "xsDeploy", "xsDeploy.mtaext", "mta.yaml", fReadFile, fWriteFile)
+
+		assert.EqualError(t, err, "MTA extension does not match the MTA descriptor 'mta.yaml': "+
+			"module 'backend' is not defined in the MTA (available: srv, ui); "+
+			"module #3 has no name; "+
+			"resource 'uaa' is not defined in the MTA (available: hdi-container)")
+		assert.Empty(t, written)
+	})
+
+	t.Run("MTA descriptor missing", func(t *testing.T) {
+		err := renderMtaExtension(mtaExtension{}, "xsDeploy", "xsDeploy.mtaext", "notExisting.yaml", fReadFile, fWriteFile)
+		assert.EqualError(t, err, "Cannot read MTA descriptor 'notExisting.yaml': file not found")
+	})
+}
+
+func TestParseMtaExtension(t *testing.T) {
+
+	t.Run("unknown key", func(t *testing.T) {
+		_, err := parseMtaExtension(map[string]interface{}{"module": []interface{}{}})
+		assert.EqualError(t, err, "Invalid MTA extension: json: unknown field \"module\"")
+	})
+
+	t.Run("extends provided", func(t *testing.T) {
+		_, err := parseMtaExtension(map[string]interface{}{"extends": "com.example.other"})
+		assert.EqualError(t, err, "Invalid MTA extension: 'extends' must not be provided, it is taken from the MTA descriptor")
+	})
+}
+
+func TestApplyMtaExtension(t *testing.T) {
+
+	myXsDeployOptions := xsDeployOptions{
+		Mode:         "DEPLOY",
+		Action:       "NONE",
+		DeployOpts:   "--dummy-deploy-opts",
+		MtaExtension: map[string]interface{}{"parameters": map[string]interface{}{"instances": 2}},
+	}
+
+	var renderedFiles []string
+	render := func(extension mtaExtension, idSuffix, file string) error {
+		renderedFiles = append(renderedFiles, file)
+		return nil
+	}
+
+	t.Run("extension added to deploy options", func(t *testing.T) {
+		renderedFiles = nil
+		options, err := applyMtaExtension(myXsDeployOptions, render)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{xsExtensionFile}, renderedFiles)
+		deployOpts, err := command.ParseArgs(options.DeployOpts)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"--dummy-deploy-opts", "-e", xsExtensionFile}, deployOpts)
+	})
+
+	t.Run("no extension in case of resume", func(t *testing.T) {
+		renderedFiles = nil
+		options := myXsDeployOptions
+		options.Mode = "BG_DEPLOY"
+		options.Action = "RESUME"
+
+		options, err := applyMtaExtension(options, render)
+
+		assert.NoError(t, err)
+		assert.Empty(t, renderedFiles)
+		assert.Equal(t, "--dummy-deploy-opts", options.DeployOpts)
+	})
+
+	t.Run("rendering fails", func(t *testing.T) {
+		_, err := applyMtaExtension(myXsDeployOptions, func(mtaExtension, string, string) error {
+			return errors.New("mismatch")
+		})
+		assert.EqualError(t, err, "mismatch")
+	})
+}
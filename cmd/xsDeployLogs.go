@@ -0,0 +1,275 @@
+package cmd
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/SAP/jenkins-library/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// file names of the artifacts created in the workspace in case the deployment failed
+const (
+	xsLogReportFile  = "xsDeploy_errors.json"
+	xsLogArchiveFile = "xsDeploy_logs.tar.gz"
+)
+
+// maximum number of lines kept for the message of an error
+const xsLogMessageLines = 20
+
+// maximum number of errors listed in the step log, the report contains all errors
+const xsLogSummaryErrors = 5
+
+var (
+	xsLogErrorLine   = regexp.MustCompile(`(?i)(#ERROR#|\bERROR\b|\bfailed\b|\bexception\b)`)
+	xsLogEntryStart  = regexp.MustCompile(`^(#\d+\.\d+#|\[?\d{4}[-/ ]\d{2}[-/ ]\d{2}|\[?\d{2}:\d{2}:\d{2})`)
+	xsLogEntryHeader = regexp.MustCompile(`^#\d+\.\d+#.*#$`)
+	xsLogTimestamp   = regexp.MustCompile(`^\[[^\]]*\]\s*`)
+	xsLogModule      = regexp.MustCompile(`(?i)\b(?:application|module)\s+"([^"]+)"`)
+	xsLogService     = regexp.MustCompile(`(?i)\bservice(?:\s+instance)?\s+"([^"]+)"`)
+	xsLogErrorCodes  = []*regexp.Regexp{
+		regexp.MustCompile(`\b(CF-[A-Za-z]+\s*\(\d+\))`),
+		regexp.MustCompile(`(?i)\berror\s+code:?\s+([A-Za-z0-9_.-]+)`),
+		regexp.MustCompile(`\b([45]\d{2} (?:Bad Request|Unauthorized|Forbidden|Not Found|Conflict|Unprocessable Entity|Internal Server Error|Bad Gateway|Service Unavailable|Gateway Timeout))\b`),
+	}
+)
+
+type xsLogReport struct {
+	LogDir  string       `json:"logDir"`
+	Files   []string     `json:"files"`
+	Archive string       `json:"archive,omitempty"`
+	Errors  []xsLogError `json:"errors"`
+}
+
+type xsLogError struct {
+	File       string   `json:"file"`
+	Line       int      `json:"line"`
+	Module     string   `json:"module,omitempty"`
+	Service    string   `json:"service,omitempty"`
+	ErrorCodes []string `json:"errorCodes,omitempty"`
+	Message    string   `json:"message"`
+}
+
+// handleLog analyzes the logs written by the xs client, logs a summary of the errors found and
+// provides a report as well as an archive containing the raw logs inside the report directory.
+func handleLog(logDir, reportDir string) error {
+
+	if _, e := os.Stat(logDir); os.IsNotExist(e) {
+		log.Entry().Warningf("Cannot provide xs logs. Log directory '%s' does not exist.", logDir)
+		return nil
+	}
+
+	report, err := analyzeXsLogs(logDir)
+	if err != nil {
+		return err
+	}
+
+	if len(report.Files) == 0 {
+		log.Entry().Warningf("Cannot provide xs logs. No log files found inside '%s'.", logDir)
+		return nil
+	}
+
+	archive := filepath.Join(reportDir, xsLogArchiveFile)
+	if err := archiveXsLogs(logDir, report.Files, archive); err != nil {
+		log.Entry().WithError(err).Warn("Cannot archive the xs logs")
+	} else {
+		report.Archive = archive
+	}
+
+	logXsLogSummary(report)
+
+	content, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "Cannot create xs log report")
+	}
+	reportFile := filepath.Join(reportDir, xsLogReportFile)
+	if err := ioutil.WriteFile(reportFile, content, 0644); err != nil {
+		return errors.Wrapf(err, "Cannot write xs log report '%s'", reportFile)
+	}
+	log.Entry().Infof("Details are available in '%s'", reportFile)
+	return nil
+}
+
+func analyzeXsLogs(logDir string) (xsLogReport, error) {
+	report := xsLogReport{LogDir: logDir, Files: []string{}, Errors: []xsLogError{}}
+
+	logFiles, err := ioutil.ReadDir(logDir)
+	if err != nil {
+		return report, errors.Wrapf(err, "Cannot read xs log directory '%s'", logDir)
+	}
+
+	known := map[string]bool{}
+	for _, logFile := range logFiles {
+		if !logFile.Mode().IsRegular() {
+			continue
+		}
+		report.Files = append(report.Files, logFile.Name())
+
+		f, err := os.Open(filepath.Join(logDir, logFile.Name()))
+		if err != nil {
+			return report, errors.Wrapf(err, "Cannot open xs log file '%s'", logFile.Name())
+		}
+		logErrors, err := parseXsLog(logFile.Name(), f)
+		f.Close()
+		if err != nil {
+			return report, errors.Wrapf(err, "Cannot read xs log file '%s'", logFile.Name())
+		}
+
+		// the same error is typically reported several times, e.g. by the different processing steps
+		for _, logError := range logErrors {
+			key := xsLogTimestamp.ReplaceAllString(strings.SplitN(logError.Message, "\n", 2)[0], "")
+			if !known[key] {
+				known[key] = true
+				report.Errors = append(report.Errors, logError)
+			}
+		}
+	}
+	return report, nil
+}
+
+// parseXsLog collects the error entries of a log file. An entry consists of the line reporting the error
+// and the subsequent lines until the next log entry starts. In case the error is only denoted by the header
+// of a log entry (e.g. "#2.0#<timestamp>#+00#ERROR#<location>#") the message consists of the subsequent lines.
+func parseXsLog(name string, r io.Reader) ([]xsLogError, error) {
+	logErrors := []xsLogError{}
+	var current *xsLogError
+	var block []string
+
+	finish := func() {
+		if current != nil && len(block) > 0 {
+			current.Message = strings.Join(block, "\n")
+			enrichXsLogError(current)
+			logErrors = append(logErrors, *current)
+		}
+		current, block = nil, nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimRight(scanner.Text(), "\r ")
+
+		if xsLogEntryStart.MatchString(line) || len(strings.TrimSpace(line)) == 0 {
+			finish()
+		} else if current != nil {
+			if len(block) == 0 {
+				current.Line = lineNumber
+			}
+			if len(block) < xsLogMessageLines {
+				block = append(block, line)
+			}
+			continue
+		}
+
+		if xsLogErrorLine.MatchString(line) {
+			current = &xsLogError{File: name, Line: lineNumber}
+			block = []string{}
+			if !xsLogEntryHeader.MatchString(line) {
+				block = append(block, line)
+			}
+		}
+	}
+	finish()
+	return logErrors, scanner.Err()
+}
+
+func enrichXsLogError(logError *xsLogError) {
+	if m := xsLogModule.FindStringSubmatch(logError.Message); len(m) > 1 {
+		logError.Module = m[1]
+	}
+	if m := xsLogService.FindStringSubmatch(logError.Message); len(m) > 1 {
+		logError.Service = m[1]
+	}
+	for _, re := range xsLogErrorCodes {
+		for _, m := range re.FindAllStringSubmatch(logError.Message, -1) {
+			if !sliceContains(logError.ErrorCodes, m[1]) {
+				logError.ErrorCodes = append(logError.ErrorCodes, m[1])
+			}
+		}
+	}
+}
+
+func logXsLogSummary(report xsLogReport) {
+	if len(report.Errors) == 0 {
+		log.Entry().Warningf("No errors found in the xs logs (%s).", strings.Join(report.Files, ", "))
+	} else {
+		log.Entry().Errorf("%d error(s) found in the xs logs:", len(report.Errors))
+	}
+
+	for i, logError := range report.Errors {
+		if i == xsLogSummaryErrors {
+			log.Entry().Errorf("... %d more error(s)", len(report.Errors)-xsLogSummaryErrors)
+			break
+		}
+		details := []string{}
+		if len(logError.Module) > 0 {
+			details = append(details, fmt.Sprintf("module '%s'", logError.Module))
+		}
+		if len(logError.Service) > 0 {
+			details = append(details, fmt.Sprintf("service '%s'", logError.Service))
+		}
+		if len(logError.ErrorCodes) > 0 {
+			details = append(details, fmt.Sprintf("error codes: %s", strings.Join(logError.ErrorCodes, ", ")))
+		}
+		details = append(details, fmt.Sprintf("%s:%d", logError.File, logError.Line))
+		log.Entry().Errorf("  %s (%s)", strings.SplitN(logError.Message, "\n", 2)[0], strings.Join(details, ", "))
+	}
+
+	if len(report.Archive) > 0 {
+		log.Entry().Infof("The raw xs logs are available in '%s'", report.Archive)
+	}
+}
+
+func archiveXsLogs(logDir string, files []string, archive string) error {
+	out, err := os.Create(archive)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	gz := gzip.NewWriter(out)
+	tw := tar.NewWriter(gz)
+
+	for _, name := range files {
+		if err := addToArchive(tw, filepath.Join(logDir, name), name); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func addToArchive(tw *tar.Writer, path, name string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	header, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	header.Name = name
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
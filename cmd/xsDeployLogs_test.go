@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const xsMainLog = `#2.0#2019 11 05 10:20:30.123#+00#INFO#com.sap.cloud.lm.sl.xs2#
+Detected MTA schema version: "3"
+#2.0#2019 11 05 10:20:31.456#+00#ERROR#com.sap.cloud.lm.sl.xs2.process.CreateServiceStep#
+Error creating service "my-hdi-container": Controller operation failed: 502 Bad Gateway: Service broker error: CF-ServiceBrokerBadResponse (10001)
+    at com.sap.cloud.lm.sl.cf.client.CloudControllerClient.createService
+#2.0#2019 11 05 10:20:32.789#+00#ERROR#com.sap.cloud.lm.sl.xs2.process.StartAppStep#
+Error staging application "my-app-green": Application "my-app-green" failed to start (error code: STAGING_FAILED)
+
+#2.0#2019 11 05 10:20:33.000#+00#INFO#com.sap.cloud.lm.sl.xs2#
+Process finished.
+`
+
+const xsOperationLog = `[2019-11-05 10:20:31] Error creating service "my-hdi-container": Controller operation failed: 502 Bad Gateway: Service broker error: CF-ServiceBrokerBadResponse (10001)
+[2019-11-05 10:20:35] Process failed.
+`
+
+func TestParseXsLog(t *testing.T) {
+
+	logErrors, err := parseXsLog("MAIN_LOG", strings.NewReader(xsMainLog))
+
+	assert.NoError(t, err)
+	if assert.Len(t, logErrors, 2) {
+		assert.Equal(t, 4, logErrors[0].Line)
+		assert.Equal(t, "my-hdi-container", logErrors[0].Service)
+		assert.Empty(t, logErrors[0].Module)
+		assert.Equal(t, []string{"CF-ServiceBrokerBadResponse (10001)", "502 Bad Gateway"}, logErrors[0].ErrorCodes)
+		assert.Equal(t, "Error creating service \"my-hdi-container\": Controller operation failed: 502 Bad Gateway: Service broker error: CF-ServiceBrokerBadResponse (10001)\n"+
+			"    at com.sap.cloud.lm.sl.cf.client.CloudControllerClient.createService", logErrors[0].Message)
+
+		assert.Equal(t, 7, logErrors[1].Line)
+		assert.Equal(t, "my-app-green", logErrors[1].Module)
+		assert.Equal(t, []string{"STAGING_FAILED"}, logErrors[1].ErrorCodes)
+	}
+}
+
+func TestHandleLog(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "xsLogs")
+	if err != nil {
+		t.Fatal("Failed to create temporary directory")
+	}
+	defer os.RemoveAll(dir)
+
+	logDir := filepath.Join(dir, ".xs_logs")
+	reportDir := filepath.Join(dir, "workspace")
+	os.Mkdir(logDir, 0755)
+	os.Mkdir(reportDir, 0755)
+
+	t.Run("no log files", func(t *testing.T) {
+		err := handleLog(logDir, reportDir)
+
+		assert.NoError(t, err)
+		_, err = os.Stat(filepath.Join(reportDir, xsLogReportFile))
+		assert.True(t, os.IsNotExist(err), "no report expected")
+	})
+
+	t.Run("log directory does not exist", func(t *testing.T) {
+		assert.NoError(t, handleLog(filepath.Join(dir, "notExisting"), reportDir))
+	})
+
+	t.Run("report and archive", func(t *testing.T) {
+		ioutil.WriteFile(filepath.Join(logDir, "MAIN_LOG"), []byte(xsMainLog), 0644)
+		ioutil.WriteFile(filepath.Join(logDir, "OPERATION.log"), []byte(xsOperationLog), 0644)
+
+		err := handleLog(logDir, reportDir)
+		assert.NoError(t, err)
+
+		content, err := ioutil.ReadFile(filepath.Join(reportDir, xsLogReportFile))
+		if assert.NoError(t, err) {
+			report := xsLogReport{}
+			assert.NoError(t, json.Unmarshal(content, &report))
+			assert.Equal(t, []string{"MAIN_LOG", "OPERATION.log"}, report.Files)
+			assert.Equal(t, filepath.Join(reportDir, xsLogArchiveFile), report.Archive)
+			// duplicates from the operation log are dropped
+			assert.Len(t, report.Errors, 3)
+			assert.Equal(t, "OPERATION.log", report.Errors[2].File)
+			assert.Equal(t, 2, report.Errors[2].Line)
+			assert.Equal(t, "[2019-11-05 10:20:35] Process failed.", report.Errors[2].Message)
+		}
+
+		f, err := os.Open(filepath.Join(reportDir, xsLogArchiveFile))
+		if assert.NoError(t, err) {
+			defer f.Close()
+			gz, err := gzip.NewReader(f)
+			assert.NoError(t, err)
+			tr := tar.NewReader(gz)
+			names := []string{}
+			for {
+				header, err := tr.Next()
+				if err != nil {
+					break
+				}
+				names = append(names, header.Name)
+			}
+			assert.Equal(t, []string{"MAIN_LOG", "OPERATION.log"}, names)
+		}
+	})
+}
@@ -0,0 +1,302 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/SAP/jenkins-library/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// directory inside the workspace keeping the sessions and logs of the individual targets
+const xsTargetsDir = ".xsDeploy"
+
+// status of the deployment to a target
+const (
+	xsTargetSucceeded = "SUCCESS"
+	xsTargetFailed    = "FAILURE"
+	xsTargetSkipped   = "SKIPPED"
+)
+
+var xsTargetNameInvalidChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+type xsDeployTarget struct {
+	Name                 string                 `json:"name"`
+	APIURL               string                 `json:"apiUrl"`
+	Org                  string                 `json:"org"`
+	Space                string                 `json:"space"`
+	CredentialsID        string                 `json:"credentialsId"`
+	ExtensionDescriptors []string               `json:"extensionDescriptors"`
+	MtaExtension         map[string]interface{} `json:"mtaExtension"`
+	extension            mtaExtension
+	// credentials resolved via credentialsId
+	user     string
+	password string
+}
+
+type xsDeployTargetResult struct {
+	Name   string `json:"name"`
+	APIURL string `json:"apiUrl"`
+	Org    string `json:"org"`
+	Space  string `json:"space"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// xsTargetCredentialsEnv provides the names of the environment variables the credentials of target #number
+// (starting with 1) are provided with. The library step binds them based on the credentialsId of the target.
+func xsTargetCredentialsEnv(number int) (string, string) {
+	return fmt.Sprintf("PIPER_XS_TARGET_%d_USERNAME", number), fmt.Sprintf("PIPER_XS_TARGET_%d_PASSWORD", number)
+}
+
+// xsDeployTargetFunc performs the deployment to a single target
+type xsDeployTargetFunc func(targetOptions xsDeployOptions, session xsSession, targetEnvironment *xsDeployCommonPipelineEnvironment) error
+
+// runXsDeployTargets deploys to all configured targets, either one after another or in parallel. Each target
+// uses its own xs session. The results of all targets are reported, the step fails in case any deployment failed.
+func runXsDeployTargets(XsDeployOptions xsDeployOptions, piperEnvironment *xsDeployCommonPipelineEnvironment,
+	deployTarget xsDeployTargetFunc,
+	render mtaExtensionRenderer,
+	fMkdirAll func(string, os.FileMode) error,
+	fRemoveAll func(string) error,
+	fGetenv func(string) string,
+	stdout io.Writer) error {
+
+	// deployments to multiple targets are completed within the step, hence there is nothing to be resumed later
+	piperEnvironment.xsDeploy.mode = XsDeployOptions.Mode
+	piperEnvironment.xsDeploy.operationID = ""
+
+	mode, err := ValueOfMode(XsDeployOptions.Mode)
+	if err != nil {
+		return errors.Wrapf(err, "Extracting mode failed: '%s'", XsDeployOptions.Mode)
+	}
+
+	if mode == NoDeploy {
+		log.Entry().Infof("Deployment skipped intentionally. Deploy mode '%s'", mode.String())
+		return nil
+	}
+
+	action, err := ValueOfAction(XsDeployOptions.Action)
+	if err != nil {
+		return errors.Wrapf(err, "Extracting action failed: '%s'", XsDeployOptions.Action)
+	}
+
+	if action != None || (mode == BGDeploy && !XsDeployOptions.AutoComplete) {
+		return fmt.Errorf("Cannot perform action '%s' in mode '%s' for multiple targets. Blue-green deployments to multiple targets are only supported with 'autoComplete'.", action, mode)
+	}
+
+	targets, err := parseXsDeployTargets(XsDeployOptions.Targets, fGetenv)
+	if err != nil {
+		return err
+	}
+
+	extension, err := parseMtaExtension(XsDeployOptions.MtaExtension)
+	if err != nil {
+		return err
+	}
+
+	results := make([]xsDeployTargetResult, len(targets))
+	deployTo := func(i int) {
+		target := targets[i]
+		results[i] = xsDeployTargetResult{Name: target.Name, APIURL: target.APIURL, Org: target.Org, Space: target.Space, Status: xsTargetSucceeded}
+
+		log.Entry().Infof("Deploying to target '%s' (api-url: '%s', org: '%s', space: '%s')", target.Name, target.APIURL, target.Org, target.Space)
+
+		err := deployToTarget(XsDeployOptions, target, extension, deployTarget, render, fMkdirAll, fRemoveAll)
+		if err != nil {
+			log.Entry().WithError(err).Errorf("Deployment to target '%s' failed", target.Name)
+			results[i].Status, results[i].Error = xsTargetFailed, err.Error()
+			return
+		}
+		log.Entry().Infof("Deployment to target '%s' succeeded", target.Name)
+	}
+
+	if XsDeployOptions.ParallelTargets {
+		var wg sync.WaitGroup
+		for i := range targets {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				deployTo(i)
+			}(i)
+		}
+		wg.Wait()
+	} else {
+		failed := false
+		for i, target := range targets {
+			if failed && XsDeployOptions.StopOnFirstFailure {
+				log.Entry().Infof("Deployment to target '%s' skipped due to previous failure", target.Name)
+				results[i] = xsDeployTargetResult{Name: target.Name, APIURL: target.APIURL, Org: target.Org, Space: target.Space, Status: xsTargetSkipped}
+				continue
+			}
+			deployTo(i)
+			failed = failed || results[i].Status == xsTargetFailed
+		}
+	}
+
+	return reportXsDeployTargets(results, stdout)
+}
+
+// parseXsDeployTargets converts the targets provided via configuration and checks them for completeness.
+// The credentials of targets with a credentialsId are read from the environment.
+func parseXsDeployTargets(rawTargets []map[string]interface{}, fGetenv func(string) string) ([]xsDeployTarget, error) {
+	targets := []xsDeployTarget{}
+	names := map[string]bool{}
+
+	for i, rawTarget := range rawTargets {
+		// credentials must not be part of the configuration
+		for _, key := range []string{"user", "password"} {
+			if _, ok := rawTarget[key]; ok {
+				return nil, fmt.Errorf("Target #%d contains '%s'. Provide the credentials of a target via 'credentialsId'.", i+1, key)
+			}
+		}
+
+		target := xsDeployTarget{}
+		content, err := json.Marshal(rawTarget)
+		if err == nil {
+			err = json.Unmarshal(content, &target)
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "Invalid target #%d", i+1)
+		}
+
+		missing := []string{}
+		for _, param := range []struct{ name, value string }{
+			{"apiUrl", target.APIURL},
+			{"org", target.Org},
+			{"space", target.Space},
+		} {
+			if len(param.value) == 0 {
+				missing = append(missing, param.name)
+			}
+		}
+		if len(missing) > 0 {
+			return nil, fmt.Errorf("Target #%d is incomplete. Missing parameter(s): %s", i+1, strings.Join(missing, ", "))
+		}
+
+		if len(target.CredentialsID) > 0 {
+			userEnv, passwordEnv := xsTargetCredentialsEnv(i + 1)
+			target.user, target.password = fGetenv(userEnv), fGetenv(passwordEnv)
+			if len(target.user) == 0 || len(target.password) == 0 {
+				return nil, fmt.Errorf("Credentials '%s' of target #%d are not available. Provide them via the environment variables '%s' and '%s'.", target.CredentialsID, i+1, userEnv, passwordEnv)
+			}
+		}
+
+		if target.extension, err = parseMtaExtension(target.MtaExtension); err != nil {
+			return nil, errors.Wrapf(err, "Invalid target #%d", i+1)
+		}
+
+		if len(target.Name) == 0 {
+			target.Name = fmt.Sprintf("%s-%s", target.Org, target.Space)
+		}
+		// the name is used as directory name for the session of the target
+		target.Name = xsTargetNameInvalidChars.ReplaceAllString(target.Name, "_")
+		if names[target.Name] {
+			return nil, fmt.Errorf("Target name '%s' is not unique. Provide a distinct 'name' for each target.", target.Name)
+		}
+		names[target.Name] = true
+
+		targets = append(targets, target)
+	}
+	return targets, nil
+}
+
+func deployToTarget(XsDeployOptions xsDeployOptions, target xsDeployTarget, extension mtaExtension,
+	deployTarget xsDeployTargetFunc,
+	render mtaExtensionRenderer,
+	fMkdirAll func(string, os.FileMode) error,
+	fRemoveAll func(string) error) error {
+
+	workDir := filepath.Join(xsTargetsDir, target.Name)
+	homeDir, err := filepath.Abs(filepath.Join(workDir, "home"))
+	if err != nil {
+		return errors.Wrapf(err, "Cannot determine home directory for target '%s'", target.Name)
+	}
+	if err := fMkdirAll(homeDir, 0700); err != nil {
+		return errors.Wrapf(err, "Cannot create home directory '%s' for target '%s'", homeDir, target.Name)
+	}
+	// the home directory contains the xs session, hence it is removed in any case once the deployment is finished
+	defer func() {
+		if err := fRemoveAll(homeDir); err != nil {
+			log.Entry().WithError(err).Warnf("Cannot remove home directory '%s' of target '%s'", homeDir, target.Name)
+		}
+	}()
+
+	// the extension of the step is specialized by the extension of the target
+	if len(XsDeployOptions.MtaExtension) > 0 || len(target.MtaExtension) > 0 {
+		file := filepath.Join(workDir, xsExtensionFile)
+		if err := render(mergeMtaExtensions(extension, target.extension), target.Name, file); err != nil {
+			return err
+		}
+		target.ExtensionDescriptors = append([]string{file}, target.ExtensionDescriptors...)
+	}
+
+	return deployTarget(xsDeployTargetOptions(XsDeployOptions, target), xsSession{homeDir: homeDir, workDir: workDir}, &xsDeployCommonPipelineEnvironment{})
+}
+
+// xsDeployTargetOptions provides the options for the deployment to a single target. In case the target does
+// not refer to own credentials the credentials of the step are used.
+func xsDeployTargetOptions(XsDeployOptions xsDeployOptions, target xsDeployTarget) xsDeployOptions {
+	targetOptions := XsDeployOptions
+	targetOptions.Targets = nil
+	targetOptions.MtaExtension = nil
+	targetOptions.OperationID = ""
+	targetOptions.APIURL = target.APIURL
+	targetOptions.Org = target.Org
+	targetOptions.Space = target.Space
+
+	if len(target.CredentialsID) > 0 {
+		targetOptions.User = target.user
+		targetOptions.Password = target.password
+	}
+
+	if len(target.ExtensionDescriptors) > 0 {
+		targetOptions.DeployOpts = strings.TrimSpace(fmt.Sprintf("%s -e %s", XsDeployOptions.DeployOpts, quoteArg(strings.Join(target.ExtensionDescriptors, ","))))
+	}
+	return targetOptions
+}
+
+// quoteArg quotes an argument in a way that it is kept as a single argument when the options are split into arguments
+func quoteArg(arg string) string {
+	return "'" + strings.Replace(arg, "'", `'\''`, -1) + "'"
+}
+
+func reportXsDeployTargets(results []xsDeployTargetResult, stdout io.Writer) error {
+	failed := []string{}
+
+	log.Entry().Info("Deployment summary:")
+	for _, result := range results {
+		if len(result.Error) > 0 {
+			log.Entry().Infof("  %s: %s (%s)", result.Name, result.Status, result.Error)
+		} else {
+			log.Entry().Infof("  %s: %s", result.Name, result.Status)
+		}
+		if result.Status == xsTargetFailed {
+			failed = append(failed, result.Name)
+		}
+	}
+
+	var err error
+	if len(failed) > 0 {
+		err = fmt.Errorf("Deployment failed for %d of %d target(s): %s", len(failed), len(results), strings.Join(failed, ", "))
+	}
+
+	b, e := json.Marshal(struct {
+		Targets []xsDeployTargetResult `json:"targets"`
+	}{Targets: results})
+	if e != nil {
+		if err == nil {
+			err = e
+		}
+		return err
+	}
+	fmt.Fprintln(stdout, string(b))
+
+	return err
+}
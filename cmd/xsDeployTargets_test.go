@@ -0,0 +1,269 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/SAP/jenkins-library/pkg/command"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestXsDeployTargets(t *testing.T) {
+
+	myXsDeployOptions := xsDeployOptions{
+		User:                  "me",
+		Password:              "secretPassword",
+		LoginOpts:             "--skip-ssl-validation",
+		DeployOpts:            "--dummy-deploy-opts",
+		Mode:                  "DEPLOY",
+		Action:                "NONE",
+		MtaPath:               "dummy.mtar",
+		OperationIDLogPattern: `^.*xs bg-deploy -i (.*) -a.*$`,
+		StopOnFirstFailure:    true,
+		Targets: []map[string]interface{}{
+			{"apiUrl": "https://eu.example.org:30030", "org": "myOrg", "space": "dev", "extensionDescriptors": []interface{}{"eu.mtaext", "dev's.mtaext"}},
+			{"name": "us", "apiUrl": "https://us.example.org:30030", "org": "myOrg", "space": "dev", "credentialsId": "usCredentials"},
+			{"name": "asia", "apiUrl": "https://asia.example.org:30030", "org": "myOrg", "space": "dev"},
+		},
+	}
+
+	var mutex sync.Mutex
+	var deployed []xsDeployOptions
+	var sessions []xsSession
+	var createdDirs []string
+	failingTargets := map[string]bool{}
+
+	deployTarget := func(targetOptions xsDeployOptions, session xsSession, targetEnvironment *xsDeployCommonPipelineEnvironment) error {
+		mutex.Lock()
+		defer mutex.Unlock()
+		deployed = append(deployed, targetOptions)
+		sessions = append(sessions, session)
+		if failingTargets[targetOptions.APIURL] {
+			return errors.New("deployment failed")
+		}
+		return nil
+	}
+
+	fMkdirAll := func(path string, perm os.FileMode) error {
+		mutex.Lock()
+		defer mutex.Unlock()
+		createdDirs = append(createdDirs, path)
+		return nil
+	}
+
+	var removedDirs []string
+	fRemoveAll := func(path string) error {
+		mutex.Lock()
+		defer mutex.Unlock()
+		removedDirs = append(removedDirs, path)
+		return nil
+	}
+
+	environment := map[string]string{
+		"PIPER_XS_TARGET_2_USERNAME": "other",
+		"PIPER_XS_TARGET_2_PASSWORD": "otherPassword",
+	}
+	fGetenv := func(name string) string { return environment[name] }
+
+	var rendered []mtaExtension
+	var renderedFiles []string
+	render := func(extension mtaExtension, idSuffix, file string) error {
+		mutex.Lock()
+		defer mutex.Unlock()
+		rendered = append(rendered, extension)
+		renderedFiles = append(renderedFiles, file)
+		return nil
+	}
+
+	reset := func() {
+		deployed, sessions, createdDirs, removedDirs = nil, nil, nil, nil
+		rendered, renderedFiles = nil, nil
+		failingTargets = map[string]bool{}
+	}
+
+	t.Run("sequential deployment succeeds", func(t *testing.T) {
+		defer reset()
+
+		piperEnvironment := xsDeployCommonPipelineEnvironment{}
+		stdout := new(bytes.Buffer)
+
+		err := runXsDeployTargets(myXsDeployOptions, &piperEnvironment, deployTarget, render, fMkdirAll, fRemoveAll, fGetenv, stdout)
+
+		if assert.NoError(t, err) && assert.Len(t, deployed, 3) {
+			assert.Equal(t, "https://eu.example.org:30030", deployed[0].APIURL)
+			assert.Equal(t, "me", deployed[0].User)
+			assert.Equal(t, "secretPassword", deployed[0].Password)
+			assert.Nil(t, deployed[0].Targets)
+
+			deployOpts, err := command.ParseArgs(deployed[0].DeployOpts)
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"--dummy-deploy-opts", "-e", "eu.mtaext,dev's.mtaext"}, deployOpts)
+
+			assert.Equal(t, "other", deployed[1].User)
+			assert.Equal(t, "otherPassword", deployed[1].Password)
+			assert.Equal(t, "--dummy-deploy-opts", deployed[1].DeployOpts)
+
+			assert.Equal(t, filepath.Join(xsTargetsDir, "myOrg-dev"), sessions[0].workDir)
+			assert.Equal(t, filepath.Join(xsTargetsDir, "us"), sessions[1].workDir)
+			assert.True(t, filepath.IsAbs(sessions[2].homeDir))
+			assert.NotEqual(t, sessions[1].homeDir, sessions[2].homeDir)
+			assert.Len(t, createdDirs, 3)
+			assert.Equal(t, createdDirs, removedDirs, "home directories with xs sessions expected to be removed")
+		}
+
+		result := struct {
+			Targets []xsDeployTargetResult `json:"targets"`
+		}{}
+		if assert.NoError(t, json.Unmarshal(stdout.Bytes(), &result)) && assert.Len(t, result.Targets, 3) {
+			assert.Equal(t, xsDeployTargetResult{Name: "us", APIURL: "https://us.example.org:30030", Org: "myOrg", Space: "dev", Status: xsTargetSucceeded}, result.Targets[1])
+		}
+		assert.NotContains(t, stdout.String(), "Password")
+		assert.Equal(t, "DEPLOY", piperEnvironment.xsDeploy.mode)
+	})
+
+	t.Run("sequential deployment stops on first failure", func(t *testing.T) {
+		defer reset()
+		failingTargets["https://us.example.org:30030"] = true
+
+		stdout := new(bytes.Buffer)
+		err := runXsDeployTargets(myXsDeployOptions, &xsDeployCommonPipelineEnvironment{}, deployTarget, render, fMkdirAll, fRemoveAll, fGetenv, stdout)
+
+		assert.EqualError(t, err, "Deployment failed for 1 of 3 target(s): us")
+		assert.Len(t, deployed, 2)
+		assert.Len(t, removedDirs, 2, "home directory expected to be removed also in case of failure")
+		assert.Contains(t, stdout.String(), `{"name":"us","apiUrl":"https://us.example.org:30030","org":"myOrg","space":"dev","status":"FAILURE","error":"deployment failed"}`)
+		assert.Contains(t, stdout.String(), `"name":"asia","apiUrl":"https://asia.example.org:30030","org":"myOrg","space":"dev","status":"SKIPPED"`)
+	})
+
+	t.Run("sequential deployment continues after failure", func(t *testing.T) {
+		defer reset()
+		failingTargets["https://eu.example.org:30030"] = true
+		failingTargets["https://us.example.org:30030"] = true
+
+		options := myXsDeployOptions
+		options.StopOnFirstFailure = false
+
+		err := runXsDeployTargets(options, &xsDeployCommonPipelineEnvironment{}, deployTarget, render, fMkdirAll, fRemoveAll, fGetenv, new(bytes.Buffer))
+
+		assert.EqualError(t, err, "Deployment failed for 2 of 3 target(s): myOrg-dev, us")
+		assert.Len(t, deployed, 3)
+	})
+
+	t.Run("parallel deployment", func(t *testing.T) {
+		defer reset()
+		failingTargets["https://eu.example.org:30030"] = true
+
+		options := myXsDeployOptions
+		options.ParallelTargets = true
+
+		err := runXsDeployTargets(options, &xsDeployCommonPipelineEnvironment{}, deployTarget, render, fMkdirAll, fRemoveAll, fGetenv, new(bytes.Buffer))
+
+		assert.EqualError(t, err, "Deployment failed for 1 of 3 target(s): myOrg-dev")
+		urls := []string{}
+		for _, d := range deployed {
+			urls = append(urls, d.APIURL)
+		}
+		sort.Strings(urls)
+		assert.Equal(t, []string{"https://asia.example.org:30030", "https://eu.example.org:30030", "https://us.example.org:30030"}, urls)
+	})
+
+	t.Run("home directory cannot be created", func(t *testing.T) {
+		defer reset()
+
+		err := runXsDeployTargets(myXsDeployOptions, &xsDeployCommonPipelineEnvironment{}, deployTarget, render,
+			func(string, os.FileMode) error { return errors.New("permission denied") }, fRemoveAll, fGetenv, new(bytes.Buffer))
+
+		assert.EqualError(t, err, "Deployment failed for 1 of 3 target(s): myOrg-dev")
+		assert.Empty(t, deployed)
+	})
+
+	t.Run("blue-green deployment requires auto complete", func(t *testing.T) {
+		defer reset()
+
+		options := myXsDeployOptions
+		options.Mode = "BG_DEPLOY"
+
+		err := runXsDeployTargets(options, &xsDeployCommonPipelineEnvironment{}, deployTarget, render, fMkdirAll, fRemoveAll, fGetenv, new(bytes.Buffer))
+		assert.EqualError(t, err, "Cannot perform action 'NONE' in mode 'BG_DEPLOY' for multiple targets. Blue-green deployments to multiple targets are only supported with 'autoComplete'.")
+
+		options.AutoComplete = true
+		err = runXsDeployTargets(options, &xsDeployCommonPipelineEnvironment{}, deployTarget, render, fMkdirAll, fRemoveAll, fGetenv, new(bytes.Buffer))
+		assert.NoError(t, err)
+		assert.Len(t, deployed, 3)
+	})
+
+	t.Run("MTA extensions", func(t *testing.T) {
+		defer reset()
+
+		options := myXsDeployOptions
+		options.MtaExtension = map[string]interface{}{
+			"parameters": map[string]interface{}{"instances": 1},
+			"modules":    []interface{}{map[string]interface{}{"name": "srv", "properties": map[string]interface{}{"LOG_LEVEL": "info"}}},
+		}
+		options.Targets = []map[string]interface{}{
+			{"name": "eu", "apiUrl": "https://eu.example.org:30030", "org": "myOrg", "space": "dev", "extensionDescriptors": []interface{}{"eu.mtaext"},
+				"mtaExtension": map[string]interface{}{
+					"modules": []interface{}{map[string]interface{}{"name": "srv", "properties": map[string]interface{}{"REGION": "eu"}}},
+				}},
+			{"name": "us", "apiUrl": "https://us.example.org:30030", "org": "myOrg", "space": "dev"},
+		}
+
+		err := runXsDeployTargets(options, &xsDeployCommonPipelineEnvironment{}, deployTarget, render, fMkdirAll, fRemoveAll, fGetenv, new(bytes.Buffer))
+
+		assert.NoError(t, err)
+		if assert.Len(t, rendered, 2) && assert.Len(t, deployed, 2) {
+			assert.Equal(t, []string{filepath.Join(xsTargetsDir, "eu", xsExtensionFile), filepath.Join(xsTargetsDir, "us", xsExtensionFile)}, renderedFiles)
+			assert.Equal(t, []mtaExtensionEntry{{Name: "srv", Properties: map[string]interface{}{"LOG_LEVEL": "info", "REGION": "eu"}}}, rendered[0].Modules)
+			assert.Equal(t, []mtaExtensionEntry{{Name: "srv", Properties: map[string]interface{}{"LOG_LEVEL": "info"}}}, rendered[1].Modules)
+
+			deployOpts, err := command.ParseArgs(deployed[0].DeployOpts)
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"--dummy-deploy-opts", "-e", filepath.Join(xsTargetsDir, "eu", xsExtensionFile) + ",eu.mtaext"}, deployOpts)
+			assert.Nil(t, deployed[0].MtaExtension)
+		}
+	})
+
+	t.Run("invalid targets", func(t *testing.T) {
+		defer reset()
+
+		options := myXsDeployOptions
+		options.Targets = []map[string]interface{}{
+			{"apiUrl": "https://eu.example.org:30030", "org": "myOrg"},
+		}
+		err := runXsDeployTargets(options, &xsDeployCommonPipelineEnvironment{}, deployTarget, render, fMkdirAll, fRemoveAll, fGetenv, new(bytes.Buffer))
+		assert.EqualError(t, err, "Target #1 is incomplete. Missing parameter(s): space")
+
+		options.Targets = []map[string]interface{}{
+			{"apiUrl": "https://eu.example.org:30030", "org": "myOrg", "space": "dev"},
+			{"apiUrl": "https://us.example.org:30030", "org": "myOrg", "space": "dev"},
+		}
+		err = runXsDeployTargets(options, &xsDeployCommonPipelineEnvironment{}, deployTarget, render, fMkdirAll, fRemoveAll, fGetenv, new(bytes.Buffer))
+		assert.EqualError(t, err, "Target name 'myOrg-dev' is not unique. Provide a distinct 'name' for each target.")
+
+		options.Targets = []map[string]interface{}{
+			{"apiUrl": "https://eu.example.org:30030", "org": "myOrg", "space": "dev", "extensionDescriptors": "eu.mtaext"},
+		}
+		err = runXsDeployTargets(options, &xsDeployCommonPipelineEnvironment{}, deployTarget, render, fMkdirAll, fRemoveAll, fGetenv, new(bytes.Buffer))
+		assert.Contains(t, err.Error(), "Invalid target #1")
+
+		options.Targets = []map[string]interface{}{
+			{"apiUrl": "https://eu.example.org:30030", "org": "myOrg", "space": "dev", "user": "me", "password": "secret"},
+		}
+		err = runXsDeployTargets(options, &xsDeployCommonPipelineEnvironment{}, deployTarget, render, fMkdirAll, fRemoveAll, fGetenv, new(bytes.Buffer))
+		assert.EqualError(t, err, "Target #1 contains 'user'. Provide the credentials of a target via 'credentialsId'.")
+
+		options.Targets = []map[string]interface{}{
+			{"apiUrl": "https://eu.example.org:30030", "org": "myOrg", "space": "dev", "credentialsId": "euCredentials"},
+		}
+		err = runXsDeployTargets(options, &xsDeployCommonPipelineEnvironment{}, deployTarget, render, fMkdirAll, fRemoveAll, fGetenv, new(bytes.Buffer))
+		assert.EqualError(t, err, "Credentials 'euCredentials' of target #1 are not available. Provide them via the environment variables 'PIPER_XS_TARGET_1_USERNAME' and 'PIPER_XS_TARGET_1_PASSWORD'.")
+
+		assert.Empty(t, deployed)
+	})
+}
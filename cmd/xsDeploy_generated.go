@@ -3,26 +3,74 @@ package cmd
 import (
 	"os"
 
+	"path/filepath"
+
 	"github.com/SAP/jenkins-library/pkg/config"
 	"github.com/SAP/jenkins-library/pkg/log"
-
+	"github.com/SAP/jenkins-library/pkg/piperenv"
 	"github.com/spf13/cobra"
 )
 
 type xsDeployOptions struct {
-	DeployOpts            string `json:"deployOpts,omitempty"`
-	OperationIDLogPattern string `json:"operationIdLogPattern,omitempty"`
-	MtaPath               string `json:"mtaPath,omitempty"`
-	Action                string `json:"action,omitempty"`
-	Mode                  string `json:"mode,omitempty"`
-	OperationID           string `json:"operationId,omitempty"`
-	APIURL                string `json:"apiUrl,omitempty"`
-	User                  string `json:"user,omitempty"`
-	Password              string `json:"password,omitempty"`
-	Org                   string `json:"org,omitempty"`
-	Space                 string `json:"space,omitempty"`
-	LoginOpts             string `json:"loginOpts,omitempty"`
-	XsSessionFile         string `json:"xsSessionFile,omitempty"`
+	DeployOpts            string                   `json:"deployOpts,omitempty"`
+	OperationIDLogPattern string                   `json:"operationIdLogPattern,omitempty"`
+	MtaPath               string                   `json:"mtaPath,omitempty"`
+	Action                string                   `json:"action,omitempty"`
+	AutoComplete          bool                     `json:"autoComplete,omitempty"`
+	HealthCheckURLs       []string                 `json:"healthCheckUrls,omitempty"`
+	HealthCheckPath       string                   `json:"healthCheckPath,omitempty"`
+	HealthCheckTimeout    string                   `json:"healthCheckTimeout,omitempty"`
+	IdleRouteLogPattern   string                   `json:"idleRouteLogPattern,omitempty"`
+	Mode                  string                   `json:"mode,omitempty"`
+	OperationID           string                   `json:"operationId,omitempty"`
+	APIURL                string                   `json:"apiUrl,omitempty"`
+	User                  string                   `json:"user,omitempty"`
+	Password              string                   `json:"password,omitempty"`
+	Org                   string                   `json:"org,omitempty"`
+	Space                 string                   `json:"space,omitempty"`
+	LoginOpts             string                   `json:"loginOpts,omitempty"`
+	XsSessionFile         string                   `json:"xsSessionFile,omitempty"`
+	MtaExtension          map[string]interface{}   `json:"mtaExtension,omitempty"`
+	MtaDescriptorPath     string                   `json:"mtaDescriptorPath,omitempty"`
+	Targets               []map[string]interface{} `json:"targets,omitempty"`
+	ParallelTargets       bool                     `json:"parallelTargets,omitempty"`
+	StopOnFirstFailure    bool                     `json:"stopOnFirstFailure,omitempty"`
+}
+
+type xsDeployCommonPipelineEnvironment struct {
+	xsDeploy struct {
+		operationID string
+		mode        string
+		APIURL      string
+		org         string
+		space       string
+	}
+}
+
+func (p *xsDeployCommonPipelineEnvironment) persist(path, resourceName string) {
+	content := []struct {
+		category string
+		name     string
+		value    string
+	}{
+		{category: "xsDeploy", name: "operationId", value: p.xsDeploy.operationID},
+		{category: "xsDeploy", name: "mode", value: p.xsDeploy.mode},
+		{category: "xsDeploy", name: "apiUrl", value: p.xsDeploy.APIURL},
+		{category: "xsDeploy", name: "org", value: p.xsDeploy.org},
+		{category: "xsDeploy", name: "space", value: p.xsDeploy.space},
+	}
+
+	errCount := 0
+	for _, param := range content {
+		err := piperenv.SetResourceParameter(path, resourceName, filepath.Join(param.category, param.name), param.value)
+		if err != nil {
+			log.Entry().WithError(err).Error("Error persisting piper environment.")
+			errCount++
+		}
+	}
+	if errCount > 0 {
+		os.Exit(1)
+	}
 }
 
 var myXsDeployOptions xsDeployOptions
@@ -30,6 +78,7 @@ var myXsDeployOptions xsDeployOptions
 // XsDeployCommand Performs xs deployment
 func XsDeployCommand() *cobra.Command {
 	metadata := xsDeployMetadata()
+	var commonPipelineEnvironment xsDeployCommonPipelineEnvironment
 
 	var createXsDeployCmd = &cobra.Command{
 		Use:   "xsDeploy",
@@ -41,8 +90,12 @@ func XsDeployCommand() *cobra.Command {
 			return PrepareConfig(cmd, &metadata, "xsDeploy", &myXsDeployOptions, config.OpenPiperFile)
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-
-			return xsDeploy(myXsDeployOptions)
+			handler := func() {
+				commonPipelineEnvironment.persist(GeneralConfig.EnvRootPath, "commonPipelineEnvironment")
+			}
+			log.DeferExitHandler(handler)
+			defer handler()
+			return xsDeploy(myXsDeployOptions, &commonPipelineEnvironment)
 		},
 	}
 
@@ -51,27 +104,30 @@ func XsDeployCommand() *cobra.Command {
 }
 
 func addXsDeployFlags(cmd *cobra.Command) {
-	cmd.Flags().StringVar(&myXsDeployOptions.DeployOpts, "deployOpts", os.Getenv("PIPER_deployOpts"), "Additional options appended to the deploy command. Only needed for sophisticated cases. When provided it is the duty of the provider to ensure proper quoting / escaping.")
+	cmd.Flags().StringVar(&myXsDeployOptions.DeployOpts, "deployOpts", os.Getenv("PIPER_deployOpts"), "Additional options appended to the deploy command. Only needed for sophisticated cases. The options are split into separate arguments like a shell would do, hence values containing blanks need to be quoted.")
 	cmd.Flags().StringVar(&myXsDeployOptions.OperationIDLogPattern, "operationIdLogPattern", "^.*xs bg-deploy -i (.*) -a.*$", "Regex pattern for retrieving the ID of the operation from the xs log.")
 	cmd.Flags().StringVar(&myXsDeployOptions.MtaPath, "mtaPath", os.Getenv("PIPER_mtaPath"), "Path to deployable")
 	cmd.Flags().StringVar(&myXsDeployOptions.Action, "action", "NONE", "Used for finalizing the blue-green deployment.")
-	cmd.Flags().StringVar(&myXsDeployOptions.Mode, "mode", "DEPLOY", "Controls if there is a standard deployment or a blue green deployment. Values: 'DEPLOY', 'BG_DEPLOY'")
+	cmd.Flags().BoolVar(&myXsDeployOptions.AutoComplete, "autoComplete", false, "Only relevant in mode 'BG_DEPLOY'. When set to `true` the idle routes of the new deployment are verified via health checks directly after the deployment. In case all checks succeed the deployment is resumed, otherwise it is aborted.")
+	cmd.Flags().StringSliceVar(&myXsDeployOptions.HealthCheckURLs, "healthCheckUrls", []string{}, "Only relevant in case `autoComplete` is active. The urls of the idle routes to be checked. In case no urls are provided the urls are retrieved from the xs log via `idleRouteLogPattern`.")
+	cmd.Flags().StringVar(&myXsDeployOptions.HealthCheckPath, "healthCheckPath", os.Getenv("PIPER_healthCheckPath"), "Only relevant in case `autoComplete` is active. Path appended to the idle routes retrieved from the xs log, e.g. `/health`.")
+	cmd.Flags().StringVar(&myXsDeployOptions.HealthCheckTimeout, "healthCheckTimeout", "5m", "Only relevant in case `autoComplete` is active. Maximum time to wait for the idle routes to become healthy, e.g. `5m` or `90s`.")
+	cmd.Flags().StringVar(&myXsDeployOptions.IdleRouteLogPattern, "idleRouteLogPattern", "^.*Application \".*\" started and available at \"(.*)\".*$", "Regex pattern for retrieving the urls of the idle routes from the xs log.")
+	cmd.Flags().StringVar(&myXsDeployOptions.Mode, "mode", "DEPLOY", "Controls if there is a standard deployment or a blue green deployment. Values: 'DEPLOY', 'BG_DEPLOY'. For the actions 'RESUME' and 'ABORT' the mode of the deployment started before is taken from the common pipeline environment in case the mode is not configured or set to the default 'DEPLOY'.")
 	cmd.Flags().StringVar(&myXsDeployOptions.OperationID, "operationId", os.Getenv("PIPER_operationId"), "The operation ID. Used in case of bg-deploy in order to resume or abort a previously started deployment.")
-	cmd.Flags().StringVar(&myXsDeployOptions.APIURL, "apiUrl", os.Getenv("PIPER_apiUrl"), "The api url (e.g. https://example.org:12345")
-	cmd.Flags().StringVar(&myXsDeployOptions.User, "user", os.Getenv("PIPER_user"), "User")
-	cmd.Flags().StringVar(&myXsDeployOptions.Password, "password", os.Getenv("PIPER_password"), "Password")
-	cmd.Flags().StringVar(&myXsDeployOptions.Org, "org", os.Getenv("PIPER_org"), "The org")
-	cmd.Flags().StringVar(&myXsDeployOptions.Space, "space", os.Getenv("PIPER_space"), "The space")
-	cmd.Flags().StringVar(&myXsDeployOptions.LoginOpts, "loginOpts", os.Getenv("PIPER_loginOpts"), "Additional options appended to the login command. Only needed for sophisticated cases. When provided it is the duty of the provider to ensure proper quoting / escaping.")
+	cmd.Flags().StringVar(&myXsDeployOptions.APIURL, "apiUrl", os.Getenv("PIPER_apiUrl"), "The api url (e.g. https://example.org:12345). Mandatory in case no `targets` are provided. For the actions `RESUME` and `ABORT` the value of the deployment started before is taken from the common pipeline environment in case it is not configured.")
+	cmd.Flags().StringVar(&myXsDeployOptions.User, "user", os.Getenv("PIPER_user"), "User. Mandatory in case no `targets` are provided. Used as fallback for targets without `credentialsId`.")
+	cmd.Flags().StringVar(&myXsDeployOptions.Password, "password", os.Getenv("PIPER_password"), "Password. Mandatory in case no `targets` are provided. Used as fallback for targets without `credentialsId`. It is entered at the password prompt of `xs login` via stdin, hence it does not appear on the command line of the xs client.")
+	cmd.Flags().StringVar(&myXsDeployOptions.Org, "org", os.Getenv("PIPER_org"), "The org. Mandatory in case no `targets` are provided. For the actions `RESUME` and `ABORT` the value of the deployment started before is taken from the common pipeline environment in case it is not configured.")
+	cmd.Flags().StringVar(&myXsDeployOptions.Space, "space", os.Getenv("PIPER_space"), "The space. Mandatory in case no `targets` are provided. For the actions `RESUME` and `ABORT` the value of the deployment started before is taken from the common pipeline environment in case it is not configured.")
+	cmd.Flags().StringVar(&myXsDeployOptions.LoginOpts, "loginOpts", os.Getenv("PIPER_loginOpts"), "Additional options appended to the login command. Only needed for sophisticated cases. The options are split into separate arguments like a shell would do, hence values containing blanks need to be quoted. The password must not be provided via these options.")
 	cmd.Flags().StringVar(&myXsDeployOptions.XsSessionFile, "xsSessionFile", os.Getenv("PIPER_xsSessionFile"), "The file keeping the xs session.")
+	cmd.Flags().StringVar(&myXsDeployOptions.MtaDescriptorPath, "mtaDescriptorPath", "mta.yaml", "Only relevant in case an `mtaExtension` is provided. Path to the MTA descriptor the extension is validated against.")
+	cmd.Flags().BoolVar(&myXsDeployOptions.ParallelTargets, "parallelTargets", false, "Only relevant in case `targets` are provided. When set to `true` the deployments to all targets are performed in parallel, otherwise one after another.")
+	cmd.Flags().BoolVar(&myXsDeployOptions.StopOnFirstFailure, "stopOnFirstFailure", true, "Only relevant in case `targets` are provided and `parallelTargets` is not active. When set to `true` the remaining targets are skipped after the first failed deployment.")
 
 	cmd.MarkFlagRequired("mtaPath")
 	cmd.MarkFlagRequired("mode")
-	cmd.MarkFlagRequired("apiUrl")
-	cmd.MarkFlagRequired("user")
-	cmd.MarkFlagRequired("password")
-	cmd.MarkFlagRequired("org")
-	cmd.MarkFlagRequired("space")
 	cmd.MarkFlagRequired("loginOpts")
 }
 
@@ -113,6 +169,46 @@ func xsDeployMetadata() config.StepData {
 						Mandatory:   false,
 						Aliases:     []config.Alias{},
 					},
+					{
+						Name:        "autoComplete",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "bool",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "healthCheckUrls",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "[]string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "healthCheckPath",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "healthCheckTimeout",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "idleRouteLogPattern",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
 					{
 						Name:        "mode",
 						ResourceRef: []config.ResourceReference{},
@@ -123,7 +219,7 @@ func xsDeployMetadata() config.StepData {
 					},
 					{
 						Name:        "operationId",
-						ResourceRef: []config.ResourceReference{},
+						ResourceRef: []config.ResourceReference{{Name: "commonPipelineEnvironment", Param: "xsDeploy/operationId"}},
 						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
 						Type:        "string",
 						Mandatory:   false,
@@ -134,7 +230,7 @@ func xsDeployMetadata() config.StepData {
 						ResourceRef: []config.ResourceReference{},
 						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
 						Type:        "string",
-						Mandatory:   true,
+						Mandatory:   false,
 						Aliases:     []config.Alias{},
 					},
 					{
@@ -142,7 +238,7 @@ func xsDeployMetadata() config.StepData {
 						ResourceRef: []config.ResourceReference{},
 						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
 						Type:        "string",
-						Mandatory:   true,
+						Mandatory:   false,
 						Aliases:     []config.Alias{},
 					},
 					{
@@ -150,7 +246,7 @@ func xsDeployMetadata() config.StepData {
 						ResourceRef: []config.ResourceReference{},
 						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
 						Type:        "string",
-						Mandatory:   true,
+						Mandatory:   false,
 						Aliases:     []config.Alias{},
 					},
 					{
@@ -158,7 +254,7 @@ func xsDeployMetadata() config.StepData {
 						ResourceRef: []config.ResourceReference{},
 						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
 						Type:        "string",
-						Mandatory:   true,
+						Mandatory:   false,
 						Aliases:     []config.Alias{},
 					},
 					{
@@ -166,7 +262,7 @@ func xsDeployMetadata() config.StepData {
 						ResourceRef: []config.ResourceReference{},
 						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
 						Type:        "string",
-						Mandatory:   true,
+						Mandatory:   false,
 						Aliases:     []config.Alias{},
 					},
 					{
@@ -185,6 +281,46 @@ func xsDeployMetadata() config.StepData {
 						Mandatory:   false,
 						Aliases:     []config.Alias{},
 					},
+					{
+						Name:        "mtaExtension",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "map[string]interface{}",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "mtaDescriptorPath",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "string",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "targets",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "[]map[string]interface{}",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "parallelTargets",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "bool",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
+					{
+						Name:        "stopOnFirstFailure",
+						ResourceRef: []config.ResourceReference{},
+						Scope:       []string{"PARAMETERS", "STAGES", "STEPS"},
+						Type:        "bool",
+						Mandatory:   false,
+						Aliases:     []config.Alias{},
+					},
 				},
 			},
 		},
@@ -4,12 +4,17 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	piperhttp "github.com/SAP/jenkins-library/pkg/http"
 	"github.com/stretchr/testify/assert"
 	"io"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
 	"strings"
 	"sync"
 	"testing"
+	"time"
 )
 
 func TestDeploy(t *testing.T) {
@@ -28,7 +33,7 @@ func TestDeploy(t *testing.T) {
 		OperationIDLogPattern: `^.*xs bg-deploy -i (.*) -a.*$`,
 	}
 
-	s := shellMockRunner{}
+	s := execMockRunner{}
 
 	var copiedFiles []string
 	var removedFiles []string
@@ -47,6 +52,20 @@ func TestDeploy(t *testing.T) {
 		return nil
 	}
 
+	piperEnvironment := xsDeployCommonPipelineEnvironment{}
+	session := xsSession{homeDir: "/home/me", workDir: "."}
+
+	var verifiedURLs []string
+	var verifyErr error
+	fVerify := func(urls []string, timeout time.Duration) ([]healthCheckResult, error) {
+		verifiedURLs = append(verifiedURLs, urls...)
+		results := []healthCheckResult{}
+		for _, url := range urls {
+			results = append(results, healthCheckResult{URL: url, StatusCode: 200, Err: verifyErr})
+		}
+		return results, verifyErr
+	}
+
 	var stdout string
 
 	t.Run("Standard deploy succeeds", func(t *testing.T) {
@@ -70,7 +89,7 @@ func TestDeploy(t *testing.T) {
 			wg.Done()
 		}()
 
-		e := runXsDeploy(myXsDeployOptions, &s, fExists, fCopy, fRemove, wStdout)
+		e := runXsDeploy(myXsDeployOptions, &piperEnvironment, session, &s, fExists, fCopy, fRemove, fVerify, wStdout)
 
 		wStdout.Close()
 		wg.Wait()
@@ -78,11 +97,11 @@ func TestDeploy(t *testing.T) {
 		checkErr(t, e, "")
 
 		t.Run("Standard checks", func(t *testing.T) {
-			// Contains --> we do not check for the shebang
-			assert.Contains(t, s.calls[0], "xs login -a https://example.org:12345 -u me -p 'secretPassword' -o myOrg -s mySpace --skip-ssl-validation")
-			assert.Contains(t, s.calls[1], "xs deploy dummy.mtar --dummy-deploy-opts")
-			assert.Contains(t, s.calls[2], "xs logout")
-			assert.Len(t, s.calls, 3)
+			assert.Equal(t, []execCall{
+				{exec: "xs", params: []string{"login", "-a", "https://example.org:12345", "-u", "me", "-o", "myOrg", "-s", "mySpace", "--skip-ssl-validation"}},
+				{exec: "xs", params: []string{"deploy", "dummy.mtar", "--dummy-deploy-opts"}},
+				{exec: "xs", params: []string{"logout"}},
+			}, s.calls)
 
 			// xs session file needs to be removed at end during a normal deployment
 			assert.Len(t, removedFiles, 1)
@@ -101,6 +120,13 @@ func TestDeploy(t *testing.T) {
 		t.Run("Password not exposed", func(t *testing.T) {
 			assert.NotEmpty(t, stdout)
 			assert.NotContains(t, stdout, myXsDeployOptions.Password)
+			for _, call := range s.calls {
+				assert.NotContains(t, call.params, myXsDeployOptions.Password)
+			}
+			// password is provided via stdin for the login only
+			assert.Contains(t, s.stdinContent, myXsDeployOptions.Password+"\n")
+			assert.Nil(t, s.stdin)
+			assert.Empty(t, s.env)
 		})
 	})
 
@@ -121,7 +147,7 @@ func TestDeploy(t *testing.T) {
 		// this file is not denoted in the file exists mock
 		myXsDeployOptions.MtaPath = "doesNotExist"
 
-		e := runXsDeploy(myXsDeployOptions, &s, fExists, fCopy, fRemove, ioutil.Discard)
+		e := runXsDeploy(myXsDeployOptions, &piperEnvironment, session, &s, fExists, fCopy, fRemove, fVerify, ioutil.Discard)
 		checkErr(t, e, "Deployable 'doesNotExist' does not exist")
 	})
 
@@ -138,7 +164,7 @@ func TestDeploy(t *testing.T) {
 			myXsDeployOptions.Action = "NONE"
 		}()
 
-		e := runXsDeploy(myXsDeployOptions, &s, fExists, fCopy, fRemove, ioutil.Discard)
+		e := runXsDeploy(myXsDeployOptions, &piperEnvironment, session, &s, fExists, fCopy, fRemove, fVerify, ioutil.Discard)
 		checkErr(t, e, "Cannot perform action 'RETRY' in mode 'DEPLOY'. Only action 'NONE' is allowed.")
 	})
 
@@ -153,7 +179,7 @@ func TestDeploy(t *testing.T) {
 
 		s.shouldFailWith = errors.New("Error from underlying process")
 
-		e := runXsDeploy(myXsDeployOptions, &s, fExists, fCopy, fRemove, ioutil.Discard)
+		e := runXsDeploy(myXsDeployOptions, &piperEnvironment, session, &s, fExists, fCopy, fRemove, fVerify, ioutil.Discard)
 		checkErr(t, e, "Error from underlying process")
 	})
 
@@ -173,12 +199,27 @@ func TestDeploy(t *testing.T) {
 
 		myXsDeployOptions.Mode = "BG_DEPLOY"
 
-		e := runXsDeploy(myXsDeployOptions, &s, fExists, fCopy, fRemove, ioutil.Discard)
+		s.stdoutReturn = map[string]string{
+			"xs bg-deploy": "Process has entered validation phase.\nUse \"xs bg-deploy -i 1234 -a resume\" to resume the process.\n",
+		}
+		defer func() { s.stdoutReturn = nil }()
+
+		stdout := new(bytes.Buffer)
+		e := runXsDeploy(myXsDeployOptions, &piperEnvironment, session, &s, fExists, fCopy, fRemove, fVerify, stdout)
 		checkErr(t, e, "")
 
-		assert.Contains(t, s.calls[0], "xs login")
-		assert.Contains(t, s.calls[1], "xs bg-deploy dummy.mtar --dummy-deploy-opts")
+		assert.Equal(t, "login", s.calls[0].params[0])
+		assert.Equal(t, []string{"bg-deploy", "dummy.mtar", "--dummy-deploy-opts"}, s.calls[1].params)
 		assert.Len(t, s.calls, 2) // There are two entries --> no logout in this case.
+		assert.Contains(t, stdout.String(), `"operationId":"1234"`)
+		assert.Equal(t, xsOutputLines, s.tailLines, "output tail expected to be logged by the command in case of failures")
+
+		// the operation id is provided to subsequent resume or abort calls
+		assert.Equal(t, "1234", piperEnvironment.xsDeploy.operationID)
+		assert.Equal(t, "BG_DEPLOY", piperEnvironment.xsDeploy.mode)
+		assert.Equal(t, "https://example.org:12345", piperEnvironment.xsDeploy.APIURL)
+		assert.Equal(t, "myOrg", piperEnvironment.xsDeploy.org)
+		assert.Equal(t, "mySpace", piperEnvironment.xsDeploy.space)
 	})
 
 	t.Run("BG deploy abort succeeds", func(t *testing.T) {
@@ -202,12 +243,231 @@ func TestDeploy(t *testing.T) {
 		myXsDeployOptions.Action = "ABORT"
 		myXsDeployOptions.OperationID = "12345"
 
-		e := runXsDeploy(myXsDeployOptions, &s, fExists, fCopy, fRemove, ioutil.Discard)
+		e := runXsDeploy(myXsDeployOptions, &piperEnvironment, session, &s, fExists, fCopy, fRemove, fVerify, ioutil.Discard)
 		checkErr(t, e, "")
 
-		assert.Contains(t, s.calls[0], "xs bg-deploy -i 12345 -a abort")
-		assert.Contains(t, s.calls[1], "xs logout")
+		assert.Equal(t, execCall{exec: "xs", params: []string{"bg-deploy", "-i", "12345", "-a", "abort"}}, s.calls[0])
+		assert.Equal(t, execCall{exec: "xs", params: []string{"logout"}}, s.calls[1])
 		assert.Len(t, s.calls, 2) // There is no login --> we have two calls
+
+		// the operation is completed and must not be used anymore
+		assert.Empty(t, piperEnvironment.xsDeploy.operationID)
+	})
+
+	t.Run("BG deploy abort fails, operation id kept", func(t *testing.T) {
+
+		oldOptions := myXsDeployOptions
+		defer func() {
+			myXsDeployOptions = oldOptions
+			copiedFiles = nil
+			removedFiles = nil
+			s.calls = nil
+			s.shouldFailWith = nil
+		}()
+
+		myXsDeployOptions.Mode = "BG_DEPLOY"
+		myXsDeployOptions.Action = "ABORT"
+		myXsDeployOptions.OperationID = "12345"
+		s.shouldFailWith = errors.New("Error from underlying process")
+
+		e := runXsDeploy(myXsDeployOptions, &piperEnvironment, session, &s, fExists, fCopy, fRemove, fVerify, ioutil.Discard)
+		checkErr(t, e, "Error from underlying process")
+
+		assert.Equal(t, "12345", piperEnvironment.xsDeploy.operationID)
+	})
+
+	t.Run("Deploy options with quotes", func(t *testing.T) {
+
+		defer func() {
+			copiedFiles = nil
+			removedFiles = nil
+			s.calls = nil
+		}()
+
+		oldDeployOpts, oldMtaPath := myXsDeployOptions.DeployOpts, myXsDeployOptions.MtaPath
+		defer func() {
+			myXsDeployOptions.DeployOpts, myXsDeployOptions.MtaPath = oldDeployOpts, oldMtaPath
+		}()
+
+		myXsDeployOptions.DeployOpts = `-e "my ext.mtaext" --version-rule 'ALL; rm -rf /'`
+		myXsDeployOptions.MtaPath = "my app.mtar"
+
+		fExists := func(path string) (bool, error) {
+			return path == "my app.mtar" || path == ".xs_session", nil
+		}
+
+		e := runXsDeploy(myXsDeployOptions, &piperEnvironment, session, &s, fExists, fCopy, fRemove, fVerify, ioutil.Discard)
+		checkErr(t, e, "")
+
+		assert.Equal(t, []string{"deploy", "my app.mtar", "-e", "my ext.mtaext", "--version-rule", "ALL; rm -rf /"}, s.calls[1].params)
+	})
+
+	t.Run("Deploy fails, invalid deploy options", func(t *testing.T) {
+
+		defer func() {
+			copiedFiles = nil
+			removedFiles = nil
+			s.calls = nil
+		}()
+
+		oldDeployOpts := myXsDeployOptions.DeployOpts
+		defer func() { myXsDeployOptions.DeployOpts = oldDeployOpts }()
+
+		myXsDeployOptions.DeployOpts = `-e "my ext.mtaext`
+
+		e := runXsDeploy(myXsDeployOptions, &piperEnvironment, session, &s, fExists, fCopy, fRemove, fVerify, ioutil.Discard)
+		checkErr(t, e, "Cannot parse deploy options: unterminated double quote")
+
+		// logout happens nevertheless
+		assert.Equal(t, execCall{exec: "xs", params: []string{"logout"}}, s.calls[len(s.calls)-1])
+	})
+
+	t.Run("Login fails, mandatory parameters missing", func(t *testing.T) {
+
+		defer func() {
+			s.calls = nil
+		}()
+
+		myXsDeployOptions := myXsDeployOptions
+		myXsDeployOptions.APIURL = ""
+		myXsDeployOptions.Password = ""
+
+		e := runXsDeploy(myXsDeployOptions, &piperEnvironment, session, &s, fExists, fCopy, fRemove, fVerify, ioutil.Discard)
+		checkErr(t, e, "Missing mandatory parameter(s) for xs login: apiUrl, password")
+		assert.Empty(t, s.calls)
+	})
+
+	t.Run("Login fails, password in login options", func(t *testing.T) {
+
+		defer func() {
+			copiedFiles = nil
+			removedFiles = nil
+			s.calls = nil
+		}()
+
+		oldLoginOpts := myXsDeployOptions.LoginOpts
+		defer func() { myXsDeployOptions.LoginOpts = oldLoginOpts }()
+
+		myXsDeployOptions.LoginOpts = "--skip-ssl-validation -p secret"
+
+		e := runXsDeploy(myXsDeployOptions, &piperEnvironment, session, &s, fExists, fCopy, fRemove, fVerify, ioutil.Discard)
+		checkErr(t, e, "Login options must not contain a password")
+		assert.Empty(t, s.calls)
+	})
+
+	t.Run("BG deploy with auto complete", func(t *testing.T) {
+
+		oldOptions := myXsDeployOptions
+		defer func() {
+			myXsDeployOptions = oldOptions
+			copiedFiles = nil
+			removedFiles = nil
+			s.calls = nil
+			s.stdoutReturn = nil
+			verifiedURLs = nil
+			verifyErr = nil
+		}()
+
+		myXsDeployOptions.Mode = "BG_DEPLOY"
+		myXsDeployOptions.AutoComplete = true
+		myXsDeployOptions.HealthCheckTimeout = "1m"
+		myXsDeployOptions.HealthCheckPath = "/health"
+		myXsDeployOptions.IdleRouteLogPattern = `^.*Application ".*" started and available at "(.*)".*$`
+
+		bgDeployOutput := "Application \"app-green\" started and available at \"https://app-idle.example.org/\"\n" +
+			"Use \"xs bg-deploy -i 1234 -a resume\" to resume the process.\n"
+
+		t.Run("resume", func(t *testing.T) {
+			s.calls = nil
+			verifiedURLs = nil
+			s.stdoutReturn = map[string]string{"xs bg-deploy": bgDeployOutput}
+
+			stdout := new(bytes.Buffer)
+			e := runXsDeploy(myXsDeployOptions, &piperEnvironment, session, &s, fExists, fCopy, fRemove, fVerify, stdout)
+			checkErr(t, e, "")
+
+			assert.Equal(t, []string{"https://app-idle.example.org/health"}, verifiedURLs)
+			assert.Equal(t, []string{"bg-deploy", "-i", "1234", "-a", "resume"}, s.calls[2].params)
+			assert.Equal(t, []string{"logout"}, s.calls[3].params)
+			assert.Contains(t, stdout.String(), `"action":"RESUME"`)
+			assert.NotContains(t, stdout.String(), `"operationId"`)
+			assert.Empty(t, piperEnvironment.xsDeploy.operationID)
+		})
+
+		t.Run("abort", func(t *testing.T) {
+			s.calls = nil
+			verifiedURLs = nil
+			verifyErr = errors.New("health check of 'https://app-idle.example.org/health' did not succeed within 1m0s")
+			defer func() { verifyErr = nil }()
+
+			e := runXsDeploy(myXsDeployOptions, &piperEnvironment, session, &s, fExists, fCopy, fRemove, fVerify, ioutil.Discard)
+			checkErr(t, e, "Blue-green deployment with operation id '1234' has been aborted: health check of 'https://app-idle.example.org/health' did not succeed")
+
+			assert.Equal(t, []string{"bg-deploy", "-i", "1234", "-a", "abort"}, s.calls[2].params)
+			assert.Equal(t, []string{"logout"}, s.calls[3].params)
+		})
+
+		t.Run("configured urls", func(t *testing.T) {
+			s.calls = nil
+			verifiedURLs = nil
+			myXsDeployOptions.HealthCheckURLs = []string{"https://my.idle.route/ping"}
+			defer func() { myXsDeployOptions.HealthCheckURLs = nil }()
+
+			e := runXsDeploy(myXsDeployOptions, &piperEnvironment, session, &s, fExists, fCopy, fRemove, fVerify, ioutil.Discard)
+			checkErr(t, e, "")
+			assert.Equal(t, []string{"https://my.idle.route/ping"}, verifiedURLs)
+		})
+
+		t.Run("no idle routes", func(t *testing.T) {
+			s.calls = nil
+			verifiedURLs = nil
+			s.stdoutReturn = map[string]string{"xs bg-deploy": "Use \"xs bg-deploy -i 1234 -a resume\" to resume the process.\n"}
+
+			e := runXsDeploy(myXsDeployOptions, &piperEnvironment, session, &s, fExists, fCopy, fRemove, fVerify, ioutil.Discard)
+			checkErr(t, e, "has been aborted: no idle routes found for the health check")
+			assert.Empty(t, verifiedURLs)
+			assert.Equal(t, []string{"bg-deploy", "-i", "1234", "-a", "abort"}, s.calls[2].params)
+		})
+
+		t.Run("no operation id", func(t *testing.T) {
+			s.calls = nil
+			s.stdoutReturn = nil
+
+			e := runXsDeploy(myXsDeployOptions, &piperEnvironment, session, &s, fExists, fCopy, fRemove, fVerify, ioutil.Discard)
+			checkErr(t, e, "No operation identifier found")
+			// no resume/abort, but logout
+			assert.Len(t, s.calls, 3)
+			assert.Equal(t, []string{"logout"}, s.calls[2].params)
+		})
+
+		t.Run("invalid timeout", func(t *testing.T) {
+			s.calls = nil
+			myXsDeployOptions.HealthCheckTimeout = "5 minutes"
+			defer func() { myXsDeployOptions.HealthCheckTimeout = "1m" }()
+
+			e := runXsDeploy(myXsDeployOptions, &piperEnvironment, session, &s, fExists, fCopy, fRemove, fVerify, ioutil.Discard)
+			checkErr(t, e, "Invalid health check timeout: '5 minutes'")
+			assert.Empty(t, s.calls)
+		})
+
+		t.Run("invalid log patterns", func(t *testing.T) {
+			s.calls = nil
+			myXsDeployOptions.IdleRouteLogPattern = `^.*available at "(.*".*$`
+			defer func() {
+				myXsDeployOptions.IdleRouteLogPattern = `^.*Application ".*" started and available at "(.*)".*$`
+			}()
+
+			e := runXsDeploy(myXsDeployOptions, &piperEnvironment, session, &s, fExists, fCopy, fRemove, fVerify, ioutil.Discard)
+			checkErr(t, e, "Invalid idleRouteLogPattern")
+			assert.Empty(t, s.calls)
+
+			myXsDeployOptions.OperationIDLogPattern = `^.*xs bg-deploy -i (.* -a.*$`
+			defer func() { myXsDeployOptions.OperationIDLogPattern = `^.*xs bg-deploy -i (.*) -a.*$` }()
+
+			e = runXsDeploy(myXsDeployOptions, &piperEnvironment, session, &s, fExists, fCopy, fRemove, fVerify, ioutil.Discard)
+			checkErr(t, e, "Invalid operationIdLogPattern")
+			assert.Empty(t, s.calls)
+		})
 	})
 
 	t.Run("BG deploy abort fails due to missing operationId", func(t *testing.T) {
@@ -229,13 +489,58 @@ func TestDeploy(t *testing.T) {
 		myXsDeployOptions.Mode = "BG_DEPLOY"
 		myXsDeployOptions.Action = "ABORT"
 
-		e := runXsDeploy(myXsDeployOptions, &s, fExists, fCopy, fRemove, ioutil.Discard)
+		e := runXsDeploy(myXsDeployOptions, &piperEnvironment, session, &s, fExists, fCopy, fRemove, fVerify, ioutil.Discard)
 		checkErr(t, e, "OperationID was not provided")
 	})
 }
 
-func TestRetrieveOperationID(t *testing.T) {
-	operationID := retrieveOperationID(`
+func TestCheckIdleRoutes(t *testing.T) {
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		requests++
+		switch req.URL.Path {
+		case "/healthy":
+			rw.WriteHeader(http.StatusOK)
+		case "/slow":
+			if requests < 3 {
+				rw.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			rw.WriteHeader(http.StatusOK)
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	var sleeps []time.Duration
+	sleep := func(d time.Duration) { sleeps = append(sleeps, d) }
+
+	t.Run("healthy after retries", func(t *testing.T) {
+		requests, sleeps = 0, nil
+
+		results, err := checkIdleRoutes([]string{server.URL + "/healthy", server.URL + "/slow"}, time.Minute, &piperhttp.Client{}, sleep)
+
+		assert.NoError(t, err)
+		assert.Len(t, results, 2)
+		assert.Equal(t, 200, results[1].StatusCode)
+		assert.Equal(t, []time.Duration{healthCheckInterval}, sleeps)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		requests, sleeps = 0, nil
+
+		results, err := checkIdleRoutes([]string{server.URL + "/unknown"}, 30*time.Second, &piperhttp.Client{}, sleep)
+
+		assert.EqualError(t, err, fmt.Sprintf("health check of '%s/unknown' did not succeed within 30s", server.URL))
+		assert.Equal(t, 404, results[0].StatusCode)
+		assert.Len(t, sleeps, 3)
+	})
+}
+
+func TestRetrieveFromLog(t *testing.T) {
+	operationID := retrieveFromLog(`
 	Uploading 1 files:
         myFolder/dummy.mtar
 	File upload finished
@@ -258,9 +563,15 @@ func TestRetrieveOperationID(t *testing.T) {
 	Use "xs bg-deploy -i 1234 -a resume" to resume the process.
 	Use "xs bg-deploy -i 1234 -a abort" to abort the process.
 	Hint: Use the '--no-confirm' option of the bg-deploy command to skip this phase.
-	`, `^.*xs bg-deploy -i (.*) -a.*$`)
+	`, regexp.MustCompile(`^.*xs bg-deploy -i (.*) -a.*$`))
 
 	assert.Equal(t, "1234", operationID)
+
+	route := retrieveFromLog(`Application "xx-green" started and available at "https://xx-idle.example.org"`, regexp.MustCompile(`^.*Application ".*" started and available at "(.*)".*$`))
+	assert.Equal(t, "https://xx-idle.example.org", route)
+
+	assert.Empty(t, retrieveFromLog("Task execution status: succeeded", regexp.MustCompile(`^.*xs bg-deploy -i (.*) -a.*$`)))
+	assert.Empty(t, retrieveFromLog("Task execution status: succeeded", regexp.MustCompile(`^Task.*$`)), "pattern without capturing group")
 }
 
 func checkErr(t *testing.T, e error, message string) {
@@ -281,3 +592,62 @@ func checkErr(t *testing.T, e error, message string) {
 		}
 	}
 }
+
+func TestXsLogin(t *testing.T) {
+
+	options := xsDeployOptions{APIURL: "https://example.org", User: "me", Password: "secret", Org: "myOrg", Space: "mySpace"}
+
+	t.Run("password via stdin", func(t *testing.T) {
+		s := execMockRunner{}
+		err := xsLogin(options, &s)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"secret\n"}, s.stdinContent)
+		assert.Equal(t, execCall{exec: "xs", params: []string{"login", "-a", "https://example.org", "-u", "me", "-o", "myOrg", "-s", "mySpace"}}, s.calls[0])
+		assert.Empty(t, s.env, "password expected not to be part of the environment")
+		assert.Nil(t, s.stdin, "password expected to be provided to the login only")
+	})
+
+	t.Run("password in login options", func(t *testing.T) {
+		for _, loginOpts := range []string{"-p secret", "-psecret", "-p=secret", "--password secret", "--password=secret"} {
+			options := options
+			options.LoginOpts = "--skip-ssl-validation " + loginOpts
+			s := execMockRunner{}
+			err := xsLogin(options, &s)
+
+			assert.EqualError(t, err, "Login options must not contain a password. Provide the password via the 'password' parameter.", loginOpts)
+			assert.Empty(t, s.calls)
+		}
+	})
+}
+
+func TestApplyXsOperationState(t *testing.T) {
+
+	environment := map[string]string{
+		"xsDeploy/mode":   "BG_DEPLOY",
+		"xsDeploy/apiUrl": "https://example.org",
+		"xsDeploy/org":    "myOrg",
+		"xsDeploy/space":  "mySpace",
+	}
+	getParameter := func(name string) string { return environment[name] }
+
+	t.Run("resume", func(t *testing.T) {
+		options := applyXsOperationState(xsDeployOptions{Action: "RESUME", Mode: "DEPLOY", Space: "otherSpace"}, getParameter)
+		assert.Equal(t, xsDeployOptions{Action: "RESUME", Mode: "BG_DEPLOY", APIURL: "https://example.org", Org: "myOrg", Space: "otherSpace"}, options)
+	})
+
+	t.Run("configured values take precedence", func(t *testing.T) {
+		options := applyXsOperationState(xsDeployOptions{Action: "ABORT", Mode: "NONE", APIURL: "https://other.example.org", Org: "otherOrg", Space: "otherSpace"}, getParameter)
+		assert.Equal(t, xsDeployOptions{Action: "ABORT", Mode: "NONE", APIURL: "https://other.example.org", Org: "otherOrg", Space: "otherSpace"}, options)
+	})
+
+	t.Run("standard deployment does not inherit state", func(t *testing.T) {
+		options := applyXsOperationState(xsDeployOptions{Action: "NONE", Mode: "DEPLOY", Space: "otherSpace"}, getParameter)
+		assert.Equal(t, xsDeployOptions{Action: "NONE", Mode: "DEPLOY", Space: "otherSpace"}, options)
+	})
+
+	t.Run("abort without state", func(t *testing.T) {
+		options := applyXsOperationState(xsDeployOptions{Action: "ABORT", Mode: "BG_DEPLOY", Org: "myOrg"}, func(string) string { return "" })
+		assert.Equal(t, xsDeployOptions{Action: "ABORT", Mode: "BG_DEPLOY", Org: "myOrg"}, options)
+	})
+}
@@ -0,0 +1,230 @@
+package blackduck
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	piperhttp "github.com/SAP/jenkins-library/pkg/http"
+	"github.com/pkg/errors"
+)
+
+const requestTimeout = time.Minute
+
+// number of items requested per page of a collection, Black Duck returns 10 items by default
+const pageSize = 100
+
+// Client provides access to the Black Duck REST API, see https://<blackduck-server>/api-doc/public.html
+type Client struct {
+	serverURL   string
+	apiToken    string
+	bearerToken string
+	httpClient  piperhttp.Sender
+}
+
+// Meta contains the links of a Black Duck resource
+type Meta struct {
+	Href string `json:"href"`
+}
+
+// Project is a Black Duck project
+type Project struct {
+	Name string `json:"name"`
+	Meta Meta   `json:"_meta"`
+}
+
+// ProjectVersion is a version of a Black Duck project
+type ProjectVersion struct {
+	VersionName string `json:"versionName"`
+	Meta        Meta   `json:"_meta"`
+}
+
+// RiskProfile provides the number of components per risk category and risk level
+type RiskProfile struct {
+	Categories map[string]map[string]int `json:"categories"`
+}
+
+// PolicyStatus provides the policy status of a project version
+type PolicyStatus struct {
+	OverallStatus                string        `json:"overallStatus"`
+	ComponentVersionStatusCounts []StatusCount `json:"componentVersionStatusCounts"`
+}
+
+// StatusCount provides the number of components with the given policy status
+type StatusCount struct {
+	Name  string `json:"name"`
+	Value int    `json:"value"`
+}
+
+// NewClient creates a client for the Black Duck server authenticating with the given API token
+func NewClient(serverURL, apiToken string, httpClient piperhttp.Sender) *Client {
+	return &Client{serverURL: strings.TrimSuffix(serverURL, "/"), apiToken: apiToken, httpClient: httpClient}
+}
+
+// GetProject provides the project with the given name
+func (c *Client) GetProject(projectName string) (*Project, error) {
+	var project *Project
+	query := url.Values{"q": []string{"name:" + projectName}}
+	err := c.findItem(c.serverURL+"/api/projects", query, func(item json.RawMessage) (bool, error) {
+		candidate := Project{}
+		if err := json.Unmarshal(item, &candidate); err != nil {
+			return false, err
+		}
+		// the query matches project names containing the given name
+		if candidate.Name != projectName {
+			return false, nil
+		}
+		project = &candidate
+		return true, nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get project '%v'", projectName)
+	}
+	if project == nil {
+		return nil, fmt.Errorf("project '%v' not found", projectName)
+	}
+	return project, nil
+}
+
+// GetProjectVersion provides the version of the project with the given name
+func (c *Client) GetProjectVersion(projectName, versionName string) (*ProjectVersion, error) {
+	project, err := c.GetProject(projectName)
+	if err != nil {
+		return nil, err
+	}
+
+	var version *ProjectVersion
+	query := url.Values{"q": []string{"versionName:" + versionName}}
+	err = c.findItem(project.Meta.Href+"/versions", query, func(item json.RawMessage) (bool, error) {
+		candidate := ProjectVersion{}
+		if err := json.Unmarshal(item, &candidate); err != nil {
+			return false, err
+		}
+		if candidate.VersionName != versionName {
+			return false, nil
+		}
+		version = &candidate
+		return true, nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get version '%v' of project '%v'", versionName, projectName)
+	}
+	if version == nil {
+		return nil, fmt.Errorf("version '%v' of project '%v' not found", versionName, projectName)
+	}
+	return version, nil
+}
+
+// GetRiskProfile provides the risk profile of the project version
+func (c *Client) GetRiskProfile(version *ProjectVersion) (*RiskProfile, error) {
+	riskProfile := RiskProfile{}
+	if err := c.get(version.Meta.Href+"/risk-profile", &riskProfile); err != nil {
+		return nil, errors.Wrapf(err, "failed to get risk profile of version '%v'", version.VersionName)
+	}
+	return &riskProfile, nil
+}
+
+// GetPolicyStatus provides the policy status of the project version
+func (c *Client) GetPolicyStatus(version *ProjectVersion) (*PolicyStatus, error) {
+	policyStatus := PolicyStatus{}
+	if err := c.get(version.Meta.Href+"/policy-status", &policyStatus); err != nil {
+		return nil, errors.Wrapf(err, "failed to get policy status of version '%v'", version.VersionName)
+	}
+	return &policyStatus, nil
+}
+
+// Count provides the number of components with the given risk level in the given category, e.g. VULNERABILITY or LICENSE
+func (r *RiskProfile) Count(category, level string) int {
+	return r.Categories[category][level]
+}
+
+// Count provides the number of components with the given policy status, e.g. IN_VIOLATION
+func (p *PolicyStatus) Count(status string) int {
+	for _, count := range p.ComponentVersionStatusCounts {
+		if count.Name == status {
+			return count.Value
+		}
+	}
+	return 0
+}
+
+// findItem reads the collection page by page until match accepts an item or all items have been read
+func (c *Client) findItem(href string, query url.Values, match func(item json.RawMessage) (bool, error)) error {
+	offset := 0
+	for {
+		query.Set("limit", strconv.Itoa(pageSize))
+		query.Set("offset", strconv.Itoa(offset))
+		page := struct {
+			TotalCount int               `json:"totalCount"`
+			Items      []json.RawMessage `json:"items"`
+		}{}
+		if err := c.get(fmt.Sprintf("%v?%v", href, query.Encode()), &page); err != nil {
+			return err
+		}
+		for _, item := range page.Items {
+			found, err := match(item)
+			if err != nil {
+				return errors.Wrapf(err, "failed to parse response of '%v'", href)
+			}
+			if found {
+				return nil
+			}
+		}
+		offset += len(page.Items)
+		if len(page.Items) == 0 || offset >= page.TotalCount {
+			return nil
+		}
+	}
+}
+
+func (c *Client) authenticate() error {
+	if len(c.bearerToken) > 0 {
+		return nil
+	}
+	c.httpClient.SetOptions(piperhttp.ClientOptions{Timeout: requestTimeout})
+	header := http.Header{"Authorization": []string{"token " + c.apiToken}, "Accept": []string{"application/json"}}
+	response, err := c.httpClient.SendRequest(http.MethodPost, c.serverURL+"/api/tokens/authenticate", nil, header, nil)
+	if response != nil && response.Body != nil {
+		defer response.Body.Close()
+	}
+	if err != nil {
+		return errors.Wrap(err, "authentication at the Black Duck server failed")
+	}
+
+	token := struct {
+		BearerToken string `json:"bearerToken"`
+	}{}
+	if err := json.NewDecoder(response.Body).Decode(&token); err != nil {
+		return errors.Wrap(err, "failed to read the authentication response of the Black Duck server")
+	}
+	c.bearerToken = token.BearerToken
+	return nil
+}
+
+func (c *Client) get(href string, result interface{}) error {
+	if err := c.authenticate(); err != nil {
+		return err
+	}
+	c.httpClient.SetOptions(piperhttp.ClientOptions{Timeout: requestTimeout, Token: "Bearer " + c.bearerToken})
+	response, err := c.httpClient.SendRequest(http.MethodGet, href, nil, http.Header{"Accept": []string{"application/json"}}, nil)
+	if response != nil && response.Body != nil {
+		defer response.Body.Close()
+	}
+	if err != nil {
+		return err
+	}
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read response of '%v'", href)
+	}
+	if err := json.Unmarshal(body, result); err != nil {
+		return errors.Wrapf(err, "failed to parse response of '%v'", href)
+	}
+	return nil
+}
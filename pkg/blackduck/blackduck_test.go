@@ -0,0 +1,114 @@
+package blackduck
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	piperhttp "github.com/SAP/jenkins-library/pkg/http"
+	"github.com/stretchr/testify/assert"
+)
+
+// writePage writes the page of the items requested via limit and offset
+func writePage(w http.ResponseWriter, r *http.Request, items []string) {
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil {
+		limit = 10
+	}
+	end := offset + limit
+	if end > len(items) {
+		end = len(items)
+	}
+	if offset > end {
+		offset = end
+	}
+	fmt.Fprintf(w, `{"totalCount": %v, "items": [%v]}`, len(items), strings.Join(items[offset:end], ", "))
+}
+
+func newBlackDuckServer(t *testing.T) *httptest.Server {
+	var server *httptest.Server
+	projects := []string{}
+	for i := 0; i < 150; i++ {
+		projects = append(projects, fmt.Sprintf(`{"name": "project-%[1]v", "_meta": {"href": "/api/projects/%[1]v"}}`, i+2))
+	}
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/tokens/authenticate" {
+			assert.Equal(t, http.MethodPost, r.Method)
+			if r.Header.Get("Authorization") != "token apiToken" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(w, `{"bearerToken": "bearer", "expiresInMilliseconds": 7200000}`)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer bearer" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/api/projects":
+			assert.Contains(t, r.URL.Query().Get("q"), "name:proj")
+			// the project is only contained in the second page
+			writePage(w, r, append(projects, fmt.Sprintf(`{"name": "project", "_meta": {"href": "%v/api/projects/1"}}`, server.URL)))
+		case "/api/projects/1/versions":
+			assert.Contains(t, r.URL.Query().Get("q"), "versionName:")
+			writePage(w, r, []string{fmt.Sprintf(`{"versionName": "1.0", "_meta": {"href": "%v/api/projects/1/versions/10"}}`, server.URL)})
+		case "/api/projects/1/versions/10/risk-profile":
+			fmt.Fprint(w, `{"categories": {"VULNERABILITY": {"CRITICAL": 1, "HIGH": 2, "MEDIUM": 3, "LOW": 4, "OK": 5}, "LICENSE": {"HIGH": 6, "MEDIUM": 0, "LOW": 7}}}`)
+		case "/api/projects/1/versions/10/policy-status":
+			fmt.Fprint(w, `{"overallStatus": "IN_VIOLATION", "componentVersionStatusCounts": [{"name": "IN_VIOLATION", "value": 2}, {"name": "NOT_IN_VIOLATION", "value": 20}]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	return server
+}
+
+func TestClient(t *testing.T) {
+	server := newBlackDuckServer(t)
+	defer server.Close()
+
+	t.Run("success", func(t *testing.T) {
+		client := NewClient(server.URL+"/", "apiToken", &piperhttp.Client{})
+
+		version, err := client.GetProjectVersion("project", "1.0")
+		if assert.NoError(t, err) {
+			assert.Equal(t, server.URL+"/api/projects/1/versions/10", version.Meta.Href)
+		}
+
+		riskProfile, err := client.GetRiskProfile(version)
+		if assert.NoError(t, err) {
+			assert.Equal(t, 1, riskProfile.Count("VULNERABILITY", "CRITICAL"))
+			assert.Equal(t, 7, riskProfile.Count("LICENSE", "LOW"))
+			assert.Equal(t, 0, riskProfile.Count("OPERATIONAL", "HIGH"))
+		}
+
+		policyStatus, err := client.GetPolicyStatus(version)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "IN_VIOLATION", policyStatus.OverallStatus)
+			assert.Equal(t, 2, policyStatus.Count("IN_VIOLATION"))
+			assert.Equal(t, 0, policyStatus.Count("IN_VIOLATION_OVERRIDDEN"))
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		client := NewClient(server.URL, "apiToken", &piperhttp.Client{})
+
+		_, err := client.GetProjectVersion("project", "2.0")
+		assert.EqualError(t, err, "version '2.0' of project 'project' not found")
+
+		_, err = client.GetProject("proj")
+		assert.EqualError(t, err, "project 'proj' not found")
+	})
+
+	t.Run("authentication fails", func(t *testing.T) {
+		client := NewClient(server.URL, "wrong", &piperhttp.Client{})
+
+		_, err := client.GetProject("project")
+		assert.Contains(t, fmt.Sprint(err), "authentication at the Black Duck server failed")
+	})
+}
@@ -0,0 +1,73 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// ParseArgs splits a string containing command line arguments into separate arguments
+// following the quoting rules of a POSIX shell: arguments are separated by whitespace,
+// single quotes preserve their content literally, double quotes and backslashes allow escaping.
+// In contrast to a shell no expansion of variables or globs takes place.
+func ParseArgs(s string) ([]string, error) {
+	args := []string{}
+	var current strings.Builder
+	inArg := false
+	runes := []rune(s)
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		case r == '\\':
+			if i+1 >= len(runes) {
+				return nil, fmt.Errorf("unterminated escape sequence in '%v'", s)
+			}
+			i++
+			current.WriteRune(runes[i])
+			inArg = true
+		case r == '\'':
+			end := indexRune(runes, i+1, '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated single quote in '%v'", s)
+			}
+			current.WriteString(string(runes[i+1 : end]))
+			i = end
+			inArg = true
+		case r == '"':
+			i++
+			for ; i < len(runes) && runes[i] != '"'; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) && strings.ContainsRune("\"\\$`", runes[i+1]) {
+					i++
+				}
+				current.WriteRune(runes[i])
+			}
+			if i >= len(runes) {
+				return nil, fmt.Errorf("unterminated double quote in '%v'", s)
+			}
+			inArg = true
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
+
+func indexRune(runes []rune, start int, r rune) int {
+	for i := start; i < len(runes); i++ {
+		if runes[i] == r {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,41 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseArgs(t *testing.T) {
+	tt := []struct {
+		input    string
+		expected []string
+	}{
+		{input: "", expected: []string{}},
+		{input: "  ", expected: []string{}},
+		{input: "--skip-ssl-validation", expected: []string{"--skip-ssl-validation"}},
+		{input: " -e  ext.mtaext\t--no-confirm ", expected: []string{"-e", "ext.mtaext", "--no-confirm"}},
+		{input: `--desc 'my app; rm -rf /'`, expected: []string{"--desc", "my app; rm -rf /"}},
+		{input: `--desc "say \"hello\" to \$HOME"`, expected: []string{"--desc", `say "hello" to $HOME`}},
+		{input: `--path my\ folder`, expected: []string{"--path", "my folder"}},
+		{input: `--empty ''`, expected: []string{"--empty", ""}},
+		{input: `--key=a'b c'"d"`, expected: []string{"--key=ab cd"}},
+		{input: `"C:\path\file"`, expected: []string{`C:\path\file`}},
+	}
+
+	for _, test := range tt {
+		args, err := ParseArgs(test.input)
+		if assert.NoError(t, err, test.input) {
+			assert.Equal(t, test.expected, args, test.input)
+		}
+	}
+
+	t.Run("error cases", func(t *testing.T) {
+		_, err := ParseArgs("--desc 'abc")
+		assert.EqualError(t, err, "unterminated single quote in '--desc 'abc'")
+		_, err = ParseArgs(`--desc "abc`)
+		assert.EqualError(t, err, `unterminated double quote in '--desc "abc'`)
+		_, err = ParseArgs(`abc\`)
+		assert.EqualError(t, err, `unterminated escape sequence in 'abc\'`)
+	})
+}
@@ -0,0 +1,167 @@
+package command
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Capture keeps the most recent lines written to it in a bounded ring buffer and
+// notifies registered handlers about each complete line.
+// Writers for the different output streams (e.g. stdout and stderr) are obtained via Writer().
+type Capture struct {
+	mu sync.Mutex
+	// handlerMu serializes the handler calls, which happen without holding mu
+	// so that handlers are able to access the capture
+	handlerMu sync.Mutex
+	lines     []string
+	next      int
+	full      bool
+	handlers  []func(line string)
+	writers   []*lineWriter
+}
+
+type lineWriter struct {
+	capture *Capture
+	partial []byte
+}
+
+// NewCapture creates a capture keeping the last size lines
+func NewCapture(size int) *Capture {
+	if size < 1 {
+		size = 1
+	}
+	return &Capture{lines: make([]string, size)}
+}
+
+// OnLine registers a handler which is called for each complete line.
+// Handlers are called sequentially, also in case the lines originate from different writers.
+func (c *Capture) OnLine(handler func(line string)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.handlers = append(c.handlers, handler)
+}
+
+// Writer provides a new writer for one output stream.
+// Each writer keeps track of incomplete lines on its own, thus output from different streams is not mixed up within a line.
+func (c *Capture) Writer() io.Writer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	w := &lineWriter{capture: c}
+	c.writers = append(c.writers, w)
+	return w
+}
+
+// Flush processes content which has been written without a trailing line break
+func (c *Capture) Flush() {
+	c.mu.Lock()
+	lines := []string{}
+	for _, w := range c.writers {
+		lines = append(lines, w.flush()...)
+	}
+	handlers := c.copyHandlers()
+	c.mu.Unlock()
+	c.notify(handlers, lines)
+}
+
+// release flushes the writer and removes it from the capture, it is not used anymore afterwards
+func (c *Capture) release(writer io.Writer) {
+	c.mu.Lock()
+	lines := []string{}
+	for i, w := range c.writers {
+		if w == writer {
+			lines = w.flush()
+			c.writers = append(c.writers[:i], c.writers[i+1:]...)
+			break
+		}
+	}
+	handlers := c.copyHandlers()
+	c.mu.Unlock()
+	c.notify(handlers, lines)
+}
+
+// Lines returns the captured lines, oldest line first
+func (c *Capture) Lines() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.full {
+		return append([]string{}, c.lines[:c.next]...)
+	}
+	return append(append([]string{}, c.lines[c.next:]...), c.lines[:c.next]...)
+}
+
+// Tail returns the last n captured lines
+func (c *Capture) Tail(n int) []string {
+	lines := c.Lines()
+	if n >= 0 && n < len(lines) {
+		return lines[len(lines)-n:]
+	}
+	return lines
+}
+
+// String returns the captured lines as one string
+func (c *Capture) String() string {
+	return strings.Join(c.Lines(), "\n")
+}
+
+// add stores the line, mu needs to be held by the caller
+func (c *Capture) add(line string) string {
+	line = strings.TrimSuffix(line, "\r")
+	c.lines[c.next] = line
+	c.next++
+	if c.next == len(c.lines) {
+		c.next = 0
+		c.full = true
+	}
+	return line
+}
+
+// copyHandlers provides the handlers for calling them after mu is released, mu needs to be held by the caller
+func (c *Capture) copyHandlers() []func(line string) {
+	return append([]func(line string){}, c.handlers...)
+}
+
+func (c *Capture) notify(handlers []func(line string), lines []string) {
+	if len(handlers) == 0 || len(lines) == 0 {
+		return
+	}
+	c.handlerMu.Lock()
+	defer c.handlerMu.Unlock()
+	for _, line := range lines {
+		for _, handler := range handlers {
+			handler(line)
+		}
+	}
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.capture.mu.Lock()
+
+	lines := []string{}
+	data := append(w.partial, p...)
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		lines = append(lines, w.capture.add(string(data[:i])))
+		data = data[i+1:]
+	}
+	w.partial = append([]byte{}, data...)
+	handlers := w.capture.copyHandlers()
+	w.capture.mu.Unlock()
+
+	w.capture.notify(handlers, lines)
+	return len(p), nil
+}
+
+// flush stores content without a trailing line break, mu needs to be held by the caller
+func (w *lineWriter) flush() []string {
+	if len(w.partial) == 0 {
+		return nil
+	}
+	line := w.capture.add(string(w.partial))
+	w.partial = nil
+	return []string{line}
+}
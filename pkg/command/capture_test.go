@@ -0,0 +1,77 @@
+package command
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCapture(t *testing.T) {
+
+	t.Run("lines", func(t *testing.T) {
+		c := NewCapture(10)
+		w := c.Writer()
+		fmt.Fprint(w, "line1\nline")
+		fmt.Fprint(w, "2\r\nline3")
+
+		assert.Equal(t, []string{"line1", "line2"}, c.Lines())
+
+		c.Flush()
+		assert.Equal(t, []string{"line1", "line2", "line3"}, c.Lines())
+		assert.Equal(t, "line1\nline2\nline3", c.String())
+	})
+
+	t.Run("ring buffer", func(t *testing.T) {
+		c := NewCapture(3)
+		w := c.Writer()
+		for i := 1; i <= 5; i++ {
+			fmt.Fprintf(w, "line%v\n", i)
+		}
+
+		assert.Equal(t, []string{"line3", "line4", "line5"}, c.Lines())
+		assert.Equal(t, []string{"line4", "line5"}, c.Tail(2))
+		assert.Equal(t, []string{"line3", "line4", "line5"}, c.Tail(10))
+	})
+
+	t.Run("multiple writers", func(t *testing.T) {
+		c := NewCapture(10)
+		out := c.Writer()
+		err := c.Writer()
+		fmt.Fprint(out, "std")
+		fmt.Fprint(err, "error\n")
+		fmt.Fprint(out, "out\n")
+
+		assert.Equal(t, []string{"error", "stdout"}, c.Lines())
+	})
+
+	t.Run("line handler", func(t *testing.T) {
+		c := NewCapture(1)
+		lines := []string{}
+		c.OnLine(func(line string) { lines = append(lines, line) })
+		fmt.Fprint(c.Writer(), "a\nb\nc")
+		c.Flush()
+
+		assert.Equal(t, []string{"a", "b", "c"}, lines)
+		assert.Equal(t, []string{"c"}, c.Lines())
+	})
+
+	t.Run("line handler accessing capture", func(t *testing.T) {
+		c := NewCapture(10)
+		counts := []int{}
+		c.OnLine(func(line string) { counts = append(counts, len(c.Lines())) })
+		fmt.Fprint(c.Writer(), "a\nb\n")
+
+		assert.Equal(t, []int{2, 2}, counts)
+	})
+
+	t.Run("release writer", func(t *testing.T) {
+		c := NewCapture(10)
+		w := c.Writer()
+		fmt.Fprint(w, "a")
+		c.release(w)
+
+		assert.Equal(t, []string{"a"}, c.Lines())
+		assert.Empty(t, c.writers)
+	})
+}
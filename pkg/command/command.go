@@ -6,16 +6,22 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"strings"
 	"sync"
 
+	"github.com/SAP/jenkins-library/pkg/log"
 	"github.com/pkg/errors"
 )
 
 // Command defines the information required for executing a call to any executable
 type Command struct {
-	dir    string
-	stdout io.Writer
-	stderr io.Writer
+	dir       string
+	env       []string
+	stdin     io.Reader
+	stdout    io.Writer
+	stderr    io.Writer
+	capture   *Capture
+	tailLines int
 }
 
 // Dir sets the working directory for the execution
@@ -23,6 +29,18 @@ func (c *Command) Dir(d string) {
 	c.dir = d
 }
 
+// Env sets additional environment variables for the execution in the form "key=value".
+// They extend the environment of the current process.
+func (c *Command) Env(env []string) {
+	c.env = env
+}
+
+// Stdin sets the input for the execution of executables.
+// Since the input is consumed during the execution it needs to be provided again for subsequent executions.
+func (c *Command) Stdin(stdin io.Reader) {
+	c.stdin = stdin
+}
+
 // Stdout ..
 func (c *Command) Stdout(stdout io.Writer) {
 	c.stdout = stdout
@@ -33,6 +51,16 @@ func (c *Command) Stderr(stderr io.Writer) {
 	c.stderr = stderr
 }
 
+// Capture routes stdout and stderr additionally into the provided capture
+func (c *Command) Capture(capture *Capture) {
+	c.capture = capture
+}
+
+// TailOnFailure defines the number of output lines (stdout and stderr) which are logged in case the execution fails
+func (c *Command) TailOnFailure(lines int) {
+	c.tailLines = lines
+}
+
 // ExecCommand defines how to execute os commands
 var ExecCommand = exec.Command
 
@@ -40,15 +68,19 @@ var ExecCommand = exec.Command
 func (c *Command) RunShell(shell, script string) error {
 
 	_out, _err := prepareOut(c.stdout, c.stderr)
+	_out, _err, finishCapture := c.prepareCapture(_out, _err)
 
 	cmd := ExecCommand(shell)
 
 	cmd.Dir = c.dir
+	c.prepareEnv(cmd)
 	in := bytes.Buffer{}
 	in.Write([]byte(script))
 	cmd.Stdin = &in
 
-	if err := runCmd(cmd, _out, _err); err != nil {
+	err := runCmd(cmd, _out, _err)
+	finishCapture(err)
+	if err != nil {
 		return errors.Wrapf(err, "running shell script failed with %v", shell)
 	}
 	return nil
@@ -58,19 +90,36 @@ func (c *Command) RunShell(shell, script string) error {
 func (c *Command) RunExecutable(executable string, params ...string) error {
 
 	_out, _err := prepareOut(c.stdout, c.stderr)
+	_out, _err, finishCapture := c.prepareCapture(_out, _err)
 
 	cmd := ExecCommand(executable, params...)
 
 	if len(c.dir) > 0 {
 		cmd.Dir = c.dir
 	}
+	c.prepareEnv(cmd)
+	if c.stdin != nil {
+		cmd.Stdin = c.stdin
+	}
 
-	if err := runCmd(cmd, _out, _err); err != nil {
+	err := runCmd(cmd, _out, _err)
+	finishCapture(err)
+	if err != nil {
 		return errors.Wrapf(err, "running command '%v' failed", executable)
 	}
 	return nil
 }
 
+func (c *Command) prepareEnv(cmd *exec.Cmd) {
+	if len(c.env) == 0 {
+		return
+	}
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	cmd.Env = append(cmd.Env, c.env...)
+}
+
 func runCmd(cmd *exec.Cmd, _out, _err io.Writer) error {
 
 	stdout, stderr, err := cmdPipes(cmd)
@@ -130,6 +179,28 @@ func prepareOut(stdout, stderr io.Writer) (io.Writer, io.Writer) {
 	return stdout, stderr
 }
 
+// prepareCapture routes the output additionally into the capture. The returned function needs to be called
+// once the execution is finished, it releases the writers of this execution and logs the tail in case of an error.
+func (c *Command) prepareCapture(stdout, stderr io.Writer) (io.Writer, io.Writer, func(err error)) {
+	capture := c.capture
+	if capture == nil {
+		if c.tailLines <= 0 {
+			return stdout, stderr, func(error) {}
+		}
+		capture = NewCapture(c.tailLines)
+	}
+	captureOut, captureErr := capture.Writer(), capture.Writer()
+	finish := func(err error) {
+		capture.release(captureOut)
+		capture.release(captureErr)
+		if err != nil && c.tailLines > 0 {
+			tail := capture.Tail(c.tailLines)
+			log.Entry().Errorf("Last %v lines of output:\n%v", len(tail), strings.Join(tail, "\n"))
+		}
+	}
+	return io.MultiWriter(stdout, captureOut), io.MultiWriter(stderr, captureErr), finish
+}
+
 func cmdPipes(cmd *exec.Cmd) (io.ReadCloser, io.ReadCloser, error) {
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
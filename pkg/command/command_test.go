@@ -3,10 +3,15 @@ package command
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"os/exec"
+	"strings"
 	"testing"
+
+	"github.com/SAP/jenkins-library/pkg/log"
+	"github.com/stretchr/testify/assert"
 )
 
 //based on https://golang.org/src/os/exec/exec_test.go
@@ -72,6 +77,101 @@ func TestExecutableRun(t *testing.T) {
 			})
 		})
 	})
+
+	t.Run("test stdin", func(t *testing.T) {
+		ExecCommand = helperCommand
+		defer func() { ExecCommand = exec.Command }()
+		o := new(bytes.Buffer)
+		e := new(bytes.Buffer)
+
+		ex := Command{stdout: o, stderr: e}
+		ex.Stdin(strings.NewReader("secret\n"))
+		err := ex.RunExecutable("cat")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "secret\n", o.String())
+	})
+
+	t.Run("test env", func(t *testing.T) {
+		ExecCommand = helperCommand
+		defer func() { ExecCommand = exec.Command }()
+		o := new(bytes.Buffer)
+		e := new(bytes.Buffer)
+
+		ex := Command{stdout: o, stderr: e}
+		ex.Env([]string{"HOME=/tmp/isolated"})
+		err := ex.RunExecutable("env", "HOME")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "/tmp/isolated\n", o.String())
+	})
+}
+
+func TestCaptureOutput(t *testing.T) {
+	ExecCommand = helperCommand
+	defer func() { ExecCommand = exec.Command }()
+
+	t.Run("capture", func(t *testing.T) {
+		o := new(bytes.Buffer)
+		e := new(bytes.Buffer)
+		capture := NewCapture(10)
+
+		ex := Command{stdout: o, stderr: e}
+		ex.Capture(capture)
+		err := ex.RunExecutable("echo", "foo bar")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "foo bar\n", o.String(), "output not forwarded")
+		assert.Contains(t, capture.Lines(), "foo bar")
+		assert.Contains(t, capture.Lines(), "Stderr: command echo")
+
+		err = ex.RunExecutable("echo", "foo bar")
+		assert.NoError(t, err)
+		assert.Empty(t, capture.writers, "writers of finished executions expected to be released")
+	})
+
+	t.Run("tail on failure", func(t *testing.T) {
+		o := new(bytes.Buffer)
+		e := new(bytes.Buffer)
+		logBuffer := new(bytes.Buffer)
+		log.Entry().Logger.SetOutput(logBuffer)
+		defer log.Entry().Logger.SetOutput(os.Stderr)
+
+		ex := Command{stdout: o, stderr: e}
+		ex.TailOnFailure(2)
+		err := ex.RunExecutable("fail", "line1", "line2", "line3")
+
+		assert.EqualError(t, err, "running command 'fail' failed: cmd.Run() failed: exit status 1")
+		assert.Contains(t, logBuffer.String(), "Last 2 lines of output")
+		assert.Contains(t, logBuffer.String(), "line2\\nline3")
+		assert.NotContains(t, logBuffer.String(), "line1")
+	})
+
+	t.Run("tail on failure with less lines", func(t *testing.T) {
+		logBuffer := new(bytes.Buffer)
+		log.Entry().Logger.SetOutput(logBuffer)
+		defer log.Entry().Logger.SetOutput(os.Stderr)
+
+		ex := Command{stdout: new(bytes.Buffer), stderr: new(bytes.Buffer)}
+		ex.TailOnFailure(10)
+		err := ex.RunExecutable("fail", "line1")
+
+		assert.Error(t, err)
+		assert.Contains(t, logBuffer.String(), "Last 1 lines of output")
+	})
+
+	t.Run("no tail on success", func(t *testing.T) {
+		logBuffer := new(bytes.Buffer)
+		log.Entry().Logger.SetOutput(logBuffer)
+		defer log.Entry().Logger.SetOutput(os.Stderr)
+
+		ex := Command{stdout: new(bytes.Buffer), stderr: new(bytes.Buffer)}
+		ex.TailOnFailure(2)
+		err := ex.RunExecutable("echo", "foo")
+
+		assert.NoError(t, err)
+		assert.Empty(t, logBuffer.String())
+	})
 }
 
 func TestPrepareOut(t *testing.T) {
@@ -173,6 +273,17 @@ func TestHelperProcess(*testing.T) {
 		}
 		fmt.Println(iargs...)
 		fmt.Fprintf(os.Stderr, "Stderr: command %v\n", cmd)
+	case "cat":
+		io.Copy(os.Stdout, os.Stdin)
+	case "env":
+		for _, s := range args {
+			fmt.Println(os.Getenv(s))
+		}
+	case "fail":
+		for _, s := range args {
+			fmt.Println(s)
+		}
+		os.Exit(1)
 	default:
 		fmt.Fprintf(os.Stderr, "Unknown command %q\n", cmd)
 		os.Exit(2)
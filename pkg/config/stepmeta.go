@@ -101,15 +101,22 @@ type Container struct {
 	WorkingDir      string      `json:"workingDir"`
 	Conditions      []Condition `json:"conditions,omitempty"`
 	Options         []Option    `json:"options,omitempt"`
-	//VolumeMounts    []VolumeMount `json:"volumeMounts,omitempty"`
+	Ports           []Port      `json:"ports,omitempty"`
+	// ToDo: Add the missing Volumes part to enable the volume mount completly
+	VolumeMounts    []VolumeMount   `json:"volumeMounts,omitempty"`
+	SecurityContext SecurityContext `json:"securityContext,omitempty"`
 }
 
-// ToDo: Add the missing Volumes part to enable the volume mount completly
 // VolumeMount defines an mount path
-// type VolumeMount struct {
-//	MountPath string `json:"mountPath"`
-//	Name      string `json:"name"`
-//}
+type VolumeMount struct {
+	MountPath string `json:"mountPath"`
+	Name      string `json:"name"`
+}
+
+// SecurityContext defines the security options of a container
+type SecurityContext struct {
+	Privileged bool `json:"privileged,omitempty"`
+}
 
 // Option defines an docker option
 type Option struct {
@@ -117,6 +124,13 @@ type Option struct {
 	Value string `json:"value"`
 }
 
+// Port defines a port exposed by a container
+type Port struct {
+	Name          string `json:"name,omitempty"`
+	ContainerPort int    `json:"containerPort"`
+	HostPort      int    `json:"hostPort,omitempty"`
+}
+
 // EnvVar defines an environment variable
 type EnvVar struct {
 	Name  string `json:"name"`
@@ -7,6 +7,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"text/template"
 
@@ -19,11 +20,13 @@ type stepInfo struct {
 	CreateCmdVar     string
 	ExportPrefix     string
 	FlagsFunc        string
+	InfluxResources  bool
 	Long             string
 	Metadata         []config.StepParameters
 	OSImport         bool
 	OutputResources  []map[string]string
 	Short            string
+	Sidecars         []config.Container
 	StepFunc         string
 	StepName         string
 }
@@ -32,8 +35,8 @@ type stepInfo struct {
 const stepGoTemplate = `package cmd
 
 import (
-	{{ if .OSImport }}"os"{{ end }}
-	{{ if .OutputResources }}"fmt"{{ end }}
+	{{ if or .OSImport .OutputResources }}"os"{{ end }}
+	{{ if .InfluxResources }}"fmt"{{ end }}
 	{{ if .OutputResources }}"path/filepath"{{ end }}
 
 	{{ if .ExportPrefix}}{{ .ExportPrefix }} "github.com/SAP/jenkins-library/cmd"{{ end -}}
@@ -70,6 +73,13 @@ func {{.CobraCmdFuncName}}() *cobra.Command {
 			return {{if .ExportPrefix}}{{ .ExportPrefix }}.{{end}}PrepareConfig(cmd, &metadata, "{{ .StepName }}", &my{{ .StepName | title}}Options, config.OpenPiperFile)
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
+			{{ if .Sidecars -}}
+			stopSidecars, err := {{if .ExportPrefix}}{{ .ExportPrefix }}.{{end}}StartSidecars(&metadata)
+			if err != nil {
+				return err
+			}
+			defer stopSidecars()
+			{{ end -}}
 			{{ if .OutputResources -}}
 			handler := func() {
 				{{- range $notused, $oRes := .OutputResources }}
@@ -87,10 +97,10 @@ func {{.CobraCmdFuncName}}() *cobra.Command {
 }
 
 func {{.FlagsFunc}}(cmd *cobra.Command) {
-	{{- range $key, $value := .Metadata }}
-	cmd.Flags().{{ $value.Type | flagType }}(&my{{ $.StepName | title }}Options.{{ $value.Name | golangName }}, "{{ $value.Name }}", {{ $value.Default }}, "{{ $value.Description }}"){{ end }}
+	{{- range $key, $value := .Metadata }}{{ if $value.Type | flagType }}
+	cmd.Flags().{{ $value.Type | flagType }}(&my{{ $.StepName | title }}Options.{{ $value.Name | golangName }}, "{{ $value.Name }}", {{ $value.Default }}, "{{ $value.Description }}"){{ end }}{{ end }}
 	{{- printf "\n" }}
-	{{- range $key, $value := .Metadata }}{{ if $value.Mandatory }}
+	{{- range $key, $value := .Metadata }}{{ if and $value.Mandatory ($value.Type | flagType) }}
 	cmd.MarkFlagRequired("{{ $value.Name }}"){{ end }}{{ end }}
 }
 
@@ -111,6 +121,24 @@ func {{ .StepName }}Metadata() config.StepData {
 					},{{ end }}
 				},
 			},
+			{{- if .Sidecars }}
+			Sidecars: []config.Container{
+				{{- range $notused, $sidecar := .Sidecars }}
+				{
+					Name:            "{{ $sidecar.Name }}",
+					Image:           "{{ $sidecar.Image }}",
+					ImagePullPolicy: "{{ $sidecar.ImagePullPolicy }}",
+					ReadyCommand:    {{ $sidecar.ReadyCommand | quote }},
+					WorkingDir:      "{{ $sidecar.WorkingDir }}",
+					Command:         []string{{ "{" }}{{ range $notused, $c := $sidecar.Command }}{{ $c | quote }},{{ end }}{{ "}" }},
+					EnvVars:         []config.EnvVar{{ "{" }}{{ range $notused, $env := $sidecar.EnvVars }}{{ "{" }}Name: "{{ $env.Name }}", Value: {{ $env.Value | quote }}{{ "}" }},{{ end }}{{ "}" }},
+					Options:         []config.Option{{ "{" }}{{ range $notused, $o := $sidecar.Options }}{{ "{" }}Name: "{{ $o.Name }}", Value: {{ $o.Value | quote }}{{ "}" }},{{ end }}{{ "}" }},
+					Ports:           []config.Port{{ "{" }}{{ range $notused, $p := $sidecar.Ports }}{{ "{" }}Name: "{{ $p.Name }}", ContainerPort: {{ $p.ContainerPort }}, HostPort: {{ $p.HostPort }}{{ "}" }},{{ end }}{{ "}" }},
+					VolumeMounts:    []config.VolumeMount{{ "{" }}{{ range $notused, $v := $sidecar.VolumeMounts }}{{ "{" }}Name: "{{ $v.Name }}", MountPath: "{{ $v.MountPath }}"{{ "}" }},{{ end }}{{ "}" }},
+					SecurityContext: config.SecurityContext{Privileged: {{ $sidecar.SecurityContext.Privileged }}},
+				},{{ end }}
+			},
+			{{- end }}
 		},
 	}
 	return theMetaData
@@ -223,13 +251,16 @@ func setDefaultParameters(stepData *config.StepData) (bool, error) {
 			case "[]string":
 				// ToDo: Check if default should be read from env
 				param.Default = "[]string{}"
+			case "map[string]interface{}", "[]map[string]interface{}":
+				// only available via configuration, no flag and thus no default
+				param.Default = "nil"
 			default:
 				return false, fmt.Errorf("Meta data type not set or not known: '%v'", param.Type)
 			}
 		} else {
 			switch param.Type {
 			case "string":
-				param.Default = fmt.Sprintf("\"%v\"", param.Default)
+				param.Default = strconv.Quote(fmt.Sprint(param.Default))
 			case "bool":
 				boolVal := "false"
 				if param.Default.(bool) == true {
@@ -237,7 +268,11 @@ func setDefaultParameters(stepData *config.StepData) (bool, error) {
 				}
 				param.Default = boolVal
 			case "[]string":
-				param.Default = fmt.Sprintf("[]string{\"%v\"}", strings.Join(getStringSliceFromInterface(param.Default), "\", \""))
+				values := []string{}
+				for _, v := range getStringSliceFromInterface(param.Default) {
+					values = append(values, strconv.Quote(v))
+				}
+				param.Default = fmt.Sprintf("[]string{%v}", strings.Join(values, ", "))
 			default:
 				return false, fmt.Errorf("Meta data type not set or not known: '%v'", param.Type)
 			}
@@ -261,11 +296,22 @@ func getStepInfo(stepData *config.StepData, osImport bool, exportPrefix string)
 			FlagsFunc:        fmt.Sprintf("add%vFlags", strings.Title(stepData.Metadata.Name)),
 			OSImport:         osImport,
 			OutputResources:  oRes,
+			InfluxResources:  hasInfluxResources(stepData),
 			ExportPrefix:     exportPrefix,
+			Sidecars:         stepData.Spec.Sidecars,
 		},
 		err
 }
 
+func hasInfluxResources(stepData *config.StepData) bool {
+	for _, res := range stepData.Spec.Outputs.Resources {
+		if res.Type == "influx" {
+			return true
+		}
+	}
+	return false
+}
+
 func getOutputResourceDetails(stepData *config.StepData) ([]map[string]string, error) {
 	outputResources := []map[string]string{}
 
@@ -359,6 +405,7 @@ func stepTemplate(myStepInfo stepInfo) []byte {
 		"golangName": golangNameTitle,
 		"title":      strings.Title,
 		"longName":   longName,
+		"quote":      strconv.Quote,
 	}
 
 	tmpl, err := template.New("step").Funcs(funcMap).Parse(stepGoTemplate)
@@ -434,6 +481,9 @@ func flagType(paramType string) string {
 		theFlagType = "StringVar"
 	case "[]string":
 		theFlagType = "StringSliceVar"
+	case "map[string]interface{}", "[]map[string]interface{}":
+		// structured parameters can only be provided via configuration
+		theFlagType = ""
 	default:
 		fmt.Printf("Meta data type not set or not known: '%v'\n", paramType)
 		os.Exit(1)
@@ -121,6 +121,8 @@ func TestSetDefaultParameters(t *testing.T) {
 						{Name: "param3", Scope: []string{"PARAMETERS"}, Type: "bool"},
 						{Name: "param4", Scope: []string{"ENV"}, Type: "[]string", Default: stringSliceDefault},
 						{Name: "param5", Scope: []string{"ENV"}, Type: "[]string"},
+						{Name: "param6", Scope: []string{"STEPS"}, Type: "string", Default: `available at "(.*)"`},
+						{Name: "param7", Scope: []string{"STEPS"}, Type: "[]map[string]interface{}"},
 					},
 				},
 			},
@@ -133,6 +135,8 @@ func TestSetDefaultParameters(t *testing.T) {
 			"false",
 			"[]string{\"val4_1\", \"val4_2\"}",
 			"[]string{}",
+			`"available at \"(.*)\""`,
+			"nil",
 		}
 
 		osImport, err := setDefaultParameters(&stepData)
@@ -208,6 +212,90 @@ func TestGetStepInfo(t *testing.T) {
 
 }
 
+func TestStepTemplateSidecars(t *testing.T) {
+
+	stepData := config.StepData{
+		Metadata: config.StepMetadata{Name: "testStep"},
+		Spec: config.StepSpec{
+			Sidecars: []config.Container{
+				{
+					Name:            "selenium",
+					Image:           "selenium/standalone-chrome",
+					ReadyCommand:    "curl \"http://localhost:4444\"",
+					EnvVars:         []config.EnvVar{{Name: "NO_PROXY", Value: "localhost"}},
+					Ports:           []config.Port{{ContainerPort: 4444}},
+					VolumeMounts:    []config.VolumeMount{{Name: "dev-shm", MountPath: "/dev/shm"}},
+					SecurityContext: config.SecurityContext{Privileged: true},
+				},
+			},
+		},
+	}
+
+	myStepInfo, err := getStepInfo(&stepData, false, "")
+	assert.NoError(t, err)
+
+	step := string(stepTemplate(myStepInfo))
+
+	assert.Contains(t, step, "stopSidecars, err := StartSidecars(&metadata)")
+	assert.Contains(t, step, `ReadyCommand:    "curl \"http://localhost:4444\"",`)
+	assert.Contains(t, step, `EnvVars:         []config.EnvVar{{Name: "NO_PROXY", Value: "localhost"},},`)
+	assert.Contains(t, step, `Ports:           []config.Port{{Name: "", ContainerPort: 4444, HostPort: 0},},`)
+	assert.Contains(t, step, `VolumeMounts:    []config.VolumeMount{{Name: "dev-shm", MountPath: "/dev/shm"},},`)
+	assert.Contains(t, step, `SecurityContext: config.SecurityContext{Privileged: true},`)
+}
+
+func TestStepTemplateImports(t *testing.T) {
+
+	stepData := config.StepData{
+		Metadata: config.StepMetadata{Name: "testStep"},
+		Spec: config.StepSpec{
+			Outputs: config.StepOutputs{
+				Resources: []config.StepResources{
+					{Name: "commonPipelineEnvironment", Type: "piperEnvironment", Parameters: []map[string]interface{}{{"name": "test/param"}}},
+				},
+			},
+		},
+	}
+
+	myStepInfo, err := getStepInfo(&stepData, false, "")
+	assert.NoError(t, err)
+
+	step := string(stepTemplate(myStepInfo))
+
+	// os is required for persisting the pipeline environment, fmt only for influx resources
+	assert.Contains(t, step, `"os"`)
+	assert.NotContains(t, step, `"fmt"`)
+}
+
+func TestStepTemplateStructuredParameters(t *testing.T) {
+
+	stepData := config.StepData{
+		Metadata: config.StepMetadata{Name: "testStep"},
+		Spec: config.StepSpec{
+			Inputs: config.StepInputs{
+				Parameters: []config.StepParameters{
+					{Name: "targets", Scope: []string{"STEPS"}, Type: "[]map[string]interface{}", Mandatory: true},
+					{Name: "extension", Scope: []string{"STEPS"}, Type: "map[string]interface{}"},
+				},
+			},
+		},
+	}
+
+	_, err := setDefaultParameters(&stepData)
+	assert.NoError(t, err)
+	myStepInfo, err := getStepInfo(&stepData, false, "")
+	assert.NoError(t, err)
+
+	step := string(stepTemplate(myStepInfo))
+
+	assert.Contains(t, step, "Targets []map[string]interface{} `json:\"targets,omitempty\"`")
+	// no flag available for structured parameters
+	assert.NotContains(t, step, `"targets", nil`)
+	assert.NotContains(t, step, `MarkFlagRequired("targets")`)
+	assert.Contains(t, step, "Extension map[string]interface{} `json:\"extension,omitempty\"`")
+	assert.NotContains(t, step, `"extension", nil`)
+}
+
 func TestLongName(t *testing.T) {
 	tt := []struct {
 		input    string
@@ -248,6 +336,8 @@ func TestFlagType(t *testing.T) {
 		{input: "bool", expected: "BoolVar"},
 		{input: "string", expected: "StringVar"},
 		{input: "[]string", expected: "StringSliceVar"},
+		{input: "[]map[string]interface{}", expected: ""},
+		{input: "map[string]interface{}", expected: ""},
 	}
 
 	for k, v := range tt {
@@ -0,0 +1,154 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v28/github"
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+)
+
+// validity of the JWT authenticating the GitHub App, GitHub accepts at most 10 minutes
+const appJWTValidity = 9 * time.Minute
+
+// installation tokens are refreshed this time before they expire
+const installationTokenRefreshMargin = 5 * time.Minute
+
+// NewAppClient creates a new GitHub client authenticating as installation of a GitHub App.
+// The installation token is created using the private key of the app and refreshed before it expires.
+func NewAppClient(appID, installationID int64, privateKey []byte, apiURL, uploadURL string) (context.Context, *github.Client, error) {
+	key, err := parseAppPrivateKey(privateKey)
+	if err != nil {
+		return context.Background(), nil, err
+	}
+
+	if !strings.HasSuffix(apiURL, "/") {
+		apiURL += "/"
+	}
+	ts := &appTokenSource{
+		appID:          appID,
+		installationID: installationID,
+		key:            key,
+		apiURL:         apiURL,
+		client:         &http.Client{Transport: newRateLimitTransport(http.DefaultTransport)},
+		now:            time.Now,
+	}
+	return newClient(oauth2.ReuseTokenSource(nil, ts), apiURL, uploadURL)
+}
+
+// appTokenSource creates installation tokens of a GitHub App
+type appTokenSource struct {
+	appID          int64
+	installationID int64
+	key            *rsa.PrivateKey
+	apiURL         string
+	client         *http.Client
+	now            func() time.Time
+}
+
+// Token exchanges a JWT signed with the private key of the app for an installation token
+func (s *appTokenSource) Token() (*oauth2.Token, error) {
+	jwt, err := s.jwt()
+	if err != nil {
+		return nil, err
+	}
+
+	url := fmt.Sprintf("%vapp/installations/%v/access_tokens", s.apiURL, s.installationID)
+	req, err := http.NewRequest(http.MethodPost, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+jwt)
+	req.Header.Set("Accept", "application/vnd.github.machine-man-preview+json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to get installation token of GitHub App %v", s.appID)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to read installation token of GitHub App %v", s.appID)
+	}
+	if resp.StatusCode != http.StatusCreated {
+		return nil, fmt.Errorf("Failed to get installation token of GitHub App %v: %v %v", s.appID, resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	installationToken := struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}{}
+	if err := json.Unmarshal(body, &installationToken); err != nil {
+		return nil, errors.Wrapf(err, "Failed to read installation token of GitHub App %v", s.appID)
+	}
+
+	return &oauth2.Token{
+		AccessToken: installationToken.Token,
+		TokenType:   "token",
+		Expiry:      installationToken.ExpiresAt.Add(-installationTokenRefreshMargin),
+	}, nil
+}
+
+// jwt creates the JSON Web Token authenticating the app, it is signed using RS256
+func (s *appTokenSource) jwt() (string, error) {
+	now := s.now()
+	header := map[string]string{"alg": "RS256", "typ": "JWT"}
+	claims := map[string]interface{}{
+		// issued in the past to compensate for clock differences
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(appJWTValidity).Unix(),
+		"iss": s.appID,
+	}
+
+	segments := []string{}
+	for _, part := range []interface{}{header, claims} {
+		content, err := json.Marshal(part)
+		if err != nil {
+			return "", err
+		}
+		segments = append(segments, base64.RawURLEncoding.EncodeToString(content))
+	}
+
+	signingInput := strings.Join(segments, ".")
+	hash := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", errors.Wrap(err, "Failed to sign JWT of GitHub App")
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// parseAppPrivateKey reads the PEM encoded private key as provided by GitHub (PKCS #1) or in PKCS #8 format
+func parseAppPrivateKey(privateKey []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(bytes.TrimSpace(privateKey))
+	if block == nil {
+		return nil, fmt.Errorf("Failed to parse private key of GitHub App: no PEM data found")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to parse private key of GitHub App")
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("Failed to parse private key of GitHub App: RSA key expected")
+	}
+	return rsaKey, nil
+}
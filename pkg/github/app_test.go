@@ -0,0 +1,142 @@
+package github
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewAppClient(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal("Failed to generate key")
+	}
+	pemKey := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tokenRequests := 0
+	expiresAt := time.Now().Add(time.Hour)
+	var jwtClaims map[string]interface{}
+	var jwtError error
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/app/installations/2/access_tokens":
+			tokenRequests++
+			jwtClaims, jwtError = verifyJWT(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "), &key.PublicKey)
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintf(w, `{"token": "token%v", "expires_at": "%v"}`, tokenRequests, expiresAt.Format(time.RFC3339))
+		case "/repos/TEST/test":
+			authorization = r.Header.Get("Authorization")
+			fmt.Fprint(w, `{"name": "test"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	t.Run("installation token", func(t *testing.T) {
+		ctx, client, err := NewAppClient(1, 2, pemKey, server.URL, server.URL)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		client.Repositories.Get(ctx, "TEST", "test")
+		_, _, err = client.Repositories.Get(ctx, "TEST", "test")
+
+		if assert.NoError(t, err) {
+			assert.Equal(t, "token token1", authorization)
+			assert.Equal(t, 1, tokenRequests, "valid token not reused")
+			assert.NoError(t, jwtError)
+			assert.Equal(t, float64(1), jwtClaims["iss"])
+		}
+	})
+
+	t.Run("token refresh", func(t *testing.T) {
+		tokenRequests = 0
+		// the token expires within the refresh margin
+		expiresAt = time.Now().Add(installationTokenRefreshMargin - time.Minute)
+		ctx, client, _ := NewAppClient(1, 2, pemKey, server.URL+"/", server.URL)
+
+		client.Repositories.Get(ctx, "TEST", "test")
+		_, _, err = client.Repositories.Get(ctx, "TEST", "test")
+
+		if assert.NoError(t, err) {
+			assert.Equal(t, "token token2", authorization)
+			assert.Equal(t, 2, tokenRequests)
+		}
+	})
+
+	t.Run("JWT", func(t *testing.T) {
+		s := appTokenSource{appID: 1, key: key, now: func() time.Time { return now }}
+
+		jwt, err := s.jwt()
+
+		if assert.NoError(t, err) {
+			header, _ := base64.RawURLEncoding.DecodeString(strings.Split(jwt, ".")[0])
+			assert.JSONEq(t, `{"alg": "RS256", "typ": "JWT"}`, string(header))
+			claims, err := verifyJWT(jwt, &key.PublicKey)
+			assert.NoError(t, err)
+			assert.Equal(t, map[string]interface{}{
+				"iat": float64(now.Add(-time.Minute).Unix()),
+				"exp": float64(now.Add(9 * time.Minute).Unix()),
+				"iss": float64(1),
+			}, claims)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, _, err := NewAppClient(1, 2, []byte("no key"), server.URL, server.URL)
+		assert.EqualError(t, err, "Failed to parse private key of GitHub App: no PEM data found")
+
+		ctx, client, _ := NewAppClient(1, 3, pemKey, server.URL, server.URL)
+		_, _, err = client.Repositories.Get(ctx, "TEST", "test")
+		assert.Contains(t, fmt.Sprint(err), "Failed to get installation token of GitHub App 1: 404 Not Found")
+	})
+}
+
+func TestParseAppPrivateKey(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 1024)
+	pkcs8, _ := x509.MarshalPKCS8PrivateKey(key)
+
+	parsed, err := parseAppPrivateKey(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8}))
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, key.D, parsed.D)
+	}
+}
+
+// verifyJWT verifies the RS256 signature of the JWT and provides its claims
+func verifyJWT(jwt string, key *rsa.PublicKey) (map[string]interface{}, error) {
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("invalid JWT '%v'", jwt)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], signature); err != nil {
+		return nil, err
+	}
+	content, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, err
+	}
+	claims := map[string]interface{}{}
+	return claims, json.Unmarshal(content, &claims)
+}
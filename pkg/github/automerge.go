@@ -0,0 +1,72 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-github/v28/github"
+)
+
+const enableAutoMergeMutation = `mutation($pullRequestId: ID!, $mergeMethod: PullRequestMergeMethod!) {
+  enablePullRequestAutoMerge(input: {pullRequestId: $pullRequestId, mergeMethod: $mergeMethod}) {
+    clientMutationId
+  }
+}`
+
+// AutoMergeService enables auto-merge for pull requests which is only available via the GraphQL API
+type AutoMergeService struct {
+	client *github.Client
+}
+
+// NewAutoMergeService creates a new AutoMergeService based on the given GitHub client
+func NewAutoMergeService(client *github.Client) *AutoMergeService {
+	return &AutoMergeService{client: client}
+}
+
+type graphQLResponse struct {
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// EnableAutoMerge lets GitHub merge the pull request as soon as all requirements are met.
+// pullRequestID is the node ID of the pull request, mergeMethod is one of MERGE, SQUASH or REBASE.
+func (s *AutoMergeService) EnableAutoMerge(ctx context.Context, pullRequestID, mergeMethod string) (*github.Response, error) {
+	body := map[string]interface{}{
+		"query": enableAutoMergeMutation,
+		"variables": map[string]string{
+			"pullRequestId": pullRequestID,
+			"mergeMethod":   strings.ToUpper(mergeMethod),
+		},
+	}
+
+	req, err := s.client.NewRequest("POST", graphQLURL(s.client), body)
+	if err != nil {
+		return nil, err
+	}
+
+	result := graphQLResponse{}
+	resp, err := s.client.Do(ctx, req, &result)
+	if err != nil {
+		return resp, err
+	}
+	if len(result.Errors) > 0 {
+		messages := []string{}
+		for _, e := range result.Errors {
+			messages = append(messages, e.Message)
+		}
+		return resp, fmt.Errorf("GraphQL request failed: %v", strings.Join(messages, "; "))
+	}
+	return resp, nil
+}
+
+// graphQLURL provides the GraphQL endpoint, on GitHub Enterprise it is located next to the REST API at /api/graphql
+func graphQLURL(client *github.Client) string {
+	if strings.HasSuffix(client.BaseURL.Path, "/api/v3/") {
+		u := *client.BaseURL
+		u.Path = strings.TrimSuffix(u.Path, "v3/") + "graphql"
+		return u.String()
+	}
+	return "graphql"
+}
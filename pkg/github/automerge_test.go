@@ -0,0 +1,59 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-github/v28/github"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnableAutoMerge(t *testing.T) {
+	var requestPath string
+	var requestBody map[string]interface{}
+	response := `{"data": {"enablePullRequestAutoMerge": {"clientMutationId": null}}}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestPath = r.URL.Path
+		json.NewDecoder(r.Body).Decode(&requestBody)
+		fmt.Fprint(w, response)
+	}))
+	defer server.Close()
+
+	t.Run("github.com", func(t *testing.T) {
+		client, err := github.NewEnterpriseClient(server.URL, server.URL, nil)
+		assert.NoError(t, err)
+
+		_, err = NewAutoMergeService(client).EnableAutoMerge(context.Background(), "PR_1", "squash")
+
+		if assert.NoError(t, err) {
+			assert.Equal(t, "/graphql", requestPath)
+			assert.Contains(t, requestBody["query"], "enablePullRequestAutoMerge")
+			assert.Equal(t, map[string]interface{}{"pullRequestId": "PR_1", "mergeMethod": "SQUASH"}, requestBody["variables"])
+		}
+	})
+
+	t.Run("GitHub Enterprise", func(t *testing.T) {
+		client, err := github.NewEnterpriseClient(server.URL+"/api/v3/", server.URL, nil)
+		assert.NoError(t, err)
+
+		_, err = NewAutoMergeService(client).EnableAutoMerge(context.Background(), "PR_1", "merge")
+
+		if assert.NoError(t, err) {
+			assert.Equal(t, "/api/graphql", requestPath)
+		}
+	})
+
+	t.Run("GraphQL error", func(t *testing.T) {
+		response = `{"errors": [{"message": "Pull request is in clean status"}]}`
+		client, err := github.NewEnterpriseClient(server.URL, server.URL, nil)
+		assert.NoError(t, err)
+
+		_, err = NewAutoMergeService(client).EnableAutoMerge(context.Background(), "PR_1", "merge")
+
+		assert.EqualError(t, err, "GraphQL request failed: Pull request is in clean status")
+	})
+}
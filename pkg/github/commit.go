@@ -0,0 +1,171 @@
+package github
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-github/v28/github"
+	"github.com/pkg/errors"
+)
+
+// CommitFile denotes a file which is committed
+type CommitFile struct {
+	// Path of the file inside the repository using slashes as separator
+	Path       string
+	Content    []byte
+	Executable bool
+}
+
+// BranchCommit describes a commit of files to a branch
+type BranchCommit struct {
+	// Base is the branch the commit is based on
+	Base string
+	// Branch is the branch which is created or reset to the new commit
+	Branch  string
+	Message string
+	Files   []CommitFile
+	// Author of the commit, the owner of the token is used if not set
+	Author *github.CommitAuthor
+}
+
+// CommitService commits files to a branch using the Git Data API, i.e. without a local git repository
+type CommitService struct {
+	client *github.Client
+}
+
+// NewCommitService creates a new CommitService based on the given GitHub client
+func NewCommitService(client *github.Client) *CommitService {
+	return &CommitService{client: client}
+}
+
+// CommitFiles creates a commit containing the files on top of the base branch and points the branch to it.
+// An existing branch is reset, hence the branch always contains a single commit on top of the base branch.
+// Resetting is refused in case the branch contains commits which have not been created by CommitFiles.
+// No commit is created in case the files do not contain any changes compared to the base branch, then nil is returned.
+// In case the branch already contains exactly these changes it is left untouched and its head commit is returned.
+func (s *CommitService) CommitFiles(ctx context.Context, owner, repo string, commit BranchCommit) (*github.Commit, error) {
+	baseRef, _, err := s.client.Git.GetRef(ctx, owner, repo, "heads/"+commit.Base)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to get branch '%v'", commit.Base)
+	}
+	baseCommit, _, err := s.client.Git.GetCommit(ctx, owner, repo, baseRef.GetObject().GetSHA())
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to get commit '%v'", baseRef.GetObject().GetSHA())
+	}
+
+	branchSHA, err := s.branchHead(ctx, owner, repo, commit.Branch)
+	if err != nil {
+		return nil, err
+	}
+	// a branch which is contained in the base branch is fast-forwarded, only a diverged branch is force-updated
+	diverged := false
+	if len(branchSHA) > 0 {
+		if diverged, err = s.verifyBranchReset(ctx, owner, repo, commit, baseCommit.GetSHA(), branchSHA); err != nil {
+			return nil, err
+		}
+	}
+
+	entries := []github.TreeEntry{}
+	for _, file := range commit.Files {
+		blob, _, err := s.client.Git.CreateBlob(ctx, owner, repo, &github.Blob{
+			Content:  github.String(base64.StdEncoding.EncodeToString(file.Content)),
+			Encoding: github.String("base64"),
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to create blob for '%v'", file.Path)
+		}
+		mode := "100644"
+		if file.Executable {
+			mode = "100755"
+		}
+		entries = append(entries, github.TreeEntry{Path: github.String(file.Path), Mode: github.String(mode), Type: github.String("blob"), SHA: blob.SHA})
+	}
+
+	tree, _, err := s.client.Git.CreateTree(ctx, owner, repo, baseCommit.GetTree().GetSHA(), entries)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to create tree")
+	}
+	if tree.GetSHA() == baseCommit.GetTree().GetSHA() {
+		return nil, nil
+	}
+	if len(branchSHA) > 0 {
+		branchCommit, _, err := s.client.Git.GetCommit(ctx, owner, repo, branchSHA)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to get commit '%v'", branchSHA)
+		}
+		if branchCommit.GetTree().GetSHA() == tree.GetSHA() {
+			return branchCommit, nil
+		}
+	}
+
+	newCommit, _, err := s.client.Git.CreateCommit(ctx, owner, repo, &github.Commit{
+		Message: github.String(commit.Message),
+		Tree:    &github.Tree{SHA: tree.SHA},
+		Parents: []github.Commit{{SHA: baseCommit.SHA}},
+		Author:  commit.Author,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to create commit")
+	}
+
+	ref := github.Reference{Ref: github.String("refs/heads/" + commit.Branch), Object: &github.GitObject{SHA: newCommit.SHA}}
+	if len(branchSHA) > 0 {
+		_, _, err = s.client.Git.UpdateRef(ctx, owner, repo, &ref, diverged)
+	} else {
+		_, _, err = s.client.Git.CreateRef(ctx, owner, repo, &ref)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to point branch '%v' to commit '%v'", commit.Branch, newCommit.GetSHA())
+	}
+	return newCommit, nil
+}
+
+// branchHead provides the commit the branch points to, an empty string in case the branch does not exist
+func (s *CommitService) branchHead(ctx context.Context, owner, repo, branch string) (string, error) {
+	ref, resp, err := s.client.Git.GetRef(ctx, owner, repo, "heads/"+branch)
+	if err != nil {
+		// a successful response in case of an error means that only branches starting with the name exist
+		if resp != nil && (resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusOK) {
+			return "", nil
+		}
+		return "", errors.Wrapf(err, "Failed to get branch '%v'", branch)
+	}
+	if ref.GetRef() != "refs/heads/"+branch {
+		return "", nil
+	}
+	return ref.GetObject().GetSHA(), nil
+}
+
+// verifyBranchReset ensures that resetting the branch does not discard any commit pushed by someone else, i.e. the branch
+// only contains commits of the base branch and at most a single commit with the same message created by a previous run.
+// It provides whether the branch diverged from the base branch, i.e. whether it contains such a commit.
+func (s *CommitService) verifyBranchReset(ctx context.Context, owner, repo string, commit BranchCommit, baseSHA, branchSHA string) (bool, error) {
+	contained, err := s.containedInBase(ctx, owner, repo, baseSHA, branchSHA)
+	if err != nil || contained {
+		return false, err
+	}
+
+	branchCommit, _, err := s.client.Git.GetCommit(ctx, owner, repo, branchSHA)
+	if err != nil {
+		return false, errors.Wrapf(err, "Failed to get commit '%v'", branchSHA)
+	}
+	if len(branchCommit.Parents) == 1 && strings.TrimSpace(branchCommit.GetMessage()) == strings.TrimSpace(commit.Message) {
+		contained, err = s.containedInBase(ctx, owner, repo, baseSHA, branchCommit.Parents[0].GetSHA())
+		if err != nil || contained {
+			return contained, err
+		}
+	}
+	return false, fmt.Errorf("Branch '%v' contains commits which have not been created by this step, it is not reset to '%v'", commit.Branch, commit.Base)
+}
+
+// containedInBase checks whether the commit is part of the history of the base commit
+func (s *CommitService) containedInBase(ctx context.Context, owner, repo, baseSHA, sha string) (bool, error) {
+	comparison, _, err := NewCompareService(s.client).CompareCommits(ctx, owner, repo, baseSHA, sha, nil)
+	if err != nil {
+		return false, errors.Wrapf(err, "Failed to compare commit '%v' with '%v'", sha, baseSHA)
+	}
+	return comparison.GetStatus() == "identical" || comparison.GetStatus() == "behind", nil
+}
@@ -0,0 +1,297 @@
+package github
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-github/v28/github"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeGitDataAPI implements the parts of the Git Data API required for committing files
+type fakeGitDataAPI struct {
+	mutex   sync.Mutex
+	refs    map[string]string
+	commits map[string]*github.Commit
+	trees   map[string]map[string]string
+	blobs   map[string]string
+	// requests contains method and path of all modifying requests
+	requests []string
+	// forcedRefs contains the refs which have been force-updated
+	forcedRefs []string
+}
+
+func newFakeGitDataAPI() *fakeGitDataAPI {
+	api := fakeGitDataAPI{
+		refs:    map[string]string{},
+		commits: map[string]*github.Commit{},
+		trees:   map[string]map[string]string{},
+		blobs:   map[string]string{},
+	}
+	baseTree := api.addTree(map[string]string{"README.md": api.addBlob("readme")})
+	api.commits["base"] = &github.Commit{SHA: github.String("base"), Tree: &github.Tree{SHA: &baseTree}}
+	api.refs["refs/heads/master"] = "base"
+	return &api
+}
+
+func fakeSHA(content string) string {
+	return fmt.Sprintf("%x", sha1.Sum([]byte(content)))
+}
+
+func (api *fakeGitDataAPI) addBlob(content string) string {
+	sha := fakeSHA("blob " + content)
+	api.blobs[sha] = content
+	return sha
+}
+
+func (api *fakeGitDataAPI) addTree(entries map[string]string) string {
+	lines := []string{}
+	for path, sha := range entries {
+		lines = append(lines, path+" "+sha)
+	}
+	sort.Strings(lines)
+	sha := fakeSHA("tree " + strings.Join(lines, "\n"))
+	api.trees[sha] = entries
+	return sha
+}
+
+// isAncestor checks whether the commit is reachable from sha via its parents
+func (api *fakeGitDataAPI) isAncestor(commit, sha string) bool {
+	if commit == sha {
+		return true
+	}
+	for _, parent := range api.commits[sha].Parents {
+		if api.isAncestor(commit, parent.GetSHA()) {
+			return true
+		}
+	}
+	return false
+}
+
+func (api *fakeGitDataAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	api.mutex.Lock()
+	defer api.mutex.Unlock()
+
+	path := strings.TrimPrefix(r.URL.Path, "/repos/TEST/test/git/")
+	if r.Method != http.MethodGet {
+		api.requests = append(api.requests, r.Method+" "+path)
+	}
+	var result interface{}
+	switch {
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/repos/TEST/test/compare/"):
+		shas := strings.Split(strings.TrimPrefix(r.URL.Path, "/repos/TEST/test/compare/"), "...")
+		status := "diverged"
+		switch {
+		case shas[0] == shas[1]:
+			status = "identical"
+		case api.isAncestor(shas[1], shas[0]):
+			status = "behind"
+		case api.isAncestor(shas[0], shas[1]):
+			status = "ahead"
+		}
+		result = github.CommitsComparison{Status: &status}
+	case r.Method == http.MethodGet && strings.HasPrefix(path, "refs/"):
+		sha, ok := api.refs[path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message": "Not Found"}`)
+			return
+		}
+		result = github.Reference{Ref: &path, Object: &github.GitObject{SHA: &sha}}
+	case r.Method == http.MethodGet && strings.HasPrefix(path, "commits/"):
+		result = api.commits[strings.TrimPrefix(path, "commits/")]
+	case r.Method == http.MethodPost && path == "blobs":
+		blob := github.Blob{}
+		json.NewDecoder(r.Body).Decode(&blob)
+		content, _ := base64.StdEncoding.DecodeString(blob.GetContent())
+		result = github.Blob{SHA: github.String(api.addBlob(string(content)))}
+	case r.Method == http.MethodPost && path == "trees":
+		request := struct {
+			BaseTree string             `json:"base_tree"`
+			Entries  []github.TreeEntry `json:"tree"`
+		}{}
+		json.NewDecoder(r.Body).Decode(&request)
+		entries := map[string]string{}
+		for p, sha := range api.trees[request.BaseTree] {
+			entries[p] = sha
+		}
+		for _, entry := range request.Entries {
+			entries[entry.GetPath()] = entry.GetSHA()
+		}
+		result = github.Tree{SHA: github.String(api.addTree(entries))}
+	case r.Method == http.MethodPost && path == "commits":
+		request := struct {
+			Message string   `json:"message"`
+			Tree    string   `json:"tree"`
+			Parents []string `json:"parents"`
+		}{}
+		json.NewDecoder(r.Body).Decode(&request)
+		commit := github.Commit{
+			SHA:     github.String(fakeSHA(fmt.Sprintf("commit %v %v", request.Tree, request.Message))),
+			Message: &request.Message,
+			Tree:    &github.Tree{SHA: &request.Tree},
+		}
+		for _, parent := range request.Parents {
+			commit.Parents = append(commit.Parents, github.Commit{SHA: github.String(parent)})
+		}
+		api.commits[commit.GetSHA()] = &commit
+		result = commit
+	case r.Method == http.MethodPost && path == "refs":
+		request := struct {
+			Ref string `json:"ref"`
+			SHA string `json:"sha"`
+		}{}
+		json.NewDecoder(r.Body).Decode(&request)
+		api.refs[request.Ref] = request.SHA
+		result = github.Reference{Ref: &request.Ref, Object: &github.GitObject{SHA: &request.SHA}}
+	case r.Method == http.MethodPatch && strings.HasPrefix(path, "refs/"):
+		request := struct {
+			SHA   string `json:"sha"`
+			Force bool   `json:"force"`
+		}{}
+		json.NewDecoder(r.Body).Decode(&request)
+		api.refs[path] = request.SHA
+		if request.Force {
+			api.forcedRefs = append(api.forcedRefs, path)
+		}
+		result = github.Reference{Ref: &path, Object: &github.GitObject{SHA: &request.SHA}}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"message": "Not Found"}`)
+		return
+	}
+	json.NewEncoder(w).Encode(result)
+}
+
+func TestCommitFiles(t *testing.T) {
+	ctx := context.Background()
+	files := []CommitFile{
+		{Path: "README.md", Content: []byte("new readme")},
+		{Path: "bin/build.sh", Content: []byte("#!/bin/sh"), Executable: true},
+	}
+
+	t.Run("new branch", func(t *testing.T) {
+		api := newFakeGitDataAPI()
+		server := httptest.NewServer(api)
+		defer server.Close()
+		client, _ := github.NewEnterpriseClient(server.URL, server.URL, nil)
+
+		commit, err := NewCommitService(client).CommitFiles(ctx, "TEST", "test", BranchCommit{Base: "master", Branch: "update/readme", Message: "Update readme", Files: files})
+
+		if assert.NoError(t, err) && assert.NotNil(t, commit) {
+			assert.Equal(t, commit.GetSHA(), api.refs["refs/heads/update/readme"])
+			assert.Equal(t, "base", api.refs["refs/heads/master"])
+			assert.Equal(t, "Update readme", api.commits[commit.GetSHA()].GetMessage())
+			assert.Equal(t, "base", api.commits[commit.GetSHA()].Parents[0].GetSHA())
+
+			tree := api.trees[api.commits[commit.GetSHA()].GetTree().GetSHA()]
+			assert.Equal(t, "new readme", api.blobs[tree["README.md"]])
+			assert.Equal(t, "#!/bin/sh", api.blobs[tree["bin/build.sh"]])
+			assert.Contains(t, api.requests, "POST refs")
+		}
+	})
+
+	t.Run("existing branch", func(t *testing.T) {
+		api := newFakeGitDataAPI()
+		api.commits["outdated"] = &github.Commit{SHA: github.String("outdated"), Message: github.String("Update readme"), Parents: []github.Commit{{SHA: github.String("old")}}}
+		api.commits["old"] = &github.Commit{SHA: github.String("old")}
+		api.commits["base"].Parents = []github.Commit{{SHA: github.String("old")}}
+		api.refs["refs/heads/update/readme"] = "outdated"
+		server := httptest.NewServer(api)
+		defer server.Close()
+		client, _ := github.NewEnterpriseClient(server.URL, server.URL, nil)
+
+		commit, err := NewCommitService(client).CommitFiles(ctx, "TEST", "test", BranchCommit{Base: "master", Branch: "update/readme", Message: "Update readme", Files: files})
+
+		if assert.NoError(t, err) && assert.NotNil(t, commit) {
+			assert.Equal(t, commit.GetSHA(), api.refs["refs/heads/update/readme"])
+			assert.Contains(t, api.requests, "PATCH refs/heads/update/readme")
+			assert.NotContains(t, api.requests, "POST refs")
+			assert.Equal(t, []string{"refs/heads/update/readme"}, api.forcedRefs)
+		}
+	})
+
+	t.Run("existing branch up to date", func(t *testing.T) {
+		api := newFakeGitDataAPI()
+		tree := api.addTree(map[string]string{"README.md": api.addBlob("new readme"), "bin/build.sh": api.addBlob("#!/bin/sh")})
+		api.commits["current"] = &github.Commit{SHA: github.String("current"), Message: github.String("Update readme"), Tree: &github.Tree{SHA: &tree}, Parents: []github.Commit{{SHA: github.String("base")}}}
+		api.refs["refs/heads/update/readme"] = "current"
+		server := httptest.NewServer(api)
+		defer server.Close()
+		client, _ := github.NewEnterpriseClient(server.URL, server.URL, nil)
+
+		commit, err := NewCommitService(client).CommitFiles(ctx, "TEST", "test", BranchCommit{Base: "master", Branch: "update/readme", Message: "Update readme", Files: files})
+
+		if assert.NoError(t, err) && assert.NotNil(t, commit) {
+			assert.Equal(t, "current", commit.GetSHA())
+			assert.Equal(t, "current", api.refs["refs/heads/update/readme"])
+			assert.NotContains(t, api.requests, "POST commits")
+			assert.NotContains(t, api.requests, "PATCH refs/heads/update/readme")
+		}
+	})
+
+	t.Run("existing branch without own commits", func(t *testing.T) {
+		api := newFakeGitDataAPI()
+		api.commits["old"] = &github.Commit{SHA: github.String("old")}
+		api.commits["base"].Parents = []github.Commit{{SHA: github.String("old")}}
+		api.refs["refs/heads/update/readme"] = "old"
+		server := httptest.NewServer(api)
+		defer server.Close()
+		client, _ := github.NewEnterpriseClient(server.URL, server.URL, nil)
+
+		commit, err := NewCommitService(client).CommitFiles(ctx, "TEST", "test", BranchCommit{Base: "master", Branch: "update/readme", Message: "Update readme", Files: files})
+
+		if assert.NoError(t, err) && assert.NotNil(t, commit) {
+			assert.Equal(t, commit.GetSHA(), api.refs["refs/heads/update/readme"])
+			assert.Empty(t, api.forcedRefs, "fast-forward")
+		}
+	})
+
+	t.Run("existing branch with foreign commits", func(t *testing.T) {
+		api := newFakeGitDataAPI()
+		api.commits["outdated"] = &github.Commit{SHA: github.String("outdated"), Message: github.String("Update readme"), Parents: []github.Commit{{SHA: github.String("base")}}}
+		api.commits["manual"] = &github.Commit{SHA: github.String("manual"), Message: github.String("Fix typo"), Parents: []github.Commit{{SHA: github.String("outdated")}}}
+		api.refs["refs/heads/update/readme"] = "manual"
+		server := httptest.NewServer(api)
+		defer server.Close()
+		client, _ := github.NewEnterpriseClient(server.URL, server.URL, nil)
+
+		_, err := NewCommitService(client).CommitFiles(ctx, "TEST", "test", BranchCommit{Base: "master", Branch: "update/readme", Message: "Update readme", Files: files})
+
+		assert.EqualError(t, err, "Branch 'update/readme' contains commits which have not been created by this step, it is not reset to 'master'")
+		assert.Equal(t, "manual", api.refs["refs/heads/update/readme"])
+		assert.Empty(t, api.requests)
+	})
+
+	t.Run("no changes", func(t *testing.T) {
+		api := newFakeGitDataAPI()
+		server := httptest.NewServer(api)
+		defer server.Close()
+		client, _ := github.NewEnterpriseClient(server.URL, server.URL, nil)
+
+		commit, err := NewCommitService(client).CommitFiles(ctx, "TEST", "test", BranchCommit{Base: "master", Branch: "update/readme", Message: "Update readme", Files: []CommitFile{{Path: "README.md", Content: []byte("readme")}}})
+
+		assert.NoError(t, err)
+		assert.Nil(t, commit)
+		assert.NotContains(t, api.refs, "refs/heads/update/readme")
+	})
+
+	t.Run("missing base branch", func(t *testing.T) {
+		server := httptest.NewServer(newFakeGitDataAPI())
+		defer server.Close()
+		client, _ := github.NewEnterpriseClient(server.URL, server.URL, nil)
+
+		_, err := NewCommitService(client).CommitFiles(ctx, "TEST", "test", BranchCommit{Base: "develop", Branch: "update/readme", Files: files})
+
+		assert.EqualError(t, err, "Failed to get branch 'develop': no match found for this ref")
+	})
+}
@@ -0,0 +1,50 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/google/go-github/v28/github"
+)
+
+// CompareService compares commits page by page which is not supported by go-github.
+// Without pagination the GitHub API returns at most 250 commits of a comparison.
+type CompareService struct {
+	client *github.Client
+}
+
+// NewCompareService creates a new CompareService based on the given GitHub client
+func NewCompareService(client *github.Client) *CompareService {
+	return &CompareService{client: client}
+}
+
+// CompareCommits compares a range of commits, the commits contained in the comparison are paginated based on opt
+func (s *CompareService) CompareCommits(ctx context.Context, owner, repo string, base, head string, opt *github.ListOptions) (*github.CommitsComparison, *github.Response, error) {
+	u := fmt.Sprintf("repos/%v/%v/compare/%v...%v", owner, repo, url.PathEscape(base), url.PathEscape(head))
+	if opt != nil {
+		query := url.Values{}
+		if opt.Page > 0 {
+			query.Set("page", strconv.Itoa(opt.Page))
+		}
+		if opt.PerPage > 0 {
+			query.Set("per_page", strconv.Itoa(opt.PerPage))
+		}
+		if len(query) > 0 {
+			u += "?" + query.Encode()
+		}
+	}
+
+	req, err := s.client.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	comparison := new(github.CommitsComparison)
+	resp, err := s.client.Do(ctx, req, comparison)
+	if err != nil {
+		return nil, resp, err
+	}
+	return comparison, resp, nil
+}
@@ -0,0 +1,44 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-github/v28/github"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompareCommits(t *testing.T) {
+	var requestURI string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestURI = r.URL.RequestURI()
+		w.Header().Set("Link", fmt.Sprintf(`<%v%v?page=3&per_page=100>; rel="next"`, "http://"+r.Host, r.URL.Path))
+		fmt.Fprint(w, `{"total_commits": 201, "commits": [{"sha": "abc"}]}`)
+	}))
+	defer server.Close()
+
+	client, err := github.NewEnterpriseClient(server.URL, server.URL, nil)
+	assert.NoError(t, err)
+
+	t.Run("paginated", func(t *testing.T) {
+		comparison, resp, err := NewCompareService(client).CompareCommits(context.Background(), "TEST", "test", "1.0", "feature/x", &github.ListOptions{Page: 2, PerPage: 100})
+
+		if assert.NoError(t, err) {
+			assert.Equal(t, "/repos/TEST/test/compare/1.0...feature%2Fx?page=2&per_page=100", requestURI)
+			assert.Equal(t, 201, comparison.GetTotalCommits())
+			assert.Equal(t, "abc", comparison.Commits[0].GetSHA())
+			assert.Equal(t, 3, resp.NextPage)
+		}
+	})
+
+	t.Run("without options", func(t *testing.T) {
+		_, _, err := NewCompareService(client).CompareCommits(context.Background(), "TEST", "test", "1.0", "master", nil)
+
+		if assert.NoError(t, err) {
+			assert.Equal(t, "/repos/TEST/test/compare/1.0...master", requestURI)
+		}
+	})
+}
@@ -2,6 +2,9 @@ package github
 
 import (
 	"context"
+	"fmt"
+	"net/http"
+	"strconv"
 
 	"github.com/google/go-github/v28/github"
 	"golang.org/x/oauth2"
@@ -9,11 +12,60 @@ import (
 
 //NewClient creates a new GitHub client using an OAuth token for authentication
 func NewClient(token, apiURL, uploadURL string) (context.Context, *github.Client, error) {
-	ctx := context.Background()
 	ts := oauth2.StaticTokenSource(
 		&oauth2.Token{AccessToken: token},
 	)
-	tc := oauth2.NewClient(ctx, ts)
+	return newClient(ts, apiURL, uploadURL)
+}
+
+// Authentication contains the credentials used by NewAuthenticatedClient, either a personal access token or a GitHub App installation
+type Authentication struct {
+	Token          string
+	AppID          string
+	InstallationID string
+	// PrivateKey of the GitHub App in PEM format
+	PrivateKey string
+}
+
+// IsApp checks whether the authentication as installation of a GitHub App is configured
+func (a Authentication) IsApp() bool {
+	return len(a.AppID) > 0
+}
+
+// NewAuthenticatedClient creates a new GitHub client authenticating as installation of a GitHub App in case an app is configured,
+// otherwise the token is used for authentication
+func NewAuthenticatedClient(auth Authentication, apiURL, uploadURL string) (context.Context, *github.Client, error) {
+	if !auth.IsApp() {
+		if len(auth.Token) == 0 {
+			return context.Background(), nil, fmt.Errorf("No GitHub authentication configured, please provide either a token or appId, installationId and privateKey of a GitHub App")
+		}
+		return NewClient(auth.Token, apiURL, uploadURL)
+	}
+
+	appID, err := strconv.ParseInt(auth.AppID, 10, 64)
+	if err != nil {
+		return context.Background(), nil, fmt.Errorf("Invalid appId '%v', expected the numeric ID of the GitHub App", auth.AppID)
+	}
+	installationID, err := strconv.ParseInt(auth.InstallationID, 10, 64)
+	if err != nil {
+		return context.Background(), nil, fmt.Errorf("Invalid installationId '%v', expected the numeric ID of the installation of GitHub App %v", auth.InstallationID, appID)
+	}
+	if len(auth.PrivateKey) == 0 {
+		return context.Background(), nil, fmt.Errorf("No privateKey provided for GitHub App %v", appID)
+	}
+	return NewAppClient(appID, installationID, []byte(auth.PrivateKey), apiURL, uploadURL)
+}
+
+// newClient creates a GitHub client authenticating with the tokens of the token source.
+// Requests exceeding the rate limit are retried once the rate limit is reset.
+func newClient(ts oauth2.TokenSource, apiURL, uploadURL string) (context.Context, *github.Client, error) {
+	ctx := context.Background()
+	tc := &http.Client{
+		Transport: &oauth2.Transport{
+			Source: ts,
+			Base:   newRateLimitTransport(http.DefaultTransport),
+		},
+	}
 
 	client, err := github.NewEnterpriseClient(apiURL, uploadURL, tc)
 	if err != nil {
@@ -0,0 +1,73 @@
+package github
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewAuthenticatedClient(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal("Failed to generate key")
+	}
+	pemKey := string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/app/installations/2/access_tokens":
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprint(w, `{"token": "installationToken", "expires_at": "2099-01-01T00:00:00Z"}`)
+		case "/repos/TEST/test":
+			authorization = r.Header.Get("Authorization")
+			fmt.Fprint(w, `{"name": "test"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	t.Run("token", func(t *testing.T) {
+		ctx, client, err := NewAuthenticatedClient(Authentication{Token: "personalToken"}, server.URL, server.URL)
+
+		if assert.NoError(t, err) {
+			_, _, err = client.Repositories.Get(ctx, "TEST", "test")
+			assert.NoError(t, err)
+			assert.Equal(t, "Bearer personalToken", authorization)
+		}
+	})
+
+	t.Run("app takes precedence", func(t *testing.T) {
+		auth := Authentication{Token: "personalToken", AppID: "1", InstallationID: "2", PrivateKey: pemKey}
+		ctx, client, err := NewAuthenticatedClient(auth, server.URL, server.URL)
+
+		if assert.NoError(t, err) {
+			assert.True(t, auth.IsApp())
+			_, _, err = client.Repositories.Get(ctx, "TEST", "test")
+			assert.NoError(t, err)
+			assert.Equal(t, "token installationToken", authorization)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, _, err := NewAuthenticatedClient(Authentication{}, server.URL, server.URL)
+		assert.EqualError(t, err, "No GitHub authentication configured, please provide either a token or appId, installationId and privateKey of a GitHub App")
+
+		_, _, err = NewAuthenticatedClient(Authentication{AppID: "app", InstallationID: "2", PrivateKey: pemKey}, server.URL, server.URL)
+		assert.EqualError(t, err, "Invalid appId 'app', expected the numeric ID of the GitHub App")
+
+		_, _, err = NewAuthenticatedClient(Authentication{AppID: "1", PrivateKey: pemKey}, server.URL, server.URL)
+		assert.EqualError(t, err, "Invalid installationId '', expected the numeric ID of the installation of GitHub App 1")
+
+		_, _, err = NewAuthenticatedClient(Authentication{AppID: "1", InstallationID: "2"}, server.URL, server.URL)
+		assert.EqualError(t, err, "No privateKey provided for GitHub App 1")
+	})
+}
@@ -0,0 +1,87 @@
+package github
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/SAP/jenkins-library/pkg/log"
+)
+
+// rateLimitTransport retries requests which failed since the rate limit of GitHub is exceeded
+type rateLimitTransport struct {
+	base       http.RoundTripper
+	maxRetries int
+	// maximum time to wait for a reset of the rate limit, the response is returned in case the reset takes longer
+	maxWait time.Duration
+	sleep   func(time.Duration)
+	now     func() time.Time
+}
+
+func newRateLimitTransport(base http.RoundTripper) *rateLimitTransport {
+	return &rateLimitTransport{
+		base:       base,
+		maxRetries: 3,
+		maxWait:    15 * time.Minute,
+		sleep:      time.Sleep,
+		now:        time.Now,
+	}
+}
+
+// RoundTrip executes the request and retries it after waiting for the rate limit to be reset
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for retry := 0; ; retry++ {
+		resp, err := t.base.RoundTrip(req)
+		if err != nil || retry >= t.maxRetries {
+			return resp, err
+		}
+
+		wait, limited := t.rateLimitWait(resp)
+		if !limited || wait > t.maxWait {
+			return resp, nil
+		}
+		// the body of the request cannot be sent again
+		if req.Body != nil && req.GetBody == nil {
+			return resp, nil
+		}
+		resp.Body.Close()
+
+		log.Entry().Infof("GitHub rate limit exceeded, retrying %v %v in %v", req.Method, req.URL.Path, wait)
+		t.sleep(wait)
+
+		retryReq := req.Clone(req.Context())
+		if req.GetBody != nil {
+			retryReq.Body, err = req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+		}
+		req = retryReq
+	}
+}
+
+// rateLimitWait provides the time until the rate limit is reset in case the response denotes an exceeded rate limit.
+// Besides the primary rate limit GitHub applies secondary rate limits which provide the time to wait as Retry-After header.
+func (t *rateLimitTransport) rateLimitWait(resp *http.Response) (time.Duration, bool) {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+
+	if retryAfter, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		return time.Duration(retryAfter) * time.Second, true
+	}
+
+	if resp.Header.Get("X-RateLimit-Remaining") != "0" {
+		return 0, false
+	}
+	reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	// one additional second compensates for clock differences
+	wait := time.Unix(reset, 0).Sub(t.now()) + time.Second
+	if wait < 0 {
+		wait = 0
+	}
+	return wait, true
+}
@@ -0,0 +1,105 @@
+package github
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimitTransport(t *testing.T) {
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	newTransport := func(sleeps *[]time.Duration) *rateLimitTransport {
+		transport := newRateLimitTransport(http.DefaultTransport)
+		transport.sleep = func(d time.Duration) { *sleeps = append(*sleeps, d) }
+		transport.now = func() time.Time { return now }
+		return transport
+	}
+
+	t.Run("primary rate limit", func(t *testing.T) {
+		bodies := []string{}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			bodies = append(bodies, string(body))
+			if len(bodies) == 1 {
+				w.Header().Set("X-RateLimit-Remaining", "0")
+				w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(now.Add(time.Minute).Unix(), 10))
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			fmt.Fprint(w, "{}")
+		}))
+		defer server.Close()
+		sleeps := []time.Duration{}
+		client := http.Client{Transport: newTransport(&sleeps)}
+
+		resp, err := client.Post(server.URL, "application/json", strings.NewReader(`{"title": "test"}`))
+
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, []time.Duration{61 * time.Second}, sleeps)
+			assert.Equal(t, []string{`{"title": "test"}`, `{"title": "test"}`}, bodies)
+		}
+	})
+
+	t.Run("secondary rate limit", func(t *testing.T) {
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			w.Header().Set("Retry-After", "30")
+			w.WriteHeader(http.StatusForbidden)
+		}))
+		defer server.Close()
+		sleeps := []time.Duration{}
+		client := http.Client{Transport: newTransport(&sleeps)}
+
+		resp, err := client.Get(server.URL)
+
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+			assert.Equal(t, 4, requests, "maximum number of retries not respected")
+			assert.Equal(t, []time.Duration{30 * time.Second, 30 * time.Second, 30 * time.Second}, sleeps)
+		}
+	})
+
+	t.Run("no retry", func(t *testing.T) {
+		tt := []struct {
+			name    string
+			status  int
+			headers map[string]string
+		}{
+			{name: "other error", status: http.StatusForbidden, headers: map[string]string{"X-RateLimit-Remaining": "10"}},
+			{name: "reset too late", status: http.StatusForbidden, headers: map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": strconv.FormatInt(now.Add(time.Hour).Unix(), 10)}},
+			{name: "success", status: http.StatusOK, headers: map[string]string{"X-RateLimit-Remaining": "0"}},
+		}
+
+		for _, test := range tt {
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				for name, value := range test.headers {
+					w.Header().Set(name, value)
+				}
+				w.WriteHeader(test.status)
+			}))
+			sleeps := []time.Duration{}
+			client := http.Client{Transport: newTransport(&sleeps)}
+
+			resp, err := client.Get(server.URL)
+
+			if assert.NoError(t, err, test.name) {
+				assert.Equal(t, test.status, resp.StatusCode, test.name)
+				assert.Equal(t, 1, requests, test.name)
+				assert.Empty(t, sleeps, test.name)
+			}
+			server.Close()
+		}
+	})
+}
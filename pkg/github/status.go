@@ -0,0 +1,179 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/go-github/v28/github"
+	"github.com/pkg/errors"
+)
+
+// maximum number of annotations GitHub accepts per check run request
+const maxAnnotationsPerRequest = 50
+
+// maximum length of the description of a commit status
+const maxStatusDescriptionLength = 140
+
+// Annotation denotes a finding related to a line range of a file
+type Annotation struct {
+	Path      string `json:"path"`
+	StartLine int    `json:"startLine"`
+	EndLine   int    `json:"endLine,omitempty"`
+	// Level is one of notice, warning or failure
+	Level   string `json:"level,omitempty"`
+	Title   string `json:"title,omitempty"`
+	Message string `json:"message"`
+}
+
+// StatusReport contains the result of a step which is reported for a commit
+type StatusReport struct {
+	// Name identifies the status, e.g. the name of the step
+	Name string
+	SHA  string
+	// State is one of pending, success, failure or error
+	State       string
+	Description string
+	// Summary is shown in markdown format on the check run page
+	Summary     string
+	DetailsURL  string
+	Annotations []Annotation
+}
+
+// StatusService reports results back to GitHub, either as commit status or as check run
+type StatusService struct {
+	client *github.Client
+}
+
+// NewStatusService creates a new StatusService based on the given GitHub client
+func NewStatusService(client *github.Client) *StatusService {
+	return &StatusService{client: client}
+}
+
+// SetCommitStatus creates a commit status, the summary and the annotations are not supported by commit statuses
+func (s *StatusService) SetCommitStatus(ctx context.Context, owner, repo string, report StatusReport) error {
+	if err := validateStatusState(report.State); err != nil {
+		return err
+	}
+	description := report.Description
+	if len(description) > maxStatusDescriptionLength {
+		description = description[:maxStatusDescriptionLength-3] + "..."
+	}
+	status := github.RepoStatus{
+		State:       github.String(report.State),
+		Context:     github.String(report.Name),
+		Description: github.String(description),
+	}
+	if len(report.DetailsURL) > 0 {
+		status.TargetURL = github.String(report.DetailsURL)
+	}
+	_, _, err := s.client.Repositories.CreateStatus(ctx, owner, repo, report.SHA, &status)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to set status '%v' of commit '%v'", report.Name, report.SHA)
+	}
+	return nil
+}
+
+// CreateCheckRun creates a check run including summary and annotations. GitHub only accepts a limited
+// number of annotations per request, hence further annotations are added by updating the check run.
+// Check runs can only be created when authenticated as GitHub App.
+func (s *StatusService) CreateCheckRun(ctx context.Context, owner, repo string, report StatusReport) (*github.CheckRun, error) {
+	if err := validateStatusState(report.State); err != nil {
+		return nil, err
+	}
+	annotations := checkRunAnnotations(report.Annotations)
+
+	options := github.CreateCheckRunOptions{
+		Name:    report.Name,
+		HeadSHA: report.SHA,
+		Output:  checkRunOutput(report, annotations, 0),
+	}
+	if len(report.DetailsURL) > 0 {
+		options.DetailsURL = github.String(report.DetailsURL)
+	}
+	if report.State == "pending" {
+		options.Status = github.String("in_progress")
+	} else {
+		options.Status = github.String("completed")
+		options.Conclusion = github.String(checkRunConclusion(report.State))
+		options.CompletedAt = &github.Timestamp{Time: time.Now()}
+	}
+
+	checkRun, _, err := s.client.Checks.CreateCheckRun(ctx, owner, repo, options)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to create check run '%v' for commit '%v'", report.Name, report.SHA)
+	}
+
+	for offset := maxAnnotationsPerRequest; offset < len(annotations); offset += maxAnnotationsPerRequest {
+		update := github.UpdateCheckRunOptions{Name: report.Name, Output: checkRunOutput(report, annotations, offset)}
+		_, _, err := s.client.Checks.UpdateCheckRun(ctx, owner, repo, checkRun.GetID(), update)
+		if err != nil {
+			return checkRun, errors.Wrapf(err, "Failed to add annotations to check run '%v'", report.Name)
+		}
+	}
+	return checkRun, nil
+}
+
+func validateStatusState(state string) error {
+	switch state {
+	case "pending", "success", "failure", "error":
+		return nil
+	}
+	return fmt.Errorf("Invalid state '%v'. Supported values: 'pending', 'success', 'failure', 'error'", state)
+}
+
+func checkRunConclusion(state string) string {
+	if state == "success" {
+		return "success"
+	}
+	return "failure"
+}
+
+// checkRunOutput provides the output containing the annotations starting at offset
+func checkRunOutput(report StatusReport, annotations []*github.CheckRunAnnotation, offset int) *github.CheckRunOutput {
+	title := report.Description
+	if len(title) == 0 {
+		title = report.Name
+	}
+	output := github.CheckRunOutput{
+		Title:   github.String(title),
+		Summary: github.String(report.Summary),
+	}
+	if len(report.Summary) == 0 {
+		output.Summary = github.String(title)
+	}
+	if offset < len(annotations) {
+		end := offset + maxAnnotationsPerRequest
+		if end > len(annotations) {
+			end = len(annotations)
+		}
+		output.Annotations = annotations[offset:end]
+	}
+	return &output
+}
+
+func checkRunAnnotations(annotations []Annotation) []*github.CheckRunAnnotation {
+	result := []*github.CheckRunAnnotation{}
+	for _, a := range annotations {
+		endLine := a.EndLine
+		if endLine < a.StartLine {
+			endLine = a.StartLine
+		}
+		level := a.Level
+		if len(level) == 0 {
+			level = "warning"
+		}
+		annotation := github.CheckRunAnnotation{
+			Path:            github.String(a.Path),
+			StartLine:       github.Int(a.StartLine),
+			EndLine:         github.Int(endLine),
+			AnnotationLevel: github.String(level),
+			Message:         github.String(a.Message),
+		}
+		if len(a.Title) > 0 {
+			annotation.Title = github.String(a.Title)
+		}
+		result = append(result, &annotation)
+	}
+	return result
+}
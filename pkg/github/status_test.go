@@ -0,0 +1,152 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v28/github"
+	"github.com/stretchr/testify/assert"
+)
+
+type statusRequest struct {
+	method string
+	path   string
+	body   map[string]interface{}
+}
+
+func newStatusServer(requests *[]statusRequest, status int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request := statusRequest{method: r.Method, path: r.URL.Path}
+		json.NewDecoder(r.Body).Decode(&request.body)
+		*requests = append(*requests, request)
+		w.WriteHeader(status)
+		fmt.Fprint(w, `{"id": 42}`)
+	}))
+}
+
+func TestSetCommitStatus(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("success", func(t *testing.T) {
+		requests := []statusRequest{}
+		server := newStatusServer(&requests, http.StatusCreated)
+		defer server.Close()
+		client, _ := github.NewEnterpriseClient(server.URL, server.URL, nil)
+
+		err := NewStatusService(client).SetCommitStatus(ctx, "TEST", "test", StatusReport{
+			Name:        "piper/scan",
+			SHA:         "abc",
+			State:       "failure",
+			Description: strings.Repeat("x", 150),
+			DetailsURL:  "https://jenkins/job/1",
+		})
+
+		if assert.NoError(t, err) && assert.Len(t, requests, 1) {
+			assert.Equal(t, "/repos/TEST/test/statuses/abc", requests[0].path)
+			assert.Equal(t, map[string]interface{}{
+				"state":       "failure",
+				"context":     "piper/scan",
+				"description": strings.Repeat("x", 137) + "...",
+				"target_url":  "https://jenkins/job/1",
+			}, requests[0].body)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		requests := []statusRequest{}
+		server := newStatusServer(&requests, http.StatusUnprocessableEntity)
+		defer server.Close()
+		client, _ := github.NewEnterpriseClient(server.URL, server.URL, nil)
+
+		err := NewStatusService(client).SetCommitStatus(ctx, "TEST", "test", StatusReport{Name: "piper", SHA: "abc", State: "done"})
+		assert.EqualError(t, err, "Invalid state 'done'. Supported values: 'pending', 'success', 'failure', 'error'")
+
+		err = NewStatusService(client).SetCommitStatus(ctx, "TEST", "test", StatusReport{Name: "piper", SHA: "abc", State: "success"})
+		assert.Contains(t, fmt.Sprint(err), "Failed to set status 'piper' of commit 'abc'")
+	})
+}
+
+func TestCreateCheckRun(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("with annotations", func(t *testing.T) {
+		requests := []statusRequest{}
+		server := newStatusServer(&requests, http.StatusCreated)
+		defer server.Close()
+		client, _ := github.NewEnterpriseClient(server.URL, server.URL, nil)
+
+		annotations := []Annotation{}
+		for i := 1; i <= 60; i++ {
+			annotations = append(annotations, Annotation{Path: "src/main.go", StartLine: i, Message: fmt.Sprintf("finding %v", i)})
+		}
+		annotations[0].Level = "failure"
+		annotations[0].Title = "Vulnerability"
+
+		checkRun, err := NewStatusService(client).CreateCheckRun(ctx, "TEST", "test", StatusReport{
+			Name:        "piper/scan",
+			SHA:         "abc",
+			State:       "failure",
+			Description: "60 findings",
+			Summary:     "**60** findings",
+			Annotations: annotations,
+		})
+
+		if assert.NoError(t, err) && assert.Len(t, requests, 2) {
+			assert.Equal(t, int64(42), checkRun.GetID())
+
+			create := requests[0]
+			assert.Equal(t, "POST /repos/TEST/test/check-runs", create.method+" "+create.path)
+			assert.Equal(t, "completed", create.body["status"])
+			assert.Equal(t, "failure", create.body["conclusion"])
+			assert.NotEmpty(t, create.body["completed_at"])
+			output := create.body["output"].(map[string]interface{})
+			assert.Equal(t, "60 findings", output["title"])
+			assert.Equal(t, "**60** findings", output["summary"])
+			assert.Len(t, output["annotations"], 50)
+			assert.Equal(t, map[string]interface{}{
+				"path":             "src/main.go",
+				"start_line":       float64(1),
+				"end_line":         float64(1),
+				"annotation_level": "failure",
+				"title":            "Vulnerability",
+				"message":          "finding 1",
+			}, output["annotations"].([]interface{})[0])
+
+			update := requests[1]
+			assert.Equal(t, "PATCH /repos/TEST/test/check-runs/42", update.method+" "+update.path)
+			updateAnnotations := update.body["output"].(map[string]interface{})["annotations"].([]interface{})
+			assert.Len(t, updateAnnotations, 10)
+			assert.Equal(t, "warning", updateAnnotations[0].(map[string]interface{})["annotation_level"])
+		}
+	})
+
+	t.Run("pending", func(t *testing.T) {
+		requests := []statusRequest{}
+		server := newStatusServer(&requests, http.StatusCreated)
+		defer server.Close()
+		client, _ := github.NewEnterpriseClient(server.URL, server.URL, nil)
+
+		_, err := NewStatusService(client).CreateCheckRun(ctx, "TEST", "test", StatusReport{Name: "piper", SHA: "abc", State: "pending"})
+
+		if assert.NoError(t, err) && assert.Len(t, requests, 1) {
+			assert.Equal(t, "in_progress", requests[0].body["status"])
+			assert.Nil(t, requests[0].body["conclusion"])
+			assert.Equal(t, map[string]interface{}{"title": "piper", "summary": "piper"}, requests[0].body["output"])
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		requests := []statusRequest{}
+		server := newStatusServer(&requests, http.StatusForbidden)
+		defer server.Close()
+		client, _ := github.NewEnterpriseClient(server.URL, server.URL, nil)
+
+		_, err := NewStatusService(client).CreateCheckRun(ctx, "TEST", "test", StatusReport{Name: "piper", SHA: "abc", State: "success"})
+		assert.Contains(t, fmt.Sprint(err), "Failed to create check run 'piper' for commit 'abc'")
+	})
+}
@@ -0,0 +1,188 @@
+package kubernetes
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/SAP/jenkins-library/pkg/log"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// defaultPollInterval is used in case no positive poll interval is configured
+const defaultPollInterval = 5 * time.Second
+
+type execRunner interface {
+	RunExecutable(e string, p ...string) error
+}
+
+// Executor runs step commands inside a pod on a Kubernetes cluster.
+// The pod is managed via the Kubernetes API while workspace transfer and command execution are done using kubectl.
+type Executor struct {
+	// Namespace the pod is created in
+	Namespace string
+	// ReadyTimeout defines how long to wait for the pod to become ready
+	ReadyTimeout time.Duration
+	// PollInterval defines the time between two checks of the pod status
+	PollInterval time.Duration
+	// LogWriter receives the logs of the sidecar containers in case the execution failed
+	LogWriter io.Writer
+
+	client kubernetes.Interface
+	runner execRunner
+	sleep  func(time.Duration)
+	logs   func(pod, container string) (io.ReadCloser, error)
+}
+
+// NewClient creates a Kubernetes client based on the provided kubeconfig file.
+// In case no kubeconfig is provided the in-cluster configuration is used.
+func NewClient(kubeconfig string) (kubernetes.Interface, error) {
+	restConfig, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load Kubernetes configuration")
+	}
+	client, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create Kubernetes client")
+	}
+	return client, nil
+}
+
+// NewExecutor creates a new executor using the provided Kubernetes client and kubectl via the provided runner
+func NewExecutor(client kubernetes.Interface, runner execRunner, namespace string) *Executor {
+	e := &Executor{
+		Namespace:    namespace,
+		ReadyTimeout: 5 * time.Minute,
+		PollInterval: defaultPollInterval,
+		LogWriter:    os.Stderr,
+		client:       client,
+		runner:       runner,
+		sleep:        time.Sleep,
+	}
+	e.logs = func(pod, container string) (io.ReadCloser, error) {
+		return e.client.CoreV1().Pods(e.Namespace).GetLogs(pod, &corev1.PodLogOptions{Container: container}).Stream()
+	}
+	return e
+}
+
+// Run creates the pod, copies the workspace into the step container, executes the command and copies the workspace back.
+// The pod is deleted once the execution is finished, also in case of errors.
+func (e *Executor) Run(pod *corev1.Pod, workspace string, command []string) error {
+	if len(pod.Spec.Containers) == 0 {
+		return fmt.Errorf("no containers defined for pod '%v'", pod.Name)
+	}
+	container := pod.Spec.Containers[0]
+
+	pods := e.client.CoreV1().Pods(e.Namespace)
+	if _, err := pods.Create(pod); err != nil {
+		return errors.Wrapf(err, "creating pod '%v' failed", pod.Name)
+	}
+	log.Entry().Infof("Pod '%v' created in namespace '%v'", pod.Name, e.Namespace)
+
+	defer func() {
+		if err := pods.Delete(pod.Name, &metav1.DeleteOptions{}); err != nil {
+			log.Entry().WithError(err).Warnf("Deleting pod '%v' failed", pod.Name)
+			return
+		}
+		log.Entry().Infof("Pod '%v' deleted", pod.Name)
+	}()
+
+	if err := e.waitUntilReady(pod.Name); err != nil {
+		e.streamLogs(pod)
+		return err
+	}
+
+	remoteWorkspace := fmt.Sprintf("%v:%v", pod.Name, container.WorkingDir)
+	if len(workspace) > 0 {
+		if err := e.kubectl("cp", contents(workspace), remoteWorkspace, "--container", container.Name); err != nil {
+			return errors.Wrap(err, "copying workspace into pod failed")
+		}
+	}
+
+	execErr := e.kubectl(append([]string{"exec", pod.Name, "--container", container.Name, "--"}, command...)...)
+	if execErr != nil {
+		e.streamLogs(pod)
+		execErr = errors.Wrapf(execErr, "execution of command in pod '%v' failed", pod.Name)
+	}
+
+	if len(workspace) > 0 {
+		// also copy back in case of errors since the workspace may contain reports
+		if err := e.kubectl("cp", contents(remoteWorkspace), workspace, "--container", container.Name); err != nil {
+			if execErr != nil {
+				log.Entry().WithError(err).Warn("Copying workspace from pod failed")
+				return execErr
+			}
+			return errors.Wrap(err, "copying workspace from pod failed")
+		}
+	}
+
+	return execErr
+}
+
+func (e *Executor) kubectl(args ...string) error {
+	return e.runner.RunExecutable("kubectl", append([]string{"--namespace", e.Namespace}, args...)...)
+}
+
+func (e *Executor) waitUntilReady(name string) error {
+	pollInterval := e.PollInterval
+	if pollInterval <= 0 {
+		pollInterval = defaultPollInterval
+	}
+	maxRetries := int(e.ReadyTimeout / pollInterval)
+	for retries := 0; ; retries++ {
+		pod, err := e.client.CoreV1().Pods(e.Namespace).Get(name, metav1.GetOptions{})
+		if err != nil {
+			return errors.Wrapf(err, "retrieving status of pod '%v' failed", name)
+		}
+		switch pod.Status.Phase {
+		case corev1.PodFailed, corev1.PodSucceeded:
+			return fmt.Errorf("pod '%v' terminated unexpectedly with phase '%v': %v", name, pod.Status.Phase, pod.Status.Message)
+		case corev1.PodRunning:
+			if podReady(pod) {
+				log.Entry().Infof("Pod '%v' is ready", name)
+				return nil
+			}
+		}
+		if retries >= maxRetries {
+			return fmt.Errorf("timeout while waiting for pod '%v' to be ready", name)
+		}
+		log.Entry().Infof("Waiting for pod '%v' (phase '%v')", name, pod.Status.Phase)
+		e.sleep(pollInterval)
+	}
+}
+
+// contents refers to the content of a directory instead of the directory itself when used with kubectl cp
+func contents(dir string) string {
+	return strings.TrimSuffix(dir, "/") + "/."
+}
+
+func podReady(pod *corev1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// streamLogs writes the logs of all sidecar containers, the output of the step container is already available via kubectl exec
+func (e *Executor) streamLogs(pod *corev1.Pod) {
+	for _, container := range pod.Spec.Containers[1:] {
+		stream, err := e.logs(pod.Name, container.Name)
+		if err != nil {
+			log.Entry().WithError(err).Warnf("Retrieving logs of container '%v' failed", container.Name)
+			continue
+		}
+		fmt.Fprintf(e.LogWriter, "--- logs of container '%v' ---\n", container.Name)
+		if _, err := io.Copy(e.LogWriter, stream); err != nil {
+			log.Entry().WithError(err).Warnf("Streaming logs of container '%v' failed", container.Name)
+		}
+		stream.Close()
+	}
+}
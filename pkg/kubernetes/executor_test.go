@@ -0,0 +1,164 @@
+package kubernetes
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+type kubectlMock struct {
+	calls      []string
+	failAlways string
+}
+
+func (k *kubectlMock) RunExecutable(e string, p ...string) error {
+	call := strings.Join(append([]string{e}, p...), " ")
+	k.calls = append(k.calls, call)
+	if len(k.failAlways) > 0 && strings.HasPrefix(call, k.failAlways) {
+		return fmt.Errorf("%v failed", call)
+	}
+	return nil
+}
+
+func testPod() *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "step-x1"},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{Name: "container-exec", Image: "node", WorkingDir: "/home/piper"},
+				{Name: "selenium", Image: "selenium/standalone-chrome"},
+			},
+		},
+	}
+}
+
+// newTestExecutor creates an executor whose pods get the provided status as soon as they are created
+func newTestExecutor(runner execRunner, status corev1.PodStatus) (*Executor, *fake.Clientset, *bytes.Buffer, *[]time.Duration) {
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		pod := action.(k8stesting.CreateAction).GetObject().(*corev1.Pod)
+		pod.Status = status
+		return false, nil, nil
+	})
+
+	sleeps := []time.Duration{}
+	logs := bytes.Buffer{}
+	e := NewExecutor(client, runner, "piper")
+	e.LogWriter = &logs
+	e.sleep = func(d time.Duration) { sleeps = append(sleeps, d) }
+	e.logs = func(pod, container string) (io.ReadCloser, error) {
+		return ioutil.NopCloser(strings.NewReader(fmt.Sprintf("%v/%v log\n", pod, container))), nil
+	}
+	return e, client, &logs, &sleeps
+}
+
+var readyStatus = corev1.PodStatus{
+	Phase:      corev1.PodRunning,
+	Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}},
+}
+
+func TestRun(t *testing.T) {
+
+	t.Run("success case", func(t *testing.T) {
+		k := kubectlMock{}
+		e, client, logs, sleeps := newTestExecutor(&k, readyStatus)
+
+		err := e.Run(testPod(), "/ws", []string{"npm", "test"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{
+			"kubectl --namespace piper cp /ws/. step-x1:/home/piper --container container-exec",
+			"kubectl --namespace piper exec step-x1 --container container-exec -- npm test",
+			"kubectl --namespace piper cp step-x1:/home/piper/. /ws --container container-exec",
+		}, k.calls)
+		assert.Empty(t, *sleeps)
+		assert.Empty(t, logs.String())
+
+		pods, _ := client.CoreV1().Pods("piper").List(metav1.ListOptions{})
+		assert.Empty(t, pods.Items, "pod has not been deleted")
+	})
+
+	t.Run("no workspace", func(t *testing.T) {
+		k := kubectlMock{}
+		e, _, _, _ := newTestExecutor(&k, readyStatus)
+
+		err := e.Run(testPod(), "", []string{"ls"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"kubectl --namespace piper exec step-x1 --container container-exec -- ls"}, k.calls)
+	})
+
+	t.Run("command fails", func(t *testing.T) {
+		k := kubectlMock{failAlways: "kubectl --namespace piper exec"}
+		e, client, logs, _ := newTestExecutor(&k, readyStatus)
+
+		err := e.Run(testPod(), "/ws", []string{"npm", "test"})
+
+		assert.EqualError(t, err, "execution of command in pod 'step-x1' failed: kubectl --namespace piper exec step-x1 --container container-exec -- npm test failed")
+		assert.Equal(t, "--- logs of container 'selenium' ---\nstep-x1/selenium log\n", logs.String())
+		// workspace is copied back nevertheless
+		assert.Equal(t, "kubectl --namespace piper cp step-x1:/home/piper/. /ws --container container-exec", k.calls[len(k.calls)-1])
+
+		pods, _ := client.CoreV1().Pods("piper").List(metav1.ListOptions{})
+		assert.Empty(t, pods.Items, "pod has not been deleted")
+	})
+
+	t.Run("pod not ready", func(t *testing.T) {
+		k := kubectlMock{}
+		e, client, _, sleeps := newTestExecutor(&k, corev1.PodStatus{Phase: corev1.PodPending})
+		e.ReadyTimeout = 15 * time.Second
+
+		err := e.Run(testPod(), "/ws", []string{"npm", "test"})
+
+		assert.EqualError(t, err, "timeout while waiting for pod 'step-x1' to be ready")
+		assert.Len(t, *sleeps, 3)
+		assert.Empty(t, k.calls)
+
+		pods, _ := client.CoreV1().Pods("piper").List(metav1.ListOptions{})
+		assert.Empty(t, pods.Items, "pod has not been deleted")
+	})
+
+	t.Run("no poll interval", func(t *testing.T) {
+		k := kubectlMock{}
+		e, _, _, sleeps := newTestExecutor(&k, corev1.PodStatus{Phase: corev1.PodPending})
+		e.ReadyTimeout = 10 * time.Second
+		e.PollInterval = 0
+
+		err := e.Run(testPod(), "/ws", []string{"npm", "test"})
+
+		assert.EqualError(t, err, "timeout while waiting for pod 'step-x1' to be ready")
+		assert.Equal(t, []time.Duration{5 * time.Second, 5 * time.Second}, *sleeps)
+	})
+
+	t.Run("pod failed", func(t *testing.T) {
+		k := kubectlMock{}
+		e, _, _, _ := newTestExecutor(&k, corev1.PodStatus{Phase: corev1.PodFailed, Message: "image not found"})
+
+		err := e.Run(testPod(), "/ws", []string{"npm", "test"})
+
+		assert.EqualError(t, err, "pod 'step-x1' terminated unexpectedly with phase 'Failed': image not found")
+	})
+
+	t.Run("pod creation fails", func(t *testing.T) {
+		k := kubectlMock{}
+		e, client, _, _ := newTestExecutor(&k, readyStatus)
+		client.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, nil, fmt.Errorf("forbidden")
+		})
+
+		err := e.Run(testPod(), "/ws", []string{"npm", "test"})
+
+		assert.EqualError(t, err, "creating pod 'step-x1' failed: forbidden")
+	})
+}
@@ -0,0 +1,259 @@
+package kubernetes
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/SAP/jenkins-library/pkg/config"
+	"github.com/SAP/jenkins-library/pkg/log"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DefaultContainerName is used for the step container in case no name is configured
+const DefaultContainerName = "container-exec"
+
+// DefaultWorkspace is used as working directory of the step container in case no dockerWorkspace is configured
+const DefaultWorkspace = "/home/piper"
+
+// defaultCommand keeps the step container alive so that commands can be executed inside it
+var defaultCommand = []string{"/usr/bin/tail", "-f", "/dev/null"}
+
+// BuildPod creates the pod specification for a step based on the containers and sidecars defined in the step metadata.
+// Values provided via the step's context configuration (e.g. dockerImage, dockerEnvVars, sidecarImage, ...) take precedence.
+// The first container of the pod is always the step container.
+func BuildPod(name string, stepData *config.StepData, contextConfig map[string]interface{}) (*corev1.Pod, error) {
+	main, err := stepContainer(stepData, contextConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	containers := []corev1.Container{main}
+	for i, sidecar := range stepData.Spec.Sidecars {
+		if i == 0 {
+			sidecar = applySidecarConfig(sidecar, contextConfig)
+		}
+		if len(sidecar.Image) == 0 {
+			return nil, fmt.Errorf("no image defined for sidecar '%v'", sidecar.Name)
+		}
+		c, err := podContainer(sidecar)
+		if err != nil {
+			return nil, err
+		}
+		containers = append(containers, c)
+	}
+
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: map[string]string{"app": "piper", "step": stepData.Metadata.Name},
+		},
+		Spec: corev1.PodSpec{
+			Containers:    containers,
+			RestartPolicy: corev1.RestartPolicyNever,
+		},
+	}, nil
+}
+
+func stepContainer(stepData *config.StepData, contextConfig map[string]interface{}) (corev1.Container, error) {
+	container := config.Container{}
+	if len(stepData.Spec.Containers) > 0 {
+		container = stepData.Spec.Containers[0]
+	}
+
+	if image := stringValue(contextConfig, "dockerImage"); len(image) > 0 {
+		container.Image = image
+	}
+	if len(container.Image) == 0 {
+		return corev1.Container{}, fmt.Errorf("no image defined for step '%v'", stepData.Metadata.Name)
+	}
+
+	container.Name = DefaultContainerName
+	if name := stringValue(contextConfig, "containerName"); len(name) > 0 {
+		container.Name = name
+	}
+	if envVars, ok := contextConfig["dockerEnvVars"]; ok {
+		container.EnvVars = envVarsValue(envVars)
+	}
+	if workspace := stringValue(contextConfig, "dockerWorkspace"); len(workspace) > 0 {
+		container.WorkingDir = workspace
+	}
+	if len(container.WorkingDir) == 0 {
+		container.WorkingDir = DefaultWorkspace
+	}
+	if options, ok := contextConfig["dockerOptions"]; ok {
+		container.Options = optionsValue(options)
+	}
+	if pull, ok := contextConfig["dockerPullImage"].(bool); ok && !pull {
+		container.ImagePullPolicy = "Never"
+	}
+
+	// the step container needs to stay alive until the step command has been executed
+	container.Command = defaultCommand
+	if command := stringValue(contextConfig, "containerCommand"); len(command) > 0 {
+		container.Command = strings.Fields(command)
+	}
+	// no readiness check for the step container
+	container.ReadyCommand = ""
+
+	return podContainer(container)
+}
+
+func applySidecarConfig(sidecar config.Container, contextConfig map[string]interface{}) config.Container {
+	if image := stringValue(contextConfig, "sidecarImage"); len(image) > 0 {
+		sidecar.Image = image
+	}
+	if name := stringValue(contextConfig, "sidecarName"); len(name) > 0 {
+		sidecar.Name = name
+	}
+	if envVars, ok := contextConfig["sidecarEnvVars"]; ok {
+		sidecar.EnvVars = envVarsValue(envVars)
+	}
+	if readyCommand := stringValue(contextConfig, "sidecarReadyCommand"); len(readyCommand) > 0 {
+		sidecar.ReadyCommand = readyCommand
+	}
+	if workspace := stringValue(contextConfig, "sidecarWorkspace"); len(workspace) > 0 {
+		sidecar.WorkingDir = workspace
+	}
+	if options, ok := contextConfig["sidecarOptions"]; ok {
+		sidecar.Options = optionsValue(options)
+	}
+	if pull, ok := contextConfig["sidecarPullImage"].(bool); ok && !pull {
+		sidecar.ImagePullPolicy = "Never"
+	}
+	return sidecar
+}
+
+func podContainer(container config.Container) (corev1.Container, error) {
+	c := corev1.Container{
+		Name:       container.Name,
+		Image:      container.Image,
+		Command:    container.Command,
+		WorkingDir: container.WorkingDir,
+	}
+
+	switch container.ImagePullPolicy {
+	case "Never":
+		c.ImagePullPolicy = corev1.PullNever
+	case "Always":
+		c.ImagePullPolicy = corev1.PullAlways
+	case "IfNotPresent":
+		c.ImagePullPolicy = corev1.PullIfNotPresent
+	}
+
+	for _, env := range container.EnvVars {
+		c.Env = append(c.Env, corev1.EnvVar{Name: env.Name, Value: env.Value})
+	}
+
+	for _, port := range container.Ports {
+		c.Ports = append(c.Ports, corev1.ContainerPort{Name: port.Name, ContainerPort: int32(port.ContainerPort)})
+	}
+
+	if len(container.ReadyCommand) > 0 {
+		c.ReadinessProbe = &corev1.Probe{
+			Handler: corev1.Handler{
+				Exec: &corev1.ExecAction{Command: []string{"sh", "-c", container.ReadyCommand}},
+			},
+			PeriodSeconds: 10,
+		}
+	}
+
+	for _, option := range container.Options {
+		switch option.Name {
+		case "--privileged":
+			privileged := true
+			c.SecurityContext = securityContext(c.SecurityContext)
+			c.SecurityContext.Privileged = &privileged
+		case "--user", "-u":
+			// only numeric user ids are supported by Kubernetes, the group part is ignored
+			user, err := strconv.ParseInt(strings.Split(option.Value, ":")[0], 10, 64)
+			if err != nil {
+				return corev1.Container{}, fmt.Errorf("invalid user '%v' for container '%v'", option.Value, container.Name)
+			}
+			c.SecurityContext = securityContext(c.SecurityContext)
+			c.SecurityContext.RunAsUser = &user
+		default:
+			log.Entry().Debugf("Docker option '%v' is not supported on Kubernetes and will be ignored", option.Name)
+		}
+	}
+
+	return c, nil
+}
+
+func securityContext(ctx *corev1.SecurityContext) *corev1.SecurityContext {
+	if ctx == nil {
+		return &corev1.SecurityContext{}
+	}
+	return ctx
+}
+
+func stringValue(contextConfig map[string]interface{}, key string) string {
+	if v, ok := contextConfig[key].(string); ok {
+		return v
+	}
+	return ""
+}
+
+func stringSliceValue(v interface{}) []string {
+	switch t := v.(type) {
+	case []string:
+		return t
+	case []interface{}:
+		s := []string{}
+		for _, e := range t {
+			s = append(s, fmt.Sprint(e))
+		}
+		return s
+	case string:
+		if len(t) > 0 {
+			return []string{t}
+		}
+	}
+	return []string{}
+}
+
+// envVarsValue supports environment variables configured as list of "name=value" entries or as map
+func envVarsValue(v interface{}) []config.EnvVar {
+	envVars := []config.EnvVar{}
+	if m, ok := v.(map[string]interface{}); ok {
+		// sorted in order to provide the same pod spec for each run
+		names := make([]string, 0, len(m))
+		for name := range m {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			envVars = append(envVars, config.EnvVar{Name: name, Value: fmt.Sprint(m[name])})
+		}
+		return envVars
+	}
+	for _, entry := range stringSliceValue(v) {
+		parts := strings.SplitN(entry, "=", 2)
+		env := config.EnvVar{Name: parts[0]}
+		if len(parts) > 1 {
+			env.Value = parts[1]
+		}
+		envVars = append(envVars, env)
+	}
+	return envVars
+}
+
+// optionsValue supports docker options in the form "--name value" as provided by the context defaults
+func optionsValue(v interface{}) []config.Option {
+	options := []config.Option{}
+	for _, entry := range stringSliceValue(v) {
+		parts := strings.SplitN(strings.TrimSpace(entry), " ", 2)
+		option := config.Option{Name: parts[0]}
+		if len(parts) > 1 {
+			option.Value = strings.TrimSpace(parts[1])
+		}
+		if strings.Contains(option.Name, "=") {
+			nameValue := strings.SplitN(option.Name, "=", 2)
+			option.Name, option.Value = nameValue[0], nameValue[1]
+		}
+		options = append(options, option)
+	}
+	return options
+}
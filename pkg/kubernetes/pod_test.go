@@ -0,0 +1,91 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/SAP/jenkins-library/pkg/config"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestBuildPod(t *testing.T) {
+	stepData := config.StepData{
+		Metadata: config.StepMetadata{Name: "karmaExecuteTests"},
+		Spec: config.StepSpec{
+			Containers: []config.Container{{Name: "node", Image: "node:8-stretch", WorkingDir: "/home/node"}},
+			Sidecars: []config.Container{{
+				Name:         "selenium",
+				Image:        "selenium/standalone-chrome",
+				ReadyCommand: "curl -f http://localhost:4444",
+				EnvVars:      []config.EnvVar{{Name: "NO_PROXY", Value: "localhost"}},
+				Ports:        []config.Port{{ContainerPort: 4444}},
+			}},
+		},
+	}
+
+	t.Run("metadata defaults", func(t *testing.T) {
+		pod, err := BuildPod("karma-x1", &stepData, map[string]interface{}{})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "karma-x1", pod.Name)
+		assert.Equal(t, "karmaExecuteTests", pod.Labels["step"])
+		assert.Equal(t, corev1.RestartPolicyNever, pod.Spec.RestartPolicy)
+		if assert.Len(t, pod.Spec.Containers, 2) {
+			main := pod.Spec.Containers[0]
+			assert.Equal(t, DefaultContainerName, main.Name)
+			assert.Equal(t, "node:8-stretch", main.Image)
+			assert.Equal(t, "/home/node", main.WorkingDir)
+			assert.Equal(t, []string{"/usr/bin/tail", "-f", "/dev/null"}, main.Command)
+			assert.Nil(t, main.ReadinessProbe)
+
+			sidecar := pod.Spec.Containers[1]
+			assert.Equal(t, "selenium", sidecar.Name)
+			assert.Equal(t, []corev1.EnvVar{{Name: "NO_PROXY", Value: "localhost"}}, sidecar.Env)
+			assert.Equal(t, []corev1.ContainerPort{{ContainerPort: 4444}}, sidecar.Ports)
+			assert.Equal(t, []string{"sh", "-c", "curl -f http://localhost:4444"}, sidecar.ReadinessProbe.Exec.Command)
+		}
+	})
+
+	t.Run("context configuration", func(t *testing.T) {
+		contextConfig := map[string]interface{}{
+			"containerName":    "main",
+			"containerCommand": "/bin/sleep infinity",
+			"dockerImage":      "node:12",
+			"dockerEnvVars":    []interface{}{"HOME=/tmp", "CI=true"},
+			"dockerWorkspace":  "/workspace",
+			"dockerOptions":    []interface{}{"--user 1000:1000", "--privileged ", "--shm-size 2g"},
+			"dockerPullImage":  false,
+			"sidecarImage":     "selenium/standalone-firefox",
+			"sidecarEnvVars":   map[string]interface{}{"SE_OPTS": "-debug", "HUB_PORT": "4444", "NO_PROXY": "localhost"},
+			"sidecarPullImage": true,
+		}
+
+		pod, err := BuildPod("karma-x1", &stepData, contextConfig)
+
+		assert.NoError(t, err)
+		main := pod.Spec.Containers[0]
+		assert.Equal(t, "main", main.Name)
+		assert.Equal(t, "node:12", main.Image)
+		assert.Equal(t, "/workspace", main.WorkingDir)
+		assert.Equal(t, []string{"/bin/sleep", "infinity"}, main.Command)
+		assert.Equal(t, []corev1.EnvVar{{Name: "HOME", Value: "/tmp"}, {Name: "CI", Value: "true"}}, main.Env)
+		assert.Equal(t, corev1.PullNever, main.ImagePullPolicy)
+		assert.Equal(t, int64(1000), *main.SecurityContext.RunAsUser)
+		assert.True(t, *main.SecurityContext.Privileged)
+
+		sidecar := pod.Spec.Containers[1]
+		assert.Equal(t, "selenium/standalone-firefox", sidecar.Image)
+		assert.Equal(t, []corev1.EnvVar{{Name: "HUB_PORT", Value: "4444"}, {Name: "NO_PROXY", Value: "localhost"}, {Name: "SE_OPTS", Value: "-debug"}}, sidecar.Env, "env vars not sorted")
+		assert.Equal(t, corev1.PullPolicy(""), sidecar.ImagePullPolicy)
+	})
+
+	t.Run("no image", func(t *testing.T) {
+		_, err := BuildPod("x", &config.StepData{Metadata: config.StepMetadata{Name: "myStep"}}, map[string]interface{}{})
+		assert.EqualError(t, err, "no image defined for step 'myStep'")
+	})
+
+	t.Run("invalid user", func(t *testing.T) {
+		_, err := BuildPod("x", &stepData, map[string]interface{}{"dockerOptions": []string{"--user piper"}})
+		assert.EqualError(t, err, "invalid user 'piper' for container 'container-exec'")
+	})
+}
@@ -0,0 +1,218 @@
+package sidecar
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/SAP/jenkins-library/pkg/config"
+	"github.com/SAP/jenkins-library/pkg/log"
+	"github.com/pkg/errors"
+)
+
+// defaultPollInterval is used in case no positive poll interval is configured
+const defaultPollInterval = 10 * time.Second
+
+// shmPath is the mount path of the shared memory, docker provides it with a size of 64 MB only
+const shmPath = "/dev/shm"
+
+type execRunner interface {
+	RunExecutable(e string, p ...string) error
+}
+
+// Manager takes care of the lifecycle of sidecar containers which are required by a step
+// in case the step is not executed inside Jenkins (e.g. GitHub Actions or on a developer's machine).
+// All sidecars are started inside a dedicated docker network and are reachable via their name.
+type Manager struct {
+	// ReadyTimeout defines how long to wait for a sidecar to become ready
+	ReadyTimeout time.Duration
+	// PollInterval defines the time between two executions of the ready command
+	PollInterval time.Duration
+	// ShmSize defines the size of the shared memory of sidecars mounting a volume to /dev/shm
+	ShmSize string
+
+	runner     execRunner
+	id         string
+	network    string
+	containers []string
+	volumes    []string
+	sleep      func(time.Duration)
+	suffix     func() string
+}
+
+// NewManager creates a new sidecar manager which uses the docker cli via the provided runner
+func NewManager(runner execRunner) *Manager {
+	return &Manager{
+		ReadyTimeout: 5 * time.Minute,
+		PollInterval: defaultPollInterval,
+		ShmSize:      "2g",
+		runner:       runner,
+		sleep:        time.Sleep,
+		suffix: func() string {
+			return strconv.FormatInt(time.Now().UnixNano(), 36)
+		},
+	}
+}
+
+// Network returns the name of the docker network the sidecars are attached to
+func (m *Manager) Network() string {
+	return m.network
+}
+
+// Start creates the docker network, starts all sidecars and waits until each of them is ready.
+// In case of an error all sidecars which have been started so far are removed again.
+func (m *Manager) Start(sidecars []config.Container) error {
+	if len(sidecars) == 0 {
+		return nil
+	}
+
+	m.id = m.suffix()
+	m.network = fmt.Sprintf("sidecar-%v", m.id)
+	if err := m.runner.RunExecutable("docker", "network", "create", m.network); err != nil {
+		m.network = ""
+		return errors.Wrap(err, "creating docker network for sidecars failed")
+	}
+	log.Entry().Debugf("Docker network '%v' created", m.network)
+
+	for _, sidecar := range sidecars {
+		if err := m.start(sidecar); err != nil {
+			if e := m.Stop(); e != nil {
+				log.Entry().WithError(e).Warn("Cleanup of sidecars failed")
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// Stop removes all sidecar containers as well as the docker network
+func (m *Manager) Stop() error {
+	var err error
+
+	for i := len(m.containers) - 1; i >= 0; i-- {
+		if e := m.runner.RunExecutable("docker", "rm", "--force", m.containers[i]); e != nil {
+			log.Entry().WithError(e).Warnf("Removing sidecar container '%v' failed", m.containers[i])
+			if err == nil {
+				err = errors.Wrapf(e, "removing sidecar container '%v' failed", m.containers[i])
+			}
+		} else {
+			log.Entry().Infof("Sidecar container '%v' removed", m.containers[i])
+		}
+	}
+	m.containers = nil
+
+	for _, volume := range m.volumes {
+		if e := m.runner.RunExecutable("docker", "volume", "rm", "--force", volume); e != nil && err == nil {
+			err = errors.Wrapf(e, "removing docker volume '%v' failed", volume)
+		}
+	}
+	m.volumes = nil
+
+	if len(m.network) > 0 {
+		if e := m.runner.RunExecutable("docker", "network", "rm", m.network); e != nil && err == nil {
+			err = errors.Wrapf(e, "removing docker network '%v' failed", m.network)
+		}
+		m.network = ""
+	}
+	return err
+}
+
+func (m *Manager) start(sidecar config.Container) error {
+	if len(sidecar.Image) == 0 {
+		return fmt.Errorf("no image defined for sidecar '%v'", sidecar.Name)
+	}
+
+	if sidecar.ImagePullPolicy != "Never" {
+		if err := m.runner.RunExecutable("docker", "pull", sidecar.Image); err != nil {
+			return errors.Wrapf(err, "pulling image '%v' failed", sidecar.Image)
+		}
+	} else {
+		log.Entry().Infof("Skipped pull of image '%v'", sidecar.Image)
+	}
+
+	name := fmt.Sprintf("%v-%v", sidecar.Name, m.id)
+	if err := m.runner.RunExecutable("docker", m.runArgs(name, sidecar)...); err != nil {
+		return errors.Wrapf(err, "starting sidecar '%v' failed", sidecar.Name)
+	}
+	m.containers = append(m.containers, name)
+	log.Entry().Infof("Sidecar container '%v' started (image '%v')", name, sidecar.Image)
+
+	return m.waitUntilReady(name, sidecar.ReadyCommand)
+}
+
+func (m *Manager) waitUntilReady(name, readyCommand string) error {
+	if len(readyCommand) == 0 {
+		return nil
+	}
+
+	pollInterval := m.PollInterval
+	if pollInterval <= 0 {
+		pollInterval = defaultPollInterval
+	}
+	maxRetries := int(m.ReadyTimeout / pollInterval)
+	for retries := 0; ; retries++ {
+		log.Entry().Infof("Waiting for sidecar container '%v'", name)
+		if err := m.runner.RunExecutable("docker", "exec", name, "sh", "-c", readyCommand); err == nil {
+			log.Entry().Infof("Sidecar container '%v' is ready", name)
+			return nil
+		}
+		if retries >= maxRetries {
+			return fmt.Errorf("timeout while waiting for sidecar container '%v' to be ready", name)
+		}
+		m.sleep(pollInterval)
+	}
+}
+
+// runArgs provides the arguments of docker run, volumes are created by docker and removed when the sidecars are stopped
+func (m *Manager) runArgs(name string, sidecar config.Container) []string {
+	args := []string{"run", "--detach", "--name", name, "--network", m.network}
+	if len(sidecar.Name) > 0 {
+		args = append(args, "--network-alias", sidecar.Name)
+	}
+	for _, env := range sidecar.EnvVars {
+		args = append(args, "--env", fmt.Sprintf("%v=%v", env.Name, os.ExpandEnv(env.Value)))
+	}
+	for _, port := range sidecar.Ports {
+		hostPort := port.HostPort
+		if hostPort == 0 {
+			hostPort = port.ContainerPort
+		}
+		args = append(args, "--publish", fmt.Sprintf("%v:%v", hostPort, port.ContainerPort))
+	}
+	if sidecar.SecurityContext.Privileged {
+		args = append(args, "--privileged")
+	}
+	for _, mount := range sidecar.VolumeMounts {
+		if mount.MountPath == shmPath {
+			// docker does not allow to mount a volume as shared memory, hence only its size is increased
+			args = append(args, "--shm-size", m.ShmSize)
+			continue
+		}
+		volume := fmt.Sprintf("%v-%v", mount.Name, m.id)
+		if !containsString(m.volumes, volume) {
+			m.volumes = append(m.volumes, volume)
+		}
+		args = append(args, "--mount", fmt.Sprintf("type=volume,source=%v,target=%v", volume, mount.MountPath))
+	}
+	if len(sidecar.WorkingDir) > 0 {
+		args = append(args, "--workdir", sidecar.WorkingDir)
+	}
+	for _, option := range sidecar.Options {
+		args = append(args, option.Name)
+		if len(option.Value) > 0 {
+			args = append(args, option.Value)
+		}
+	}
+	args = append(args, sidecar.Image)
+	return append(args, sidecar.Command...)
+}
+
+func containsString(slice []string, find string) bool {
+	for _, elem := range slice {
+		if elem == find {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,200 @@
+package sidecar
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SAP/jenkins-library/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+type dockerMock struct {
+	calls      []string
+	failOn     map[string]int
+	failAlways string
+}
+
+func (d *dockerMock) RunExecutable(e string, p ...string) error {
+	call := strings.Join(append([]string{e}, p...), " ")
+	d.calls = append(d.calls, call)
+	if len(d.failAlways) > 0 && strings.HasPrefix(call, d.failAlways) {
+		return fmt.Errorf("%v failed", call)
+	}
+	for prefix, count := range d.failOn {
+		if strings.HasPrefix(call, prefix) && count > 0 {
+			d.failOn[prefix] = count - 1
+			return fmt.Errorf("%v failed", call)
+		}
+	}
+	return nil
+}
+
+func newTestManager(runner execRunner) (*Manager, *[]time.Duration) {
+	sleeps := []time.Duration{}
+	m := NewManager(runner)
+	m.suffix = func() string { return "x1" }
+	m.sleep = func(d time.Duration) { sleeps = append(sleeps, d) }
+	return m, &sleeps
+}
+
+func TestStart(t *testing.T) {
+	selenium := config.Container{
+		Name:         "selenium",
+		Image:        "selenium/standalone-chrome",
+		ReadyCommand: "curl -f http://localhost:4444/wd/hub/status",
+		EnvVars:      []config.EnvVar{{Name: "NO_PROXY", Value: "localhost,selenium"}},
+		Ports:        []config.Port{{ContainerPort: 4444}, {ContainerPort: 5900, HostPort: 15900}},
+		Options:      []config.Option{{Name: "--shm-size", Value: "2g"}, {Name: "--privileged"}},
+	}
+
+	t.Run("success case", func(t *testing.T) {
+		d := dockerMock{}
+		m, sleeps := newTestManager(&d)
+
+		err := m.Start([]config.Container{selenium})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "sidecar-x1", m.Network())
+		assert.Equal(t, []string{
+			"docker network create sidecar-x1",
+			"docker pull selenium/standalone-chrome",
+			"docker run --detach --name selenium-x1 --network sidecar-x1 --network-alias selenium --env NO_PROXY=localhost,selenium --publish 4444:4444 --publish 15900:5900 --shm-size 2g --privileged selenium/standalone-chrome",
+			"docker exec selenium-x1 sh -c curl -f http://localhost:4444/wd/hub/status",
+		}, d.calls)
+		assert.Empty(t, *sleeps)
+	})
+
+	t.Run("pull skipped", func(t *testing.T) {
+		d := dockerMock{}
+		m, _ := newTestManager(&d)
+
+		err := m.Start([]config.Container{{Name: "db", Image: "postgres", ImagePullPolicy: "Never"}})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{
+			"docker network create sidecar-x1",
+			"docker run --detach --name db-x1 --network sidecar-x1 --network-alias db postgres",
+		}, d.calls)
+	})
+
+	t.Run("ready after retries", func(t *testing.T) {
+		d := dockerMock{failOn: map[string]int{"docker exec": 2}}
+		m, sleeps := newTestManager(&d)
+
+		err := m.Start([]config.Container{selenium})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []time.Duration{10 * time.Second, 10 * time.Second}, *sleeps)
+	})
+
+	t.Run("ready timeout", func(t *testing.T) {
+		d := dockerMock{failAlways: "docker exec"}
+		m, sleeps := newTestManager(&d)
+		m.ReadyTimeout = 30 * time.Second
+
+		err := m.Start([]config.Container{selenium})
+
+		assert.EqualError(t, err, "timeout while waiting for sidecar container 'selenium-x1' to be ready")
+		assert.Len(t, *sleeps, 3)
+		// cleanup happened
+		assert.Equal(t, "docker rm --force selenium-x1", d.calls[len(d.calls)-2])
+		assert.Equal(t, "docker network rm sidecar-x1", d.calls[len(d.calls)-1])
+		assert.Empty(t, m.Network())
+	})
+
+	t.Run("security context and volume mounts", func(t *testing.T) {
+		d := dockerMock{}
+		m, _ := newTestManager(&d)
+
+		err := m.Start([]config.Container{{
+			Name:            "selenium",
+			Image:           "selenium/standalone-chrome",
+			SecurityContext: config.SecurityContext{Privileged: true},
+			VolumeMounts:    []config.VolumeMount{{Name: "dev-shm", MountPath: "/dev/shm"}, {Name: "data", MountPath: "/data"}},
+		}})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "docker run --detach --name selenium-x1 --network sidecar-x1 --network-alias selenium --privileged --shm-size 2g --mount type=volume,source=data-x1,target=/data selenium/standalone-chrome", d.calls[2])
+
+		d.calls = nil
+		assert.NoError(t, m.Stop())
+		assert.Equal(t, []string{
+			"docker rm --force selenium-x1",
+			"docker volume rm --force data-x1",
+			"docker network rm sidecar-x1",
+		}, d.calls)
+	})
+
+	t.Run("no poll interval", func(t *testing.T) {
+		d := dockerMock{failOn: map[string]int{"docker exec": 1}}
+		m, sleeps := newTestManager(&d)
+		m.PollInterval = 0
+
+		err := m.Start([]config.Container{selenium})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []time.Duration{10 * time.Second}, *sleeps)
+	})
+
+	t.Run("network creation fails", func(t *testing.T) {
+		d := dockerMock{failAlways: "docker network create"}
+		m, _ := newTestManager(&d)
+
+		err := m.Start([]config.Container{selenium})
+
+		assert.EqualError(t, err, "creating docker network for sidecars failed: docker network create sidecar-x1 failed")
+		assert.Len(t, d.calls, 1)
+	})
+
+	t.Run("no image", func(t *testing.T) {
+		d := dockerMock{}
+		m, _ := newTestManager(&d)
+
+		err := m.Start([]config.Container{{Name: "noImage"}})
+
+		assert.EqualError(t, err, "no image defined for sidecar 'noImage'")
+		assert.Equal(t, "docker network rm sidecar-x1", d.calls[len(d.calls)-1])
+	})
+
+	t.Run("no sidecars", func(t *testing.T) {
+		d := dockerMock{}
+		m, _ := newTestManager(&d)
+
+		assert.NoError(t, m.Start([]config.Container{}))
+		assert.Empty(t, d.calls)
+	})
+}
+
+func TestStop(t *testing.T) {
+	t.Run("success case", func(t *testing.T) {
+		d := dockerMock{}
+		m, _ := newTestManager(&d)
+		m.Start([]config.Container{{Name: "a", Image: "a"}, {Name: "b", Image: "b"}})
+		d.calls = nil
+
+		err := m.Stop()
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{
+			"docker rm --force b-x1",
+			"docker rm --force a-x1",
+			"docker network rm sidecar-x1",
+		}, d.calls)
+	})
+
+	t.Run("error case", func(t *testing.T) {
+		d := dockerMock{}
+		m, _ := newTestManager(&d)
+		m.Start([]config.Container{{Name: "a", Image: "a"}})
+		d.calls = nil
+		d.failAlways = "docker rm"
+
+		err := m.Stop()
+
+		assert.EqualError(t, err, "removing sidecar container 'a-x1' failed: docker rm --force a-x1 failed")
+		// network removal is still attempted
+		assert.Equal(t, "docker network rm sidecar-x1", d.calls[len(d.calls)-1])
+	})
+}
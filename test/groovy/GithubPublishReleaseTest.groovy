@@ -63,4 +63,31 @@ class GithubPublishReleaseTest extends BasePiperTest {
         assertThat(withEnvArgs[0], allOf(startsWith('PIPER_parametersJSON'), containsString('"testParam":"This is test content"')))
         assertThat(shellCallRule.shell[1], is('./piper githubPublishRelease --token thisIsATestToken'))
     }
+
+    @Test
+    void testGithubPublishReleaseSigningKeyPassphraseViaEnvironment() {
+        List credentials
+        credentialsRule.withCredentials('passphraseId', 'thisIsATestPassphrase')
+        helper.registerAllowedMethod('file', [Map.class], { m -> m })
+        helper.registerAllowedMethod('withCredentials', [List, Closure], { l, c ->
+            credentials = l
+            binding.setVariable('TOKEN', 'thisIsATestToken')
+            binding.setVariable('SIGNING_KEY', 'key.asc')
+            try {
+                c()
+            } finally {
+                binding.setVariable('TOKEN', null)
+                binding.setVariable('SIGNING_KEY', null)
+            }
+        })
+        shellCallRule.setReturnValue('./piper getConfig --contextConfig --stepMetadata \'metadata/githubrelease.yaml\'', '{"githubTokenCredentialsId":"githubTokenId", "signingKeyCredentialsId":"signingKeyId", "signingKeyPassphraseCredentialsId":"passphraseId"}')
+
+        stepRule.step.githubPublishRelease(
+            juStabUtils: utils,
+            script: nullScript
+        )
+
+        assertThat(credentials, hasItem([credentialsId: 'passphraseId', variable: 'PIPER_signingKeyPassphrase']))
+        assertThat(shellCallRule.shell[1], allOf(containsString('--signingKeyPath "key.asc"'), not(containsString('signingKeyPassphrase'))))
+    }
 }
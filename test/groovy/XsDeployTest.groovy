@@ -51,7 +51,8 @@ class XsDeployTest extends BasePiperTest {
                                         .around(dockerRule)
                                         .around(writeFileRule)
                                         .around(new JenkinsCredentialsRule(this)
-                                            .withCredentials('myCreds', 'cred_xs', 'topSecret'))
+                                            .withCredentials('myCreds', 'cred_xs', 'topSecret')
+                                            .withCredentials('usCreds', 'cred_us', 'usSecret'))
                                         .around(lockRule)
                                         .around(shellRule)
                                         .around(thrown)
@@ -209,6 +210,22 @@ class XsDeployTest extends BasePiperTest {
         assertThat(lockRule.getLockResources(), contains('xsDeploy:https://example.org/xs:myOrg:mySpace'))
     }
 
+    @Test
+    public void testBlueGreenDeployAutoCompleted() {
+
+        nullScript.commonPipelineEnvironment.xsDeploymentId = '1234'
+
+        // the deployment has been resumed inside the step, hence the status does not contain an operation id
+        shellRule.setReturnValue(JenkinsShellCallRule.Type.REGEX, '.*xsDeploy .*', '{"action": "RESUME", "mode": "BG_DEPLOY"}')
+
+        stepRule.step.xsDeploy(
+            script: nullScript,
+            piperGoUtils: goUtils
+        )
+
+        assertThat(nullScript.commonPipelineEnvironment.xsDeploymentId, nullValue())
+    }
+
     @Test
     public void testBlueGreenDeployResume() {
 
@@ -345,6 +362,33 @@ class XsDeployTest extends BasePiperTest {
         assertThat(dockerRule.dockerParams.dockerImage, equalTo('xs2'))
     }
 
+    @Test
+    public void testTargetCredentials() {
+
+        List credentials
+
+        helper.registerAllowedMethod('withCredentials', [List, Closure], { l, c -> credentials = l; c() })
+
+        shellRule.setReturnValue(JenkinsShellCallRule.Type.REGEX, 'getConfig.* (?!--contextConfig)', '{"mode": "DEPLOY", "action": "NONE", "targets": [{"apiUrl": "https://eu.example.org/xs", "org": "myOrg", "space": "eu"}, {"apiUrl": "https://us.example.org/xs", "org": "myOrg", "space": "us", "credentialsId": "usCreds"}]}')
+        shellRule.setReturnValue(JenkinsShellCallRule.Type.REGEX, '.*xsDeploy .*', '{"targets": []}')
+
+        stepRule.step.xsDeploy(
+            script: nullScript,
+            piperGoUtils: goUtils
+        )
+
+        assertThat(credentials, contains(
+            [credentialsId: 'myCreds', passwordVariable: 'PASSWORD', usernameVariable: 'USERNAME'],
+            [credentialsId: 'usCreds', passwordVariable: 'PIPER_XS_TARGET_2_PASSWORD', usernameVariable: 'PIPER_XS_TARGET_2_USERNAME']))
+
+        // deployments to multiple targets do not provide an operation id
+        assertThat(nullScript.commonPipelineEnvironment.xsDeploymentId, nullValue())
+
+        assertThat(lockRule.getLockResources(), contains(
+            'xsDeploy:https://eu.example.org/xs:myOrg:eu',
+            'xsDeploy:https://us.example.org/xs:myOrg:us'))
+    }
+
     @Test
     public void testAdditionalCustomConfigLayers() {
 
@@ -34,8 +34,26 @@ void call(Map parameters = [:]) {
             config = readJSON (text: sh(returnStdout: true, script: "./piper getConfig --contextConfig --stepMetadata '${METADATA_FILE}'"))
 
             // execute step
-            withCredentials([string(credentialsId: config.githubTokenCredentialsId, variable: 'TOKEN')]) {
-                sh "./piper githubPublishRelease  --token ${TOKEN}"
+            def credentials = []
+            if (config.githubTokenCredentialsId) {
+                credentials.add(string(credentialsId: config.githubTokenCredentialsId, variable: 'TOKEN'))
+            }
+            if (config.githubAppPrivateKeyCredentialsId) {
+                // the private key of the GitHub App is picked up by the go layer from the environment
+                credentials.add(string(credentialsId: config.githubAppPrivateKeyCredentialsId, variable: 'PIPER_privateKey'))
+            }
+            String signingOptions = ''
+            if (config.signingKeyCredentialsId) {
+                credentials.add(file(credentialsId: config.signingKeyCredentialsId, variable: 'SIGNING_KEY'))
+                signingOptions = ' --signingKeyPath "$SIGNING_KEY"'
+                if (config.signingKeyPassphraseCredentialsId) {
+                    // the passphrase is picked up by the go layer from the environment, hence it does not appear on the command line
+                    credentials.add(string(credentialsId: config.signingKeyPassphraseCredentialsId, variable: 'PIPER_signingKeyPassphrase'))
+                }
+            }
+            withCredentials(credentials) {
+                String tokenOption = config.githubTokenCredentialsId ? " --token ${TOKEN}" : ''
+                sh "./piper githubPublishRelease ${tokenOption}${signingOptions}"
             }
         }
     }
@@ -118,12 +118,9 @@ void call(Map parameters = [:]) {
 
             def xsDeployStdout
 
-            lock(getLockIdentifier(projectConfig)) {
+            withLocks(getLockIdentifiers(projectConfig)) {
 
-                withCredentials([usernamePassword(
-                        credentialsId: contextConfig.credentialsId,
-                        passwordVariable: 'PASSWORD',
-                        usernameVariable: 'USERNAME')]) {
+                withCredentials(getCredentials(contextConfig, projectConfig)) {
 
                     dockerExecute([script: this].plus([dockerImage: options.dockerImage, dockerPullImage: options.dockerPullImage])) {
                         xsDeployStdout = sh returnStdout: true, script: """#!/bin/bash
@@ -134,19 +131,63 @@ void call(Map parameters = [:]) {
                 }
             }
 
-            if(mode == DeployMode.BG_DEPLOY && action == Action.NONE) {
-                script.commonPipelineEnvironment.xsDeploymentId = readJSON(text: xsDeployStdout).operationId
-                if (!script.commonPipelineEnvironment.xsDeploymentId) {
-                    error "No Operation id returned from xs deploy step. This is required for mode '${mode}' and action '${action}'."
+            // deployments to multiple targets are completed inside the step, there is no operation to be resumed
+            if(mode == DeployMode.BG_DEPLOY && action == Action.NONE && ! projectConfig.targets) {
+                Map status = readJSON(text: xsDeployStdout)
+                // with autoComplete the deployment has already been resumed or aborted inside the step
+                if(status.action && status.action != Action.NONE.name()) {
+                    script.commonPipelineEnvironment.xsDeploymentId = null
+                    echo "[INFO] Deployment completed with action '${status.action}', there is no operation to be resumed or aborted."
+                } else {
+                    script.commonPipelineEnvironment.xsDeploymentId = status.operationId
+                    if (!script.commonPipelineEnvironment.xsDeploymentId) {
+                        error "No Operation id returned from xs deploy step. This is required for mode '${mode}' and action '${action}'."
+                    }
+                    echo "[INFO] OperationId for subsequent resume or abort: '${script.commonPipelineEnvironment.xsDeploymentId}'."
                 }
-                echo "[INFO] OperationId for subsequent resume or abort: '${script.commonPipelineEnvironment.xsDeploymentId}'."
             }
         }
     }
 }
 
-String getLockIdentifier(Map config) {
-    "$STEP_NAME:${config.apiUrl}:${config.org}:${config.space}"
+/*
+ * The credentials of the targets are provided to the go layer via environment variables
+ * based on the position of the target, e.g. PIPER_XS_TARGET_1_USERNAME.
+ */
+List getCredentials(Map contextConfig, Map projectConfig) {
+    List credentials = [usernamePassword(
+        credentialsId: contextConfig.credentialsId,
+        passwordVariable: 'PASSWORD',
+        usernameVariable: 'USERNAME')]
+
+    (projectConfig.targets ?: []).eachWithIndex { target, i ->
+        if(target.credentialsId) {
+            credentials << usernamePassword(
+                credentialsId: target.credentialsId,
+                passwordVariable: "PIPER_XS_TARGET_${i + 1}_PASSWORD".toString(),
+                usernameVariable: "PIPER_XS_TARGET_${i + 1}_USERNAME".toString())
+        }
+    }
+    credentials
+}
+
+/*
+ * In case of targets each target is locked. The identifiers are sorted in order to
+ * acquire the locks always in the same order, otherwise concurrent builds could deadlock.
+ */
+List getLockIdentifiers(Map config) {
+    List targets = config.targets ?: [config]
+    targets.collect { target -> "$STEP_NAME:${target.apiUrl}:${target.org}:${target.space}".toString() }.unique().sort()
+}
+
+void withLocks(List identifiers, Closure body) {
+    if(! identifiers) {
+        body()
+        return
+    }
+    lock(identifiers.head()) {
+        withLocks(identifiers.tail(), body)
+    }
 }
 
 /*